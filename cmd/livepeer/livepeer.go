@@ -37,6 +37,7 @@ import (
 	"github.com/livepeer/go-livepeer/eth/blockwatch"
 	"github.com/livepeer/go-livepeer/eth/eventservices"
 	"github.com/livepeer/go-livepeer/eth/watchers"
+	"github.com/livepeer/go-livepeer/net"
 	"github.com/livepeer/go-livepeer/verification"
 
 	lpmon "github.com/livepeer/go-livepeer/monitor"
@@ -84,13 +85,17 @@ func main() {
 	// Network & Addresses:
 	network := flag.String("network", "offchain", "Network to connect to")
 	rtmpAddr := flag.String("rtmpAddr", "127.0.0.1:"+RtmpPort, "Address to bind for RTMP commands")
-	cliAddr := flag.String("cliAddr", "127.0.0.1:"+CliPort, "Address to bind for  CLI commands")
+	cliAddr := flag.String("cliAddr", "127.0.0.1:"+CliPort, "Address to bind for  CLI commands. Transcoders only serve status and drain mode, if set")
 	httpAddr := flag.String("httpAddr", "", "Address to bind for HTTP commands")
 	serviceAddr := flag.String("serviceAddr", "", "Orchestrator only. Overrides the on-chain serviceURI that broadcasters can use to contact this node; may be an IP or hostname.")
 	orchAddr := flag.String("orchAddr", "", "Orchestrator to connect to as a standalone transcoder")
 	verifierURL := flag.String("verifierUrl", "", "URL of the verifier to use")
 
 	verifierPath := flag.String("verifierPath", "", "Path to verifier shared volume")
+	localVerifier := flag.Bool("localVerifier", false, "Verify renditions on the Broadcaster by decoding them, without an external verifier")
+	verifierSampleRate := flag.Float64("verifierSampleRate", 0, "Fraction of segments to verify, adjusted per orchestrator based on past verifications. Verifies every segment if not set")
+	redundancy := flag.Int("redundancy", 1, "Number of orchestrators the Broadcaster submits each segment to in parallel; the first result is used")
+	crossCheck := flag.Bool("crossCheck", false, "Compare the results of redundant transcodes and remove orchestrators that disagree with the majority, or all of them if there is none")
 
 	// Transcoding:
 	orchestrator := flag.Bool("orchestrator", false, "Set to true to be an orchestrator")
@@ -98,9 +103,30 @@ func main() {
 	broadcaster := flag.Bool("broadcaster", false, "Set to true to be a broadcaster")
 	orchSecret := flag.String("orchSecret", "", "Shared secret with the orchestrator as a standalone transcoder")
 	transcodingOptions := flag.String("transcodingOptions", "P240p30fps16x9,P360p30fps16x9", "Transcoding options for broadcast job")
+	selector := flag.String("selector", "minls", "How the broadcaster selects orchestrators for segments: minls for the lowest latency score, or weighted to blend price, latency, success rate and stake")
+	selectionWeights := flag.String("selectionWeights", "price=1,latency=1,success=1,stake=1", "Weights of the criteria of the weighted selector, as comma separated name=weight pairs")
+	reputationCooldown := flag.Duration("reputationCooldown", 10*time.Minute, "How long the broadcaster doesn't use an orchestrator after repeated or verification failures")
+	reputationFailures := flag.Int64("reputationFailures", 3, "Number of consecutive failures after which the broadcaster cools down an orchestrator")
+	probeInterval := flag.Duration("probeInterval", 0, "How often the broadcaster pings orchestrators to prefer the ones with the lowest round trip time during discovery. Disabled if not set")
+	region := flag.String("region", "", "Region of the broadcaster. Orchestrators the discovery webhook tags with the same region are preferred")
+	record := flag.Bool("record", false, "Record every segment of live streams along with full-length playlists to the S3 or GS bucket. The auth webhook can override this per stream")
+	segmentFormat := flag.String("segmentFormat", "ts", "Container of the renditions of streams that don't set one through the auth webhook: ts, or fmp4 for fragmented MP4 (CMAF)")
+	fallback := flag.String("fallback", "", "What the Broadcaster inserts into rendition playlists when no orchestrator can transcode a segment: source, to use the source segment, or transcode, to transcode the lowest resolution rendition locally. Disabled if not set")
+	hlsPullTimeout := flag.Duration("hlsPullTimeout", server.HLSPullTimeout, "How long the Broadcaster keeps polling a pulled HLS playlist without new segments before ending the stream")
+	vodParallelism := flag.Int("vodParallelism", server.VODParallelism, "Number of segments of a VOD job the Broadcaster transcodes at once")
+	fallbackDeadline := flag.Duration("fallbackDeadline", server.FallbackDeadline, "How long the Broadcaster waits for orchestrators after a segment emerged before falling back")
 	maxSessions := flag.Int("maxSessions", 10, "Maximum number of concurrent transcoding sessions for Orchestrator, maximum number or RTMP streams for Broadcaster, or maximum capacity for transcoder")
+	segmentConcurrency := flag.Int("segmentConcurrency", 1, "Maximum number of segments of a single stream the Orchestrator transcodes concurrently")
+	maxSessionsPerSender := flag.Int("maxSessionsPerSender", 0, "Maximum number of concurrent transcoding sessions the Orchestrator accepts from a single broadcaster. 0 is unlimited")
+	maxPixelsPerMinutePerSender := flag.Int64("maxPixelsPerMinutePerSender", 0, "Maximum number of pixels the Orchestrator transcodes for a single broadcaster per minute. 0 is unlimited")
+	maxSegmentsPerSecondPerSender := flag.Float64("maxSegmentsPerSecondPerSender", 0, "Maximum rate of segments per second the Orchestrator accepts from a single broadcaster. 0 is unlimited")
+	resultCacheSize := flag.Int64("resultCacheSize", 0, "Maximum number of bytes of transcoded segments the Orchestrator caches to answer retried segments. 0 disables the cache")
+	resultCacheTTL := flag.Duration("resultCacheTTL", core.ResultCacheTTL, "Duration for which the Orchestrator caches the result of a transcoded segment")
 	currentManifest := flag.Bool("currentManifest", false, "Expose the currently active ManifestID as \"/stream/current.m3u8\"")
 	nvidia := flag.String("nvidia", "", "Comma-separated list of Nvidia GPU device IDs to use for transcoding")
+	transcodeCommand := flag.String("transcodeCommand", "", "External command to transcode with instead of the built-in transcoder, run once per profile. Supports the placeholders {in}, {out}, {profile}, {resolution}, {width}, {height}, {fps} and {bitrate}, and must print pixels=<n> to stdout")
+	maxResolution := flag.String("maxResolution", "", "Maximum output resolution (WxH) the transcoder supports, e.g. 1920x1080. Unlimited if not set")
+	supportedProfiles := flag.String("supportedProfiles", "", "Comma-separated list of profile names the transcoder supports. Supports any profile if not set")
 
 	// Onchain:
 	ethAcctAddr := flag.String("ethAcctAddr", "", "Existing Eth account address")
@@ -139,6 +165,8 @@ func main() {
 	// API
 	authWebhookURL := flag.String("authWebhookUrl", "", "RTMP authentication webhook URL")
 	orchWebhookURL := flag.String("orchWebhookUrl", "", "Orchestrator discovery callback URL")
+	orchWebhookSigner := flag.String("orchWebhookSigner", "", "Ethereum address that must sign the responses of the orchestrator discovery callback")
+	orchTags := flag.String("orchTags", "", "Comma separated tags an orchestrator from the discovery callback must all have to be used")
 
 	flag.Parse()
 	vFlag.Value.Set(*verbosity)
@@ -158,6 +186,21 @@ func main() {
 		return
 	}
 
+	if *segmentConcurrency <= 0 {
+		glog.Fatal("-segmentConcurrency must be greater than zero")
+		return
+	}
+
+	if *resultCacheSize < 0 || *resultCacheTTL <= 0 {
+		glog.Fatal("-resultCacheSize must not be negative and -resultCacheTTL must be greater than zero")
+		return
+	}
+
+	if *maxSessionsPerSender < 0 || *maxPixelsPerMinutePerSender < 0 || *maxSegmentsPerSecondPerSender < 0 {
+		glog.Fatal("Per-sender quotas must not be negative")
+		return
+	}
+
 	type NetworkConfig struct {
 		ethUrl        string
 		ethController string
@@ -239,11 +282,20 @@ func main() {
 	}
 
 	if *transcoder {
-		if *nvidia != "" {
+		if *transcodeCommand != "" {
+			if *nvidia != "" {
+				glog.Fatal("-transcodeCommand cannot be used together with -nvidia")
+			}
+			n.Transcoder = core.NewCommandTranscoder(*datadir, *transcodeCommand)
+		} else if *nvidia != "" {
 			n.Transcoder = core.NewLoadBalancingTranscoder(*nvidia, *datadir, core.NewNvidiaTranscoder)
 		} else {
 			n.Transcoder = core.NewLocalTranscoder(*datadir)
 		}
+		n.Capabilities, err = transcoderCapabilities(*nvidia, *maxResolution, *supportedProfiles)
+		if err != nil {
+			glog.Fatal(err)
+		}
 	}
 
 	if *orchestrator {
@@ -282,8 +334,15 @@ func main() {
 		if n.OrchSecret == "" {
 			glog.Fatal("Missing -orchSecret")
 		}
+		// Serve the status and drain mode if asked to
+		if isFlagSet("cliAddr") {
+			*cliAddr = defaultAddr(*cliAddr, "127.0.0.1", CliPort)
+			go func() {
+				glog.Fatal(server.NewLivepeerServer(*rtmpAddr, n).StartTranscoderCliWebserver(*cliAddr))
+			}()
+		}
 		if len(orchURLs) > 0 {
-			server.RunTranscoder(n, orchURLs[0].Host, *maxSessions)
+			server.RunTranscoder(n, orchURLs[0].Host, *maxSessions, n.Capabilities)
 		} else {
 			glog.Fatal("Missing -orchAddr")
 		}
@@ -449,7 +508,16 @@ func main() {
 		go serviceRegistryWatcher.Watch()
 		defer serviceRegistryWatcher.Stop()
 
-		n.Balances = core.NewAddressBalances(cleanupInterval)
+		if *orchestrator {
+			// Persist balances so that credit paid for by broadcasters survives a restart
+			n.Balances, err = core.NewAddressBalancesWithStore(cleanupInterval, dbh)
+			if err != nil {
+				glog.Errorf("Unable to load persisted balances: %v", err)
+				return
+			}
+		} else {
+			n.Balances = core.NewAddressBalances(cleanupInterval)
+		}
 		defer n.Balances.StopCleanup()
 
 		if *orchestrator {
@@ -627,6 +695,12 @@ func main() {
 	}
 
 	core.MaxSessions = *maxSessions
+	core.SegmentConcurrency = *segmentConcurrency
+	core.MaxSessionsPerSender = *maxSessionsPerSender
+	core.MaxPixelsPerMinutePerSender = *maxPixelsPerMinutePerSender
+	core.MaxSegmentsPerSecondPerSender = *maxSegmentsPerSecondPerSender
+	core.ResultCacheSize = *resultCacheSize
+	core.ResultCacheTTL = *resultCacheTTL
 	if lpmon.Enabled {
 		lpmon.MaxSessions(core.MaxSessions)
 	}
@@ -660,7 +734,17 @@ func main() {
 				glog.Fatal("Error setting orch webhook URL ", err)
 			}
 			glog.Info("Using orchestrator webhook URL ", whurl)
-			n.OrchestratorPool = discovery.NewWebhookPool(bcast, whurl)
+			if *orchTags != "" {
+				discovery.RequiredTags = strings.Split(*orchTags, ",")
+			}
+			if *orchWebhookSigner != "" {
+				if !ethcommon.IsHexAddress(*orchWebhookSigner) {
+					glog.Fatal("Invalid -orchWebhookSigner: ", *orchWebhookSigner)
+				}
+				n.OrchestratorPool = discovery.NewWebhookPoolWithSigner(bcast, whurl, ethcommon.HexToAddress(*orchWebhookSigner))
+			} else {
+				n.OrchestratorPool = discovery.NewWebhookPool(bcast, whurl)
+			}
 		} else if len(orchURLs) > 0 {
 			n.OrchestratorPool = discovery.NewOrchestratorPool(bcast, orchURLs)
 		}
@@ -669,6 +753,66 @@ func main() {
 			// Not a fatal error; may continue operating in segment-only mode
 			glog.Error("No orchestrator specified; transcoding will not happen")
 		}
+
+		discovery.Region = *region
+		if *probeInterval > 0 && n.OrchestratorPool != nil {
+			discovery.Prober = discovery.NewRTTProber(n.OrchestratorPool, *probeInterval)
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			go discovery.Prober.Start(ctx)
+		}
+
+		server.Fallback, err = server.ParseFallbackMode(*fallback)
+		if err != nil {
+			glog.Fatal(err)
+		}
+		server.DefaultSegmentFormat, err = core.ParseSegmentFormat(*segmentFormat)
+		if err != nil {
+			glog.Fatal("Invalid -segmentFormat: ", *segmentFormat)
+		}
+		server.Selector, err = server.ParseSelectorMode(*selector)
+		if err != nil {
+			glog.Fatal(err)
+		}
+		server.SelectorWeights, err = server.ParseSelectionWeights(*selectionWeights)
+		if err != nil {
+			glog.Fatal(err)
+		}
+		// Remember how orchestrators performed across streams and restarts
+		server.OrchReputation, err = server.NewReputationTracker(dbh)
+		if err != nil {
+			glog.Errorf("Unable to load orchestrator reputations: %v", err)
+			return
+		}
+		reputationCtx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go server.OrchReputation.Start(reputationCtx)
+		defer server.OrchReputation.Flush()
+		server.ReputationCooldown = *reputationCooldown
+		if *reputationFailures < 1 {
+			glog.Fatal("-reputationFailures must be at least 1")
+		}
+		server.ReputationFailureThreshold = *reputationFailures
+		server.OrchLists, err = server.NewOrchestratorLists(dbh)
+		if err != nil {
+			glog.Errorf("Unable to load orchestrator lists: %v", err)
+			return
+		}
+		// Only external storage keeps recordings beyond the live window
+		server.RecordStorage = drivers.NodeStorage
+		if *record && server.RecordStorage == nil {
+			glog.Fatal("-record requires -s3bucket or -gsbucket")
+		}
+		server.RecordStreams = *record
+		server.FallbackDeadline = *fallbackDeadline
+		server.HLSPullTimeout = *hlsPullTimeout
+		if *vodParallelism < 1 {
+			glog.Fatal("-vodParallelism must be at least 1")
+		}
+		server.VODParallelism = *vodParallelism
+		if server.Fallback == server.FallbackTranscode {
+			server.FallbackTranscoder = core.NewLocalTranscoder(*datadir)
+		}
 		if *authWebhookURL != "" {
 			_, err := validateURL(*authWebhookURL)
 			if err != nil {
@@ -679,13 +823,19 @@ func main() {
 		}
 
 		// Set up verifier
+		if *verifierURL != "" && *localVerifier {
+			glog.Fatal("Cannot use both -verifierUrl and -localVerifier")
+		}
+		if *verifierSampleRate < 0 || *verifierSampleRate > 1 {
+			glog.Fatal("-verifierSampleRate must be between 0 and 1")
+		}
 		if *verifierURL != "" {
 			_, err := validateURL(*verifierURL)
 			if err != nil {
 				glog.Fatal("Error setting verifier URL ", err)
 			}
 			glog.Info("Using the Epic Labs classifier for verification at ", *verifierURL)
-			server.Policy = &verification.Policy{Retries: 2, Verifier: &verification.EpicClassifier{Addr: *verifierURL}}
+			server.Policy = &verification.Policy{Retries: 2, Verifier: &verification.EpicClassifier{Addr: *verifierURL}, SampleRate: *verifierSampleRate}
 			// TODO Set up a default "empty" verifier-less policy for onchain
 			//      that only checks sigs and pixels?
 
@@ -695,6 +845,24 @@ func main() {
 				glog.Fatal("Requires a path to the verifier shared volume when local storage is in use; use -verifierPath, S3 or GCS")
 			}
 			verification.VerifierPath = *verifierPath
+		} else if *localVerifier {
+			if server.DefaultSegmentFormat != core.FormatMPEGTS {
+				glog.Fatal("-localVerifier only supports the mpegts -segmentFormat")
+			}
+			glog.Info("Using the local verifier")
+			server.Policy = &verification.Policy{Retries: 2, Verifier: &verification.LocalVerifier{}, SampleRate: *verifierSampleRate}
+		}
+
+		// Set up redundant transcoding
+		if *redundancy > 1 {
+			if server.Policy == nil {
+				server.Policy = &verification.Policy{}
+			}
+			server.Policy.Redundancy = *redundancy
+			server.Policy.CrossCheck = *crossCheck
+			glog.Infof("Transcoding each segment on up to %d orchestrators crossCheck=%v", *redundancy, *crossCheck)
+		} else if *crossCheck {
+			glog.Fatal("-crossCheck requires -redundancy greater than 1")
 		}
 	} else if n.NodeType == core.OrchestratorNode {
 		suri, err := getServiceURI(n, *serviceAddr)
@@ -897,6 +1065,17 @@ func setupOrchestrator(ctx context.Context, n *core.LivepeerNode, initializeRoun
 	return nil
 }
 
+// isFlagSet returns whether the flag was given on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
 func defaultAddr(addr, defaultHost, defaultPort string) string {
 	if addr == "" {
 		return defaultHost + ":" + defaultPort
@@ -911,6 +1090,32 @@ func defaultAddr(addr, defaultHost, defaultPort string) string {
 	return addr
 }
 
+// transcoderCapabilities builds the capabilities of the local transcoder
+func transcoderCapabilities(nvidia, maxResolution, supportedProfiles string) (*net.TranscoderCapabilities, error) {
+	caps := &net.TranscoderCapabilities{
+		Acceleration: net.TranscoderCapabilities_SOFTWARE,
+		Version:      core.LivepeerVersion,
+	}
+	if nvidia != "" {
+		caps.Acceleration = net.TranscoderCapabilities_NVIDIA
+	}
+	if maxResolution != "" {
+		var w, h int32
+		if _, err := fmt.Sscanf(maxResolution, "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+			return nil, fmt.Errorf("Invalid -maxResolution %v; expected WxH", maxResolution)
+		}
+		caps.MaxWidth, caps.MaxHeight = w, h
+	}
+	if supportedProfiles != "" {
+		for _, p := range strings.Split(supportedProfiles, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				caps.Profiles = append(caps.Profiles, p)
+			}
+		}
+	}
+	return caps, nil
+}
+
 func checkOrStoreChainID(dbh *common.DB, chainID *big.Int) error {
 	expectedChainID, err := dbh.ChainID()
 	if err != nil {
@@ -11,6 +11,7 @@ import (
 	"github.com/livepeer/go-livepeer/core"
 	"github.com/livepeer/go-livepeer/eth"
 	lpTypes "github.com/livepeer/go-livepeer/eth/types"
+	"github.com/livepeer/go-livepeer/net"
 	"github.com/livepeer/go-livepeer/pm"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -56,3 +57,29 @@ func TestSetupOrchestrator(t *testing.T) {
 	err = setupOrchestrator(context.Background(), n, false)
 	assert.EqualError(err, "GetTranscoder error")
 }
+
+func TestTranscoderCapabilities(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	// Defaults
+	caps, err := transcoderCapabilities("", "", "")
+	require.Nil(err)
+	assert.Equal(net.TranscoderCapabilities_SOFTWARE, caps.Acceleration)
+	assert.Equal(core.LivepeerVersion, caps.Version)
+	assert.Zero(caps.MaxWidth)
+	assert.Zero(caps.MaxHeight)
+	assert.Empty(caps.Profiles)
+
+	caps, err = transcoderCapabilities("0,1", "1920x1080", "P720p30fps16x9, P360p30fps16x9,")
+	require.Nil(err)
+	assert.Equal(net.TranscoderCapabilities_NVIDIA, caps.Acceleration)
+	assert.Equal(int32(1920), caps.MaxWidth)
+	assert.Equal(int32(1080), caps.MaxHeight)
+	assert.Equal([]string{"P720p30fps16x9", "P360p30fps16x9"}, caps.Profiles)
+
+	for _, res := range []string{"1920", "axb", "0x1080", "-1x-1"} {
+		_, err = transcoderCapabilities("", res, "")
+		assert.NotNil(err, res)
+	}
+}
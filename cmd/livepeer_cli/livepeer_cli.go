@@ -101,7 +101,12 @@ func (w *wizard) initializeOptions() []wizardOpt {
 		{desc: "Invoke \"cancel unlock of broadcasting funds\"", invoke: w.cancelUnlock, notOrchestrator: true},
 		{desc: "Invoke \"withdraw broadcasting funds\"", invoke: w.withdraw, notOrchestrator: true},
 		{desc: "Set broadcast config", invoke: w.setBroadcastConfig, notOrchestrator: true},
+		{desc: "View orchestrator allowlist and blocklist", invoke: w.showOrchestratorLists, notOrchestrator: true},
+		{desc: "Add to orchestrator allowlist or blocklist", invoke: w.addToOrchestratorList, notOrchestrator: true},
+		{desc: "Remove from orchestrator allowlist or blocklist", invoke: w.removeFromOrchestratorList, notOrchestrator: true},
 		{desc: "Set Eth gas price", invoke: w.setGasPrice},
+		{desc: "Drain node", invoke: w.drain},
+		{desc: "Resume drained node", invoke: w.resume},
 		{desc: "Get test LPT", invoke: w.requestTokens, testnet: true},
 		{desc: "Get test ETH", invoke: func() {
 			fmt.Print("For Rinkeby Eth, go to the Rinkeby faucet (https://faucet.rinkeby.io/).")
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"fmt"
+)
+
+func (w *wizard) drain() {
+	fmt.Printf("The node will finish its current work but won't accept new streams or segments. Continue? (y/n) ")
+	if w.readStringYesOrNo() != "y" {
+		return
+	}
+	fmt.Println(httpPost(fmt.Sprintf("http://%v:%v/drain", w.host, w.httpPort)))
+}
+
+func (w *wizard) resume() {
+	fmt.Println(httpPost(fmt.Sprintf("http://%v:%v/resume", w.host, w.httpPort)))
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+func (w *wizard) showOrchestratorLists() {
+	var lists map[string][]string
+	if err := json.Unmarshal([]byte(httpGet(fmt.Sprintf("http://%v:%v/orchestratorLists", w.host, w.httpPort))), &lists); err != nil {
+		fmt.Println("Could not get the orchestrator lists")
+		return
+	}
+
+	wtr := tabwriter.NewWriter(os.Stdout, 0, 8, 1, '\t', 0)
+	fmt.Fprintln(wtr, "List\tEntry")
+	for _, list := range []string{"allow", "block"} {
+		for _, entry := range lists[list] {
+			fmt.Fprintf(wtr, "%v\t%v\n", list, entry)
+		}
+	}
+	wtr.Flush()
+	if len(lists["allow"]) == 0 {
+		fmt.Println("The allowlist is empty; any orchestrator that isn't blocked is used")
+	}
+}
+
+func (w *wizard) readOrchList() string {
+	fmt.Printf("Enter the list (allow or block) - ")
+	return w.readStringAndValidate(func(in string) (string, error) {
+		if in != "allow" && in != "block" {
+			return "", errors.New("Enter allow or block")
+		}
+		return in, nil
+	})
+}
+
+func (w *wizard) updateOrchestratorList(endpoint string) {
+	list := w.readOrchList()
+	fmt.Printf("Enter the Ethereum address, service URI or host of the orchestrator - ")
+	entry := strings.TrimSpace(w.readString())
+
+	val := url.Values{
+		"list":  {list},
+		"entry": {entry},
+	}
+	fmt.Println(httpPostWithParams(fmt.Sprintf("http://%v:%v/%v", w.host, w.httpPort, endpoint), val))
+}
+
+func (w *wizard) addToOrchestratorList() {
+	w.updateOrchestratorList("addToOrchestratorList")
+}
+
+func (w *wizard) removeFromOrchestratorList() {
+	w.updateOrchestratorList("removeFromOrchestratorList")
+}
@@ -9,6 +9,7 @@ import (
 	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -37,6 +38,13 @@ type DB struct {
 	findLatestMiniHeader             *sql.Stmt
 	findAllMiniHeadersSortedByNumber *sql.Stmt
 	deleteMiniHeader                 *sql.Stmt
+	selectBalance                    *sql.Stmt
+	updateBalance                    *sql.Stmt
+	deleteBalance                    *sql.Stmt
+	updateOrchReputation             *sql.Stmt
+	insertOrchListEntry              *sql.Stmt
+	deleteOrchListEntry              *sql.Stmt
+	selectOrchListEntries            *sql.Stmt
 }
 
 // DBOrch is the type binding for a row result from the orchestrators table
@@ -57,6 +65,29 @@ type DBUnbondingLock struct {
 	WithdrawRound int64
 }
 
+// DBBalance is the type binding for a row result from the balances table
+type DBBalance struct {
+	Sender     ethcommon.Address
+	ManifestID string
+	Amount     *big.Rat
+	LastUpdate time.Time
+}
+
+// DBOrchReputation is the type binding for a row result from the orchReputation table
+type DBOrchReputation struct {
+	ServiceURI string
+	// Moving average of the latency scores of completed segments
+	LatencyScore         float64
+	Completed            int64
+	SubmitFailures       int64
+	DownloadFailures     int64
+	VerificationFailures int64
+	// Failures since the last completed segment
+	ConsecutiveFailures int64
+	LastSeen            time.Time
+	CooldownUntil       time.Time
+}
+
 // DBOrchFilter is an object used to attach a filter to a selectOrch query
 type DBOrchFilter struct {
 	MaxPrice     *big.Rat
@@ -122,6 +153,33 @@ var schema = `
 	);
 
 	CREATE INDEX IF NOT EXISTS idx_blockheaders_number ON blockheaders(number);
+
+	CREATE TABLE IF NOT EXISTS balances (
+		sender STRING,
+		manifestID STRING,
+		amount TEXT,
+		lastUpdate int64,
+		PRIMARY KEY(sender, manifestID)
+	);
+
+	CREATE TABLE IF NOT EXISTS orchReputation (
+		serviceURI STRING PRIMARY KEY,
+		latencyScore REAL,
+		completed int64,
+		submitFailures int64,
+		downloadFailures int64,
+		verificationFailures int64,
+		consecutiveFailures int64,
+		lastSeen int64,
+		cooldownUntil int64
+	);
+
+	CREATE TABLE IF NOT EXISTS orchLists (
+		list STRING,
+		entry STRING,
+		createdAt STRING DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY(list, entry)
+	);
 `
 
 func NewDBOrch(ethereumAddr string, serviceURI string, pricePerPixel int64, activationRound int64, deactivationRound int64, stake int64) *DBOrch {
@@ -313,6 +371,63 @@ func InitDB(dbPath string) (*DB, error) {
 	}
 	d.deleteMiniHeader = stmt
 
+	// Balances prepared statements
+	stmt, err = db.Prepare("SELECT amount FROM balances WHERE sender=? AND manifestID=?")
+	if err != nil {
+		glog.Error("Unable to prepare selectBalance ", err)
+		d.Close()
+		return nil, err
+	}
+	d.selectBalance = stmt
+	stmt, err = db.Prepare("INSERT OR REPLACE INTO balances(sender, manifestID, amount, lastUpdate) VALUES(?, ?, ?, ?)")
+	if err != nil {
+		glog.Error("Unable to prepare updateBalance ", err)
+		d.Close()
+		return nil, err
+	}
+	d.updateBalance = stmt
+	stmt, err = db.Prepare("DELETE FROM balances WHERE sender=? AND manifestID=?")
+	if err != nil {
+		glog.Error("Unable to prepare deleteBalance ", err)
+		d.Close()
+		return nil, err
+	}
+	d.deleteBalance = stmt
+
+	// Orchestrator reputation prepared statements
+	stmt, err = db.Prepare(`
+	INSERT OR REPLACE INTO orchReputation(serviceURI, latencyScore, completed, submitFailures, downloadFailures, verificationFailures, consecutiveFailures, lastSeen, cooldownUntil)
+	VALUES(?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		glog.Error("Unable to prepare updateOrchReputation ", err)
+		d.Close()
+		return nil, err
+	}
+	d.updateOrchReputation = stmt
+
+	// Orchestrator allowlist and blocklist prepared statements
+	stmt, err = db.Prepare("INSERT OR IGNORE INTO orchLists(list, entry) VALUES(?, ?)")
+	if err != nil {
+		glog.Error("Unable to prepare insertOrchListEntry ", err)
+		d.Close()
+		return nil, err
+	}
+	d.insertOrchListEntry = stmt
+	stmt, err = db.Prepare("DELETE FROM orchLists WHERE list=? AND entry=?")
+	if err != nil {
+		glog.Error("Unable to prepare deleteOrchListEntry ", err)
+		d.Close()
+		return nil, err
+	}
+	d.deleteOrchListEntry = stmt
+	stmt, err = db.Prepare("SELECT entry FROM orchLists WHERE list=? ORDER BY createdAt, entry")
+	if err != nil {
+		glog.Error("Unable to prepare selectOrchListEntries ", err)
+		d.Close()
+		return nil, err
+	}
+	d.selectOrchListEntries = stmt
+
 	glog.V(DEBUG).Info("Initialized DB node")
 	return &d, nil
 }
@@ -358,6 +473,27 @@ func (db *DB) Close() {
 	if db.deleteMiniHeader != nil {
 		db.deleteMiniHeader.Close()
 	}
+	if db.selectBalance != nil {
+		db.selectBalance.Close()
+	}
+	if db.updateBalance != nil {
+		db.updateBalance.Close()
+	}
+	if db.deleteBalance != nil {
+		db.deleteBalance.Close()
+	}
+	if db.updateOrchReputation != nil {
+		db.updateOrchReputation.Close()
+	}
+	if db.insertOrchListEntry != nil {
+		db.insertOrchListEntry.Close()
+	}
+	if db.deleteOrchListEntry != nil {
+		db.deleteOrchListEntry.Close()
+	}
+	if db.selectOrchListEntries != nil {
+		db.selectOrchListEntries.Close()
+	}
 	if db.dbh != nil {
 		db.dbh.Close()
 	}
@@ -600,6 +736,202 @@ func (db *DB) UnbondingLocks(currentRound *big.Int) ([]*DBUnbondingLock, error)
 	return unbondingLocks, nil
 }
 
+// UpdateBalance adds delta to the balance for a sender's manifestID and returns the resulting balance.
+// A negative delta debits the balance. The read and the write are performed in a single transaction
+func (db *DB) UpdateBalance(sender ethcommon.Address, manifestID string, delta *big.Rat) (*big.Rat, error) {
+	var newAmount *big.Rat
+	err := db.modifyBalance(sender, manifestID, func(amount *big.Rat) *big.Rat {
+		newAmount = amount.Add(amount, delta)
+		return newAmount
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newAmount, nil
+}
+
+// ReserveBalance zeros the balance for a sender's manifestID and returns the balance prior to the reservation.
+// The read and the write are performed in a single transaction
+func (db *DB) ReserveBalance(sender ethcommon.Address, manifestID string) (*big.Rat, error) {
+	var reserved *big.Rat
+	err := db.modifyBalance(sender, manifestID, func(amount *big.Rat) *big.Rat {
+		reserved = amount
+		return big.NewRat(0, 1)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reserved, nil
+}
+
+// DeleteBalance removes the balance for a sender's manifestID.
+// This method will return nil for non-existent balances
+func (db *DB) DeleteBalance(sender ethcommon.Address, manifestID string) error {
+	glog.V(DEBUG).Infof("db: Deleting balance sender=%v manifestID=%v", sender.Hex(), manifestID)
+	_, err := db.deleteBalance.Exec(sender.Hex(), manifestID)
+	if err != nil {
+		glog.Errorf("db: Error deleting balance sender=%v manifestID=%v: %v", sender.Hex(), manifestID, err)
+		return err
+	}
+	return nil
+}
+
+// LoadBalances returns all balances stored in the DB
+func (db *DB) LoadBalances() ([]*DBBalance, error) {
+	rows, err := db.dbh.Query("SELECT sender, manifestID, amount, lastUpdate FROM balances")
+	if err != nil {
+		glog.Error("db: Unable to select balances ", err)
+		return nil, err
+	}
+	defer rows.Close()
+	balances := []*DBBalance{}
+	for rows.Next() {
+		var (
+			sender     string
+			manifestID string
+			amount     string
+			lastUpdate int64
+		)
+		if err := rows.Scan(&sender, &manifestID, &amount, &lastUpdate); err != nil {
+			glog.Error("db: Unable to fetch balance ", err)
+			continue
+		}
+		ratAmount, ok := new(big.Rat).SetString(amount)
+		if !ok {
+			glog.Errorf("db: Unable to convert amount string %v to big rat", amount)
+			continue
+		}
+		balances = append(balances, &DBBalance{
+			Sender:     ethcommon.HexToAddress(sender),
+			ManifestID: manifestID,
+			Amount:     ratAmount,
+			LastUpdate: time.Unix(0, lastUpdate),
+		})
+	}
+	return balances, nil
+}
+
+// modifyBalance reads the balance for a sender's manifestID, applies fn to it and stores the result within a transaction
+func (db *DB) modifyBalance(sender ethcommon.Address, manifestID string, fn func(amount *big.Rat) *big.Rat) error {
+	tx, err := db.dbh.Begin()
+	if err != nil {
+		glog.Error("db: Unable to begin balance transaction ", err)
+		return err
+	}
+
+	amount := big.NewRat(0, 1)
+	var amountStr string
+	err = tx.Stmt(db.selectBalance).QueryRow(sender.Hex(), manifestID).Scan(&amountStr)
+	if err != nil && err != sql.ErrNoRows {
+		tx.Rollback()
+		glog.Errorf("db: Unable to select balance sender=%v manifestID=%v: %v", sender.Hex(), manifestID, err)
+		return err
+	}
+	if err == nil {
+		if _, ok := amount.SetString(amountStr); !ok {
+			tx.Rollback()
+			return fmt.Errorf("unable to convert amount string %v to big rat", amountStr)
+		}
+	}
+
+	newAmount := fn(amount)
+	if _, err := tx.Stmt(db.updateBalance).Exec(sender.Hex(), manifestID, newAmount.String(), time.Now().UnixNano()); err != nil {
+		tx.Rollback()
+		glog.Errorf("db: Unable to update balance sender=%v manifestID=%v: %v", sender.Hex(), manifestID, err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UpdateOrchReputation stores the reputation of an orchestrator, replacing any stored one
+func (db *DB) UpdateOrchReputation(rep *DBOrchReputation) error {
+	_, err := db.updateOrchReputation.Exec(
+		rep.ServiceURI,
+		rep.LatencyScore,
+		rep.Completed,
+		rep.SubmitFailures,
+		rep.DownloadFailures,
+		rep.VerificationFailures,
+		rep.ConsecutiveFailures,
+		rep.LastSeen.UnixNano(),
+		rep.CooldownUntil.UnixNano(),
+	)
+	if err != nil {
+		glog.Errorf("db: Unable to update orchestrator reputation serviceURI=%v: %v", rep.ServiceURI, err)
+		return err
+	}
+	return nil
+}
+
+// LoadOrchReputations returns the reputations of all orchestrators stored in the DB
+func (db *DB) LoadOrchReputations() ([]*DBOrchReputation, error) {
+	rows, err := db.dbh.Query("SELECT serviceURI, latencyScore, completed, submitFailures, downloadFailures, verificationFailures, consecutiveFailures, lastSeen, cooldownUntil FROM orchReputation")
+	if err != nil {
+		glog.Error("db: Unable to select orchestrator reputations ", err)
+		return nil, err
+	}
+	defer rows.Close()
+	reps := []*DBOrchReputation{}
+	for rows.Next() {
+		var (
+			rep           DBOrchReputation
+			lastSeen      int64
+			cooldownUntil int64
+		)
+		if err := rows.Scan(&rep.ServiceURI, &rep.LatencyScore, &rep.Completed, &rep.SubmitFailures, &rep.DownloadFailures,
+			&rep.VerificationFailures, &rep.ConsecutiveFailures, &lastSeen, &cooldownUntil); err != nil {
+			glog.Error("db: Unable to fetch orchestrator reputation ", err)
+			continue
+		}
+		rep.LastSeen = time.Unix(0, lastSeen)
+		rep.CooldownUntil = time.Unix(0, cooldownUntil)
+		reps = append(reps, &rep)
+	}
+	return reps, nil
+}
+
+// InsertOrchListEntry adds an entry to an orchestrator list. Adding an existing entry is not an error
+func (db *DB) InsertOrchListEntry(list, entry string) error {
+	glog.V(DEBUG).Infof("db: Inserting orchestrator list entry list=%v entry=%v", list, entry)
+	if _, err := db.insertOrchListEntry.Exec(list, entry); err != nil {
+		glog.Errorf("db: Unable to insert orchestrator list entry list=%v entry=%v: %v", list, entry, err)
+		return err
+	}
+	return nil
+}
+
+// DeleteOrchListEntry removes an entry from an orchestrator list.
+// This method will return nil for non-existent entries
+func (db *DB) DeleteOrchListEntry(list, entry string) error {
+	glog.V(DEBUG).Infof("db: Deleting orchestrator list entry list=%v entry=%v", list, entry)
+	if _, err := db.deleteOrchListEntry.Exec(list, entry); err != nil {
+		glog.Errorf("db: Unable to delete orchestrator list entry list=%v entry=%v: %v", list, entry, err)
+		return err
+	}
+	return nil
+}
+
+// OrchListEntries returns the entries of an orchestrator list in the order they were added
+func (db *DB) OrchListEntries(list string) ([]string, error) {
+	rows, err := db.selectOrchListEntries.Query(list)
+	if err != nil {
+		glog.Errorf("db: Unable to select orchestrator list entries list=%v: %v", list, err)
+		return nil, err
+	}
+	defer rows.Close()
+	entries := []string{}
+	for rows.Next() {
+		var entry string
+		if err := rows.Scan(&entry); err != nil {
+			glog.Error("db: Unable to fetch orchestrator list entry ", err)
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
 func (db *DB) StoreWinningTicket(sessionID string, ticket *pm.Ticket, sig []byte, recipientRand *big.Int) error {
 	if ticket == nil {
 		return errors.New("cannot store nil ticket")
@@ -7,6 +7,7 @@ import (
 	"math/big"
 	"strconv"
 	"testing"
+	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	ethcommon "github.com/ethereum/go-ethereum/common"
@@ -1145,3 +1146,154 @@ func defaultMiniHeader() *blockwatch.MiniHeader {
 	block.Logs = []types.Log{log}
 	return block
 }
+
+func TestDBBalances(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dbh, dbraw, err := TempDB(t)
+	require.Nil(err)
+	defer dbh.Close()
+	defer dbraw.Close()
+
+	sender := ethcommon.BytesToAddress([]byte("foo"))
+	mid := "some manifestID"
+
+	// Empty table
+	balances, err := dbh.LoadBalances()
+	require.Nil(err)
+	assert.Len(balances, 0)
+
+	// Credit a non-existent balance
+	amount, err := dbh.UpdateBalance(sender, mid, big.NewRat(10, 3))
+	require.Nil(err)
+	assert.Zero(amount.Cmp(big.NewRat(10, 3)))
+
+	// Debit is a negative delta
+	amount, err = dbh.UpdateBalance(sender, mid, big.NewRat(-1, 3))
+	require.Nil(err)
+	assert.Zero(amount.Cmp(big.NewRat(3, 1)))
+
+	// Reserve returns the previous balance and zeros it
+	amount, err = dbh.ReserveBalance(sender, mid)
+	require.Nil(err)
+	assert.Zero(amount.Cmp(big.NewRat(3, 1)))
+	amount, err = dbh.ReserveBalance(sender, mid)
+	require.Nil(err)
+	assert.Zero(amount.Cmp(big.NewRat(0, 1)))
+
+	// Reserving a non-existent balance returns zero
+	amount, err = dbh.ReserveBalance(sender, "other manifestID")
+	require.Nil(err)
+	assert.Zero(amount.Cmp(big.NewRat(0, 1)))
+
+	_, err = dbh.UpdateBalance(sender, mid, big.NewRat(7, 1))
+	require.Nil(err)
+	balances, err = dbh.LoadBalances()
+	require.Nil(err)
+	require.Len(balances, 2)
+	for _, b := range balances {
+		assert.Equal(sender, b.Sender)
+		assert.WithinDuration(time.Now(), b.LastUpdate, time.Minute)
+		if b.ManifestID == mid {
+			assert.Zero(b.Amount.Cmp(big.NewRat(7, 1)))
+		} else {
+			assert.Zero(b.Amount.Cmp(big.NewRat(0, 1)))
+		}
+	}
+
+	// Delete balances
+	require.Nil(dbh.DeleteBalance(sender, mid))
+	require.Nil(dbh.DeleteBalance(sender, "other manifestID"))
+	assert.Equal(0, getRowCountOrFatal("SELECT count(*) FROM balances", dbraw, t))
+
+	// Deleting a non-existent balance is not an error
+	assert.Nil(dbh.DeleteBalance(sender, mid))
+}
+
+func TestDBOrchReputation(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dbh, dbraw, err := TempDB(t)
+	require.Nil(err)
+	defer dbh.Close()
+	defer dbraw.Close()
+
+	// Empty table
+	reps, err := dbh.LoadOrchReputations()
+	require.Nil(err)
+	assert.Len(reps, 0)
+
+	rep := &DBOrchReputation{
+		ServiceURI:           "https://127.0.0.1:8935",
+		LatencyScore:         0.75,
+		Completed:            10,
+		SubmitFailures:       1,
+		DownloadFailures:     2,
+		VerificationFailures: 3,
+		ConsecutiveFailures:  4,
+		LastSeen:             time.Unix(0, 1000),
+		CooldownUntil:        time.Unix(0, 2000),
+	}
+	require.Nil(dbh.UpdateOrchReputation(rep))
+	reps, err = dbh.LoadOrchReputations()
+	require.Nil(err)
+	require.Len(reps, 1)
+	assert.Equal(rep, reps[0])
+
+	// Updates replace the stored reputation
+	rep.Completed = 11
+	rep.CooldownUntil = time.Unix(0, 0)
+	require.Nil(dbh.UpdateOrchReputation(rep))
+	require.Nil(dbh.UpdateOrchReputation(&DBOrchReputation{ServiceURI: "https://127.0.0.1:8936"}))
+	assert.Equal(2, getRowCountOrFatal("SELECT count(*) FROM orchReputation", dbraw, t))
+	reps, err = dbh.LoadOrchReputations()
+	require.Nil(err)
+	require.Len(reps, 2)
+	for _, r := range reps {
+		if r.ServiceURI == rep.ServiceURI {
+			assert.Equal(rep, r)
+		}
+	}
+}
+
+func TestDBOrchLists(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dbh, dbraw, err := TempDB(t)
+	require.Nil(err)
+	defer dbh.Close()
+	defer dbraw.Close()
+
+	entries, err := dbh.OrchListEntries("block")
+	require.Nil(err)
+	assert.Len(entries, 0)
+
+	require.Nil(dbh.InsertOrchListEntry("block", "b"))
+	require.Nil(dbh.InsertOrchListEntry("block", "a"))
+	require.Nil(dbh.InsertOrchListEntry("allow", "a"))
+	// Adding an existing entry is not an error
+	require.Nil(dbh.InsertOrchListEntry("block", "a"))
+	assert.Equal(3, getRowCountOrFatal("SELECT count(*) FROM orchLists", dbraw, t))
+
+	entries, err = dbh.OrchListEntries("block")
+	require.Nil(err)
+	assert.ElementsMatch([]string{"a", "b"}, entries)
+	entries, err = dbh.OrchListEntries("allow")
+	require.Nil(err)
+	assert.Equal([]string{"a"}, entries)
+
+	// Lists are independent
+	require.Nil(dbh.DeleteOrchListEntry("block", "a"))
+	entries, err = dbh.OrchListEntries("block")
+	require.Nil(err)
+	assert.Equal([]string{"b"}, entries)
+	entries, err = dbh.OrchListEntries("allow")
+	require.Nil(err)
+	assert.Equal([]string{"a"}, entries)
+
+	// Deleting a non-existent entry is not an error
+	assert.Nil(dbh.DeleteOrchListEntry("block", "a"))
+}
@@ -15,7 +15,9 @@ type Broadcaster interface {
 
 type OrchestratorPool interface {
 	GetURLs() []*url.URL
-	GetOrchestrators(int) ([]*net.OrchestratorInfo, error)
+	// GetOrchestrators returns up to the given number of orchestrators for which pred returns true.
+	// A nil pred accepts any orchestrator
+	GetOrchestrators(int, func(*net.OrchestratorInfo) bool) ([]*net.OrchestratorInfo, error)
 	Size() int
 }
 
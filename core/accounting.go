@@ -6,8 +6,18 @@ import (
 	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/common"
 )
 
+// BalanceStore persists credit balances so that they survive a node restart
+type BalanceStore interface {
+	UpdateBalance(sender ethcommon.Address, manifestID string, delta *big.Rat) (*big.Rat, error)
+	ReserveBalance(sender ethcommon.Address, manifestID string) (*big.Rat, error)
+	DeleteBalance(sender ethcommon.Address, manifestID string) error
+	LoadBalances() ([]*common.DBBalance, error)
+}
+
 // Balance holds the credit balance for a broadcast session
 type Balance struct {
 	addr       ethcommon.Address
@@ -59,6 +69,7 @@ type AddressBalances struct {
 	balances map[ethcommon.Address]*Balances
 	mtx      sync.Mutex
 	ttl      time.Duration
+	store    BalanceStore
 }
 
 // NewAddressBalances creates a new AddressBalances instance
@@ -69,6 +80,30 @@ func NewAddressBalances(ttl time.Duration) *AddressBalances {
 	}
 }
 
+// NewAddressBalancesWithStore creates a new AddressBalances instance that writes every balance change
+// through to store and is initialized with the balances that were persisted by a previous run
+func NewAddressBalancesWithStore(ttl time.Duration, store BalanceStore) (*AddressBalances, error) {
+	a := NewAddressBalances(ttl)
+	a.store = store
+
+	stored, err := store.LoadBalances()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, sb := range stored {
+		if time.Since(sb.LastUpdate) > ttl {
+			store.DeleteBalance(sb.Sender, sb.ManifestID)
+			continue
+		}
+		a.balancesForAddr(sb.Sender).load(ManifestID(sb.ManifestID), sb.Amount, sb.LastUpdate)
+	}
+
+	glog.Infof("Loaded %v persisted balances", len(stored))
+
+	return a, nil
+}
+
 // Credit adds an an amount to the balance for an address' ManifestID
 func (a *AddressBalances) Credit(addr ethcommon.Address, id ManifestID, amount *big.Rat) {
 	a.balancesForAddr(addr).Credit(id, amount)
@@ -104,7 +139,7 @@ func (a *AddressBalances) balancesForAddr(addr ethcommon.Address) *Balances {
 	defer a.mtx.Unlock()
 
 	if _, ok := a.balances[addr]; !ok {
-		b := NewBalances(a.ttl)
+		b := newBalances(addr, a.ttl, a.store)
 		go b.StartCleanup()
 
 		a.balances[addr] = b
@@ -119,6 +154,10 @@ type Balances struct {
 	mtx      sync.RWMutex
 	ttl      time.Duration
 	quit     chan struct{}
+
+	// addr and store are only used to persist balances when store is set
+	addr  ethcommon.Address
+	store BalanceStore
 }
 
 type balance struct {
@@ -128,10 +167,16 @@ type balance struct {
 
 // NewBalances creates a Balances instance with the given ttl
 func NewBalances(ttl time.Duration) *Balances {
+	return newBalances(ethcommon.Address{}, ttl, nil)
+}
+
+func newBalances(addr ethcommon.Address, ttl time.Duration, store BalanceStore) *Balances {
 	return &Balances{
 		balances: make(map[ManifestID]*balance),
 		ttl:      ttl,
 		quit:     make(chan struct{}),
+		addr:     addr,
+		store:    store,
 	}
 }
 
@@ -144,6 +189,7 @@ func (b *Balances) Credit(id ManifestID, amount *big.Rat) {
 	}
 	b.balances[id].amount.Add(b.balances[id].amount, amount)
 	b.balances[id].lastUpdate = time.Now()
+	b.persist(id, amount)
 }
 
 // Debit substracts an amount from the balance for a ManifestID
@@ -155,6 +201,7 @@ func (b *Balances) Debit(id ManifestID, amount *big.Rat) {
 	}
 	b.balances[id].amount.Sub(b.balances[id].amount, amount)
 	b.balances[id].lastUpdate = time.Now()
+	b.persist(id, new(big.Rat).Neg(amount))
 }
 
 // Reserve zeros the balance for a ManifestID and returns the current balance
@@ -168,9 +215,32 @@ func (b *Balances) Reserve(id ManifestID) *big.Rat {
 	amount := b.balances[id].amount
 	b.balances[id].amount = big.NewRat(0, 1)
 
+	if b.store != nil {
+		if _, err := b.store.ReserveBalance(b.addr, string(id)); err != nil {
+			glog.Errorf("Unable to persist balance reservation sender=%v manifestID=%v err=%v", b.addr.Hex(), id, err)
+		}
+	}
+
 	return amount
 }
 
+// persist writes a balance change through to the store, if any.
+// The in-memory balance remains the source of truth while the node is running, so a failed write is only logged
+func (b *Balances) persist(id ManifestID, delta *big.Rat) {
+	if b.store == nil {
+		return
+	}
+	if _, err := b.store.UpdateBalance(b.addr, string(id), delta); err != nil {
+		glog.Errorf("Unable to persist balance sender=%v manifestID=%v err=%v", b.addr.Hex(), id, err)
+	}
+}
+
+func (b *Balances) load(id ManifestID, amount *big.Rat, lastUpdate time.Time) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.balances[id] = &balance{amount: amount, lastUpdate: lastUpdate}
+}
+
 // Balance retrieves the current balance for a ManifestID
 func (b *Balances) Balance(id ManifestID) *big.Rat {
 	b.mtx.RLock()
@@ -186,6 +256,9 @@ func (b *Balances) cleanup() {
 		b.mtx.Lock()
 		if int64(time.Since(balance.lastUpdate)) > int64(b.ttl) {
 			delete(b.balances, id)
+			if b.store != nil {
+				b.store.DeleteBalance(b.addr, string(id))
+			}
 		}
 		b.mtx.Unlock()
 	}
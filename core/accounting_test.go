@@ -6,7 +6,9 @@ import (
 	"time"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/livepeer/go-livepeer/common"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBalance_Credit(t *testing.T) {
@@ -248,3 +250,83 @@ func TestBalancesCleanup(t *testing.T) {
 	// Now balance for mid1 should be cleaned as well
 	assert.Nil(b.Balance(mid1))
 }
+
+func TestAddressBalancesWithStore_PersistsAndReloads(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dbh, dbraw, err := common.TempDB(t)
+	require.Nil(err)
+	defer dbh.Close()
+	defer dbraw.Close()
+
+	addr := ethcommon.BytesToAddress([]byte("foo"))
+	mid1 := ManifestID("First MID")
+	mid2 := ManifestID("Second MID")
+
+	balances, err := NewAddressBalancesWithStore(5*time.Second, dbh)
+	require.Nil(err)
+
+	balances.Credit(addr, mid1, big.NewRat(100, 1))
+	balances.Debit(addr, mid1, big.NewRat(30, 1))
+	balances.Credit(addr, mid2, big.NewRat(50, 1))
+	assert.Zero(big.NewRat(50, 1).Cmp(balances.Reserve(addr, mid2)))
+	balances.StopCleanup()
+
+	// Simulate a restart by creating a new instance backed by the same store
+	reloaded, err := NewAddressBalancesWithStore(5*time.Second, dbh)
+	require.Nil(err)
+	defer reloaded.StopCleanup()
+
+	assert.Zero(big.NewRat(70, 1).Cmp(reloaded.Balance(addr, mid1)))
+	assert.Zero(big.NewRat(0, 1).Cmp(reloaded.Balance(addr, mid2)))
+	assert.Nil(reloaded.Balance(ethcommon.BytesToAddress([]byte("bar")), mid1))
+
+	// Changes after the reload are applied on top of the persisted balance
+	reloaded.Credit(addr, mid1, big.NewRat(5, 1))
+	stored, err := dbh.LoadBalances()
+	require.Nil(err)
+	for _, b := range stored {
+		if b.ManifestID == string(mid1) {
+			assert.Zero(big.NewRat(75, 1).Cmp(b.Amount))
+		}
+	}
+}
+
+func TestAddressBalancesWithStore_SkipsExpiredBalances(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dbh, dbraw, err := common.TempDB(t)
+	require.Nil(err)
+	defer dbh.Close()
+	defer dbraw.Close()
+
+	addr := ethcommon.BytesToAddress([]byte("foo"))
+	mid := ManifestID("some manifestID")
+
+	_, err = dbh.UpdateBalance(addr, string(mid), big.NewRat(10, 1))
+	require.Nil(err)
+	_, err = dbraw.Exec("UPDATE balances SET lastUpdate = ?", time.Now().Add(-time.Hour).UnixNano())
+	require.Nil(err)
+
+	balances, err := NewAddressBalancesWithStore(5*time.Second, dbh)
+	require.Nil(err)
+	defer balances.StopCleanup()
+
+	assert.Nil(balances.Balance(addr, mid))
+	stored, err := dbh.LoadBalances()
+	require.Nil(err)
+	assert.Len(stored, 0)
+}
+
+func TestAddressBalancesWithStore_LoadError(t *testing.T) {
+	dbh, dbraw, err := common.TempDB(t)
+	require.Nil(t, err)
+	defer dbraw.Close()
+	dbh.Close()
+
+	balances, err := NewAddressBalancesWithStore(5*time.Second, dbh)
+	assert.Nil(t, balances)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,141 @@
+package core
+
+import (
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/lpms/ffmpeg"
+)
+
+// Output codecs produced by the transcoders supported by this node
+var supportedCodecs = []string{"H264"}
+
+// Segment formats produced by this node
+var supportedFormats = []net.SegmentFormat{net.SegmentFormat_MPEGTS, net.SegmentFormat_FMP4}
+
+// canTranscode returns whether a transcoder with the given capabilities is able to transcode all of the profiles.
+// Transcoders that registered without capabilities are assumed to support any profile
+func canTranscode(caps *net.TranscoderCapabilities, profiles []ffmpeg.VideoProfile) bool {
+	if caps == nil {
+		return true
+	}
+	return supportsProfiles(caps.Profiles, caps.MaxWidth, caps.MaxHeight, profiles)
+}
+
+// OrchestratorCanTranscode returns whether an orchestrator advertising the given capabilities is able to
+// transcode all of the profiles. Orchestrators that do not advertise capabilities are assumed to support any profile
+func OrchestratorCanTranscode(caps *net.OrchestratorCapabilities, profiles []ffmpeg.VideoProfile) bool {
+	if caps == nil {
+		return true
+	}
+	return supportsProfiles(caps.Profiles, caps.MaxWidth, caps.MaxHeight, profiles)
+}
+
+// OrchestratorSupportsFormat returns whether an orchestrator advertising the given capabilities produces
+// segments in the format. Orchestrators that do not advertise formats only produce MPEG-TS
+func OrchestratorSupportsFormat(caps *net.OrchestratorCapabilities, format SegmentFormat) bool {
+	if format == FormatMPEGTS {
+		return true
+	}
+	for _, f := range caps.GetFormats() {
+		if f == net.SegmentFormat(format) {
+			return true
+		}
+	}
+	return false
+}
+
+func supportsProfiles(names []string, maxW, maxH int32, profiles []ffmpeg.VideoProfile) bool {
+	var supported map[string]bool
+	if len(names) > 0 {
+		supported = make(map[string]bool, len(names))
+		for _, name := range names {
+			supported[name] = true
+		}
+	}
+
+	for _, p := range profiles {
+		if supported != nil && !supported[p.Name] {
+			return false
+		}
+		if maxW > 0 || maxH > 0 {
+			w, h, err := ffmpeg.VideoProfileResolution(p)
+			if err != nil {
+				return false
+			}
+			// Allow for portrait orientations of the maximum resolution
+			if !fitsResolution(w, h, maxW, maxH) && !fitsResolution(h, w, maxW, maxH) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func fitsResolution(w, h int, maxW, maxH int32) bool {
+	return (maxW <= 0 || w <= int(maxW)) && (maxH <= 0 || h <= int(maxH))
+}
+
+// mergeCapabilities combines the capabilities of several transcoders into the capabilities of the pool:
+// a profile or resolution is supported if any of the transcoders supports it.
+// Returns nil if no transcoder advertised capabilities
+func mergeCapabilities(all []*net.TranscoderCapabilities) *net.TranscoderCapabilities {
+	var res *net.TranscoderCapabilities
+	anyProfile := false
+	seen := make(map[string]bool)
+	for _, caps := range all {
+		if caps == nil {
+			// Transcoders without capabilities support anything
+			return nil
+		}
+		if res == nil {
+			res = &net.TranscoderCapabilities{MaxWidth: caps.MaxWidth, MaxHeight: caps.MaxHeight}
+		}
+		if caps.Acceleration == net.TranscoderCapabilities_NVIDIA {
+			res.Acceleration = net.TranscoderCapabilities_NVIDIA
+		}
+		res.MaxWidth = maxLimit(res.MaxWidth, caps.MaxWidth)
+		res.MaxHeight = maxLimit(res.MaxHeight, caps.MaxHeight)
+		if len(caps.Profiles) == 0 {
+			anyProfile = true
+		}
+		for _, p := range caps.Profiles {
+			if !seen[p] {
+				seen[p] = true
+				res.Profiles = append(res.Profiles, p)
+			}
+		}
+	}
+	if anyProfile && res != nil {
+		res.Profiles = nil
+	}
+	return res
+}
+
+// maxLimit returns the larger of two limits, where zero means unlimited
+func maxLimit(a, b int32) int32 {
+	if a <= 0 || b <= 0 {
+		return 0
+	}
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func orchestratorCapabilities(freeSessions int, caps *net.TranscoderCapabilities) *net.OrchestratorCapabilities {
+	if freeSessions < 0 {
+		freeSessions = 0
+	}
+	res := &net.OrchestratorCapabilities{
+		FreeSessions: int32(freeSessions),
+		Codecs:       supportedCodecs,
+		Formats:      supportedFormats,
+		Version:      LivepeerVersion,
+	}
+	if caps != nil {
+		res.Profiles = caps.Profiles
+		res.MaxWidth = caps.MaxWidth
+		res.MaxHeight = caps.MaxHeight
+		res.Gpu = caps.Acceleration == net.TranscoderCapabilities_NVIDIA
+	}
+	return res
+}
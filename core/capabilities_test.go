@@ -0,0 +1,143 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/lpms/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+var p1080p30fps16x9 = ffmpeg.VideoProfile{Name: "P1080p30fps16x9", Bitrate: "6000k", Framerate: 30, AspectRatio: "16:9", Resolution: "1920x1080"}
+
+func TestCanTranscode(t *testing.T) {
+	assert := assert.New(t)
+	profiles := []ffmpeg.VideoProfile{ffmpeg.P720p30fps16x9, ffmpeg.P360p30fps16x9}
+
+	// Transcoders without capabilities can transcode anything
+	assert.True(canTranscode(nil, profiles))
+	assert.True(canTranscode(&net.TranscoderCapabilities{}, profiles))
+
+	// Max resolution
+	caps := &net.TranscoderCapabilities{MaxWidth: 1280, MaxHeight: 720}
+	assert.True(canTranscode(caps, profiles))
+	assert.False(canTranscode(caps, []ffmpeg.VideoProfile{p1080p30fps16x9}))
+	caps = &net.TranscoderCapabilities{MaxWidth: 640, MaxHeight: 360}
+	assert.False(canTranscode(caps, profiles))
+	assert.True(canTranscode(caps, profiles[1:]))
+
+	// Only one of the dimensions limited
+	caps = &net.TranscoderCapabilities{MaxHeight: 720}
+	assert.True(canTranscode(caps, profiles))
+	assert.False(canTranscode(caps, []ffmpeg.VideoProfile{p1080p30fps16x9}))
+
+	// Portrait orientation of the max resolution is supported
+	portrait := ffmpeg.VideoProfile{Name: "portrait", Resolution: "720x1280", Framerate: 30}
+	caps = &net.TranscoderCapabilities{MaxWidth: 1280, MaxHeight: 720}
+	assert.True(canTranscode(caps, []ffmpeg.VideoProfile{portrait}))
+
+	// Profiles with invalid resolutions can't be checked against a max resolution
+	invalid := ffmpeg.VideoProfile{Name: "invalid", Resolution: "foo"}
+	assert.False(canTranscode(caps, []ffmpeg.VideoProfile{invalid}))
+	assert.True(canTranscode(&net.TranscoderCapabilities{}, []ffmpeg.VideoProfile{invalid}))
+
+	// Supported profiles
+	caps = &net.TranscoderCapabilities{Profiles: []string{ffmpeg.P720p30fps16x9.Name}}
+	assert.False(canTranscode(caps, profiles))
+	assert.True(canTranscode(caps, profiles[:1]))
+	assert.True(canTranscode(caps, nil))
+}
+
+func TestOrchestratorCanTranscode(t *testing.T) {
+	assert := assert.New(t)
+	profiles := []ffmpeg.VideoProfile{ffmpeg.P720p30fps16x9, ffmpeg.P360p30fps16x9}
+
+	// Orchestrators without capabilities can transcode anything
+	assert.True(OrchestratorCanTranscode(nil, profiles))
+	assert.True(OrchestratorCanTranscode(&net.OrchestratorCapabilities{FreeSessions: 1}, profiles))
+
+	caps := &net.OrchestratorCapabilities{MaxWidth: 640, MaxHeight: 360}
+	assert.False(OrchestratorCanTranscode(caps, profiles))
+	assert.True(OrchestratorCanTranscode(caps, profiles[1:]))
+
+	caps = &net.OrchestratorCapabilities{Profiles: []string{ffmpeg.P360p30fps16x9.Name}}
+	assert.False(OrchestratorCanTranscode(caps, profiles))
+	assert.True(OrchestratorCanTranscode(caps, profiles[1:]))
+}
+
+func TestOrchestratorSupportsFormat(t *testing.T) {
+	assert := assert.New(t)
+
+	// Orchestrators that don't advertise formats only produce MPEG-TS
+	assert.True(OrchestratorSupportsFormat(nil, FormatMPEGTS))
+	assert.False(OrchestratorSupportsFormat(nil, FormatFMP4))
+	assert.False(OrchestratorSupportsFormat(&net.OrchestratorCapabilities{FreeSessions: 1}, FormatFMP4))
+
+	caps := &net.OrchestratorCapabilities{Formats: []net.SegmentFormat{net.SegmentFormat_MPEGTS, net.SegmentFormat_FMP4}}
+	assert.True(OrchestratorSupportsFormat(caps, FormatMPEGTS))
+	assert.True(OrchestratorSupportsFormat(caps, FormatFMP4))
+}
+
+func TestMergeCapabilities(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Nil(mergeCapabilities(nil))
+
+	sw := &net.TranscoderCapabilities{MaxWidth: 1280, MaxHeight: 720, Profiles: []string{"a", "b"}}
+	gpu := &net.TranscoderCapabilities{Acceleration: net.TranscoderCapabilities_NVIDIA, MaxWidth: 1920, MaxHeight: 1080, Profiles: []string{"b", "c"}}
+	caps := mergeCapabilities([]*net.TranscoderCapabilities{sw, gpu})
+	assert.Equal(net.TranscoderCapabilities_NVIDIA, caps.Acceleration)
+	assert.Equal(int32(1920), caps.MaxWidth)
+	assert.Equal(int32(1080), caps.MaxHeight)
+	assert.Equal([]string{"a", "b", "c"}, caps.Profiles)
+
+	// A transcoder without limits removes the limits of the pool
+	caps = mergeCapabilities([]*net.TranscoderCapabilities{sw, &net.TranscoderCapabilities{}})
+	assert.Equal(net.TranscoderCapabilities_SOFTWARE, caps.Acceleration)
+	assert.Zero(caps.MaxWidth)
+	assert.Zero(caps.MaxHeight)
+	assert.Empty(caps.Profiles)
+
+	// A transcoder without capabilities can transcode anything
+	assert.Nil(mergeCapabilities([]*net.TranscoderCapabilities{sw, nil}))
+}
+
+func TestOrchestratorCapabilities(t *testing.T) {
+	assert := assert.New(t)
+	n, _ := NewLivepeerNode(nil, "", nil)
+	orch := NewOrchestrator(n, nil)
+
+	defer func(max int) { MaxSessions = max }(MaxSessions)
+	MaxSessions = 2
+
+	caps := orch.Capabilities()
+	assert.Equal(int32(2), caps.FreeSessions)
+	assert.Equal(supportedCodecs, caps.Codecs)
+	assert.Equal(supportedFormats, caps.Formats)
+	assert.Equal(LivepeerVersion, caps.Version)
+	assert.False(caps.Gpu)
+	assert.Empty(caps.Profiles)
+
+	// Local transcoder
+	n.Capabilities = &net.TranscoderCapabilities{Acceleration: net.TranscoderCapabilities_NVIDIA, MaxWidth: 1280, MaxHeight: 720}
+	n.SegmentChans[ManifestID("a")] = make(SegmentChan)
+	caps = orch.Capabilities()
+	assert.Equal(int32(1), caps.FreeSessions)
+	assert.True(caps.Gpu)
+	assert.Equal(int32(1280), caps.MaxWidth)
+	assert.Equal(int32(720), caps.MaxHeight)
+
+	// Remote transcoders
+	n.TranscoderManager = NewRemoteTranscoderManager()
+	strm := &StubTranscoderServer{manager: n.TranscoderManager}
+	go n.TranscoderManager.Manage(strm, 5, &net.TranscoderCapabilities{Profiles: []string{"a"}})
+	time.Sleep(1 * time.Millisecond)
+	n.SegmentChans[ManifestID("b")] = make(SegmentChan)
+	n.SegmentChans[ManifestID("c")] = make(SegmentChan)
+	caps = orch.Capabilities()
+	assert.Equal(int32(0), caps.FreeSessions)
+	assert.False(caps.Gpu)
+	assert.Equal([]string{"a"}, caps.Profiles)
+	assert.Zero(caps.MaxWidth)
+}
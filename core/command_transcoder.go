@@ -0,0 +1,226 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/monitor"
+	"github.com/livepeer/joy4/format/ts"
+	"github.com/livepeer/lpms/ffmpeg"
+)
+
+var ErrCommandTranscoderPixels = errors.New("CommandTranscoderMissingPixels")
+var ErrCommandTranscoderTimeout = errors.New("CommandTranscoderTimeout")
+
+// CommandTranscoderTimeoutFactor is how many times the duration of the segment
+// the command may run for each rendition before it is killed
+var CommandTranscoderTimeoutFactor = 4
+
+// CommandTranscoderMinTimeout is the least time the command may run for each rendition
+var CommandTranscoderMinTimeout = 10 * time.Second
+
+// CommandTranscoder transcodes segments by running an external command once per profile.
+//
+// The command is a template that is split into arguments on whitespace and run
+// without a shell. The following placeholders are substituted in each argument:
+//
+//	{in}         input segment
+//	{out}        file the rendition should be written to; the extension is
+//	             the requested container, .ts or .mp4 for fragmented MP4
+//	{profile}    profile name
+//	{resolution} output resolution, WxH
+//	{width}      output width
+//	{height}     output height
+//	{fps}        output framerate; 0 to keep the source framerate
+//	{bitrate}    output bitrate, e.g. 4000k
+//
+// The command reports the pixel counts by printing `pixels=<n>` for the encoded
+// rendition and optionally `decoded_pixels=<n>` for the input to stdout.
+//
+// The command and any processes it spawns are killed if it runs longer than
+// CommandTranscoderTimeoutFactor times the duration of the segment. The duration
+// is only read from local MPEG-TS inputs; for other inputs, such as URLs, the
+// command may run as long as the transcode loop timeout.
+type CommandTranscoder struct {
+	workDir string
+	command []string
+}
+
+func NewCommandTranscoder(workDir string, command string) Transcoder {
+	return &CommandTranscoder{workDir: workDir, command: strings.Fields(command)}
+}
+
+func (ct *CommandTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format SegmentFormat) (*TranscodeData, error) {
+	if len(ct.command) == 0 {
+		return nil, errors.New("empty transcode command")
+	}
+	opts := profilesToTranscodeOptions(ct.workDir, ffmpeg.Software, profiles, format)
+
+	_, seqNo, parseErr := parseURI(fname)
+	start := time.Now()
+	timeout := commandTimeout(fname)
+
+	res := &ffmpeg.TranscodeResults{Encoded: make([]ffmpeg.MediaInfo, len(opts))}
+	for i := range opts {
+		pixels, decoded, err := ct.run(fname, opts[i], timeout)
+		if err != nil {
+			// Clean up renditions that were already written
+			for _, o := range opts {
+				os.Remove(o.Oname)
+			}
+			return nil, err
+		}
+		res.Encoded[i].Pixels = pixels
+		if decoded > res.Decoded.Pixels {
+			res.Decoded.Pixels = decoded
+		}
+	}
+
+	if monitor.Enabled && parseErr == nil {
+		// See the note in LocalTranscoder.Transcode
+		monitor.SegmentTranscoded(0, seqNo, time.Since(start), common.ProfilesNames(profiles))
+	}
+
+	return resToTranscodeData(res, opts)
+}
+
+// commandTimeout returns how long the command may run for each rendition of the segment.
+// Segments whose duration can't be read, e.g. remote or non MPEG-TS inputs, get the
+// timeout of the transcode loop
+func commandTimeout(fname string) time.Duration {
+	dur, err := probeDuration(fname)
+	if err != nil {
+		glog.V(common.DEBUG).Infof("Unable to read segment duration fname=%s err=%v", fname, err)
+		return transcodeLoopTimeout
+	}
+	timeout := time.Duration(CommandTranscoderTimeoutFactor) * dur
+	if timeout < CommandTranscoderMinTimeout {
+		return CommandTranscoderMinTimeout
+	}
+	return timeout
+}
+
+// probeDuration reads the span of the packet timestamps of an MPEG-TS segment
+func probeDuration(fname string) (time.Duration, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	dmx := ts.NewDemuxer(bufio.NewReader(f))
+	var first, last time.Duration
+	for n := 0; ; n++ {
+		pkt, err := dmx.ReadPacket()
+		if err == io.EOF {
+			if n == 0 {
+				return 0, errors.New("no packets in segment")
+			}
+			return last - first, nil
+		} else if err != nil {
+			return 0, err
+		}
+		if n == 0 || pkt.Time < first {
+			first = pkt.Time
+		}
+		if pkt.Time > last {
+			last = pkt.Time
+		}
+	}
+}
+
+// run invokes the command for a single rendition and returns the encoded and decoded pixels it reported
+func (ct *CommandTranscoder) run(fname string, opt ffmpeg.TranscodeOptions, timeout time.Duration) (int64, int64, error) {
+	p := opt.Profile
+	w, h, _ := ffmpeg.VideoProfileResolution(p)
+	r := strings.NewReplacer(
+		"{in}", fname,
+		"{out}", opt.Oname,
+		"{profile}", p.Name,
+		"{resolution}", p.Resolution,
+		"{width}", strconv.Itoa(w),
+		"{height}", strconv.Itoa(h),
+		"{fps}", strconv.FormatUint(uint64(p.Framerate), 10),
+		"{bitrate}", p.Bitrate,
+	)
+	args := make([]string, len(ct.command))
+	for i, arg := range ct.command {
+		args[i] = r.Replace(arg)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// The command may be a script whose children keep stdout open after it is
+	// killed, so the whole process group is killed on timeout
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		glog.Errorf("Transcode command failed to start for profile=%s err=%v", p.Name, err)
+		return 0, 0, fmt.Errorf("transcode command failed: %v", err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var err error
+	select {
+	case err = <-done:
+	case <-timer.C:
+		if err := killProcessGroup(cmd); err != nil {
+			glog.Errorf("Error killing transcode command for profile=%s err=%v", p.Name, err)
+		}
+		<-done
+		glog.Errorf("Transcode command timed out for profile=%s timeout=%v", p.Name, timeout)
+		return 0, 0, ErrCommandTranscoderTimeout
+	}
+	if err != nil {
+		glog.Errorf("Transcode command failed for profile=%s err=%v stderr=%s", p.Name, err, strings.TrimSpace(stderr.String()))
+		return 0, 0, fmt.Errorf("transcode command failed: %v", err)
+	}
+
+	return parseCommandPixels(stdout.Bytes())
+}
+
+// parseCommandPixels reads the `pixels=<n>` and `decoded_pixels=<n>` lines from the command output.
+// If a line is printed several times, the last one wins
+func parseCommandPixels(out []byte) (int64, int64, error) {
+	var pixels, decoded int64 = -1, 0
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		kv := strings.SplitN(strings.TrimSpace(scanner.Text()), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		var dst *int64
+		switch strings.TrimSpace(kv[0]) {
+		case "pixels":
+			dst = &pixels
+		case "decoded_pixels":
+			dst = &decoded
+		default:
+			continue
+		}
+		v, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 64)
+		if err != nil || v < 0 {
+			return 0, 0, fmt.Errorf("invalid %s in transcode command output: %v", kv[0], kv[1])
+		}
+		*dst = v
+	}
+	if pixels < 0 {
+		return 0, 0, ErrCommandTranscoderPixels
+	}
+	return pixels, decoded, nil
+}
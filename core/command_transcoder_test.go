@@ -0,0 +1,137 @@
+package core
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/livepeer/lpms/ffmpeg"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTranscodeScript(t *testing.T, dir, body string) string {
+	fname := filepath.Join(dir, "transcode.sh")
+	err := ioutil.WriteFile(fname, []byte("#!/bin/sh\n"+body), 0755)
+	require.Nil(t, err)
+	return fname
+}
+
+func TestCommandTranscoder(t *testing.T) {
+	assert := assert.New(t)
+	dir, err := ioutil.TempDir("", "TestCommandTranscoder")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	// Fake transcoder that writes its arguments into the rendition
+	script := writeTranscodeScript(t, dir, `
+echo "$1 $3 $4 $5 $6" > $2
+echo "pixels=$(($4 * $5))"
+echo "decoded_pixels=100"
+`)
+	tc := NewCommandTranscoder(dir, script+" {in} {out} {profile} {width} {height} {fps}")
+	profiles := []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9, ffmpeg.P240p30fps16x9}
+	res, err := tc.Transcode("", "test.ts", profiles, FormatMPEGTS)
+	assert.Nil(err)
+	assert.Len(res.Segments, 2)
+	assert.Equal("test.ts P144p30fps16x9 256 144 30\n", string(res.Segments[0].Data))
+	assert.Equal(int64(256*144), res.Segments[0].Pixels)
+	assert.Equal("test.ts P240p30fps16x9 426 240 30\n", string(res.Segments[1].Data))
+	assert.Equal(int64(426*240), res.Segments[1].Pixels)
+	assert.Equal(int64(100), res.Pixels)
+
+	// Renditions are removed from the work dir once read
+	files, _ := filepath.Glob(filepath.Join(dir, "out_*"))
+	assert.Empty(files)
+
+	// Missing pixel count
+	script = writeTranscodeScript(t, dir, "touch $1\n")
+	tc = NewCommandTranscoder(dir, script+" {out}")
+	_, err = tc.Transcode("", "test.ts", profiles, FormatMPEGTS)
+	assert.Equal(ErrCommandTranscoderPixels, err)
+	files, _ = filepath.Glob(filepath.Join(dir, "out_*"))
+	assert.Empty(files)
+
+	// Failing command
+	script = writeTranscodeScript(t, dir, "echo boom >&2\nexit 1\n")
+	tc = NewCommandTranscoder(dir, script)
+	_, err = tc.Transcode("", "test.ts", profiles, FormatMPEGTS)
+	assert.Contains(err.Error(), "transcode command failed")
+
+	// Command doesn't write the rendition
+	script = writeTranscodeScript(t, dir, "echo pixels=1\n")
+	tc = NewCommandTranscoder(dir, script)
+	_, err = tc.Transcode("", "test.ts", profiles, FormatMPEGTS)
+	assert.True(os.IsNotExist(err))
+
+	// Empty command
+	tc = NewCommandTranscoder(dir, " ")
+	_, err = tc.Transcode("", "test.ts", profiles, FormatMPEGTS)
+	assert.NotNil(err)
+
+	// Commands running too long are killed
+	defer func(f int, min time.Duration) {
+		CommandTranscoderTimeoutFactor, CommandTranscoderMinTimeout = f, min
+	}(CommandTranscoderTimeoutFactor, CommandTranscoderMinTimeout)
+	CommandTranscoderTimeoutFactor = 0
+	CommandTranscoderMinTimeout = 100 * time.Millisecond
+	script = writeTranscodeScript(t, dir, "touch $1\necho pixels=1\nexec sleep 10\n")
+	tc = NewCommandTranscoder(dir, script+" {out}")
+	start := time.Now()
+	_, err = tc.Transcode("", "test.ts", profiles, FormatMPEGTS)
+	assert.Equal(ErrCommandTranscoderTimeout, err)
+	assert.True(time.Since(start) < 5*time.Second)
+	files, _ = filepath.Glob(filepath.Join(dir, "out_*"))
+	assert.Empty(files)
+
+	// Children of scripts that don't exec them are killed too
+	script = writeTranscodeScript(t, dir, "touch $1\necho pixels=1\nsleep 10\necho done\n")
+	tc = NewCommandTranscoder(dir, script+" {out}")
+	start = time.Now()
+	_, err = tc.Transcode("", "test.ts", profiles, FormatMPEGTS)
+	assert.Equal(ErrCommandTranscoderTimeout, err)
+	assert.True(time.Since(start) < 5*time.Second)
+}
+
+func TestCommandTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	dur, err := probeDuration("test.ts")
+	assert.Nil(err)
+	assert.InDelta(8.6, dur.Seconds(), 0.2)
+	_, err = probeDuration("missing.ts")
+	assert.NotNil(err)
+
+	assert.Equal(time.Duration(CommandTranscoderTimeoutFactor)*dur, commandTimeout("test.ts"))
+	// unknown durations get the transcode loop timeout
+	assert.Equal(transcodeLoopTimeout, commandTimeout("missing.ts"))
+
+	defer func(min time.Duration) { CommandTranscoderMinTimeout = min }(CommandTranscoderMinTimeout)
+	CommandTranscoderMinTimeout = time.Hour
+	assert.Equal(time.Hour, commandTimeout("test.ts"))
+}
+
+func TestParseCommandPixels(t *testing.T) {
+	assert := assert.New(t)
+
+	pixels, decoded, err := parseCommandPixels([]byte("some log\npixels=10\n decoded_pixels = 20 \npixels=30\n"))
+	assert.Nil(err)
+	assert.Equal(int64(30), pixels)
+	assert.Equal(int64(20), decoded)
+
+	pixels, decoded, err = parseCommandPixels([]byte("pixels=0"))
+	assert.Nil(err)
+	assert.Zero(pixels)
+	assert.Zero(decoded)
+
+	_, _, err = parseCommandPixels([]byte("decoded_pixels=20\n"))
+	assert.Equal(ErrCommandTranscoderPixels, err)
+
+	_, _, err = parseCommandPixels([]byte("pixels=abc\n"))
+	assert.Contains(err.Error(), "invalid pixels")
+
+	_, _, err = parseCommandPixels([]byte("pixels=-1\n"))
+	assert.NotNil(err)
+}
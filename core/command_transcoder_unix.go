@@ -0,0 +1,19 @@
+// +build !windows
+
+package core
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs the command in a process group of its own so that
+// killProcessGroup also reaches the processes it spawns
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills the command along with every process in its group
+func killProcessGroup(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,11 @@
+package core
+
+import "os/exec"
+
+// setProcessGroup is a no-op; processes spawned by the command aren't tracked on Windows
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup only kills the command itself on Windows
+func killProcessGroup(cmd *exec.Cmd) error {
+	return cmd.Process.Kill()
+}
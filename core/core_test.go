@@ -6,9 +6,11 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
 	ethCrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -95,7 +97,7 @@ func TestTranscodeSeg(t *testing.T) {
 	assert.Nil(res.Err)
 	assert.Nil(res.Sig)
 	// sanity check results
-	resBytes, _ := n.Transcoder.Transcode("", "", profiles)
+	resBytes, _ := n.Transcoder.Transcode("", "", profiles, FormatMPEGTS)
 	for i, trData := range res.TranscodeData.Segments {
 		assert.Equal(resBytes.Segments[i].Data, trData.Data)
 	}
@@ -231,3 +233,221 @@ func TestCrypto(t *testing.T) {
 	i, _ := binary.Uvarint(ethCrypto.Keccak256(newb, ethCrypto.Keccak256([]byte("abc"))))
 	fmt.Printf("%x\n\n", i%1)
 }
+
+type blockingTranscoder struct {
+	mu       sync.Mutex
+	calls    int
+	inflight int
+	maxIn    int
+	jobs     map[string]bool
+	release  map[string]chan struct{} // keyed by file name
+	err      error
+}
+
+func (t *blockingTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format SegmentFormat) (*TranscodeData, error) {
+	t.mu.Lock()
+	t.calls++
+	t.inflight++
+	if t.inflight > t.maxIn {
+		t.maxIn = t.inflight
+	}
+	t.jobs[job] = true
+	ch := t.release[fname]
+	t.mu.Unlock()
+
+	if ch != nil {
+		<-ch
+	}
+
+	t.mu.Lock()
+	t.inflight--
+	t.mu.Unlock()
+
+	if t.err != nil {
+		return nil, t.err
+	}
+	segments := make([]*TranscodedSegmentData, 0)
+	for _, p := range profiles {
+		segments = append(segments, &TranscodedSegmentData{Data: []byte(fmt.Sprintf("Transcoded_%v_%v", fname, p.Name))})
+	}
+	return &TranscodeData{Segments: segments}, nil
+}
+
+func newBlockingTranscoder() *blockingTranscoder {
+	return &blockingTranscoder{jobs: make(map[string]bool), release: make(map[string]chan struct{})}
+}
+
+func TestTranscodeLoop_SegmentConcurrency(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	drivers.NodeStorage = drivers.NewMemoryDriver(nil)
+	tmp, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(tmp)
+	n, _ := NewLivepeerNode(nil, tmp, nil)
+	tc := newBlockingTranscoder()
+	n.Transcoder = tc
+
+	defer func(c int) { SegmentConcurrency = c }(SegmentConcurrency)
+	SegmentConcurrency = 3
+
+	// Segments are saved into the local OS, so block on the resulting name
+	for i := 0; i < 3; i++ {
+		tc.release[fmt.Sprintf("/stream/concurrent/%d.ts", i)] = make(chan struct{})
+	}
+
+	type result struct {
+		seq int
+		res *TranscodeResult
+		err error
+	}
+	results := make(chan result, 3)
+	for i := 0; i < 3; i++ {
+		md := &SegTranscodingMetadata{ManifestID: "concurrent", Seq: int64(i), Profiles: videoProfiles}
+		seg := &stream.HLSSegment{SeqNo: uint64(i), Data: []byte("dummy")}
+		go func(i int) {
+			res, err := n.sendToTranscodeLoop(md, seg)
+			results <- result{i, res, err}
+		}(i)
+		// Ensure segments are submitted in order
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// All segments are transcoded at once, each in its own session
+	tc.mu.Lock()
+	assert.Equal(3, tc.inflight)
+	assert.Len(tc.jobs, 3)
+	assert.True(tc.jobs["concurrent"])
+	tc.mu.Unlock()
+
+	// Results are returned in submission order regardless of completion order
+	close(tc.release["/stream/concurrent/2.ts"])
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(results, 0)
+
+	close(tc.release["/stream/concurrent/0.ts"])
+	r := <-results
+	require.Nil(r.err)
+	assert.Equal(0, r.seq)
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(results, 0)
+
+	close(tc.release["/stream/concurrent/1.ts"])
+	for i := 0; i < 2; i++ {
+		r := <-results
+		require.Nil(r.err)
+		assert.Contains(string(r.res.TranscodeData.Segments[0].Data), fmt.Sprintf("%d.ts", r.seq))
+	}
+	assert.Equal(3, tc.maxIn)
+}
+
+func TestTranscodeLoop_DefaultConcurrencyRejectsExtraSegments(t *testing.T) {
+	assert := assert.New(t)
+
+	drivers.NodeStorage = drivers.NewMemoryDriver(nil)
+	tmp, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(tmp)
+	n, _ := NewLivepeerNode(nil, tmp, nil)
+	tc := newBlockingTranscoder()
+	n.Transcoder = tc
+	release := make(chan struct{})
+	tc.release["/stream/serial/0.ts"] = release
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		md := &SegTranscodingMetadata{ManifestID: "serial", Seq: int64(i), Profiles: videoProfiles}
+		seg := &stream.HLSSegment{SeqNo: uint64(i), Data: []byte("dummy")}
+		go func() {
+			_, err := n.sendToTranscodeLoop(md, seg)
+			errs <- err
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// One segment in flight and one queued; the next one is rejected
+	md := &SegTranscodingMetadata{ManifestID: "serial", Seq: 2, Profiles: videoProfiles}
+	_, err := n.sendToTranscodeLoop(md, &stream.HLSSegment{SeqNo: 2, Data: []byte("dummy")})
+	assert.Equal(ErrOrchBusy, err)
+
+	close(release)
+	assert.Nil(<-errs)
+	assert.Nil(<-errs)
+	assert.Equal(1, tc.maxIn)
+}
+
+func TestTranscodeLoop_DeduplicatesReplayedSegments(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	drivers.NodeStorage = drivers.NewMemoryDriver(nil)
+	tmp, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(tmp)
+	n, _ := NewLivepeerNode(nil, tmp, nil)
+	tc := newBlockingTranscoder()
+	n.Transcoder = tc
+
+	defer func(c int) { SegmentConcurrency = c }(SegmentConcurrency)
+	SegmentConcurrency = 2
+
+	release := make(chan struct{})
+	tc.release["/stream/dedup/0.ts"] = release
+	md := &SegTranscodingMetadata{ManifestID: "dedup", Seq: 0, Hash: ethcommon.BytesToHash([]byte("foo")), Profiles: videoProfiles}
+	seg := &stream.HLSSegment{SeqNo: 0, Data: []byte("dummy")}
+
+	// Replay while the original is in flight
+	results := make(chan *TranscodeResult, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			res, err := n.sendToTranscodeLoop(md, seg)
+			require.Nil(err)
+			results <- res
+		}()
+		time.Sleep(20 * time.Millisecond)
+	}
+	close(release)
+	res1, res2 := <-results, <-results
+	assert.Equal(res1, res2)
+	assert.Equal(1, tc.calls)
+
+	// Replay after the original completed
+	res, err := n.sendToTranscodeLoop(md, seg)
+	require.Nil(err)
+	assert.Equal(res1, res)
+	assert.Equal(1, tc.calls)
+
+	// Same sequence number with different data is transcoded
+	mdOther := *md
+	mdOther.Hash = ethcommon.BytesToHash([]byte("bar"))
+	_, err = n.sendToTranscodeLoop(&mdOther, seg)
+	require.Nil(err)
+	assert.Equal(2, tc.calls)
+
+	// Failed segments are not deduplicated so they can be retried
+	tc.err = ErrTranscode
+	mdFail := &SegTranscodingMetadata{ManifestID: "dedup", Seq: 1, Profiles: videoProfiles}
+	_, err = n.sendToTranscodeLoop(mdFail, seg)
+	assert.Equal(ErrTranscode, err)
+	tc.err = nil
+	_, err = n.sendToTranscodeLoop(mdFail, seg)
+	assert.Nil(err)
+	assert.Equal(4, tc.calls)
+}
+
+func TestSegmentResults_EvictsCompletedResults(t *testing.T) {
+	assert := assert.New(t)
+
+	sr := newSegmentResults()
+	inflight := sr.add(&SegTranscodingMetadata{Seq: 0})
+	for i := 1; i <= maxRecentSegments+5; i++ {
+		r := sr.add(&SegTranscodingMetadata{Seq: int64(i)})
+		sr.complete(int64(i), r, &TranscodeResult{})
+	}
+
+	assert.Len(sr.results, maxRecentSegments)
+	assert.Len(sr.order, maxRecentSegments)
+	// In-flight segments are retained
+	assert.Equal(inflight, sr.get(&SegTranscodingMetadata{Seq: 0}))
+	// Oldest completed segments are evicted
+	assert.Nil(sr.get(&SegTranscodingMetadata{Seq: 1}))
+	assert.NotNil(sr.get(&SegTranscodingMetadata{Seq: maxRecentSegments + 5}))
+}
@@ -0,0 +1,178 @@
+package core
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/livepeer/m3u8"
+)
+
+const (
+	dashProfile      = "urn:mpeg:dash:profile:isoff-live:2011"
+	dashMimeType     = "video/mp4"
+	dashTimescale    = 1000
+	dashMinBufferSec = 2
+)
+
+// dashTimeline is the DASH view of a rendition, kept alongside its HLS media playlist
+type dashTimeline struct {
+	name    string
+	vParams m3u8.VariantParams
+	// segments in the window, sorted by sequence number
+	segments []dashSegment
+	// presentation time of the first segment in the window, in seconds
+	start float64
+}
+
+type dashSegment struct {
+	seqNo    uint64
+	uri      string
+	duration float64
+}
+
+// insert adds a segment in order, dropping the oldest beyond the window.
+// Segments older than the window are ignored
+func (tl *dashTimeline) insert(seg dashSegment, window uint) {
+	i := sort.Search(len(tl.segments), func(i int) bool { return tl.segments[i].seqNo >= seg.seqNo })
+	if i < len(tl.segments) && tl.segments[i].seqNo == seg.seqNo {
+		return
+	}
+	if i == 0 && uint(len(tl.segments)) >= window {
+		return
+	}
+	tl.segments = append(tl.segments, dashSegment{})
+	copy(tl.segments[i+1:], tl.segments[i:])
+	tl.segments[i] = seg
+	for uint(len(tl.segments)) > window {
+		tl.start += tl.segments[0].duration
+		tl.segments = tl.segments[1:]
+	}
+}
+
+type mpd struct {
+	XMLName                   xml.Name    `xml:"urn:mpeg:dash:schema:mpd:2011 MPD"`
+	Profiles                  string      `xml:"profiles,attr"`
+	Type                      string      `xml:"type,attr"`
+	AvailabilityStartTime     string      `xml:"availabilityStartTime,attr,omitempty"`
+	PublishTime               string      `xml:"publishTime,attr,omitempty"`
+	MinimumUpdatePeriod       string      `xml:"minimumUpdatePeriod,attr,omitempty"`
+	TimeShiftBufferDepth      string      `xml:"timeShiftBufferDepth,attr,omitempty"`
+	MediaPresentationDuration string      `xml:"mediaPresentationDuration,attr,omitempty"`
+	MinBufferTime             string      `xml:"minBufferTime,attr"`
+	Periods                   []mpdPeriod `xml:"Period"`
+}
+
+type mpdPeriod struct {
+	ID             string             `xml:"id,attr"`
+	Start          string             `xml:"start,attr"`
+	AdaptationSets []mpdAdaptationSet `xml:"AdaptationSet"`
+}
+
+type mpdAdaptationSet struct {
+	MimeType         string              `xml:"mimeType,attr"`
+	SegmentAlignment bool                `xml:"segmentAlignment,attr"`
+	Representations  []mpdRepresentation `xml:"Representation"`
+}
+
+type mpdRepresentation struct {
+	ID          string         `xml:"id,attr"`
+	Bandwidth   uint32         `xml:"bandwidth,attr"`
+	Width       int            `xml:"width,attr,omitempty"`
+	Height      int            `xml:"height,attr,omitempty"`
+	SegmentList mpdSegmentList `xml:"SegmentList"`
+}
+
+type mpdSegmentList struct {
+	Timescale      int             `xml:"timescale,attr"`
+	StartNumber    uint64          `xml:"startNumber,attr"`
+	Initialization *mpdURL         `xml:"Initialization"`
+	Timeline       []mpdS          `xml:"SegmentTimeline>S"`
+	SegmentURLs    []mpdSegmentURL `xml:"SegmentURL"`
+}
+
+type mpdURL struct {
+	SourceURL string `xml:"sourceURL,attr"`
+}
+
+type mpdS struct {
+	T int64 `xml:"t,attr"`
+	D int64 `xml:"d,attr"`
+}
+
+type mpdSegmentURL struct {
+	Media string `xml:"media,attr"`
+}
+
+func dashDuration(secs float64) string {
+	return fmt.Sprintf("PT%.3fS", secs)
+}
+
+func dashTime(secs float64) int64 {
+	return int64(math.Round(secs * dashTimescale))
+}
+
+// encodeMPD renders the timelines as a DASH manifest. Live manifests are dynamic and
+// refreshed by players like HLS media playlists; finished ones are static.
+// Only fragmented MP4 renditions are included, since DASH players don't support MPEG-TS
+func encodeMPD(timelines []*dashTimeline, initURIs map[string]string, availabilityStart time.Time, finished bool) ([]byte, error) {
+	period := mpdPeriod{ID: "0", Start: dashDuration(0)}
+	set := mpdAdaptationSet{MimeType: dashMimeType, SegmentAlignment: true}
+	maxTarget, minWindow, total := 0.0, math.MaxFloat64, 0.0
+	for _, tl := range timelines {
+		initURI := initURIs[tl.name]
+		if len(tl.segments) == 0 || initURI == "" {
+			continue
+		}
+		rep := mpdRepresentation{
+			ID:        tl.name,
+			Bandwidth: tl.vParams.Bandwidth,
+			SegmentList: mpdSegmentList{
+				Timescale:      dashTimescale,
+				StartNumber:    tl.segments[0].seqNo,
+				Initialization: &mpdURL{SourceURL: initURI},
+			},
+		}
+		fmt.Sscanf(tl.vParams.Resolution, "%dx%d", &rep.Width, &rep.Height)
+		t := tl.start
+		for _, seg := range tl.segments {
+			// Rounded from the start of the stream so errors don't accumulate
+			rep.SegmentList.Timeline = append(rep.SegmentList.Timeline, mpdS{T: dashTime(t), D: dashTime(t+seg.duration) - dashTime(t)})
+			rep.SegmentList.SegmentURLs = append(rep.SegmentList.SegmentURLs, mpdSegmentURL{Media: seg.uri})
+			t += seg.duration
+			maxTarget = math.Max(maxTarget, seg.duration)
+		}
+		minWindow = math.Min(minWindow, t-tl.start)
+		total = math.Max(total, t)
+		set.Representations = append(set.Representations, rep)
+	}
+
+	if len(set.Representations) > 0 {
+		period.AdaptationSets = []mpdAdaptationSet{set}
+	}
+	manifest := &mpd{
+		Profiles:      dashProfile,
+		MinBufferTime: dashDuration(dashMinBufferSec),
+		Periods:       []mpdPeriod{period},
+	}
+	if finished {
+		manifest.Type = "static"
+		manifest.MediaPresentationDuration = dashDuration(total)
+	} else {
+		manifest.Type = "dynamic"
+		manifest.AvailabilityStartTime = availabilityStart.UTC().Format(time.RFC3339)
+		manifest.PublishTime = time.Now().UTC().Format(time.RFC3339)
+		if maxTarget > 0 {
+			manifest.MinimumUpdatePeriod = dashDuration(math.Ceil(maxTarget))
+			manifest.TimeShiftBufferDepth = dashDuration(minWindow)
+		}
+	}
+
+	out, err := xml.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), out...), nil
+}
@@ -0,0 +1,119 @@
+package core
+
+import (
+	"encoding/xml"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	ffmpeg "github.com/livepeer/lpms/ffmpeg"
+)
+
+func decodeMPD(t *testing.T, c PlaylistManager) *mpd {
+	data, err := c.GetDASHManifest()
+	require.Nil(t, err)
+	var m mpd
+	require.Nil(t, xml.Unmarshal(data, &m))
+	return &m
+}
+
+func TestDASHManifest_Live(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	c := NewBasicPlaylistManager(RandomManifestID(), nil)
+
+	// no segments yet
+	m := decodeMPD(t, c)
+	assert.Equal("dynamic", m.Type)
+	require.Len(m.Periods, 1)
+	assert.Empty(m.Periods[0].AdaptationSets)
+
+	source := &ffmpeg.VideoProfile{Name: "source", Resolution: "1920x1080", Bitrate: "6000k"}
+	rendition := &ffmpeg.P720p30fps16x9
+	total := LIVE_LIST_LENGTH + 2
+	for i := uint64(0); i < uint64(total); i++ {
+		require.Nil(c.InsertHLSSegment(source, i, fmt.Sprintf("source/%d.ts", i), 2.5))
+		require.Nil(c.InsertHLSSegment(rendition, i, fmt.Sprintf("%s/%d.m4s", rendition.Name, i), 2.5))
+	}
+	require.Nil(c.InsertHLSInitSegment(rendition, rendition.Name+"/init.mp4"))
+	// renditions may start later
+	require.Nil(c.InsertHLSSegment(&ffmpeg.P240p30fps16x9, 5, "P240p30fps16x9/5.m4s", 2))
+	require.Nil(c.InsertHLSInitSegment(&ffmpeg.P240p30fps16x9, "P240p30fps16x9/init.mp4"))
+
+	m = decodeMPD(t, c)
+	assert.Equal("dynamic", m.Type)
+	assert.Equal("urn:mpeg:dash:profile:isoff-live:2011", m.Profiles)
+	assert.NotEmpty(m.AvailabilityStartTime)
+	assert.Equal("PT3.000S", m.MinimumUpdatePeriod)
+	sets := m.Periods[0].AdaptationSets
+	require.Len(sets, 1)
+	assert.Equal("video/mp4", sets[0].MimeType)
+	reps := sets[0].Representations
+	require.Len(reps, 2)
+
+	// same window as the HLS playlists, timed from the start of the stream
+	assert.Equal(rendition.Name, reps[0].ID)
+	assert.Equal(uint32(4000000), reps[0].Bandwidth)
+	assert.Equal(1280, reps[0].Width)
+	assert.Equal(720, reps[0].Height)
+	list := reps[0].SegmentList
+	assert.Equal(uint64(2), list.StartNumber)
+	require.Len(list.Timeline, int(LIVE_LIST_LENGTH))
+	require.Len(list.SegmentURLs, int(LIVE_LIST_LENGTH))
+	assert.Equal(mpdS{T: 5000, D: 2500}, list.Timeline[0])
+	assert.Equal(mpdS{T: 7500, D: 2500}, list.Timeline[1])
+	assert.Equal(rendition.Name+"/2.m4s", list.SegmentURLs[0].Media)
+	require.NotNil(list.Initialization)
+	assert.Equal(rendition.Name+"/init.mp4", list.Initialization.SourceURL)
+	assert.Equal(uint64(5), reps[1].SegmentList.StartNumber)
+	assert.Len(reps[1].SegmentList.SegmentURLs, 1)
+}
+
+func TestDASHManifest_OutOfOrder(t *testing.T) {
+	// late segments outside the window are ignored
+	tl := &dashTimeline{}
+	for _, seq := range []uint64{0, 2, 1, 3, 2, 0} {
+		tl.insert(dashSegment{seqNo: seq, duration: float64(seq + 1)}, 3)
+	}
+	var seqs []uint64
+	for _, seg := range tl.segments {
+		seqs = append(seqs, seg.seqNo)
+	}
+	assert.Equal(t, []uint64{1, 2, 3}, seqs)
+	assert.Equal(t, 1.0, tl.start)
+}
+
+func TestDASHManifest_FMP4AndVOD(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	c := NewVODPlaylistManager(RandomManifestID(), nil, 3)
+
+	source := &ffmpeg.VideoProfile{Name: "source"}
+	rendition := &ffmpeg.P144p30fps16x9
+	for i := uint64(0); i < 3; i++ {
+		require.Nil(c.InsertHLSSegment(source, i, fmt.Sprintf("source/%d.ts", i), 2))
+		require.Nil(c.InsertHLSSegment(rendition, i, fmt.Sprintf("%s/%d.m4s", rendition.Name, i), 2))
+	}
+	require.Nil(c.InsertHLSInitSegment(rendition, "init.mp4"))
+	c.FinishHLSPlaylists()
+
+	m := decodeMPD(t, c)
+	assert.Equal("static", m.Type)
+	assert.Equal("PT6.000S", m.MediaPresentationDuration)
+	assert.Empty(m.AvailabilityStartTime)
+	assert.Equal("urn:mpeg:dash:profile:isoff-live:2011", m.Profiles)
+
+	// the MPEG-TS source is left out
+	sets := m.Periods[0].AdaptationSets
+	require.Len(sets, 1)
+	assert.Equal("video/mp4", sets[0].MimeType)
+	require.Len(sets[0].Representations, 1)
+	assert.Equal(rendition.Name, sets[0].Representations[0].ID)
+	list := sets[0].Representations[0].SegmentList
+	require.NotNil(list.Initialization)
+	assert.Equal("init.mp4", list.Initialization.SourceURL)
+	assert.Equal(uint64(0), list.StartNumber)
+	assert.Len(list.SegmentURLs, 3)
+}
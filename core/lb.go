@@ -6,6 +6,7 @@ import (
 	"math"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 
@@ -16,6 +17,15 @@ import (
 var ErrTranscoderBusy = errors.New("TranscoderBusy")
 var ErrTranscoderStopped = errors.New("TranscoderStopped")
 
+// LBSaturation is the utilization above which a device is considered saturated.
+// Utilization is the time spent transcoding a segment relative to the segment's
+// duration, summed over all sessions on the device. Sessions run concurrently on
+// a device so this may be greater than one.
+var LBSaturation = 2.0
+
+// Weight of the most recent measurement in a session's utilization
+const lbUtilizationAlpha = 0.5
+
 type TranscoderSession interface {
 	Transcoder
 	Stop()
@@ -30,7 +40,8 @@ type LoadBalancingTranscoder struct {
 
 	// The following fields need to be protected by the mutex `mu`
 	mu       *sync.RWMutex
-	load     map[string]int
+	load     map[string]int     // Estimated cost of the sessions on each device
+	util     map[string]float64 // Measured utilization of each device
 	sessions map[string]*transcoderSession
 	idx      int // Ensures a non-tapered work distribution
 }
@@ -43,11 +54,12 @@ func NewLoadBalancingTranscoder(devices string, workDir string, newTranscoderFn
 		newT:        newTranscoderFn,
 		mu:          &sync.RWMutex{},
 		load:        make(map[string]int),
+		util:        make(map[string]float64),
 		sessions:    make(map[string]*transcoderSession),
 	}
 }
 
-func (lb *LoadBalancingTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile) (*TranscodeData, error) {
+func (lb *LoadBalancingTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format SegmentFormat) (*TranscodeData, error) {
 
 	lb.mu.RLock()
 	session, exists := lb.sessions[job]
@@ -61,7 +73,11 @@ func (lb *LoadBalancingTranscoder) Transcode(job string, fname string, profiles
 			return nil, err
 		}
 	}
-	return session.Transcode(job, fname, profiles)
+	res, took, err := session.transcode(job, fname, profiles, format)
+	if err == nil {
+		lb.measure(job, session, took, segmentDuration(profiles, res))
+	}
+	return res, err
 }
 
 func (lb *LoadBalancingTranscoder) createSession(job string, fname string, profiles []ffmpeg.VideoProfile) (*transcoderSession, error) {
@@ -76,30 +92,43 @@ func (lb *LoadBalancingTranscoder) createSession(job string, fname string, profi
 
 	glog.V(common.DEBUG).Info("LB: Creating transcode session for ", job)
 	transcoder := lb.leastLoaded()
+	session := lb.startSession(job, transcoder, calculateCost(profiles), 0)
+	lb.idx = (lb.idx + 1) % len(lb.transcoders)
+
+	glog.V(common.DEBUG).Info("LB: Created transcode session for ", session.key)
+	return session, nil
+}
 
+// Start a transcode session for the job on the given device.
+// Expects the mutex `lb.mu` to be locked by the caller.
+func (lb *LoadBalancingTranscoder) startSession(job string, transcoder string, cost int, util float64) *transcoderSession {
 	// Acquire transcode session. Map to job id + assigned transcoder
 	key := job + "_" + transcoder
-	costEstimate := calculateCost(profiles)
 	session := &transcoderSession{
 		transcoder:  lb.newT(transcoder, lb.workDir),
 		key:         key,
+		device:      transcoder,
+		cost:        cost,
+		util:        util,
 		sender:      make(chan *transcoderParams, 1),
+		quit:        make(chan struct{}),
 		makeContext: transcodeLoopContext,
 	}
 	lb.sessions[job] = session
-	lb.load[transcoder] += costEstimate
-	lb.idx = (lb.idx + 1) % len(lb.transcoders)
+	lb.load[transcoder] += cost
+	lb.util[transcoder] += util
 
 	// Local cleanup function
 	cleanupSession := func() {
 		lb.mu.Lock()
 		defer lb.mu.Unlock()
-		_, exists := lb.sessions[job]
-		if !exists {
+		// The session may have been replaced after a migration
+		if current, exists := lb.sessions[job]; !exists || current != session {
 			return
 		}
 		delete(lb.sessions, job)
-		lb.load[transcoder] -= costEstimate
+		lb.load[transcoder] -= session.cost
+		lb.util[transcoder] -= session.util
 		glog.V(common.DEBUG).Info("LB: Deleted transcode session for ", session.key)
 	}
 
@@ -108,28 +137,93 @@ func (lb *LoadBalancingTranscoder) createSession(job string, fname string, profi
 		cleanupSession()
 	}()
 
-	glog.V(common.DEBUG).Info("LB: Created transcode session for ", session.key)
-	return session, nil
+	return session
 }
 
-// Find the lowest loaded transcoder.
+// Find the lowest loaded transcoder, preferring transcoders that are not saturated.
 // Expects the mutex `lb.mu` to be locked by the caller.
 func (lb *LoadBalancingTranscoder) leastLoaded() string {
-	min, idx := math.MaxInt64, 0
+	min, idx := math.MaxInt64, -1
+	for _, skipSaturated := range []bool{true, false} {
+		for i := 0; i < len(lb.transcoders); i++ {
+			k := (i + lb.idx) % len(lb.transcoders)
+			if skipSaturated && lb.util[lb.transcoders[k]] > LBSaturation {
+				continue
+			}
+			if lb.load[lb.transcoders[k]] < min {
+				min = lb.load[lb.transcoders[k]]
+				idx = k
+			}
+		}
+		if idx >= 0 {
+			break
+		}
+	}
+	return lb.transcoders[idx]
+}
+
+// Find the transcoder with the lowest measured utilization, excluding the given one.
+// Expects the mutex `lb.mu` to be locked by the caller.
+func (lb *LoadBalancingTranscoder) leastUtilized(exclude string) (string, bool) {
+	min, found := math.MaxFloat64, ""
 	for i := 0; i < len(lb.transcoders); i++ {
 		k := (i + lb.idx) % len(lb.transcoders)
-		if lb.load[lb.transcoders[k]] < min {
-			min = lb.load[lb.transcoders[k]]
-			idx = k
+		t := lb.transcoders[k]
+		if t != exclude && lb.util[t] < min {
+			min = lb.util[t]
+			found = t
 		}
 	}
-	return lb.transcoders[idx]
+	return found, found != ""
+}
+
+// measure updates the utilization of the session after a segment was transcoded,
+// and migrates the session to another device if its current device is saturated.
+func (lb *LoadBalancingTranscoder) measure(job string, session *transcoderSession, took, dur time.Duration) {
+	if dur <= 0 {
+		// Can't measure utilization without knowing the length of the segment
+		return
+	}
+
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if current, exists := lb.sessions[job]; !exists || current != session {
+		return
+	}
+
+	util := took.Seconds() / dur.Seconds()
+	if session.util > 0 {
+		util = lbUtilizationAlpha*util + (1-lbUtilizationAlpha)*session.util
+	}
+	lb.util[session.device] += util - session.util
+	session.util = util
+
+	if lb.util[session.device] <= LBSaturation {
+		return
+	}
+	target, ok := lb.leastUtilized(session.device)
+	// Only migrate if the target would remain less utilized than the source is now,
+	// so sessions don't bounce back and forth between devices
+	if !ok || lb.util[target]+session.util >= lb.util[session.device] {
+		return
+	}
+
+	glog.Infof("LB: Migrating transcode session for job=%s from device=%s utilization=%v to device=%s utilization=%v",
+		job, session.device, lb.util[session.device], target, lb.util[target])
+	lb.load[session.device] -= session.cost
+	lb.util[session.device] -= session.util
+	delete(lb.sessions, job)
+	session.stop()
+	lb.startSession(job, target, session.cost, session.util)
 }
 
 type transcoderParams struct {
 	job      string
 	fname    string
 	profiles []ffmpeg.VideoProfile
+	format   SegmentFormat
+	took     time.Duration
 	res      chan struct {
 		*TranscodeData
 		error
@@ -139,28 +233,41 @@ type transcoderParams struct {
 type transcoderSession struct {
 	transcoder TranscoderSession
 	key        string
+	device     string
+
+	// Estimated and measured cost of the session, protected by the balancer's mutex
+	cost int
+	util float64
 
 	sender      chan *transcoderParams
+	quit        chan struct{}
 	makeContext func() (context.Context, context.CancelFunc)
 }
 
 func (sess *transcoderSession) loop() {
+	stopped := false
 	defer func() {
-		sess.transcoder.Stop()
 		// Attempt to drain any pending messages in the channel.
 		// Otherwise, write a message into the channel to fill it up.
 		// Since we know the channel is buffered with size 1, any
 		// successful writes here mean the channel is full
 		// and we can safely exit immediately knowing that subsequent writes
-		// will be rejected
+		// will be rejected.
+		// Segments submitted before the session was stopped, e.g. to migrate it
+		// to another device, are still transcoded rather than rejected
 		for {
 			select {
 			case params := <-sess.sender:
+				if stopped {
+					sess.run(params)
+					continue
+				}
 				params.res <- struct {
 					*TranscodeData
 					error
 				}{nil, ErrTranscoderStopped}
 			case sess.sender <- &transcoderParams{}:
+				sess.transcoder.Stop()
 				return
 			default:
 				continue
@@ -177,15 +284,14 @@ func (sess *transcoderSession) loop() {
 			// Terminate the session after a period of inactivity
 			glog.V(common.DEBUG).Info("LB: Transcode loop timed out for ", sess.key)
 			return
+		case <-sess.quit:
+			cancel()
+			glog.V(common.DEBUG).Info("LB: Transcode loop stopped for ", sess.key)
+			stopped = true
+			return
 		case params := <-sess.sender:
 			cancel()
-			res, err :=
-				sess.transcoder.Transcode(params.job, params.fname, params.profiles)
-			params.res <- struct {
-				*TranscodeData
-				error
-			}{res, err}
-			if err != nil {
+			if err := sess.run(params); err != nil {
 				glog.V(common.DEBUG).Info("LB: Stopping transcoder due to error for ", sess.key)
 				return
 			}
@@ -193,8 +299,28 @@ func (sess *transcoderSession) loop() {
 	}
 }
 
-func (sess *transcoderSession) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile) (*TranscodeData, error) {
-	params := &transcoderParams{job: job, fname: fname, profiles: profiles,
+// run transcodes a submitted segment and sends back the result
+func (sess *transcoderSession) run(params *transcoderParams) error {
+	start := time.Now()
+	res, err :=
+		sess.transcoder.Transcode(params.job, params.fname, params.profiles, params.format)
+	params.took = time.Since(start)
+	params.res <- struct {
+		*TranscodeData
+		error
+	}{res, err}
+	return err
+}
+
+func (sess *transcoderSession) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format SegmentFormat) (*TranscodeData, error) {
+	res, _, err := sess.transcode(job, fname, profiles, format)
+	return res, err
+}
+
+// transcode submits the segment to the session and also returns the time
+// spent transcoding it, excluding any time spent waiting for the session
+func (sess *transcoderSession) transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format SegmentFormat) (*TranscodeData, time.Duration, error) {
+	params := &transcoderParams{job: job, fname: fname, profiles: profiles, format: format,
 		res: make(chan struct {
 			*TranscodeData
 			error
@@ -204,10 +330,33 @@ func (sess *transcoderSession) Transcode(job string, fname string, profiles []ff
 		glog.V(common.DEBUG).Info("LB: Transcode submitted for ", sess.key)
 	default:
 		glog.V(common.DEBUG).Info("LB: Transcoder was busy; exiting ", sess.key)
-		return nil, ErrTranscoderBusy
+		return nil, 0, ErrTranscoderBusy
 	}
 	res := <-params.res
-	return res.TranscodeData, res.error
+	return res.TranscodeData, params.took, res.error
+}
+
+// stop terminates the session loop. Segments already submitted to the
+// session are transcoded first; later ones are rejected with ErrTranscoderBusy.
+func (sess *transcoderSession) stop() {
+	close(sess.quit)
+}
+
+// segmentDuration estimates the duration of a transcoded segment from the
+// number of pixels encoded for each profile. Returns zero if unknown.
+func segmentDuration(profiles []ffmpeg.VideoProfile, res *TranscodeData) time.Duration {
+	if res == nil || len(res.Segments) != len(profiles) {
+		return 0
+	}
+	for i, p := range profiles {
+		w, h, err := ffmpeg.VideoProfileResolution(p)
+		if err != nil || w*h <= 0 || p.Framerate <= 0 || res.Segments[i].Pixels <= 0 {
+			continue
+		}
+		frames := float64(res.Segments[i].Pixels) / float64(w*h)
+		return time.Duration(frames / float64(p.Framerate) * float64(time.Second))
+	}
+	return 0
 }
 
 func calculateCost(profiles []ffmpeg.VideoProfile) int {
@@ -217,7 +366,8 @@ func calculateCost(profiles []ffmpeg.VideoProfile) int {
 		if err != nil {
 			continue
 		}
-		cost += w * h * int(v.Framerate) // TODO incorporate duration
+		// Cost per second of video; the measured utilization accounts for duration
+		cost += w * h * int(v.Framerate)
 	}
 	return cost
 }
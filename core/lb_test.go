@@ -48,7 +48,7 @@ func TestLB_Ratchet(t *testing.T) {
 		sess := sessions[sessIdx]
 		_, exists := lb.sessions[sess]
 		idx := lb.idx
-		lb.Transcode(sess, "", []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9})
+		lb.Transcode(sess, "", []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}, FormatMPEGTS)
 		if exists {
 			assert.Equal(idx, lb.idx)
 		} else {
@@ -78,7 +78,7 @@ func TestLB_LoadAssignment(t *testing.T) {
 		profs := shuffleProfiles(t)
 		_, exists := lb.sessions[sessName]
 		totalLoad := accumLoad(lb)
-		lb.Transcode(sessName, "", profs)
+		lb.Transcode(sessName, "", profs, FormatMPEGTS)
 		if exists {
 			assert.Equal(totalLoad, accumLoad(lb))
 		} else {
@@ -106,10 +106,61 @@ func TestLB_SessionCancel(t *testing.T) {
 	}()
 	stubCancel()
 	wgWait(wg)
-	_, err := sess.Transcode("", "", nil)
+	_, err := sess.Transcode("", "", nil, FormatMPEGTS)
 	assert.Equal(t, ErrTranscoderBusy, err)
 }
 
+func TestLB_SessionStop(t *testing.T) {
+	// Segments queued before the session is stopped should still complete
+	assert := assert.New(t)
+	transcoder := &StubTranscoder{}
+	sess := &transcoderSession{
+		transcoder: transcoder,
+		sender:     make(chan *transcoderParams, 1),
+		quit:       make(chan struct{}),
+		makeContext: func() (context.Context, context.CancelFunc) {
+			return context.WithCancel(context.Background())
+		},
+	}
+	started := make(chan struct{}, 2)
+	release := make(chan struct{})
+	transcoder.TranscodeFn = func() error {
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+
+	done := newWg(1)
+	go func() {
+		sess.loop()
+		done.Done()
+	}()
+
+	errs := make(chan error, 2)
+	transcode := func() {
+		_, err := sess.Transcode("", "", nil, FormatMPEGTS)
+		errs <- err
+	}
+	go transcode()
+	<-started // first segment in flight
+	go transcode()
+	for len(sess.sender) == 0 {
+		time.Sleep(time.Millisecond) // second segment queued
+	}
+
+	sess.stop()
+	close(release)
+	assert.Nil(<-errs)
+	assert.Nil(<-errs)
+	wgWait(done)
+	assert.Equal(2, transcoder.SegCount)
+	assert.Equal(1, transcoder.StoppedCount)
+
+	// Segments submitted after the session stopped are rejected
+	_, err := sess.Transcode("", "", nil, FormatMPEGTS)
+	assert.Equal(ErrTranscoderBusy, err)
+}
+
 func TestLB_SessionConcurrency(t *testing.T) {
 
 	stubCtx, stubCancel := context.WithCancel(context.Background())
@@ -134,7 +185,7 @@ func TestLB_SessionConcurrency(t *testing.T) {
 		}
 		wg.Add(1)
 		go func() {
-			sess.Transcode("", "", []ffmpeg.VideoProfile{})
+			sess.Transcode("", "", []ffmpeg.VideoProfile{}, FormatMPEGTS)
 			wg.Done()
 		}()
 	}
@@ -179,7 +230,7 @@ func TestLB_ConcurrentSessionErrors(t *testing.T) {
 			errCh := make(chan int)
 			for i := 0; i < innerIters; i++ {
 				go func(ch chan int) {
-					_, err := sess.Transcode("", "", nil)
+					_, err := sess.Transcode("", "", nil, FormatMPEGTS)
 					if err == nil {
 						ch <- 0
 					} else {
@@ -210,6 +261,114 @@ func TestLB_ConcurrentSessionErrors(t *testing.T) {
 	assert.True(t, wgWait2(wg, mainTimeout), "Time expired")
 }
 
+func TestLB_LeastLoadedSkipsSaturated(t *testing.T) {
+	assert := assert.New(t)
+	lb := NewLoadBalancingTranscoder("0,1,2", "", newStubTranscoder).(*LoadBalancingTranscoder)
+	lb.load["0"] = 1
+	lb.load["1"] = 2
+	lb.load["2"] = 3
+	assert.Equal("0", lb.leastLoaded())
+
+	lb.util["0"] = LBSaturation + 1
+	assert.Equal("1", lb.leastLoaded())
+
+	// Fall back to the least loaded device if all are saturated
+	lb.util["1"] = LBSaturation + 1
+	lb.util["2"] = LBSaturation + 1
+	assert.Equal("0", lb.leastLoaded())
+}
+
+func TestLB_SegmentDuration(t *testing.T) {
+	assert := assert.New(t)
+	profiles := []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9, ffmpeg.P240p30fps16x9}
+	w, h, err := ffmpeg.VideoProfileResolution(ffmpeg.P144p30fps16x9)
+	require.Nil(t, err)
+
+	// 2 seconds at 30fps
+	res := &TranscodeData{Segments: []*TranscodedSegmentData{
+		&TranscodedSegmentData{Pixels: int64(w * h * 60)},
+		&TranscodedSegmentData{},
+	}}
+	assert.Equal(2*time.Second, segmentDuration(profiles, res))
+
+	// Unknown pixel counts
+	res.Segments[0].Pixels = 0
+	assert.Equal(time.Duration(0), segmentDuration(profiles, res))
+	assert.Equal(time.Duration(0), segmentDuration(profiles, nil))
+	assert.Equal(time.Duration(0), segmentDuration(profiles, &TranscodeData{}))
+}
+
+func TestLB_MeasureAndMigrate(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	lb := NewLoadBalancingTranscoder("0,1", "", newStubTranscoder).(*LoadBalancingTranscoder)
+	profiles := []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}
+	cost := calculateCost(profiles)
+
+	// a and c are assigned to device 0, b to device 1
+	for _, job := range []string{"a", "b", "c"} {
+		_, err := lb.Transcode(job, "", profiles, FormatMPEGTS)
+		require.Nil(err)
+	}
+	require.Equal("0", lb.sessions["a"].device)
+	require.Equal("1", lb.sessions["b"].device)
+	require.Equal("0", lb.sessions["c"].device)
+
+	// Segments of unknown duration aren't measured
+	lb.measure("a", lb.sessions["a"], time.Second, 0)
+	assert.Zero(lb.util["0"])
+
+	// Utilization is a moving average of transcode time relative to the duration
+	lb.measure("a", lb.sessions["a"], 2*time.Second, 2*time.Second)
+	assert.Equal(1.0, lb.util["0"])
+	lb.measure("a", lb.sessions["a"], 4*time.Second, 2*time.Second)
+	assert.Equal(1.5, lb.util["0"])
+
+	// Migrating a session that is alone on a saturated device doesn't help
+	lb.measure("b", lb.sessions["b"], 3*time.Second, time.Second)
+	assert.Equal(3.0, lb.util["1"])
+	assert.Equal("1", lb.sessions["b"].device)
+	lb.measure("b", lb.sessions["b"], 0, time.Second)
+	lb.measure("b", lb.sessions["b"], 0, time.Second)
+	assert.Equal(0.75, lb.util["1"])
+
+	// Device 0 becomes saturated; c is moved to device 1
+	old := lb.sessions["c"]
+	lb.measure("c", old, 2*time.Second, 2*time.Second)
+	require.Contains(lb.sessions, "c")
+	assert.Equal("1", lb.sessions["c"].device)
+	assert.Equal(1.0, lb.sessions["c"].util)
+	assert.Equal(1.5, lb.util["0"])
+	assert.Equal(1.75, lb.util["1"])
+	assert.Equal(cost, lb.load["0"])
+	assert.Equal(2*cost, lb.load["1"])
+
+	// The old session is stopped without affecting the new one
+	stopped := false
+	for i := 0; i < 100 && !stopped; i++ {
+		time.Sleep(1 * time.Millisecond)
+		lb.mu.RLock()
+		stopped = old.transcoder.(*StubTranscoder).StoppedCount == 1
+		lb.mu.RUnlock()
+	}
+	assert.True(stopped, "Old session was not stopped")
+	lb.mu.RLock()
+	assert.Len(lb.sessions, 3)
+	assert.Equal(3*cost, accumLoad(lb))
+	lb.mu.RUnlock()
+
+	// Measurements of the old session are ignored
+	lb.measure("c", old, 10*time.Second, time.Second)
+	assert.Equal(1.5, lb.util["0"])
+	assert.Equal(1.75, lb.util["1"])
+
+	// Segments continue on the new session
+	_, err := lb.Transcode("c", "", profiles, FormatMPEGTS)
+	assert.Nil(err)
+	assert.Equal("1", lb.sessions["c"].device)
+	assert.Equal(1, lb.sessions["c"].transcoder.(*StubTranscoder).SegCount)
+}
+
 func accumLoad(lb *LoadBalancingTranscoder) int {
 	totalLoad := 0
 	for _, v := range lb.load {
@@ -284,7 +443,7 @@ func (m *lbMachine) TranscodeOK(t *rapid.T) {
 	// Run a successful segment transcode
 
 	sessName, state := m.randomSession(t)
-	_, err := m.lb.Transcode(sessName, "", state.profiles)
+	_, err := m.lb.Transcode(sessName, "", state.profiles, FormatMPEGTS)
 
 	assert.Nil(t, err)
 
@@ -300,7 +459,7 @@ func (m *lbMachine) TranscodeError(t *rapid.T) {
 	// If session doesn't already exist, create it by forcing a transcode
 	_, ok := m.lb.sessions[sessName]
 	if !ok {
-		_, err := m.lb.Transcode(sessName, "", state.profiles)
+		_, err := m.lb.Transcode(sessName, "", state.profiles, FormatMPEGTS)
 		assert.Nil(t, err)
 		require.Contains(t, m.lb.sessions, sessName)
 	}
@@ -309,7 +468,7 @@ func (m *lbMachine) TranscodeError(t *rapid.T) {
 	require.Equal(t, 0, transcoder.StoppedCount) // Sanity check
 
 	transcoder.FailTranscode = true
-	_, err := m.lb.Transcode(sessName, "", state.profiles)
+	_, err := m.lb.Transcode(sessName, "", state.profiles, FormatMPEGTS)
 	assert.Equal(t, ErrTranscode, err)
 
 	m.totalLoad -= calculateCost(state.profiles)
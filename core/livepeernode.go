@@ -18,10 +18,12 @@ import (
 	"sync"
 	"time"
 
+	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/livepeer/go-livepeer/pm"
 
 	"github.com/livepeer/go-livepeer/common"
 	"github.com/livepeer/go-livepeer/eth"
+	"github.com/livepeer/go-livepeer/net"
 )
 
 var ErrTranscoderAvail = errors.New("ErrTranscoderUnavailable")
@@ -35,6 +37,9 @@ var LivepeerVersion = "undefined"
 
 var MaxSessions = 10
 
+// SegmentConcurrency is the maximum number of segments of a single stream that are transcoded at once
+var SegmentConcurrency = 1
+
 type NodeType int
 
 const (
@@ -58,6 +63,7 @@ type LivepeerNode struct {
 	OrchestratorPool  common.OrchestratorPool
 	OrchSecret        string
 	Transcoder        Transcoder
+	Capabilities      *net.TranscoderCapabilities // capabilities of the local transcoder, if any
 	TranscoderManager *RemoteTranscoderManager
 	Balances          *AddressBalances
 	ErrorMonitor      *errorMonitor
@@ -70,7 +76,12 @@ type LivepeerNode struct {
 	// Transcoder private fields
 	priceInfo    *big.Rat
 	serviceURI   url.URL
+	draining     bool
+	drainCh      chan struct{} // closed when the node starts draining
 	segmentMutex *sync.RWMutex
+	// Sender of each of the SegmentChans, protected by segmentMutex
+	segmentSenders map[ManifestID]ethcommon.Address
+	resultCache    *resultCache
 }
 
 //NewLivepeerNode creates a new Livepeer Node. Eth can be nil.
@@ -82,6 +93,7 @@ func NewLivepeerNode(e eth.LivepeerEthClient, wd string, dbh *common.DB) (*Livep
 		Database:     dbh,
 		SegmentChans: make(map[ManifestID]SegmentChan),
 		segmentMutex: &sync.RWMutex{},
+		resultCache:  newResultCache(),
 	}, nil
 }
 
@@ -110,3 +122,42 @@ func (n *LivepeerNode) GetBasePrice() *big.Rat {
 	defer n.mu.RUnlock()
 	return n.priceInfo
 }
+
+// SetDraining puts the node in or out of drain mode. A draining node
+// finishes the work it already has but doesn't accept any new work.
+func (n *LivepeerNode) SetDraining(draining bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.draining == draining {
+		return
+	}
+	n.draining = draining
+	if draining {
+		if n.drainCh == nil {
+			n.drainCh = make(chan struct{})
+		}
+		close(n.drainCh)
+	} else {
+		n.drainCh = make(chan struct{})
+	}
+}
+
+// Draining returns whether the node is in drain mode
+func (n *LivepeerNode) Draining() bool {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	return n.draining
+}
+
+// DrainNotify returns a channel that is closed once the node starts draining
+func (n *LivepeerNode) DrainNotify() <-chan struct{} {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.drainCh == nil {
+		n.drainCh = make(chan struct{})
+		if n.draining {
+			close(n.drainCh)
+		}
+	}
+	return n.drainCh
+}
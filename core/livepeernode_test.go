@@ -31,7 +31,7 @@ func stubTranscoderWithProfiles(profiles []ffmpeg.VideoProfile) *StubTranscoder
 	return &StubTranscoder{Profiles: profiles}
 }
 
-func (t *StubTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile) (*TranscodeData, error) {
+func (t *StubTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format SegmentFormat) (*TranscodeData, error) {
 	if t.FailTranscode {
 		return nil, ErrTranscode
 	}
@@ -164,3 +164,37 @@ func TestSetAndGetBasePrice(t *testing.T) {
 	assert.Zero(n.priceInfo.Cmp(price))
 	assert.Zero(n.GetBasePrice().Cmp(price))
 }
+
+func TestDraining(t *testing.T) {
+	assert := assert.New(t)
+	n, _ := NewLivepeerNode(nil, "", nil)
+	assert.False(n.Draining())
+
+	drained := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+	ch := n.DrainNotify()
+	assert.False(drained(ch))
+
+	n.SetDraining(true)
+	assert.True(n.Draining())
+	assert.True(drained(ch))
+	assert.True(drained(n.DrainNotify()))
+	// idempotent
+	n.SetDraining(true)
+	assert.True(n.Draining())
+
+	n.SetDraining(false)
+	assert.False(n.Draining())
+	assert.False(drained(n.DrainNotify()))
+
+	// nodes that weren't created by the constructor
+	n = &LivepeerNode{}
+	n.SetDraining(true)
+	assert.True(drained(n.DrainNotify()))
+}
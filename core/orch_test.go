@@ -1,13 +1,16 @@
 package core
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
 	"io/ioutil"
 	"math/big"
 	"math/rand"
+	gonet "net"
 	"os"
+	"sort"
 	"sync"
 	"testing"
 	"time"
@@ -16,6 +19,8 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 
 	"github.com/livepeer/go-livepeer/pm"
 
@@ -74,7 +79,7 @@ func TestServeTranscoder(t *testing.T) {
 	strm := &StubTranscoderServer{}
 
 	// test that a transcoder was created
-	go n.serveTranscoder(strm, 5)
+	go n.serveTranscoder(strm, 5, nil)
 	time.Sleep(1 * time.Second)
 
 	tc, ok := n.TranscoderManager.liveTranscoders[strm]
@@ -97,13 +102,13 @@ func TestRemoteTranscoder(t *testing.T) {
 	m := NewRemoteTranscoderManager()
 	initTranscoder := func() (*RemoteTranscoder, *StubTranscoderServer) {
 		strm := &StubTranscoderServer{manager: m}
-		tc := NewRemoteTranscoder(m, strm, 5)
+		tc := NewRemoteTranscoder(m, strm, 5, nil)
 		return tc, strm
 	}
 
 	// happy path
 	tc, strm := initTranscoder()
-	res, err := tc.Transcode("", "", nil)
+	res, err := tc.Transcode("", "", nil, FormatMPEGTS)
 	if err != nil || string(res.Segments[0].Data) != "asdf" {
 		t.Error("Error transcoding ", err)
 	}
@@ -111,7 +116,7 @@ func TestRemoteTranscoder(t *testing.T) {
 	// error on remote while transcoding
 	tc, strm = initTranscoder()
 	strm.TranscodeError = fmt.Errorf("TranscodeError")
-	res, err = tc.Transcode("", "", nil)
+	res, err = tc.Transcode("", "", nil, FormatMPEGTS)
 	if err != strm.TranscodeError {
 		t.Error("Unexpected error ", err, res)
 	}
@@ -120,7 +125,7 @@ func TestRemoteTranscoder(t *testing.T) {
 	tc, strm = initTranscoder()
 
 	strm.SendError = fmt.Errorf("SendError")
-	_, err = tc.Transcode("", "", nil)
+	_, err = tc.Transcode("", "", nil, FormatMPEGTS)
 	if _, fatal := err.(RemoteTranscoderFatalError); !fatal ||
 		err.Error() != strm.SendError.Error() {
 		t.Error("Unexpected error ", err, fatal)
@@ -131,7 +136,7 @@ func TestRemoteTranscoder(t *testing.T) {
 	strm.WithholdResults = true
 	m.taskCount = 1001
 	RemoteTranscoderTimeout = 1 * time.Millisecond
-	_, err = tc.Transcode("", "fileName", nil)
+	_, err = tc.Transcode("", "fileName", nil, FormatMPEGTS)
 	if err.Error() != "Remote transcoder took too long" {
 		t.Error("Unexpected error: ", err)
 	}
@@ -180,7 +185,7 @@ func TestManageTranscoders(t *testing.T) {
 
 	// test that transcoder is added to liveTranscoders and remoteTranscoders
 	wg1 := newWg(1)
-	go func() { m.Manage(strm, 5); wg1.Done() }()
+	go func() { m.Manage(strm, 5, nil); wg1.Done() }()
 	time.Sleep(1 * time.Millisecond) // allow the manager to activate
 
 	assert.NotNil(m.liveTranscoders[strm])
@@ -194,7 +199,7 @@ func TestManageTranscoders(t *testing.T) {
 
 	// test that additional transcoder is added to liveTranscoders and remoteTranscoders
 	wg2 := newWg(1)
-	go func() { m.Manage(strm2, 4); wg2.Done() }()
+	go func() { m.Manage(strm2, 4, nil); wg2.Done() }()
 	time.Sleep(1 * time.Millisecond) // allow the manager to activate
 
 	assert.NotNil(m.liveTranscoders[strm])
@@ -233,9 +238,9 @@ func TestSelectTranscoder(t *testing.T) {
 
 	// register transcoders, which adds transcoder to liveTranscoders and remoteTranscoders
 	wg := newWg(1)
-	go func() { m.Manage(strm, 2) }()
+	go func() { m.Manage(strm, 2, nil) }()
 	time.Sleep(1 * time.Millisecond) // allow time for first stream to register
-	go func() { m.Manage(strm2, 1); wg.Done() }()
+	go func() { m.Manage(strm2, 1, nil); wg.Done() }()
 	time.Sleep(1 * time.Millisecond) // allow time for second stream to register
 
 	assert.NotNil(m.liveTranscoders[strm])
@@ -245,23 +250,23 @@ func TestSelectTranscoder(t *testing.T) {
 	// assert transcoder is returned from selectTranscoder
 	t1 := m.liveTranscoders[strm]
 	t2 := m.liveTranscoders[strm2]
-	currentTranscoder := m.selectTranscoder()
+	currentTranscoder := m.selectTranscoder(nil, nil)
 	assert.Equal(t2, currentTranscoder)
 	assert.Equal(1, t2.load)
 	assert.NotNil(m.liveTranscoders[strm])
 	assert.Len(m.remoteTranscoders, 2)
 
 	// assert transcoder with less load selected
-	currentTranscoder2 := m.selectTranscoder()
+	currentTranscoder2 := m.selectTranscoder(nil, nil)
 	assert.Equal(t1, currentTranscoder2)
 	assert.Equal(1, t1.load)
 
-	currentTranscoder3 := m.selectTranscoder()
+	currentTranscoder3 := m.selectTranscoder(nil, nil)
 	assert.Equal(t1, currentTranscoder3)
 	assert.Equal(2, t1.load)
 
 	// assert no transcoder returned if all at they capacity
-	noTrans := m.selectTranscoder()
+	noTrans := m.selectTranscoder(nil, nil)
 	assert.Nil(noTrans)
 
 	m.completeTranscoders(t1)
@@ -275,14 +280,14 @@ func TestSelectTranscoder(t *testing.T) {
 	assert.NotNil(m.liveTranscoders[strm])
 
 	// assert t1 is selected and t2 drained
-	currentTranscoder = m.selectTranscoder()
+	currentTranscoder = m.selectTranscoder(nil, nil)
 	assert.Equal(t1, currentTranscoder)
 	assert.Equal(1, t1.load)
 	assert.NotNil(m.liveTranscoders[strm])
 	assert.Len(m.remoteTranscoders, 2)
 
 	// assert transcoder gets added back to remoteTranscoders if no transcoding error
-	_, err := m.Transcode("", "", nil)
+	_, err := m.Transcode("", "", nil, FormatMPEGTS)
 	assert.Nil(err)
 	assert.Len(m.remoteTranscoders, 2)
 	assert.Equal(1, t1.load)
@@ -300,12 +305,12 @@ func TestTranscoderManagerTranscoding(t *testing.T) {
 	assert.Empty(m.remoteTranscoders)
 
 	// Attempt to transcode when no transcoders in the set
-	_, err := m.Transcode("", "", nil)
+	_, err := m.Transcode("", "", nil, FormatMPEGTS)
 	assert.NotNil(err)
 	assert.Equal(err.Error(), "No transcoders available")
 
 	wg := newWg(1)
-	go func() { m.Manage(s, 5); wg.Done() }()
+	go func() { m.Manage(s, 5, nil); wg.Done() }()
 	time.Sleep(1 * time.Millisecond)
 
 	assert.Len(m.remoteTranscoders, 1) // sanity
@@ -313,14 +318,14 @@ func TestTranscoderManagerTranscoding(t *testing.T) {
 	assert.NotNil(m.liveTranscoders[s])
 
 	// happy path
-	res, err := m.Transcode("", "", nil)
+	res, err := m.Transcode("", "", nil, FormatMPEGTS)
 	assert.Nil(err)
 	assert.Len(res.Segments, 1)
 	assert.Equal(string(res.Segments[0].Data), "asdf")
 
 	// non-fatal error should not remove from list
 	s.TranscodeError = fmt.Errorf("TranscodeError")
-	_, err = m.Transcode("", "", nil)
+	_, err = m.Transcode("", "", nil, FormatMPEGTS)
 	assert.Equal(s.TranscodeError, err)
 	assert.Len(m.remoteTranscoders, 1)           // sanity
 	assert.Equal(0, m.remoteTranscoders[0].load) // sanity
@@ -330,36 +335,181 @@ func TestTranscoderManagerTranscoding(t *testing.T) {
 
 	// fatal error should retry and remove from list
 	s.SendError = fmt.Errorf("SendError")
-	_, err = m.Transcode("", "", nil)
+	_, err = m.Transcode("", "", nil, FormatMPEGTS)
 	assert.True(wgWait(wg)) // should disconnect manager
 	assert.NotNil(err)
-	assert.Equal(err.Error(), "No transcoders available")
-	_, err = m.Transcode("", "", nil) // need second try to remove from remoteTranscoders
+	// no other transcoder to retry with, so the fatal error is returned
+	_, fatal := err.(RemoteTranscoderFatalError)
+	assert.True(fatal)
+	assert.Equal(err.Error(), "SendError")
+	_, err = m.Transcode("", "", nil, FormatMPEGTS) // need second try to remove from remoteTranscoders
 	assert.NotNil(err)
 	assert.Equal(err.Error(), "No transcoders available")
 	assert.Len(m.liveTranscoders, 0)
 	assert.Len(m.remoteTranscoders, 0) // retries drain the list
 	s.SendError = nil
 
-	// fatal error should not retry
+	// timeout should return a fatal error when there is no other transcoder to retry with
 	wg.Add(1)
-	go func() { m.Manage(s, 5); wg.Done() }()
+	go func() { m.Manage(s, 5, nil); wg.Done() }()
 	time.Sleep(1 * time.Millisecond)
 
 	assert.Len(m.remoteTranscoders, 1) // sanity check
 	assert.Len(m.liveTranscoders, 1)
 	s.WithholdResults = true
 	RemoteTranscoderTimeout = 1 * time.Millisecond
-	_, err = m.Transcode("", "", nil)
-	_, fatal := err.(RemoteTranscoderFatalError)
+	_, err = m.Transcode("", "", nil, FormatMPEGTS)
+	_, fatal = err.(RemoteTranscoderFatalError)
 	wg.Wait()
 	assert.True(fatal)
+	assert.Equal(ErrRemoteTranscoderTimeout, err.(RemoteTranscoderFatalError).error)
 	assert.Len(m.liveTranscoders, 0)
-	assert.Len(m.remoteTranscoders, 1) // no retries, so don't drain
 	s.WithholdResults = false
 	RemoteTranscoderTimeout = 8 * time.Second
 }
 
+func TestTranscoderManagerTranscoding_RetriesWithAnotherTranscoder(t *testing.T) {
+	assert := assert.New(t)
+	m := NewRemoteTranscoderManager()
+	failing := &StubTranscoderServer{manager: m, WithholdResults: true}
+	working := &StubTranscoderServer{manager: m}
+
+	failingWg := newWg(1)
+	go func() { m.Manage(failing, 5, nil); failingWg.Done() }()
+	go func() { m.Manage(working, 5, nil) }()
+	time.Sleep(1 * time.Millisecond)
+
+	// Ensure the failing transcoder is selected first
+	m.RTmutex.Lock()
+	m.liveTranscoders[working].load = 1
+	sort.Sort(byLoadFactor(m.remoteTranscoders))
+	m.RTmutex.Unlock()
+
+	defer func(d time.Duration) { RemoteTranscoderTimeout = d }(RemoteTranscoderTimeout)
+	RemoteTranscoderTimeout = 1 * time.Millisecond
+
+	res, err := m.Transcode("", "", nil, FormatMPEGTS)
+	assert.Nil(err)
+	assert.Equal("asdf", string(res.Segments[0].Data))
+	assert.True(wgWait(failingWg)) // timed out transcoder is disconnected
+	assert.Equal(1, m.liveTranscoders[working].load)
+
+	// A success resets the failure count of the transcoder host
+	assert.Equal(0, m.failures["TestAddress"].count)
+}
+
+func TestTranscoderManagerTranscoding_BoundedAttempts(t *testing.T) {
+	assert := assert.New(t)
+	m := NewRemoteTranscoderManager()
+
+	defer func(n int) { RemoteTranscoderMaxFailures = n }(RemoteTranscoderMaxFailures)
+	RemoteTranscoderMaxFailures = 100
+
+	wg := newWg(4)
+	servers := make([]*StubTranscoderServer, 4)
+	for i := range servers {
+		servers[i] = &StubTranscoderServer{manager: m, SendError: fmt.Errorf("SendError")}
+		go func(s *StubTranscoderServer) { m.Manage(s, 1, nil); wg.Done() }(servers[i])
+	}
+	time.Sleep(1 * time.Millisecond)
+
+	_, err := m.Transcode("", "", nil, FormatMPEGTS)
+	_, fatal := err.(RemoteTranscoderFatalError)
+	assert.True(fatal)
+	assert.Equal(MaxRemoteTranscodeAttempts, m.failures["TestAddress"].count)
+
+	// Only MaxRemoteTranscodeAttempts transcoders were tried and disconnected
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(4-MaxRemoteTranscodeAttempts, m.RegisteredTranscodersCount())
+}
+
+func TestRemoteTranscoderQuarantine(t *testing.T) {
+	assert := assert.New(t)
+	m := NewRemoteTranscoderManager()
+	s := &StubTranscoderServer{manager: m}
+	go func() { m.Manage(s, 5, nil) }()
+	time.Sleep(1 * time.Millisecond)
+	trans := m.liveTranscoders[s]
+
+	defer func(n int, d time.Duration) {
+		RemoteTranscoderMaxFailures = n
+		RemoteTranscoderQuarantine = d
+	}(RemoteTranscoderMaxFailures, RemoteTranscoderQuarantine)
+	RemoteTranscoderMaxFailures = 2
+	RemoteTranscoderQuarantine = 50 * time.Millisecond
+
+	// Failures are consecutive; a success resets the count
+	m.recordFailure(trans)
+	m.recordSuccess(trans)
+	m.recordFailure(trans)
+	assert.False(m.isQuarantined(trans))
+	assert.Equal(trans, m.selectTranscoder(nil, nil))
+	m.completeTranscoders(trans)
+
+	// Quarantined transcoders are not selected
+	m.recordFailure(trans)
+	assert.True(m.isQuarantined(trans))
+	assert.Nil(m.selectTranscoder(nil, nil))
+	_, err := m.Transcode("", "", nil, FormatMPEGTS)
+	assert.EqualError(err, "No transcoders available")
+
+	// Quarantine lapses
+	time.Sleep(60 * time.Millisecond)
+	assert.False(m.isQuarantined(trans))
+	res, err := m.Transcode("", "", nil, FormatMPEGTS)
+	assert.Nil(err)
+	assert.Equal("asdf", string(res.Segments[0].Data))
+}
+
+func TestSelectTranscoder_Capabilities(t *testing.T) {
+	assert := assert.New(t)
+	m := NewRemoteTranscoderManager()
+	sw := &StubTranscoderServer{manager: m}
+	gpu := &StubTranscoderServer{manager: m}
+
+	swCaps := &net.TranscoderCapabilities{MaxWidth: 1280, MaxHeight: 720}
+	gpuCaps := &net.TranscoderCapabilities{Acceleration: net.TranscoderCapabilities_NVIDIA, Profiles: []string{p1080p30fps16x9.Name, ffmpeg.P720p30fps16x9.Name}}
+	go func() { m.Manage(sw, 5, swCaps) }()
+	go func() { m.Manage(gpu, 5, gpuCaps) }()
+	time.Sleep(1 * time.Millisecond)
+
+	// Only the gpu transcoder supports 1080p
+	hd := []ffmpeg.VideoProfile{p1080p30fps16x9}
+	for i := 0; i < 3; i++ {
+		assert.Equal(m.liveTranscoders[gpu], m.selectTranscoder(hd, nil))
+	}
+
+	// Only the software transcoder supports 360p
+	sd := []ffmpeg.VideoProfile{ffmpeg.P360p30fps16x9}
+	for i := 0; i < 3; i++ {
+		assert.Equal(m.liveTranscoders[sw], m.selectTranscoder(sd, nil))
+	}
+
+	// Neither supports both
+	_, err := m.Transcode("", "", append(hd, sd...), FormatMPEGTS)
+	assert.EqualError(err, "No transcoders available")
+
+	// Capabilities are reported
+	for _, info := range m.RegisteredTranscodersInfo() {
+		assert.NotNil(info.Capabilities)
+	}
+}
+
+func TestTranscoderID(t *testing.T) {
+	assert := assert.New(t)
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &gonet.TCPAddr{IP: gonet.IPv4(127, 0, 0, 1), Port: 1234}})
+
+	// falls back to the connection address including the port
+	assert.Equal("127.0.0.1:1234", transcoderID(ctx))
+	assert.Equal("127.0.0.1:1234", transcoderID(metadata.NewIncomingContext(ctx, metadata.Pairs(TranscoderIDKey, ""))))
+
+	// transcoders behind the same address are told apart by their IDs
+	a := transcoderID(metadata.NewIncomingContext(ctx, metadata.Pairs(TranscoderIDKey, "a")))
+	b := transcoderID(metadata.NewIncomingContext(ctx, metadata.Pairs(TranscoderIDKey, "b")))
+	assert.Equal("a", a)
+	assert.Equal("b", b)
+}
+
 func TestTaskChan(t *testing.T) {
 	n := NewRemoteTranscoderManager()
 	// Sanity check task ID
@@ -525,18 +675,48 @@ func TestOrchCheckCapacity(t *testing.T) {
 	assert := assert.New(t)
 
 	// happy case
-	assert.Nil(o.CheckCapacity(md.ManifestID))
+	assert.Nil(o.CheckCapacity(md.ManifestID, ethcommon.Address{}))
 
 	// capped case
 	MaxSessions = 0
-	assert.Equal(ErrOrchCap, o.CheckCapacity(md.ManifestID))
+	assert.Equal(ErrOrchCap, o.CheckCapacity(md.ManifestID, ethcommon.Address{}))
 
 	// ensure existing segment chans pass while cap is active
 	MaxSessions = cap
 	_, err := n.getSegmentChan(md) // store md into segment chans
 	assert.Nil(err)
 	MaxSessions = 0
-	assert.Nil(o.CheckCapacity(md.ManifestID))
+	assert.Nil(o.CheckCapacity(md.ManifestID, ethcommon.Address{}))
+	MaxSessions = cap
+}
+
+func TestOrchCheckCapacity_Draining(t *testing.T) {
+	drivers.NodeStorage = drivers.NewMemoryDriver(nil)
+	n, _ := NewLivepeerNode(nil, "", nil)
+	o := NewOrchestrator(n, nil)
+	md := StubSegTranscodingMetadata()
+	assert := assert.New(t)
+
+	_, err := n.getSegmentChan(md)
+	assert.Nil(err)
+	n.SetDraining(true)
+
+	// existing streams continue while new ones are rejected
+	assert.Nil(o.CheckCapacity(md.ManifestID, ethcommon.Address{}))
+	assert.Equal(ErrOrchDraining, o.CheckCapacity(ManifestID("new"), ethcommon.Address{}))
+	newMd := StubSegTranscodingMetadata()
+	newMd.ManifestID = ManifestID("new")
+	_, err = n.getSegmentChan(newMd)
+	assert.Equal(ErrOrchDraining, err)
+
+	caps := o.Capabilities()
+	assert.True(caps.Draining)
+	assert.Zero(caps.FreeSessions)
+
+	// resume
+	n.SetDraining(false)
+	assert.Nil(o.CheckCapacity(ManifestID("new"), ethcommon.Address{}))
+	assert.False(o.Capabilities().Draining)
 }
 
 func TestProcessPayment_GivenRecipientError_ReturnsNil(t *testing.T) {
@@ -17,6 +17,7 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/golang/glog"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/metadata"
 
 	"github.com/livepeer/go-livepeer/common"
 	"github.com/livepeer/go-livepeer/drivers"
@@ -42,6 +43,7 @@ type orchestrator struct {
 	address ethcommon.Address
 	node    *LivepeerNode
 	rm      common.RoundsManager
+	quotas  *senderQuotas
 }
 
 func (orch *orchestrator) ServiceURI() *url.URL {
@@ -78,24 +80,59 @@ func (orch *orchestrator) TranscoderSecret() string {
 	return orch.node.OrchSecret
 }
 
-func (orch *orchestrator) CheckCapacity(mid ManifestID) error {
+func (orch *orchestrator) CheckCapacity(mid ManifestID, sender ethcommon.Address) error {
 	orch.node.segmentMutex.RLock()
 	defer orch.node.segmentMutex.RUnlock()
 	if _, ok := orch.node.SegmentChans[mid]; ok {
 		return nil
 	}
+	if orch.node.Draining() {
+		return ErrOrchDraining
+	}
 	if len(orch.node.SegmentChans) >= MaxSessions {
 		return ErrOrchCap
 	}
+	if MaxSessionsPerSender > 0 && orch.node.senderSessions(sender) >= MaxSessionsPerSender {
+		return quotaExceeded(sender, ErrSenderSessionQuota)
+	}
 	return nil
 }
 
+// Capabilities returns the capabilities and limits advertised to broadcasters
+func (orch *orchestrator) Capabilities() *net.OrchestratorCapabilities {
+	orch.node.segmentMutex.RLock()
+	free := MaxSessions - len(orch.node.SegmentChans)
+	orch.node.segmentMutex.RUnlock()
+
+	caps := orch.node.Capabilities
+	if orch.node.TranscoderManager != nil {
+		caps = orch.node.TranscoderManager.capabilities()
+	}
+	res := orchestratorCapabilities(free, caps)
+	if orch.node.Draining() {
+		res.FreeSessions = 0
+		res.Draining = true
+	}
+	return res
+}
+
 func (orch *orchestrator) TranscodeSeg(md *SegTranscodingMetadata, seg *stream.HLSSegment) (*TranscodeResult, error) {
-	return orch.node.sendToTranscodeLoop(md, seg)
+	if err := orch.quotas.admit(md.Sender); err != nil {
+		return nil, err
+	}
+	res, err := orch.node.sendToTranscodeLoop(md, seg)
+	if err == nil && res.TranscodeData != nil {
+		var pixels int64
+		for _, s := range res.TranscodeData.Segments {
+			pixels += s.Pixels
+		}
+		orch.quotas.recordPixels(md.Sender, pixels)
+	}
+	return res, err
 }
 
-func (orch *orchestrator) ServeTranscoder(stream net.Transcoder_RegisterTranscoderServer, capacity int) {
-	orch.node.serveTranscoder(stream, capacity)
+func (orch *orchestrator) ServeTranscoder(stream net.Transcoder_RegisterTranscoderServer, capacity int, capabilities *net.TranscoderCapabilities) {
+	orch.node.serveTranscoder(stream, capacity, capabilities)
 }
 
 func (orch *orchestrator) TranscoderResults(tcID int64, res *RemoteTranscoderResult) {
@@ -354,6 +391,7 @@ func NewOrchestrator(n *LivepeerNode, rm common.RoundsManager) *orchestrator {
 		node:    n,
 		address: addr,
 		rm:      rm,
+		quotas:  newSenderQuotas(),
 	}
 }
 
@@ -361,6 +399,7 @@ func NewOrchestrator(n *LivepeerNode, rm common.RoundsManager) *orchestrator {
 
 var ErrOrchBusy = ogErrors.New("OrchestratorBusy")
 var ErrOrchCap = ogErrors.New("OrchestratorCapped")
+var ErrOrchDraining = ogErrors.New("OrchestratorDraining")
 
 type TranscodeResult struct {
 	Err           error
@@ -398,6 +437,81 @@ type TranscoderChan chan *RemoteTranscoderResult
 type transcodeConfig struct {
 	OS      drivers.OSSession
 	LocalOS drivers.OSSession
+
+	// job identifies the transcode session; defaults to the manifest ID
+	job string
+}
+
+// maxRecentSegments is the number of completed segments per stream whose results are retained
+// in order to answer replayed sequence numbers without transcoding them again
+const maxRecentSegments = 8
+
+// segmentResults tracks the segments of a stream that are being transcoded or were recently transcoded
+type segmentResults struct {
+	mu      sync.Mutex
+	results map[int64]*segmentResult
+	order   []int64
+}
+
+type segmentResult struct {
+	hash ethcommon.Hash
+	done chan struct{}
+	res  *TranscodeResult
+}
+
+func newSegmentResults() *segmentResults {
+	return &segmentResults{results: make(map[int64]*segmentResult)}
+}
+
+// get returns the result for a segment with the same sequence number and hash, if any
+func (sr *segmentResults) get(md *SegTranscodingMetadata) *segmentResult {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	r, ok := sr.results[md.Seq]
+	if !ok || r.hash != md.Hash {
+		return nil
+	}
+	return r
+}
+
+func (sr *segmentResults) add(md *SegTranscodingMetadata) *segmentResult {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	r := &segmentResult{hash: md.Hash, done: make(chan struct{})}
+	if _, ok := sr.results[md.Seq]; !ok {
+		sr.order = append(sr.order, md.Seq)
+	}
+	sr.results[md.Seq] = r
+
+	// Evict the oldest completed results. In-flight results are always retained
+	for i := 0; i < len(sr.order) && len(sr.results) > maxRecentSegments; {
+		seq := sr.order[i]
+		select {
+		case <-sr.results[seq].done:
+			delete(sr.results, seq)
+			sr.order = append(sr.order[:i], sr.order[i+1:]...)
+		default:
+			i++
+		}
+	}
+	return r
+}
+
+func (sr *segmentResults) complete(seq int64, r *segmentResult, res *TranscodeResult) {
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	r.res = res
+	close(r.done)
+	// Failed segments may be retried, so don't retain their result
+	if res.Err != nil && sr.results[seq] == r {
+		delete(sr.results, seq)
+		for i, s := range sr.order {
+			if s == seq {
+				sr.order = append(sr.order[:i], sr.order[i+1:]...)
+				break
+			}
+		}
+	}
 }
 
 func (rtm *RemoteTranscoderManager) getTaskChan(taskID int64) (TranscoderChan, error) {
@@ -440,15 +554,30 @@ func (n *LivepeerNode) getSegmentChan(md *SegTranscodingMetadata) (SegmentChan,
 	if sc, ok := n.SegmentChans[md.ManifestID]; ok {
 		return sc, nil
 	}
+	if n.Draining() {
+		return nil, ErrOrchDraining
+	}
 	if len(n.SegmentChans) >= MaxSessions {
 		return nil, ErrOrchCap
 	}
-	sc := make(SegmentChan, 1)
+	if MaxSessionsPerSender > 0 && n.senderSessions(md.Sender) >= MaxSessionsPerSender {
+		return nil, quotaExceeded(md.Sender, ErrSenderSessionQuota)
+	}
+	concurrency := SegmentConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	// The buffer admits as many queued segments as may be transcoded at once
+	sc := make(SegmentChan, concurrency)
 	glog.V(common.DEBUG).Info("Creating new segment chan for manifest ", md.ManifestID)
 	if err := n.transcodeSegmentLoop(md, sc); err != nil {
 		return nil, err
 	}
 	n.SegmentChans[md.ManifestID] = sc
+	if n.segmentSenders == nil {
+		n.segmentSenders = make(map[ManifestID]ethcommon.Address)
+	}
+	n.segmentSenders[md.ManifestID] = md.Sender
 	if lpmon.Enabled {
 		lpmon.CurrentSessions(len(n.SegmentChans))
 	}
@@ -484,9 +613,16 @@ func (n *LivepeerNode) transcodeSeg(config transcodeConfig, seg *stream.HLSSegme
 		return &TranscodeResult{Err: err}
 	}
 
-	// Prevent unnecessary work, check for replayed sequence numbers.
-	// NOTE: If we ever process segments from the same job concurrently,
-	// we may still end up doing work multiple times. But this is OK for now.
+	// Replayed sequence numbers are deduplicated by the transcode loop,
+	// and retries of the same source and profiles are answered from the cache
+	if res := n.resultCache.get(md); res != nil {
+		glog.V(common.DEBUG).Infof("Using cached transcode result for segment manifestID=%s seqNo=%d", md.ManifestID, seg.SeqNo)
+		if monitor.Enabled {
+			monitor.TranscodeCacheHit()
+		}
+		res.OS = config.OS
+		return res
+	}
 
 	//Assume d is in the right format, write it to disk
 	inName := common.RandName() + ".ts"
@@ -533,7 +669,11 @@ func (n *LivepeerNode) transcodeSeg(config transcodeConfig, seg *stream.HLSSegme
 
 	//Do the transcoding
 	start := time.Now()
-	tData, err := transcoder.Transcode(string(md.ManifestID), url, md.Profiles)
+	job := config.job
+	if job == "" {
+		job = string(md.ManifestID)
+	}
+	tData, err := transcoder.Transcode(job, url, md.Profiles, md.Format)
 	if err != nil {
 		glog.Errorf("Error transcoding manifest=%s segNo=%d segName=%s - %v", string(md.ManifestID), seg.SeqNo, seg.Name, err)
 		return terr(err)
@@ -572,6 +712,7 @@ func (n *LivepeerNode) transcodeSeg(config transcodeConfig, seg *stream.HLSSegme
 	tr.TranscodeData = tData
 
 	if n == nil || n.Eth == nil {
+		n.resultCache.add(md, &tr)
 		return &tr
 	}
 
@@ -580,6 +721,7 @@ func (n *LivepeerNode) transcodeSeg(config transcodeConfig, seg *stream.HLSSegme
 	if tr.Err != nil {
 		glog.Error("Unable to sign hash of transcoded segment hashes: ", tr.Err)
 	}
+	n.resultCache.add(md, &tr)
 	return &tr
 }
 
@@ -605,37 +747,80 @@ func (n *LivepeerNode) transcodeSegmentLoop(md *SegTranscodingMetadata, segChan
 		LocalOS: los,
 	}
 	go func() {
+		// Each slot allows one segment of this stream to be transcoded.
+		// Slots other than the first use their own transcode session
+		slots := make(chan int, cap(segChan))
+		for i := 0; i < cap(segChan); i++ {
+			slots <- i
+		}
+		// Results are returned in the order in which segments were received
+		prev := make(chan struct{})
+		close(prev)
+		results := newSegmentResults()
 		for {
+			slot := <-slots
 			// XXX make context timeout configurable
 			ctx, cancel := context.WithTimeout(context.Background(), transcodeLoopTimeout)
 			select {
 			case <-ctx.Done():
+				cancel()
 				// timeout; clean up goroutine here
-				os.EndSession()
-				los.EndSession()
 				glog.V(common.DEBUG).Info("Segment loop timed out; closing ", md.ManifestID)
 				n.segmentMutex.Lock()
 				if _, ok := n.SegmentChans[md.ManifestID]; ok {
 					close(n.SegmentChans[md.ManifestID])
 					delete(n.SegmentChans, md.ManifestID)
+					delete(n.segmentSenders, md.ManifestID)
 					if lpmon.Enabled {
 						lpmon.CurrentSessions(len(n.SegmentChans))
 					}
 				}
 				n.segmentMutex.Unlock()
+				// Wait for segments that are still in flight before ending the sessions they use
+				for i := 1; i < cap(slots); i++ {
+					<-slots
+				}
+				os.EndSession()
+				los.EndSession()
+				for chanData := range segChan {
+					chanData.res <- &TranscodeResult{Err: ErrOrchBusy}
+				}
 				return
 			case chanData := <-segChan:
-				chanData.res <- n.transcodeSeg(config, chanData.seg, chanData.md)
+				cancel()
+				if r := results.get(chanData.md); r != nil {
+					glog.V(common.DEBUG).Infof("Received replayed segment manifestID=%s seqNo=%d", md.ManifestID, chanData.md.Seq)
+					slots <- slot
+					go func(chanData *SegChanData) {
+						<-r.done
+						chanData.res <- r.res
+					}(chanData)
+					continue
+				}
+				r := results.add(chanData.md)
+				done := make(chan struct{})
+				conf := config
+				if slot > 0 {
+					conf.job = fmt.Sprintf("%s_%d", md.ManifestID, slot)
+				}
+				go func(slot int, chanData *SegChanData, prev chan struct{}) {
+					res := n.transcodeSeg(conf, chanData.seg, chanData.md)
+					results.complete(chanData.md.Seq, r, res)
+					<-prev
+					chanData.res <- res
+					close(done)
+					slots <- slot
+				}(slot, chanData, prev)
+				prev = done
 			}
-			cancel()
 		}
 	}()
 	return nil
 }
 
-func (n *LivepeerNode) serveTranscoder(stream net.Transcoder_RegisterTranscoderServer, capacity int) {
+func (n *LivepeerNode) serveTranscoder(stream net.Transcoder_RegisterTranscoderServer, capacity int, capabilities *net.TranscoderCapabilities) {
 	from := common.GetConnectionAddr(stream.Context())
-	n.TranscoderManager.Manage(stream, capacity)
+	n.TranscoderManager.Manage(stream, capacity, capabilities)
 	glog.V(common.DEBUG).Infof("Closing transcoder=%s channel", from)
 }
 
@@ -648,12 +833,14 @@ func (rtm *RemoteTranscoderManager) transcoderResults(tcID int64, res *RemoteTra
 }
 
 type RemoteTranscoder struct {
-	manager  *RemoteTranscoderManager
-	stream   net.Transcoder_RegisterTranscoderServer
-	eof      chan struct{}
-	addr     string
-	capacity int
-	load     int
+	manager      *RemoteTranscoderManager
+	stream       net.Transcoder_RegisterTranscoderServer
+	eof          chan struct{}
+	addr         string
+	id           string
+	capacity     int
+	capabilities *net.TranscoderCapabilities
+	load         int
 }
 
 // RemoteTranscoderFatalError wraps error to indicate that error is fatal
@@ -670,6 +857,16 @@ func NewRemoteTranscoderFatalError(err error) error {
 var RemoteTranscoderTimeout = 8 * time.Second
 var ErrRemoteTranscoderTimeout = errors.New("Remote transcoder took too long")
 
+// MaxRemoteTranscodeAttempts is the maximum number of remote transcoders a segment is dispatched to
+// when transcoders fail or time out
+var MaxRemoteTranscodeAttempts = 3
+
+// RemoteTranscoderMaxFailures is the number of consecutive failures after which a remote transcoder is quarantined
+var RemoteTranscoderMaxFailures = 3
+
+// RemoteTranscoderQuarantine is how long a quarantined remote transcoder is excluded from selection
+var RemoteTranscoderQuarantine = 1 * time.Minute
+
 func (rt *RemoteTranscoder) done() {
 	// select so we don't block indefinitely if there's no listener
 	select {
@@ -679,7 +876,7 @@ func (rt *RemoteTranscoder) done() {
 }
 
 // Transcode do actual transcoding by sending work to remote transcoder and waiting for the result
-func (rt *RemoteTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile) (*TranscodeData, error) {
+func (rt *RemoteTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format SegmentFormat) (*TranscodeData, error) {
 	taskID, taskChan := rt.manager.addTaskChan()
 	defer rt.manager.removeTaskChan(taskID)
 	signalEOF := func(err error) (*TranscodeData, error) {
@@ -698,6 +895,7 @@ func (rt *RemoteTranscoder) Transcode(job string, fname string, profiles []ffmpe
 		Url:          fname,
 		TaskId:       taskID,
 		FullProfiles: fullProfiles,
+		Format:       net.SegmentFormat(format),
 	}
 	err = rt.stream.Send(msg)
 
@@ -715,13 +913,15 @@ func (rt *RemoteTranscoder) Transcode(job string, fname string, profiles []ffmpe
 		return chanData.TranscodeData, chanData.Err
 	}
 }
-func NewRemoteTranscoder(m *RemoteTranscoderManager, stream net.Transcoder_RegisterTranscoderServer, capacity int) *RemoteTranscoder {
+func NewRemoteTranscoder(m *RemoteTranscoderManager, stream net.Transcoder_RegisterTranscoderServer, capacity int, capabilities *net.TranscoderCapabilities) *RemoteTranscoder {
 	return &RemoteTranscoder{
-		manager:  m,
-		stream:   stream,
-		eof:      make(chan struct{}, 1),
-		capacity: capacity,
-		addr:     common.GetConnectionAddr(stream.Context()),
+		manager:      m,
+		stream:       stream,
+		eof:          make(chan struct{}, 1),
+		capacity:     capacity,
+		capabilities: capabilities,
+		addr:         common.GetConnectionAddr(stream.Context()),
+		id:           transcoderID(stream.Context()),
 	}
 }
 
@@ -733,6 +933,7 @@ func NewRemoteTranscoderManager() *RemoteTranscoderManager {
 
 		taskMutex: &sync.RWMutex{},
 		taskChans: make(map[int64]TranscoderChan),
+		failures:  make(map[string]*transcoderFailures),
 	}
 }
 
@@ -757,6 +958,30 @@ type RemoteTranscoderManager struct {
 	taskMutex *sync.RWMutex
 	taskChans map[int64]TranscoderChan
 	taskCount int64
+
+	// Failures are tracked by the ID the transcoder registers with rather than by
+	// connection because a transcoder that fails fatally is disconnected and
+	// reconnects from a new port. Protected by RTmutex
+	failures map[string]*transcoderFailures
+}
+
+type transcoderFailures struct {
+	count            int // consecutive failures
+	quarantinedUntil time.Time
+}
+
+// TranscoderIDKey is the metadata key under which a transcoder sends its ID when registering
+const TranscoderIDKey = "transcoder-id"
+
+// transcoderID returns the ID the transcoder registered with, falling back to its
+// connection address for transcoders that don't send one
+func transcoderID(ctx context.Context) string {
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(TranscoderIDKey); len(ids) > 0 && ids[0] != "" {
+			return ids[0]
+		}
+	}
+	return common.GetConnectionAddr(ctx)
 }
 
 // RegisteredTranscodersCount returns number of registered transcoders
@@ -771,16 +996,27 @@ func (rtm *RemoteTranscoderManager) RegisteredTranscodersInfo() []net.RemoteTran
 	rtm.RTmutex.Lock()
 	res := make([]net.RemoteTranscoderInfo, 0, len(rtm.liveTranscoders))
 	for _, transcoder := range rtm.liveTranscoders {
-		res = append(res, net.RemoteTranscoderInfo{Address: transcoder.addr, Capacity: transcoder.capacity})
+		res = append(res, net.RemoteTranscoderInfo{Address: transcoder.addr, Capacity: transcoder.capacity, Capabilities: transcoder.capabilities})
 	}
 	rtm.RTmutex.Unlock()
 	return res
 }
 
+// capabilities returns the combined capabilities of the live transcoders
+func (rtm *RemoteTranscoderManager) capabilities() *net.TranscoderCapabilities {
+	rtm.RTmutex.Lock()
+	all := make([]*net.TranscoderCapabilities, 0, len(rtm.liveTranscoders))
+	for _, transcoder := range rtm.liveTranscoders {
+		all = append(all, transcoder.capabilities)
+	}
+	rtm.RTmutex.Unlock()
+	return mergeCapabilities(all)
+}
+
 // Manage adds transcoder to list of live transcoders. Doesn't return untill transcoder disconnects
-func (rtm *RemoteTranscoderManager) Manage(stream net.Transcoder_RegisterTranscoderServer, capacity int) {
+func (rtm *RemoteTranscoderManager) Manage(stream net.Transcoder_RegisterTranscoderServer, capacity int, capabilities *net.TranscoderCapabilities) {
 	from := common.GetConnectionAddr(stream.Context())
-	transcoder := NewRemoteTranscoder(rtm, stream, capacity)
+	transcoder := NewRemoteTranscoder(rtm, stream, capacity, capabilities)
 	go func() {
 		ctx := stream.Context()
 		<-ctx.Done()
@@ -816,24 +1052,27 @@ func (rtm *RemoteTranscoderManager) Manage(stream net.Transcoder_RegisterTransco
 	}
 }
 
-func (rtm *RemoteTranscoderManager) selectTranscoder() *RemoteTranscoder {
+// selectTranscoder returns the least loaded live transcoder that is able to transcode the profiles
+// and is neither excluded nor quarantined
+func (rtm *RemoteTranscoderManager) selectTranscoder(profiles []ffmpeg.VideoProfile, exclude map[*RemoteTranscoder]bool) *RemoteTranscoder {
 	rtm.RTmutex.Lock()
 	defer rtm.RTmutex.Unlock()
 
-	checkTranscoders := func(rtm *RemoteTranscoderManager) bool {
-		return len(rtm.remoteTranscoders) > 0
-	}
-
-	for checkTranscoders(rtm) {
-		last := len(rtm.remoteTranscoders) - 1
-		currentTranscoder := rtm.remoteTranscoders[last]
+	// remoteTranscoders is sorted by descending load factor, so search from the tail
+	for i := len(rtm.remoteTranscoders) - 1; i >= 0; i-- {
+		currentTranscoder := rtm.remoteTranscoders[i]
 		if _, ok := rtm.liveTranscoders[currentTranscoder.stream]; !ok {
-			// transcoder does not exist in table; remove and retry
-			rtm.remoteTranscoders = rtm.remoteTranscoders[:last]
+			if i == len(rtm.remoteTranscoders)-1 {
+				// transcoder does not exist in table; remove and retry
+				rtm.remoteTranscoders = rtm.remoteTranscoders[:i]
+			}
 			continue
 		}
-		if currentTranscoder.load == currentTranscoder.capacity {
-			// Head of queue is at capacity, so the rest must be too. Exit early
+		if exclude[currentTranscoder] || rtm.isQuarantined(currentTranscoder) || !canTranscode(currentTranscoder.capabilities, profiles) {
+			continue
+		}
+		if currentTranscoder.load >= currentTranscoder.capacity {
+			// This transcoder is at capacity, so the rest must be too. Exit early
 			return nil
 		}
 		currentTranscoder.load++
@@ -844,6 +1083,45 @@ func (rtm *RemoteTranscoderManager) selectTranscoder() *RemoteTranscoder {
 	return nil
 }
 
+// Caller of this function should hold RTmutex lock
+func (rtm *RemoteTranscoderManager) isQuarantined(trans *RemoteTranscoder) bool {
+	f, ok := rtm.failures[trans.id]
+	return ok && time.Now().Before(f.quarantinedUntil)
+}
+
+// recordFailure counts a failure for the transcoder and quarantines it after RemoteTranscoderMaxFailures consecutive failures
+func (rtm *RemoteTranscoderManager) recordFailure(trans *RemoteTranscoder) {
+	rtm.RTmutex.Lock()
+	f, ok := rtm.failures[trans.id]
+	if !ok {
+		f = &transcoderFailures{}
+		rtm.failures[trans.id] = f
+	}
+	f.count++
+	quarantined := f.count >= RemoteTranscoderMaxFailures
+	if quarantined {
+		f.count = 0
+		f.quarantinedUntil = time.Now().Add(RemoteTranscoderQuarantine)
+	}
+	rtm.RTmutex.Unlock()
+
+	if quarantined {
+		glog.Errorf("Quarantining remote transcoder=%s id=%s for %v after %d consecutive failures", trans.addr, trans.id, RemoteTranscoderQuarantine, RemoteTranscoderMaxFailures)
+		if monitor.Enabled {
+			monitor.RemoteTranscoderQuarantined()
+		}
+	}
+}
+
+// recordSuccess resets the consecutive failure count for the transcoder
+func (rtm *RemoteTranscoderManager) recordSuccess(trans *RemoteTranscoder) {
+	rtm.RTmutex.Lock()
+	defer rtm.RTmutex.Unlock()
+	if f, ok := rtm.failures[trans.id]; ok {
+		f.count = 0
+	}
+}
+
 func (rtm *RemoteTranscoderManager) completeTranscoders(trans *RemoteTranscoder) {
 	rtm.RTmutex.Lock()
 	defer rtm.RTmutex.Unlock()
@@ -866,22 +1144,42 @@ func (rtm *RemoteTranscoderManager) totalLoadAndCapacity() (int, int, int) {
 	return load, capacity, len(rtm.liveTranscoders)
 }
 
-// Transcode does actual transcoding using remote transcoder from the pool
-func (rtm *RemoteTranscoderManager) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile) (*TranscodeData, error) {
-	currentTranscoder := rtm.selectTranscoder()
-	if currentTranscoder == nil {
-		return nil, errors.New("No transcoders available")
-	}
-	res, err := currentTranscoder.Transcode(job, fname, profiles)
-	_, fatal := err.(RemoteTranscoderFatalError)
-	if fatal {
-		// Don't retry if we've timed out; broadcaster likely to have moved on
-		// XXX problematic for VOD when we *should* retry
-		if err.(RemoteTranscoderFatalError).error == ErrRemoteTranscoderTimeout {
+// Transcode does actual transcoding using remote transcoder from the pool.
+// If a transcoder fails fatally or times out, the segment is re-dispatched to a different
+// transcoder, up to MaxRemoteTranscodeAttempts in total
+func (rtm *RemoteTranscoderManager) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format SegmentFormat) (*TranscodeData, error) {
+	tried := make(map[*RemoteTranscoder]bool)
+	var lastErr error
+	for attempt := 1; attempt <= MaxRemoteTranscodeAttempts; attempt++ {
+		currentTranscoder := rtm.selectTranscoder(profiles, tried)
+		if currentTranscoder == nil {
+			if lastErr != nil {
+				return nil, lastErr
+			}
+			return nil, errors.New("No transcoders available")
+		}
+		res, err := currentTranscoder.Transcode(job, fname, profiles, format)
+		if _, fatal := err.(RemoteTranscoderFatalError); !fatal {
+			if err == nil {
+				rtm.recordSuccess(currentTranscoder)
+			}
+			rtm.completeTranscoders(currentTranscoder)
 			return res, err
 		}
-		return rtm.Transcode(job, fname, profiles)
+
+		tried[currentTranscoder] = true
+		rtm.recordFailure(currentTranscoder)
+		lastErr = err
+		if attempt < MaxRemoteTranscodeAttempts {
+			code := "Fatal"
+			if err.(RemoteTranscoderFatalError).error == ErrRemoteTranscoderTimeout {
+				code = "Timeout"
+			}
+			glog.Errorf("Retrying segment with another remote transcoder fname=%s attempt=%d err=%v", fname, attempt, err)
+			if monitor.Enabled {
+				monitor.RemoteTranscodeRetried(code)
+			}
+		}
 	}
-	rtm.completeTranscoders(currentTranscoder)
-	return res, err
+	return nil, lastErr
 }
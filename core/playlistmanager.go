@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 	"github.com/livepeer/go-livepeer/drivers"
@@ -19,12 +20,25 @@ type PlaylistManager interface {
 	// Inserts in media playlist given a link to a segment
 	InsertHLSSegment(profile *ffmpeg.VideoProfile, seqNo uint64, uri string, duration float64) error
 
+	// Sets the initialization section of the fragmented MP4 segments of a
+	// rendition, referenced by the media playlist with EXT-X-MAP
+	InsertHLSInitSegment(profile *ffmpeg.VideoProfile, uri string) error
+
+	// Location of the initialization section of a rendition, if any
+	GetHLSInitSegment(rendition string) string
+
 	GetHLSMasterPlaylist() *m3u8.MasterPlaylist
 
 	GetHLSMediaPlaylist(rendition string) *m3u8.MediaPlaylist
 
+	// DASH manifest covering the same segments as the HLS playlists
+	GetDASHManifest() ([]byte, error)
+
 	GetOSSession() drivers.OSSession
 
+	// Marks the media playlists as complete; no more segments will be inserted
+	FinishHLSPlaylists()
+
 	Cleanup()
 }
 
@@ -34,7 +48,14 @@ type BasicPlaylistManager struct {
 	// Live playlist used for broadcasting
 	masterPList *m3u8.MasterPlaylist
 	mediaLists  map[string]*m3u8.MediaPlaylist
-	mapSync     *sync.RWMutex
+	initURIs    map[string]string
+	// DASH timelines by rendition, in the order of the master playlist
+	dashTimelines []*dashTimeline
+	dashStart     time.Time
+	finished      bool
+	mapSync       *sync.RWMutex
+	// Number of segments kept in each media playlist
+	window uint
 }
 
 // NewBasicPlaylistManager create new BasicPlaylistManager struct
@@ -46,7 +67,21 @@ func NewBasicPlaylistManager(manifestID ManifestID,
 		manifestID:     manifestID,
 		masterPList:    m3u8.NewMasterPlaylist(),
 		mediaLists:     make(map[string]*m3u8.MediaPlaylist),
+		initURIs:       make(map[string]string),
 		mapSync:        &sync.RWMutex{},
+		window:         LIVE_LIST_LENGTH,
+	}
+	return bplm
+}
+
+// NewVODPlaylistManager creates a playlist manager for a file of known length,
+// whose media playlists keep all segments instead of a live window
+func NewVODPlaylistManager(manifestID ManifestID,
+	storageSession drivers.OSSession, segments uint) *BasicPlaylistManager {
+
+	bplm := NewBasicPlaylistManager(manifestID, storageSession)
+	if segments > 0 {
+		bplm.window = segments
 	}
 	return bplm
 }
@@ -76,7 +111,7 @@ func (mgr *BasicPlaylistManager) getOrCreatePL(profile *ffmpeg.VideoProfile) (*m
 	if pl, ok := mgr.mediaLists[profile.Name]; ok {
 		return pl, nil
 	}
-	mpl, err := m3u8.NewMediaPlaylist(LIVE_LIST_LENGTH, LIVE_LIST_LENGTH)
+	mpl, err := m3u8.NewMediaPlaylist(mgr.window, mgr.window)
 	if err != nil {
 		glog.Error(err)
 		return nil, err
@@ -103,7 +138,77 @@ func (mgr *BasicPlaylistManager) InsertHLSSegment(profile *ffmpeg.VideoProfile,
 		mpl.SeqNo = mseg.SeqId
 	}
 
-	return mpl.InsertSegment(seqNo, mseg)
+	if err := mpl.InsertSegment(seqNo, mseg); err != nil {
+		return err
+	}
+	mgr.insertDASHSegment(profile, dashSegment{seqNo: seqNo, uri: uri, duration: duration})
+	return nil
+}
+
+func (mgr *BasicPlaylistManager) insertDASHSegment(profile *ffmpeg.VideoProfile, seg dashSegment) {
+	mgr.mapSync.Lock()
+	defer mgr.mapSync.Unlock()
+	if mgr.dashStart.IsZero() {
+		mgr.dashStart = time.Now()
+	}
+	var tl *dashTimeline
+	for _, t := range mgr.dashTimelines {
+		if t.name == profile.Name {
+			tl = t
+			break
+		}
+	}
+	if tl == nil {
+		tl = &dashTimeline{name: profile.Name, vParams: ffmpeg.VideoProfileToVariantParams(*profile)}
+		mgr.dashTimelines = append(mgr.dashTimelines, tl)
+	}
+	tl.insert(seg, mgr.window)
+}
+
+// InsertHLSInitSegment sets the EXT-X-MAP of the rendition's media playlist.
+// Replaces any previous initialization section
+func (mgr *BasicPlaylistManager) InsertHLSInitSegment(profile *ffmpeg.VideoProfile, uri string) error {
+	mpl, err := mgr.getOrCreatePL(profile)
+	if err != nil {
+		return err
+	}
+	mgr.mapSync.Lock()
+	mgr.initURIs[profile.Name] = uri
+	mgr.mapSync.Unlock()
+
+	mpl.SetDefaultMap(uri, 0, 0)
+	// EXT-X-MAP in playlists without I-frames only needs version 6
+	mpl.SetVersion(6)
+	mpl.ResetCache()
+	return nil
+}
+
+// GetHLSInitSegment ...
+func (mgr *BasicPlaylistManager) GetHLSInitSegment(rendition string) string {
+	mgr.mapSync.RLock()
+	defer mgr.mapSync.RUnlock()
+	return mgr.initURIs[rendition]
+}
+
+// FinishHLSPlaylists closes every media playlist with an ENDLIST tag
+func (mgr *BasicPlaylistManager) FinishHLSPlaylists() {
+	mgr.mapSync.Lock()
+	defer mgr.mapSync.Unlock()
+	mgr.finished = true
+	for _, mpl := range mgr.mediaLists {
+		mpl.MediaType = m3u8.VOD
+		// Re-encode with the playlist type rather than appending to a cached encoding
+		mpl.ResetCache()
+		mpl.Close()
+	}
+}
+
+// GetDASHManifest returns an MPD with a representation for each fragmented MP4 rendition.
+// Live streams get a dynamic manifest over the same window as the HLS playlists
+func (mgr *BasicPlaylistManager) GetDASHManifest() ([]byte, error) {
+	mgr.mapSync.RLock()
+	defer mgr.mapSync.RUnlock()
+	return encodeMPD(mgr.dashTimelines, mgr.initURIs, mgr.dashStart, mgr.finished)
 }
 
 // GetHLSMasterPlaylist ..
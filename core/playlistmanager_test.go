@@ -2,6 +2,7 @@ package core
 
 import (
 	"bytes"
+	"fmt"
 	"net/url"
 	"testing"
 
@@ -186,3 +187,80 @@ func TestCleanup(t *testing.T) {
 		t.Fatal("Data should be cleaned up")
 	}
 }
+
+func TestVODPlaylists(t *testing.T) {
+	vProfile := &ffmpeg.P144p30fps16x9
+	segments := LIVE_LIST_LENGTH * 2
+	c := NewVODPlaylistManager(RandomManifestID(), nil, segments)
+	// insert out of order, as parallel transcoding would
+	for i := int(segments) - 1; i >= 0; i-- {
+		if err := c.InsertHLSSegment(vProfile, uint64(i), fmt.Sprintf("%d.ts", i), 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	pl := c.GetHLSMediaPlaylist(vProfile.Name)
+	if pl.Count() != segments {
+		t.Fatalf("Expected %d segments, got %d", segments, pl.Count())
+	}
+	if bytes.Contains(pl.Encode().Bytes(), []byte("#EXT-X-ENDLIST")) {
+		t.Error("Unexpected end of playlist before finishing")
+	}
+
+	c.FinishHLSPlaylists()
+	data := pl.Encode().Bytes()
+	if !bytes.Contains(data, []byte("#EXT-X-ENDLIST")) || !bytes.Contains(data, []byte("#EXT-X-PLAYLIST-TYPE:VOD")) {
+		t.Errorf("Expected a complete VOD playlist, got %s", data)
+	}
+	if pl.Segments[0].URI != "0.ts" {
+		t.Errorf("Expected segments in order, got %s first", pl.Segments[0].URI)
+	}
+
+	// live playlists keep a window
+	live := NewBasicPlaylistManager(RandomManifestID(), nil)
+	for i := uint64(0); i < uint64(segments); i++ {
+		if err := live.InsertHLSSegment(vProfile, i, fmt.Sprintf("%d.ts", i), 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if live.GetHLSMediaPlaylist(vProfile.Name).Count() != LIVE_LIST_LENGTH {
+		t.Error("Expected live playlist to keep a window of segments")
+	}
+}
+
+func TestHLSInitSegment(t *testing.T) {
+	vProfile := &ffmpeg.P144p30fps16x9
+	c := NewBasicPlaylistManager(RandomManifestID(), nil)
+	if c.GetHLSInitSegment(vProfile.Name) != "" {
+		t.Error("Unexpected init segment")
+	}
+	if err := c.InsertHLSSegment(vProfile, 0, "0.m4s", 2); err != nil {
+		t.Fatal(err)
+	}
+	pl := c.GetHLSMediaPlaylist(vProfile.Name)
+	if bytes.Contains(pl.Encode().Bytes(), []byte("#EXT-X-MAP")) {
+		t.Error("Unexpected EXT-X-MAP without an init segment")
+	}
+
+	if err := c.InsertHLSInitSegment(vProfile, "init.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	if c.GetHLSInitSegment(vProfile.Name) != "init.mp4" {
+		t.Error("Unexpected init segment ", c.GetHLSInitSegment(vProfile.Name))
+	}
+	data := pl.Encode().Bytes()
+	if !bytes.Contains(data, []byte(`#EXT-X-MAP:URI="init.mp4"`)) || !bytes.Contains(data, []byte("#EXT-X-VERSION:6")) {
+		t.Errorf("Expected EXT-X-MAP in the playlist, got %s", data)
+	}
+
+	// replaced if the orchestrator output changes
+	c.InsertHLSInitSegment(vProfile, "init2.mp4")
+	if !bytes.Contains(pl.Encode().Bytes(), []byte(`#EXT-X-MAP:URI="init2.mp4"`)) {
+		t.Error("Expected the init segment to be replaced")
+	}
+
+	// creates the playlist if necessary
+	c.InsertHLSInitSegment(&ffmpeg.P240p30fps16x9, "init.mp4")
+	if c.GetHLSMediaPlaylist(ffmpeg.P240p30fps16x9.Name) == nil {
+		t.Error("Expected a media playlist")
+	}
+}
@@ -0,0 +1,167 @@
+package core
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/monitor"
+)
+
+// Per-sender quotas that keep a single broadcaster from using up all of an
+// orchestrator's capacity. A zero value disables the quota.
+var MaxSessionsPerSender = 0
+var MaxPixelsPerMinutePerSender int64 = 0
+var MaxSegmentsPerSecondPerSender = 0.0
+
+var ErrSenderSessionQuota = errors.New("SenderSessionQuotaExceeded")
+var ErrSenderPixelQuota = errors.New("SenderPixelQuotaExceeded")
+var ErrSenderSegmentQuota = errors.New("SenderSegmentRateExceeded")
+
+const pixelQuotaWindow = time.Minute
+
+type pixelSample struct {
+	at     time.Time
+	pixels int64
+}
+
+type senderUsage struct {
+	// Token bucket for the segment rate
+	tokens  float64
+	updated time.Time
+
+	// Pixels transcoded within the last pixelQuotaWindow
+	samples []pixelSample
+	pixels  int64
+}
+
+type senderQuotas struct {
+	mu     sync.Mutex
+	usage  map[ethcommon.Address]*senderUsage
+	pruned time.Time
+	now    func() time.Time
+}
+
+func newSenderQuotas() *senderQuotas {
+	return &senderQuotas{
+		usage: make(map[ethcommon.Address]*senderUsage),
+		now:   time.Now,
+	}
+}
+
+// admit checks whether the sender may submit another segment and consumes
+// from its segment rate quota if so
+func (q *senderQuotas) admit(sender ethcommon.Address) error {
+	rate, maxPixels := MaxSegmentsPerSecondPerSender, MaxPixelsPerMinutePerSender
+	if q == nil || (rate <= 0 && maxPixels <= 0) {
+		return nil
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	q.prune(now)
+	u := q.get(sender, now)
+
+	if maxPixels > 0 {
+		u.expire(now)
+		if u.pixels >= maxPixels {
+			return quotaExceeded(sender, ErrSenderPixelQuota)
+		}
+	}
+
+	if rate > 0 {
+		u.tokens += now.Sub(u.updated).Seconds() * rate
+		if burst := segmentBurst(); u.tokens > burst {
+			u.tokens = burst
+		}
+		u.updated = now
+		if u.tokens < 1 {
+			return quotaExceeded(sender, ErrSenderSegmentQuota)
+		}
+		u.tokens--
+	}
+	return nil
+}
+
+// recordPixels counts pixels transcoded for the sender against its pixel quota
+func (q *senderQuotas) recordPixels(sender ethcommon.Address, pixels int64) {
+	if q == nil || MaxPixelsPerMinutePerSender <= 0 || pixels <= 0 {
+		return
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := q.now()
+	u := q.get(sender, now)
+	u.samples = append(u.samples, pixelSample{at: now, pixels: pixels})
+	u.pixels += pixels
+}
+
+// Expects the mutex `q.mu` to be locked by the caller.
+func (q *senderQuotas) get(sender ethcommon.Address, now time.Time) *senderUsage {
+	u, ok := q.usage[sender]
+	if !ok {
+		u = &senderUsage{tokens: segmentBurst(), updated: now}
+		q.usage[sender] = u
+	}
+	return u
+}
+
+// prune periodically drops senders that haven't been active for a while.
+// Expects the mutex `q.mu` to be locked by the caller.
+func (q *senderQuotas) prune(now time.Time) {
+	if now.Sub(q.pruned) < pixelQuotaWindow {
+		return
+	}
+	q.pruned = now
+	for sender, u := range q.usage {
+		u.expire(now)
+		if len(u.samples) == 0 && now.Sub(u.updated) >= pixelQuotaWindow {
+			delete(q.usage, sender)
+		}
+	}
+}
+
+// expire drops pixel samples that fell out of the quota window
+func (u *senderUsage) expire(now time.Time) {
+	i := 0
+	for ; i < len(u.samples) && now.Sub(u.samples[i].at) >= pixelQuotaWindow; i++ {
+		u.pixels -= u.samples[i].pixels
+	}
+	u.samples = u.samples[i:]
+}
+
+// segmentBurst returns the number of segments a sender may submit at once,
+// which is at least one so that rates below one segment per second still work
+func segmentBurst() float64 {
+	if MaxSegmentsPerSecondPerSender < 1 {
+		return 1
+	}
+	return MaxSegmentsPerSecondPerSender
+}
+
+// senderSessions returns the number of streams the sender is currently transcoding.
+// Expects the mutex `n.segmentMutex` to be locked by the caller.
+func (n *LivepeerNode) senderSessions(sender ethcommon.Address) int {
+	count := 0
+	for _, s := range n.segmentSenders {
+		if s == sender {
+			count++
+		}
+	}
+	return count
+}
+
+func quotaExceeded(sender ethcommon.Address, err error) error {
+	glog.Errorf("Quota exceeded for sender=%s err=%v", sender.Hex(), err)
+	if monitor.Enabled {
+		monitor.SenderQuotaExceeded(err.Error())
+	}
+	return err
+}
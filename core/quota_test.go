@@ -0,0 +1,158 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/go-livepeer/pm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSenderQuotas_SegmentRate(t *testing.T) {
+	defer func(rate float64) { MaxSegmentsPerSecondPerSender = rate }(MaxSegmentsPerSecondPerSender)
+	assert := assert.New(t)
+
+	now := time.Now()
+	q := newSenderQuotas()
+	q.now = func() time.Time { return now }
+	sender := pm.RandAddress()
+
+	// no quota
+	MaxSegmentsPerSecondPerSender = 0
+	for i := 0; i < 10; i++ {
+		assert.Nil(q.admit(sender))
+	}
+
+	// burst of up to the rate is allowed
+	MaxSegmentsPerSecondPerSender = 2
+	q = newSenderQuotas()
+	q.now = func() time.Time { return now }
+	assert.Nil(q.admit(sender))
+	assert.Nil(q.admit(sender))
+	assert.Equal(ErrSenderSegmentQuota, q.admit(sender))
+
+	// other senders are unaffected
+	assert.Nil(q.admit(pm.RandAddress()))
+
+	// tokens refill over time
+	now = now.Add(500 * time.Millisecond)
+	assert.Nil(q.admit(sender))
+	assert.Equal(ErrSenderSegmentQuota, q.admit(sender))
+
+	// rates below one segment per second still admit a segment
+	MaxSegmentsPerSecondPerSender = 0.5
+	q = newSenderQuotas()
+	q.now = func() time.Time { return now }
+	assert.Nil(q.admit(sender))
+	assert.Equal(ErrSenderSegmentQuota, q.admit(sender))
+	now = now.Add(2 * time.Second)
+	assert.Nil(q.admit(sender))
+}
+
+func TestSenderQuotas_Pixels(t *testing.T) {
+	defer func(pixels int64) { MaxPixelsPerMinutePerSender = pixels }(MaxPixelsPerMinutePerSender)
+	assert := assert.New(t)
+
+	now := time.Now()
+	q := newSenderQuotas()
+	q.now = func() time.Time { return now }
+	sender := pm.RandAddress()
+
+	MaxPixelsPerMinutePerSender = 1000
+	assert.Nil(q.admit(sender))
+	q.recordPixels(sender, 600)
+	now = now.Add(30 * time.Second)
+	assert.Nil(q.admit(sender))
+	q.recordPixels(sender, 400)
+	assert.Equal(ErrSenderPixelQuota, q.admit(sender))
+	assert.Nil(q.admit(pm.RandAddress()))
+
+	// the first sample falls out of the window
+	now = now.Add(30 * time.Second)
+	assert.Nil(q.admit(sender))
+	assert.Equal(int64(400), q.usage[sender].pixels)
+	assert.Len(q.usage[sender].samples, 1)
+}
+
+func TestSenderQuotas_Prune(t *testing.T) {
+	defer func(pixels int64) { MaxPixelsPerMinutePerSender = pixels }(MaxPixelsPerMinutePerSender)
+	assert := assert.New(t)
+
+	now := time.Now()
+	q := newSenderQuotas()
+	q.now = func() time.Time { return now }
+	MaxPixelsPerMinutePerSender = 1000
+
+	idle, active := pm.RandAddress(), pm.RandAddress()
+	assert.Nil(q.admit(idle))
+	assert.Nil(q.admit(active))
+	q.recordPixels(active, 100)
+	assert.Len(q.usage, 2)
+
+	now = now.Add(pixelQuotaWindow / 2)
+	q.recordPixels(active, 100)
+	now = now.Add(pixelQuotaWindow)
+	assert.Nil(q.admit(active))
+	_, ok := q.usage[idle]
+	assert.False(ok)
+	_, ok = q.usage[active]
+	assert.True(ok)
+
+	// a nil quota tracker admits everything
+	var nilQuotas *senderQuotas
+	assert.Nil(nilQuotas.admit(active))
+	nilQuotas.recordPixels(active, 100)
+}
+
+func TestOrchCheckCapacity_SenderQuota(t *testing.T) {
+	defer func(max int) { MaxSessionsPerSender = max }(MaxSessionsPerSender)
+	drivers.NodeStorage = drivers.NewMemoryDriver(nil)
+	n, _ := NewLivepeerNode(nil, "", nil)
+	o := NewOrchestrator(n, nil)
+	assert := assert.New(t)
+
+	MaxSessionsPerSender = 1
+	sender, other := pm.RandAddress(), pm.RandAddress()
+	md := StubSegTranscodingMetadata()
+	md.Sender = sender
+	_, err := n.getSegmentChan(md)
+	assert.Nil(err)
+
+	// existing stream passes, new streams from the same sender are rejected
+	assert.Nil(o.CheckCapacity(md.ManifestID, sender))
+	assert.Equal(ErrSenderSessionQuota, o.CheckCapacity(ManifestID("new"), sender))
+	assert.Nil(o.CheckCapacity(ManifestID("new"), other))
+
+	newMd := StubSegTranscodingMetadata()
+	newMd.ManifestID = ManifestID("new")
+	newMd.Sender = sender
+	_, err = n.getSegmentChan(newMd)
+	assert.Equal(ErrSenderSessionQuota, err)
+	newMd.Sender = other
+	_, err = n.getSegmentChan(newMd)
+	assert.Nil(err)
+
+	// unlimited
+	MaxSessionsPerSender = 0
+	assert.Nil(o.CheckCapacity(ManifestID("another"), sender))
+}
+
+func TestTranscodeSeg_SenderQuota(t *testing.T) {
+	defer func(rate float64) { MaxSegmentsPerSecondPerSender = rate }(MaxSegmentsPerSecondPerSender)
+	n, _ := NewLivepeerNode(nil, "", nil)
+	o := NewOrchestrator(n, nil)
+	assert := assert.New(t)
+
+	MaxSegmentsPerSecondPerSender = 1
+	md := StubSegTranscodingMetadata()
+	md.Sender = ethcommon.BytesToAddress([]byte("sender"))
+	assert.Nil(o.quotas.admit(md.Sender))
+
+	// rejected before reaching the transcode loop
+	res, err := o.TranscodeSeg(md, nil)
+	assert.Nil(res)
+	assert.Equal(ErrSenderSegmentQuota, err)
+	assert.Empty(n.SegmentChans)
+}
@@ -0,0 +1,224 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/livepeer/go-livepeer/drivers"
+	ffmpeg "github.com/livepeer/lpms/ffmpeg"
+	"github.com/livepeer/m3u8"
+)
+
+// RecordPlaylistInterval is how often the playlists of a recording are rewritten while the stream is live
+var RecordPlaylistInterval = 30 * time.Second
+
+var errRecordingMissingData = errors.New("segment data not in storage")
+
+// RecordingPlaylistManager keeps every segment of a stream along with full-length playlists
+// in object storage, in addition to the live window of the PlaylistManager it wraps.
+// Segments already in external storage are referenced rather than copied
+type RecordingPlaylistManager struct {
+	PlaylistManager
+	// Not ended with the stream; the recording outlives it
+	storage drivers.OSSession
+
+	mu         sync.Mutex
+	renditions []*recordedRendition
+	lastSave   time.Time
+	finished   bool
+
+	// Serializes playlist uploads
+	saveLock sync.Mutex
+}
+
+type recordedRendition struct {
+	name     string
+	vParams  m3u8.VariantParams
+	initURI  string
+	segments []recordedSegment
+}
+
+type recordedSegment struct {
+	seqNo    uint64
+	uri      string
+	duration float64
+}
+
+// NewRecordingPlaylistManager records the stream of pl into storage
+func NewRecordingPlaylistManager(pl PlaylistManager, storage drivers.OSSession) *RecordingPlaylistManager {
+	return &RecordingPlaylistManager{
+		PlaylistManager: pl,
+		storage:         storage,
+		lastSave:        time.Now(),
+	}
+}
+
+// InsertHLSSegment inserts the segment into the live playlists and the recording.
+// Failing to record doesn't fail the live stream
+func (r *RecordingPlaylistManager) InsertHLSSegment(profile *ffmpeg.VideoProfile, seqNo uint64, uri string, duration float64) error {
+	if err := r.PlaylistManager.InsertHLSSegment(profile, seqNo, uri, duration); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	recorded := r.finished || r.getOrCreateRendition(profile).find(seqNo) >= 0
+	r.mu.Unlock()
+	if recorded {
+		return nil
+	}
+	recURI, err := r.record(fmt.Sprintf("%s/%s", profile.Name, path.Base(uri)), uri)
+	if err != nil {
+		glog.Errorf("Error recording segment manifestID=%s profile=%s seqNo=%d err=%v", r.ManifestID(), profile.Name, seqNo, err)
+		return nil
+	}
+
+	r.mu.Lock()
+	rend := r.getOrCreateRendition(profile)
+	i := sort.Search(len(rend.segments), func(i int) bool { return rend.segments[i].seqNo >= seqNo })
+	if r.finished || (i < len(rend.segments) && rend.segments[i].seqNo == seqNo) {
+		r.mu.Unlock()
+		return nil
+	}
+	rend.segments = append(rend.segments, recordedSegment{})
+	copy(rend.segments[i+1:], rend.segments[i:])
+	rend.segments[i] = recordedSegment{seqNo: seqNo, uri: recURI, duration: duration}
+	save := time.Since(r.lastSave) >= RecordPlaylistInterval
+	if save {
+		r.lastSave = time.Now()
+	}
+	r.mu.Unlock()
+
+	if save {
+		go r.savePlaylists(false)
+	}
+	return nil
+}
+
+// InsertHLSInitSegment sets the initialization section in the live playlists and the recording
+func (r *RecordingPlaylistManager) InsertHLSInitSegment(profile *ffmpeg.VideoProfile, uri string) error {
+	if err := r.PlaylistManager.InsertHLSInitSegment(profile, uri); err != nil {
+		return err
+	}
+	recURI, err := r.record(path.Base(uri), uri)
+	if err != nil {
+		glog.Errorf("Error recording init segment manifestID=%s profile=%s err=%v", r.ManifestID(), profile.Name, err)
+		return nil
+	}
+	r.mu.Lock()
+	r.getOrCreateRendition(profile).initURI = recURI
+	r.mu.Unlock()
+	return nil
+}
+
+// FinishRecording writes the final playlists of the recording with an ENDLIST tag.
+// Segments inserted afterwards aren't recorded
+func (r *RecordingPlaylistManager) FinishRecording() error {
+	r.mu.Lock()
+	r.finished = true
+	r.mu.Unlock()
+	return r.savePlaylists(true)
+}
+
+// record copies data that only lives in memory into the recording storage and
+// returns the location the recording should reference
+func (r *RecordingPlaylistManager) record(name string, uri string) (string, error) {
+	memOS, ok := r.GetOSSession().(*drivers.MemorySession)
+	if !ok {
+		return uri, nil
+	}
+	data := memOS.GetData(uri)
+	if data == nil {
+		return "", errRecordingMissingData
+	}
+	return r.storage.SaveData(name, data)
+}
+
+// Precondition: caller holds r.mu
+func (r *RecordingPlaylistManager) getOrCreateRendition(profile *ffmpeg.VideoProfile) *recordedRendition {
+	for _, rend := range r.renditions {
+		if rend.name == profile.Name {
+			return rend
+		}
+	}
+	rend := &recordedRendition{name: profile.Name, vParams: ffmpeg.VideoProfileToVariantParams(*profile)}
+	r.renditions = append(r.renditions, rend)
+	return rend
+}
+
+// find returns the index of the segment with the sequence number, or -1
+func (rend *recordedRendition) find(seqNo uint64) int {
+	i := sort.Search(len(rend.segments), func(i int) bool { return rend.segments[i].seqNo >= seqNo })
+	if i < len(rend.segments) && rend.segments[i].seqNo == seqNo {
+		return i
+	}
+	return -1
+}
+
+// savePlaylists uploads a media playlist per rendition and a master playlist
+// referencing them. Final playlists are VOD playlists; otherwise they are EVENT playlists
+func (r *RecordingPlaylistManager) savePlaylists(final bool) error {
+	r.saveLock.Lock()
+	defer r.saveLock.Unlock()
+
+	r.mu.Lock()
+	renditions := make([]recordedRendition, len(r.renditions))
+	for i, rend := range r.renditions {
+		renditions[i] = *rend
+		renditions[i].segments = append([]recordedSegment(nil), rend.segments...)
+	}
+	r.mu.Unlock()
+
+	master := m3u8.NewMasterPlaylist()
+	for _, rend := range renditions {
+		if len(rend.segments) == 0 {
+			continue
+		}
+		mpl, err := recordedMediaPlaylist(&rend, final)
+		if err != nil {
+			return err
+		}
+		name := rend.name + ".m3u8"
+		if _, err := r.storage.SaveData(name, mpl.Encode().Bytes()); err != nil {
+			glog.Errorf("Error saving recorded playlist manifestID=%s name=%s err=%v", r.ManifestID(), name, err)
+			return err
+		}
+		master.Append(name, mpl, rend.vParams)
+	}
+	uri, err := r.storage.SaveData("index.m3u8", master.Encode().Bytes())
+	if err != nil {
+		glog.Errorf("Error saving recorded playlist manifestID=%s err=%v", r.ManifestID(), err)
+		return err
+	}
+	if final {
+		glog.Infof("Finished recording manifestID=%s playlist=%s", r.ManifestID(), uri)
+	}
+	return nil
+}
+
+func recordedMediaPlaylist(rend *recordedRendition, final bool) (*m3u8.MediaPlaylist, error) {
+	// A window of zero includes every segment
+	mpl, err := m3u8.NewMediaPlaylist(0, uint(len(rend.segments)))
+	if err != nil {
+		return nil, err
+	}
+	mpl.SeqNo = rend.segments[0].seqNo
+	mpl.MediaType = m3u8.EVENT
+	for _, seg := range rend.segments {
+		if err := mpl.AppendSegment(newMediaSegment(seg.uri, seg.duration)); err != nil {
+			return nil, err
+		}
+	}
+	if rend.initURI != "" {
+		mpl.SetDefaultMap(rend.initURI, 0, 0)
+		mpl.SetVersion(6)
+	}
+	if final {
+		mpl.MediaType = m3u8.VOD
+		mpl.Close()
+	}
+	return mpl, nil
+}
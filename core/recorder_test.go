@@ -0,0 +1,140 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/livepeer/m3u8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/go-livepeer/net"
+	ffmpeg "github.com/livepeer/lpms/ffmpeg"
+)
+
+func decodeRecordedPlaylist(t *testing.T, storage *drivers.MemorySession, mid ManifestID, name string) *m3u8.MediaPlaylist {
+	data := storage.GetData(string(mid) + "/recording/" + name)
+	require.NotNil(t, data, name)
+	pl, _ := m3u8.NewMediaPlaylist(0, 64)
+	require.Nil(t, pl.DecodeFrom(bytes.NewBuffer(data), true))
+	return pl
+}
+
+func TestRecordingPlaylistManager(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mid := RandomManifestID()
+	driver := drivers.NewMemoryDriver(nil)
+	live := driver.NewSession(string(mid))
+	rec := driver.NewSession(string(mid) + "/recording").(*drivers.MemorySession)
+	c := NewRecordingPlaylistManager(NewBasicPlaylistManager(mid, live), rec)
+
+	source := &ffmpeg.VideoProfile{Name: "source", Resolution: "1920x1080", Bitrate: "6000k"}
+	rendition := &ffmpeg.P144p30fps16x9
+	total := int(LIVE_LIST_LENGTH) + 2
+	for i := 0; i < total; i++ {
+		for _, p := range []*ffmpeg.VideoProfile{source, rendition} {
+			uri, err := live.SaveData(fmt.Sprintf("%s/%d.ts", p.Name, i), []byte(fmt.Sprintf("%s%d", p.Name, i)))
+			require.Nil(err)
+			require.Nil(c.InsertHLSSegment(p, uint64(i), uri, 2))
+		}
+	}
+	// duplicates are ignored
+	uri, _ := live.SaveData(rendition.Name+"/0.ts", []byte("dup"))
+	require.Nil(c.InsertHLSSegment(rendition, 0, uri, 2))
+
+	// the live playlist keeps its window
+	assert.Len(segmentsOf(c.GetHLSMediaPlaylist(rendition.Name)), int(LIVE_LIST_LENGTH))
+
+	require.Nil(c.FinishRecording())
+	pl := decodeRecordedPlaylist(t, rec, mid, rendition.Name+".m3u8")
+	assert.False(pl.Live)
+	assert.Equal(m3u8.VOD, pl.MediaType)
+	assert.Equal(uint64(0), pl.SeqNo)
+	segs := segmentsOf(pl)
+	require.Len(segs, total)
+	for i, seg := range segs {
+		// copied out of the live storage
+		assert.Equal([]byte(fmt.Sprintf("%s%d", rendition.Name, i)), rec.GetData(seg.URI))
+		assert.Equal(2.0, seg.Duration)
+	}
+
+	master := m3u8.NewMasterPlaylist()
+	data := rec.GetData(string(mid) + "/recording/index.m3u8")
+	require.NotNil(data)
+	require.Nil(master.DecodeFrom(bytes.NewBuffer(data), true))
+	require.Len(master.Variants, 2)
+	assert.Equal("source.m3u8", master.Variants[0].URI)
+	assert.Equal("1920x1080", master.Variants[0].Resolution)
+	assert.Equal(rendition.Name+".m3u8", master.Variants[1].URI)
+
+	// segments after the end aren't recorded
+	uri, _ = live.SaveData(rendition.Name+"/100.ts", []byte("late"))
+	require.Nil(c.InsertHLSSegment(rendition, 100, uri, 2))
+	require.Nil(c.FinishRecording())
+	assert.Len(segmentsOf(decodeRecordedPlaylist(t, rec, mid, rendition.Name+".m3u8")), total)
+}
+
+func TestRecordingPlaylistManager_FMP4AndMissingData(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mid := RandomManifestID()
+	driver := drivers.NewMemoryDriver(nil)
+	live := driver.NewSession(string(mid))
+	rec := driver.NewSession(string(mid) + "/recording").(*drivers.MemorySession)
+	c := NewRecordingPlaylistManager(NewBasicPlaylistManager(mid, live), rec)
+	rendition := &ffmpeg.P144p30fps16x9
+
+	initURI, _ := live.SaveData(rendition.Name+"_init_01.mp4", []byte("init"))
+	require.Nil(c.InsertHLSInitSegment(rendition, initURI))
+	uri, _ := live.SaveData(rendition.Name+"/3.m4s", []byte("media"))
+	require.Nil(c.InsertHLSSegment(rendition, 3, uri, 2))
+	// data missing from the live storage still goes live but isn't recorded
+	require.Nil(c.InsertHLSSegment(rendition, 4, "missing.m4s", 2))
+	assert.Len(segmentsOf(c.GetHLSMediaPlaylist(rendition.Name)), 2)
+
+	require.Nil(c.savePlaylists(false))
+	pl := decodeRecordedPlaylist(t, rec, mid, rendition.Name+".m3u8")
+	assert.True(pl.Live)
+	assert.Equal(m3u8.EVENT, pl.MediaType)
+	assert.Equal(uint64(3), pl.SeqNo)
+	require.NotNil(pl.Map)
+	assert.Equal([]byte("init"), rec.GetData(pl.Map.URI))
+	segs := segmentsOf(pl)
+	require.Len(segs, 1)
+	assert.Equal([]byte("media"), rec.GetData(segs[0].URI))
+}
+
+func TestRecordingPlaylistManager_ExternalStorage(t *testing.T) {
+	// segments in external storage are referenced instead of copied
+	mid := RandomManifestID()
+	rec := drivers.NewMemoryDriver(nil).NewSession(string(mid) + "/recording").(*drivers.MemorySession)
+	c := NewRecordingPlaylistManager(NewBasicPlaylistManager(mid, &stubOSSession{}), rec)
+	uri := "https://bucket.example.com/" + string(mid) + "/source/0.ts"
+	require.Nil(t, c.InsertHLSSegment(&ffmpeg.VideoProfile{Name: "source"}, 0, uri, 2))
+	require.Nil(t, c.FinishRecording())
+	segs := segmentsOf(decodeRecordedPlaylist(t, rec, mid, "source.m3u8"))
+	require.Len(t, segs, 1)
+	assert.Equal(t, uri, segs[0].URI)
+}
+
+func segmentsOf(pl *m3u8.MediaPlaylist) []*m3u8.MediaSegment {
+	var segs []*m3u8.MediaSegment
+	for _, seg := range pl.Segments {
+		if seg != nil {
+			segs = append(segs, seg)
+		}
+	}
+	return segs
+}
+
+type stubOSSession struct{}
+
+func (s *stubOSSession) SaveData(name string, data []byte) (string, error) { return name, nil }
+func (s *stubOSSession) EndSession()                                       {}
+func (s *stubOSSession) GetInfo() *net.OSInfo                              { return nil }
+func (s *stubOSSession) IsExternal() bool                                  { return true }
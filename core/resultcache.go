@@ -0,0 +1,111 @@
+package core
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/livepeer/go-livepeer/common"
+)
+
+// Bounds of the cache of transcode results that answers retried segments without
+// transcoding them again. A ResultCacheSize of zero disables the cache.
+var ResultCacheSize int64 = 0 // bytes of transcoded data
+var ResultCacheTTL = 10 * time.Minute
+
+// resultCache is an LRU cache of successful transcode results keyed by the
+// source segment hash and the profiles it was transcoded into
+type resultCache struct {
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently used
+	size    int64
+	now     func() time.Time
+}
+
+type resultCacheEntry struct {
+	key     string
+	sig     []byte
+	tData   *TranscodeData
+	size    int64
+	expires time.Time
+}
+
+func newResultCache() *resultCache {
+	return &resultCache{
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// resultCacheKey identifies a transcode result. The hash has been checked against
+// the segment data when the segment was received
+func resultCacheKey(md *SegTranscodingMetadata) string {
+	return md.Hash.Hex() + common.ProfilesToHex(md.Profiles) + md.Format.String()
+}
+
+// get returns a copy of the cached result for the segment, if any. The
+// transcoded data is shared with the cache and must not be modified
+func (c *resultCache) get(md *SegTranscodingMetadata) *TranscodeResult {
+	if c == nil || ResultCacheSize <= 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[resultCacheKey(md)]
+	if !ok {
+		return nil
+	}
+	e := el.Value.(*resultCacheEntry)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return nil
+	}
+	c.lru.MoveToFront(el)
+	return &TranscodeResult{Sig: e.sig, TranscodeData: e.tData}
+}
+
+// add caches a successful transcode result for the segment
+func (c *resultCache) add(md *SegTranscodingMetadata, res *TranscodeResult) {
+	if c == nil || ResultCacheSize <= 0 || res.Err != nil || res.TranscodeData == nil {
+		return
+	}
+	var size int64
+	for _, s := range res.TranscodeData.Segments {
+		size += int64(len(s.Data))
+	}
+	if size > ResultCacheSize {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := resultCacheKey(md)
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+	e := &resultCacheEntry{
+		key:     key,
+		sig:     res.Sig,
+		tData:   res.TranscodeData,
+		size:    size,
+		expires: c.now().Add(ResultCacheTTL),
+	}
+	c.entries[key] = c.lru.PushFront(e)
+	c.size += size
+
+	for c.size > ResultCacheSize {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Expects the mutex `c.mu` to be locked by the caller.
+func (c *resultCache) remove(el *list.Element) {
+	e := c.lru.Remove(el).(*resultCacheEntry)
+	delete(c.entries, e.key)
+	c.size -= e.size
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/go-livepeer/eth"
+	"github.com/livepeer/lpms/ffmpeg"
+	"github.com/stretchr/testify/assert"
+)
+
+func cacheTestResult(size int) *TranscodeResult {
+	return &TranscodeResult{
+		Sig:           []byte("sig"),
+		TranscodeData: &TranscodeData{Segments: []*TranscodedSegmentData{{Data: make([]byte, size), Pixels: 1}}},
+	}
+}
+
+func cacheTestMetadata(hash string) *SegTranscodingMetadata {
+	return &SegTranscodingMetadata{Hash: ethcommon.BytesToHash([]byte(hash)), Profiles: videoProfiles}
+}
+
+func TestResultCache(t *testing.T) {
+	defer func(size int64, ttl time.Duration) { ResultCacheSize, ResultCacheTTL = size, ttl }(ResultCacheSize, ResultCacheTTL)
+	assert := assert.New(t)
+
+	now := time.Now()
+	c := newResultCache()
+	c.now = func() time.Time { return now }
+	md := cacheTestMetadata("a")
+
+	// disabled
+	ResultCacheSize = 0
+	c.add(md, cacheTestResult(10))
+	assert.Nil(c.get(md))
+	assert.Empty(c.entries)
+
+	// hit
+	ResultCacheSize = 25
+	ResultCacheTTL = time.Minute
+	c.add(md, cacheTestResult(10))
+	res := c.get(md)
+	assert.NotNil(res)
+	assert.Equal([]byte("sig"), res.Sig)
+	assert.Len(res.TranscodeData.Segments, 1)
+
+	// different profiles or source miss
+	assert.Nil(c.get(&SegTranscodingMetadata{Hash: md.Hash, Profiles: []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}}))
+	assert.Nil(c.get(cacheTestMetadata("b")))
+
+	// failed and oversized results aren't cached
+	c.add(cacheTestMetadata("b"), &TranscodeResult{Err: errors.New("err")})
+	c.add(cacheTestMetadata("b"), cacheTestResult(26))
+	assert.Nil(c.get(cacheTestMetadata("b")))
+
+	// least recently used entries are evicted once full
+	c.add(cacheTestMetadata("b"), cacheTestResult(10))
+	assert.NotNil(c.get(md))
+	c.add(cacheTestMetadata("c"), cacheTestResult(10))
+	assert.Nil(c.get(cacheTestMetadata("b")))
+	assert.NotNil(c.get(md))
+	assert.NotNil(c.get(cacheTestMetadata("c")))
+	assert.Equal(int64(20), c.size)
+
+	// replacing an entry doesn't count it twice
+	c.add(md, cacheTestResult(5))
+	assert.Equal(int64(15), c.size)
+	assert.Len(c.entries, 2)
+
+	// expiry
+	now = now.Add(time.Minute)
+	assert.Nil(c.get(md))
+	assert.Equal(int64(10), c.size)
+	assert.Len(c.entries, 1)
+
+	// nil cache
+	var nilCache *resultCache
+	nilCache.add(md, cacheTestResult(1))
+	assert.Nil(nilCache.get(md))
+}
+
+func TestTranscodeSeg_ResultCache(t *testing.T) {
+	defer func(size int64) { ResultCacheSize = size }(ResultCacheSize)
+	assert := assert.New(t)
+	tmp, _ := ioutil.TempDir("", "")
+	defer os.RemoveAll(tmp)
+
+	n, _ := NewLivepeerNode(&eth.StubClient{}, tmp, nil)
+	tc := stubTranscoderWithProfiles(videoProfiles)
+	n.Transcoder = tc
+	conf := transcodeConfig{LocalOS: (drivers.NewMemoryDriver(nil)).NewSession("")}
+	seg := StubSegment()
+	md := cacheTestMetadata("a")
+
+	ResultCacheSize = 1024 * 1024
+	res := n.transcodeSeg(conf, seg, md)
+	assert.Nil(res.Err)
+	assert.Equal(1, tc.SegCount)
+
+	// retries are answered from the cache with the same renditions and signature
+	conf.OS = (drivers.NewMemoryDriver(nil)).NewSession("retry")
+	cached := n.transcodeSeg(conf, seg, md)
+	assert.Nil(cached.Err)
+	assert.Equal(1, tc.SegCount)
+	assert.Equal(res.Sig, cached.Sig)
+	assert.Equal(res.TranscodeData, cached.TranscodeData)
+	assert.Equal(conf.OS, cached.OS)
+
+	// a different profile set is transcoded
+	md2 := &SegTranscodingMetadata{Hash: md.Hash, Profiles: videoProfiles[:1]}
+	tc.Profiles = md2.Profiles
+	assert.Nil(n.transcodeSeg(conf, seg, md2).Err)
+	assert.Equal(2, tc.SegCount)
+
+	// failures aren't cached
+	tc.FailTranscode = true
+	md3 := cacheTestMetadata("c")
+	assert.Equal(ErrTranscode, n.transcodeSeg(conf, seg, md3).Err)
+	tc.FailTranscode = false
+	tc.Profiles = videoProfiles
+	assert.Nil(n.transcodeSeg(conf, seg, md3).Err)
+	assert.Equal(3, tc.SegCount)
+}
@@ -23,6 +23,34 @@ func TestSegmentFlatten(t *testing.T) {
 	if !bytes.Equal(ethcrypto.Keccak256(md.Flatten()), sHash) {
 		t.Error("Flattened segment + hash did not match expected hash")
 	}
+
+	// MPEG-TS segments sign the same data as before the format was introduced
+	md.Format = FormatMPEGTS
+	if !bytes.Equal(ethcrypto.Keccak256(md.Flatten()), sHash) {
+		t.Error("Flattened MPEG-TS segment changed")
+	}
+	md.Format = FormatFMP4
+	if bytes.Equal(ethcrypto.Keccak256(md.Flatten()), sHash) {
+		t.Error("Expected the format to be signed over")
+	}
+}
+
+func TestParseSegmentFormat(t *testing.T) {
+	for s, expected := range map[string]SegmentFormat{
+		"": FormatMPEGTS, "ts": FormatMPEGTS, "MPEGTS": FormatMPEGTS,
+		"fmp4": FormatFMP4, "cmaf": FormatFMP4, "mp4": FormatFMP4,
+	} {
+		f, err := ParseSegmentFormat(s)
+		if err != nil || f != expected {
+			t.Errorf("Unexpected format for %s: %v %v", s, f, err)
+		}
+	}
+	if _, err := ParseSegmentFormat("webm"); err != ErrSegmentFormat {
+		t.Error("Expected an error for an unknown format ", err)
+	}
+	if FormatFMP4.Ext() != ".mp4" || FormatFMP4.ContentType() != "video/mp4" || FormatMPEGTS.Ext() != ".ts" {
+		t.Error("Unexpected format properties")
+	}
 }
 
 func TestRandomIdGenerator(t *testing.T) {
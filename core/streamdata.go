@@ -15,27 +15,80 @@ import (
 )
 
 var ErrManifestID = errors.New("ErrManifestID")
+var ErrSegmentFormat = errors.New("ErrSegmentFormat")
 
 const (
 	DefaultManifestIDLength = 4
 )
 
+// SegmentFormat is the container format of transcoded segments
+type SegmentFormat int
+
+const (
+	FormatMPEGTS SegmentFormat = iota
+	// Fragmented MP4 (CMAF); the initialization section is served separately
+	FormatFMP4
+)
+
+// ParseSegmentFormat parses the format names accepted in flags and webhook responses
+func ParseSegmentFormat(s string) (SegmentFormat, error) {
+	switch strings.ToLower(s) {
+	case "", "ts", "mpegts":
+		return FormatMPEGTS, nil
+	case "fmp4", "cmaf", "mp4":
+		return FormatFMP4, nil
+	}
+	return FormatMPEGTS, ErrSegmentFormat
+}
+
+func (f SegmentFormat) String() string {
+	if f == FormatFMP4 {
+		return "fmp4"
+	}
+	return "ts"
+}
+
+// Ext returns the file extension of complete transcoded segments
+func (f SegmentFormat) Ext() string {
+	if f == FormatFMP4 {
+		return ".mp4"
+	}
+	return ".ts"
+}
+
+// ContentType returns the MIME type of transcoded segments
+func (f SegmentFormat) ContentType() string {
+	if f == FormatFMP4 {
+		return "video/mp4"
+	}
+	return "video/MP2T"
+}
+
 type SegTranscodingMetadata struct {
 	ManifestID ManifestID
 	Seq        int64
 	Hash       ethcommon.Hash
 	Profiles   []ffmpeg.VideoProfile
+	Format     SegmentFormat
 	OS         *net.OSInfo
+	Sender     ethcommon.Address // Broadcaster that submitted the segment; not signed over
 }
 
 func (md *SegTranscodingMetadata) Flatten() []byte {
 	profiles := common.ProfilesToHex(md.Profiles)
 	seq := big.NewInt(md.Seq).Bytes()
-	buf := make([]byte, len(md.ManifestID)+32+len(md.Hash.Bytes())+len(profiles))
+	// The format is only signed over when it isn't the default so signatures
+	// for MPEG-TS segments stay compatible with nodes that predate it
+	var format []byte
+	if md.Format != FormatMPEGTS {
+		format = []byte(md.Format.String())
+	}
+	buf := make([]byte, len(md.ManifestID)+32+len(md.Hash.Bytes())+len(profiles)+len(format))
 	i := copy(buf[0:], []byte(md.ManifestID))
 	i += copy(buf[i:], ethcommon.LeftPadBytes(seq, 32))
 	i += copy(buf[i:], md.Hash.Bytes())
 	i += copy(buf[i:], []byte(profiles))
+	i += copy(buf[i:], format)
 	// i += copy(buf[i:], []byte(s.OS))
 	return buf
 }
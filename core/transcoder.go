@@ -17,20 +17,20 @@ import (
 )
 
 type Transcoder interface {
-	Transcode(job string, fname string, profiles []ffmpeg.VideoProfile) (*TranscodeData, error)
+	Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format SegmentFormat) (*TranscodeData, error)
 }
 
 type LocalTranscoder struct {
 	workDir string
 }
 
-func (lt *LocalTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile) (*TranscodeData, error) {
+func (lt *LocalTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format SegmentFormat) (*TranscodeData, error) {
 	// Set up in / out config
 	in := &ffmpeg.TranscodeOptionsIn{
 		Fname: fname,
 		Accel: ffmpeg.Software,
 	}
-	opts := profilesToTranscodeOptions(lt.workDir, ffmpeg.Software, profiles)
+	opts := profilesToTranscodeOptions(lt.workDir, ffmpeg.Software, profiles, format)
 
 	_, seqNo, parseErr := parseURI(fname)
 	start := time.Now()
@@ -61,14 +61,14 @@ type NvidiaTranscoder struct {
 	session *ffmpeg.Transcoder
 }
 
-func (nv *NvidiaTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile) (*TranscodeData, error) {
+func (nv *NvidiaTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format SegmentFormat) (*TranscodeData, error) {
 	// Set up in / out config
 	in := &ffmpeg.TranscodeOptionsIn{
 		Fname:  fname,
 		Accel:  ffmpeg.Nvidia,
 		Device: nv.device,
 	}
-	opts := profilesToTranscodeOptions(nv.workDir, ffmpeg.Nvidia, profiles)
+	opts := profilesToTranscodeOptions(nv.workDir, ffmpeg.Nvidia, profiles, format)
 
 	// Do the Transcoding
 	res, err := nv.session.Transcode(in, opts)
@@ -128,15 +128,25 @@ func resToTranscodeData(res *ffmpeg.TranscodeResults, opts []ffmpeg.TranscodeOpt
 	}, nil
 }
 
-func profilesToTranscodeOptions(workDir string, accel ffmpeg.Acceleration, profiles []ffmpeg.VideoProfile) []ffmpeg.TranscodeOptions {
+// Fragmented MP4 output with a single initialization section up front, so the
+// broadcaster can split it off into the EXT-X-MAP of the rendition playlist
+var fmp4Muxer = ffmpeg.ComponentOptions{
+	Name: "mp4",
+	Opts: map[string]string{"movflags": "frag_keyframe+empty_moov+default_base_moof"},
+}
+
+func profilesToTranscodeOptions(workDir string, accel ffmpeg.Acceleration, profiles []ffmpeg.VideoProfile, format SegmentFormat) []ffmpeg.TranscodeOptions {
 	opts := make([]ffmpeg.TranscodeOptions, len(profiles), len(profiles))
 	for i := range profiles {
 		o := ffmpeg.TranscodeOptions{
-			Oname:        fmt.Sprintf("%s/out_%s.ts", workDir, common.RandName()),
+			Oname:        fmt.Sprintf("%s/out_%s%s", workDir, common.RandName(), format.Ext()),
 			Profile:      profiles[i],
 			Accel:        accel,
 			AudioEncoder: ffmpeg.ComponentOptions{Name: "copy"},
 		}
+		if format == FormatFMP4 {
+			o.Muxer = fmp4Muxer
+		}
 		opts[i] = o
 	}
 	return opts
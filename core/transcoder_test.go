@@ -18,7 +18,7 @@ func TestLocalTranscoder(t *testing.T) {
 	ffmpeg.InitFFmpeg()
 
 	profiles := []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9, ffmpeg.P240p30fps16x9}
-	res, err := tc.Transcode("", "test.ts", profiles)
+	res, err := tc.Transcode("", "test.ts", profiles, FormatMPEGTS)
 	if err != nil {
 		t.Error("Error transcoding ", err)
 	}
@@ -47,7 +47,7 @@ func TestNvidiaTranscoder(t *testing.T) {
 
 	// transcoding should fail due to invalid devices
 	profiles := []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9, ffmpeg.P240p30fps16x9}
-	_, err := tc.Transcode("", fname, profiles)
+	_, err := tc.Transcode("", fname, profiles, FormatMPEGTS)
 	if err == nil ||
 		(err.Error() != "Unknown error occurred" &&
 			err.Error() != "Cannot allocate memory") {
@@ -60,7 +60,7 @@ func TestNvidiaTranscoder(t *testing.T) {
 		return
 	}
 	tc = NewNvidiaTranscoder(dev, tmp)
-	res, err := tc.Transcode("", fname, profiles)
+	res, err := tc.Transcode("", fname, profiles, FormatMPEGTS)
 	if err != nil {
 		t.Error(err)
 	}
@@ -159,12 +159,12 @@ func TestProfilesToTranscodeOptions(t *testing.T) {
 
 	// Test 0 profiles
 	profiles := []ffmpeg.VideoProfile{}
-	opts := profilesToTranscodeOptions(workDir, ffmpeg.Software, profiles)
+	opts := profilesToTranscodeOptions(workDir, ffmpeg.Software, profiles, FormatMPEGTS)
 	assert.Equal(0, len(opts))
 
 	// Test 1 profile
 	profiles = []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}
-	opts = profilesToTranscodeOptions(workDir, ffmpeg.Software, profiles)
+	opts = profilesToTranscodeOptions(workDir, ffmpeg.Software, profiles, FormatMPEGTS)
 	assert.Equal(1, len(opts))
 	assert.Equal("foo/out_bar.ts", opts[0].Oname)
 	assert.Equal(ffmpeg.Software, opts[0].Accel)
@@ -173,7 +173,7 @@ func TestProfilesToTranscodeOptions(t *testing.T) {
 
 	// Test > 1 profile
 	profiles = []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9, ffmpeg.P240p30fps16x9}
-	opts = profilesToTranscodeOptions(workDir, ffmpeg.Software, profiles)
+	opts = profilesToTranscodeOptions(workDir, ffmpeg.Software, profiles, FormatMPEGTS)
 	assert.Equal(2, len(opts))
 
 	for i, p := range profiles {
@@ -184,7 +184,7 @@ func TestProfilesToTranscodeOptions(t *testing.T) {
 	}
 
 	// Test different acceleration value
-	opts = profilesToTranscodeOptions(workDir, ffmpeg.Nvidia, profiles)
+	opts = profilesToTranscodeOptions(workDir, ffmpeg.Nvidia, profiles, FormatMPEGTS)
 	assert.Equal(2, len(opts))
 
 	for i, p := range profiles {
@@ -193,6 +193,15 @@ func TestProfilesToTranscodeOptions(t *testing.T) {
 		assert.Equal(p, opts[i].Profile)
 		assert.Equal("copy", opts[i].AudioEncoder.Name)
 	}
+
+	// Test fragmented MP4 output
+	opts = profilesToTranscodeOptions(workDir, ffmpeg.Software, profiles, FormatFMP4)
+	assert.Equal(2, len(opts))
+	for i := range profiles {
+		assert.Equal("foo/out_bar.mp4", opts[i].Oname)
+		assert.Equal("mp4", opts[i].Muxer.Name)
+		assert.Contains(opts[i].Muxer.Opts["movflags"], "frag_keyframe")
+	}
 }
 
 func TestAudioCopy(t *testing.T) {
@@ -216,7 +225,7 @@ func TestAudioCopy(t *testing.T) {
 	assert.Nil(err)
 
 	profs := []ffmpeg.VideoProfile{ffmpeg.P720p30fps16x9} // dummy
-	res, err := tc.Transcode("", audioSample, profs)
+	res, err := tc.Transcode("", audioSample, profs, FormatMPEGTS)
 	assert.Nil(err)
 
 	o, err := ioutil.ReadFile(audioSample)
@@ -90,13 +90,13 @@ func (dbo *DBOrchestratorPoolCache) GetURLs() []*url.URL {
 	return uris
 }
 
-func (dbo *DBOrchestratorPoolCache) GetOrchestrators(numOrchestrators int) ([]*net.OrchestratorInfo, error) {
+func (dbo *DBOrchestratorPoolCache) GetOrchestrators(numOrchestrators int, pred func(*net.OrchestratorInfo) bool) ([]*net.OrchestratorInfo, error) {
 	uris, err := dbo.getURLs()
 	if err != nil || len(uris) <= 0 {
 		return nil, err
 	}
 
-	pred := func(info *net.OrchestratorInfo) bool {
+	validate := func(info *net.OrchestratorInfo) bool {
 
 		if err := dbo.ticketParamsValidator.ValidateTicketParams(pmTicketParams(info.TicketParams)); err != nil {
 			return false
@@ -110,9 +110,9 @@ func (dbo *DBOrchestratorPoolCache) GetOrchestrators(numOrchestrators int) ([]*n
 		return true
 	}
 
-	orchPool := NewOrchestratorPoolWithPred(dbo.bcast, uris, pred)
+	orchPool := NewOrchestratorPoolWithPred(dbo.bcast, uris, validate)
 
-	orchInfos, err := orchPool.GetOrchestrators(numOrchestrators)
+	orchInfos, err := orchPool.GetOrchestrators(numOrchestrators, pred)
 	if err != nil || len(orchInfos) <= 0 {
 		return nil, err
 	}
@@ -3,8 +3,10 @@ package discovery
 import (
 	"context"
 	"math"
+	"math/big"
 	"math/rand"
 	"net/url"
+	"sort"
 	"sync"
 	"time"
 
@@ -21,13 +23,17 @@ const getOrchestratorsTimeoutLoop = 3 * time.Second
 var serverGetOrchInfo = server.GetOrchestratorInfo
 
 type orchestratorPool struct {
-	uris  []*url.URL
-	pred  func(info *net.OrchestratorInfo) bool
-	bcast common.Broadcaster
+	uris []*url.URL
+	// What the webhook specifies about the orchestrators, by host
+	params map[string]*orchParams
+	pred   func(info *net.OrchestratorInfo) bool
+	bcast  common.Broadcaster
 }
 
 var perm = func(len int) []int { return rand.Perm(len) }
 
+var randFloat = rand.Float64
+
 func NewOrchestratorPool(bcast common.Broadcaster, uris []*url.URL) *orchestratorPool {
 	if len(uris) <= 0 {
 		// Should we return here?
@@ -53,61 +59,194 @@ func (o *orchestratorPool) GetURLs() []*url.URL {
 	return o.uris
 }
 
-func (o *orchestratorPool) GetOrchestrators(numOrchestrators int) ([]*net.OrchestratorInfo, error) {
-	numAvailableOrchs := len(o.uris)
+func (o *orchestratorPool) GetOrchestrators(numOrchestrators int, pred func(*net.OrchestratorInfo) bool) ([]*net.OrchestratorInfo, error) {
+	// Read the lists once, as they may be replaced while the requests below are running
+	lists := server.OrchLists
+	uris, ranked := rankURIs(allowedURIs(lists, o.uris), o.params)
+	if len(uris) == 0 {
+		return []*net.OrchestratorInfo{}, nil
+	}
+	numAvailableOrchs := len(uris)
 	numOrchestrators = int(math.Min(float64(numAvailableOrchs), float64(numOrchestrators)))
 	ctx, cancel := context.WithTimeout(context.Background(), getOrchestratorsTimeoutLoop)
 	orchInfos := []*net.OrchestratorInfo{}
-	orchChan := make(chan struct{}, len(o.uris))
+	// Responses by rank of the orchestrator, if the pool is ranked
+	rankedInfos := make([]*net.OrchestratorInfo, len(uris))
+	responded := make([]bool, len(uris))
+	orchChan := make(chan struct{}, len(uris))
 	numResp := 0
 	numSuccessResp := 0
 	respLock := sync.Mutex{}
 
-	getOrchInfo := func(uri *url.URL) {
+	getOrchInfo := func(i int, uri *url.URL) {
 		info, err := serverGetOrchInfo(ctx, o.bcast, uri)
 		respLock.Lock()
 		defer respLock.Unlock()
 		numResp++
-		if err == nil && (o.pred == nil || o.pred(info)) {
+		responded[i] = true
+		if err == nil && lists.Allowed(info) && o.withinMaxPrice(uri, info) && (o.pred == nil || o.pred(info)) && (pred == nil || pred(info)) {
 			orchInfos = append(orchInfos, info)
+			rankedInfos[i] = info
 			numSuccessResp++
 		}
 		if err != nil && monitor.Enabled {
 			monitor.LogDiscoveryError(err.Error())
 		}
-		if numSuccessResp >= numOrchestrators || numResp >= len(o.uris) {
+		// A ranked pool waits for the preferred orchestrators rather than the fastest to respond
+		done := numSuccessResp >= numOrchestrators
+		if ranked {
+			done = bestResponded(responded, rankedInfos, numOrchestrators)
+		}
+		if done || numResp >= len(uris) {
 			orchChan <- struct{}{}
 		}
 	}
 
-	for _, uri := range o.uris {
-		go getOrchInfo(uri)
+	for i, uri := range uris {
+		go getOrchInfo(i, uri)
 	}
 
-	select {
-	case <-ctx.Done():
+	collect := func() []*net.OrchestratorInfo {
 		respLock.Lock()
-		if len(orchInfos) < numOrchestrators {
-			numOrchestrators = len(orchInfos)
+		defer respLock.Unlock()
+		infos := orchInfos
+		if ranked {
+			infos = []*net.OrchestratorInfo{}
+			for _, info := range rankedInfos {
+				if info != nil {
+					infos = append(infos, info)
+				}
+			}
 		}
-		returnOrchs := orchInfos[:numOrchestrators]
-		respLock.Unlock()
+		if len(infos) < numOrchestrators {
+			numOrchestrators = len(infos)
+		}
+		return infos[:numOrchestrators]
+	}
+
+	select {
+	case <-ctx.Done():
+		returnOrchs := collect()
 		glog.Info("Done fetching orch info for orchestrators, context timeout: ", len(returnOrchs))
 		cancel()
 		return returnOrchs, nil
 	case <-orchChan:
-		respLock.Lock()
-		if len(orchInfos) < numOrchestrators {
-			numOrchestrators = len(orchInfos)
-		}
-		returnOrchs := orchInfos[:numOrchestrators]
-		respLock.Unlock()
+		returnOrchs := collect()
 		glog.Info("Done fetching orch info for orchestrators, numResponses fetched: ", len(returnOrchs))
 		cancel()
 		return returnOrchs, nil
 	}
 }
 
+// allowedURIs returns the URIs that the orchestrator lists don't exclude by host
+func allowedURIs(lists *server.OrchestratorLists, uris []*url.URL) []*url.URL {
+	allowed := make([]*url.URL, 0, len(uris))
+	for _, uri := range uris {
+		if lists.AllowedURI(uri) {
+			allowed = append(allowed, uri)
+		}
+	}
+	return allowed
+}
+
+// withinMaxPrice returns whether the orchestrator's price is at most the max price the webhook set for it, if any
+func (o *orchestratorPool) withinMaxPrice(uri *url.URL, info *net.OrchestratorInfo) bool {
+	p := o.params[rttKey(uri)]
+	if p == nil || p.maxPrice == nil {
+		return true
+	}
+	priceInfo := info.GetPriceInfo()
+	if priceInfo == nil || priceInfo.PixelsPerUnit == 0 {
+		return false
+	}
+	return big.NewRat(priceInfo.PricePerUnit, priceInfo.PixelsPerUnit).Cmp(p.maxPrice) <= 0
+}
+
+// rankURIs orders uris by preference: by priority, then orchestrators in the broadcaster's region,
+// then those reachable on their last probe by average round trip time divided by their weight, then
+// the others in a random order that favours higher weights. It returns false if there is nothing to rank by
+func rankURIs(uris []*url.URL, params map[string]*orchParams) ([]*url.URL, bool) {
+	byParams := false
+	for _, p := range params {
+		if p.priority != 0 || p.weight != 1 || (Region != "" && p.region != "") {
+			byParams = true
+			break
+		}
+	}
+	if Prober == nil && !byParams {
+		return uris, false
+	}
+
+	type rank struct {
+		uri       *url.URL
+		priority  int
+		regional  bool
+		reachable bool
+		rtt       float64
+		// Weighted random sampling key; higher is preferred
+		key float64
+	}
+	ranks := make([]rank, len(uris))
+	for i, uri := range uris {
+		r := &ranks[i]
+		r.uri = uri
+		weight := 1.0
+		if p := params[rttKey(uri)]; p != nil {
+			r.priority = p.priority
+			r.regional = Region != "" && p.region == Region
+			weight = p.weight
+		}
+		r.key = math.Pow(randFloat(), 1/weight)
+		if Prober == nil {
+			continue
+		}
+		if stats := Prober.RTT(uri); stats != nil && stats.Samples > 0 && stats.Failures == 0 {
+			r.reachable = true
+			r.rtt = float64(stats.Average) / weight
+		}
+	}
+	sort.SliceStable(ranks, func(i, j int) bool {
+		a, b := ranks[i], ranks[j]
+		if a.priority != b.priority {
+			return a.priority < b.priority
+		}
+		if a.regional != b.regional {
+			return a.regional
+		}
+		if a.reachable != b.reachable {
+			return a.reachable
+		}
+		if a.reachable && a.rtt != b.rtt {
+			return a.rtt < b.rtt
+		}
+		return a.key > b.key
+	})
+
+	ranked := make([]*url.URL, len(ranks))
+	for i, r := range ranks {
+		ranked[i] = r.uri
+	}
+	return ranked, true
+}
+
+// bestResponded returns whether the n most preferred of the orchestrators that passed are known,
+// that is every orchestrator ranked ahead of them has responded
+func bestResponded(responded []bool, infos []*net.OrchestratorInfo, n int) bool {
+	found := 0
+	for i := range responded {
+		if found >= n {
+			return true
+		}
+		if !responded[i] {
+			return false
+		}
+		if infos[i] != nil {
+			found++
+		}
+	}
+	return true
+}
+
 func (o *orchestratorPool) Size() int {
 	return len(o.uris)
 }
@@ -7,6 +7,7 @@ import (
 	"math"
 	"math/big"
 	"math/rand"
+	"net/http"
 	"net/url"
 	"runtime"
 	"strconv"
@@ -14,7 +15,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/golang/glog"
 	"github.com/livepeer/go-livepeer/common"
 	"github.com/livepeer/go-livepeer/core"
@@ -23,6 +27,7 @@ import (
 	"github.com/livepeer/go-livepeer/net"
 	"github.com/livepeer/go-livepeer/pm"
 	"github.com/livepeer/go-livepeer/server"
+	"github.com/livepeer/lpms/ffmpeg"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -68,7 +73,7 @@ func TestDeadLock(t *testing.T) {
 	uris := stringsToURIs(addresses)
 	assert := assert.New(t)
 	pool := NewOrchestratorPool(nil, uris)
-	infos, err := pool.GetOrchestrators(1)
+	infos, err := pool.GetOrchestrators(1, nil)
 	assert.Nil(err, "Should not be error")
 	assert.Len(infos, 1, "Should return one orchestrator")
 	assert.Equal("transcoderfromtestserver", infos[0].Transcoder)
@@ -110,13 +115,105 @@ func TestDeadLock_NewOrchestratorPoolWithPred(t *testing.T) {
 	}
 
 	pool := NewOrchestratorPoolWithPred(nil, uris, pred)
-	infos, err := pool.GetOrchestrators(1)
+	infos, err := pool.GetOrchestrators(1, nil)
 
 	assert.Nil(err, "Should not be error")
 	assert.Len(infos, 1, "Should return one orchestrator")
 	assert.Equal("transcoderfromtestserver", infos[0].Transcoder)
 }
 
+func TestGetOrchestrators_Pred(t *testing.T) {
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, orchestratorServer *url.URL) (*net.OrchestratorInfo, error) {
+		info := &net.OrchestratorInfo{Transcoder: orchestratorServer.String()}
+		if orchestratorServer.Port() == "8936" {
+			info.Capabilities = &net.OrchestratorCapabilities{MaxWidth: 640, MaxHeight: 360}
+		}
+		return info, nil
+	}
+	defer func() { serverGetOrchInfo = server.GetOrchestratorInfo }()
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"}
+	uris := stringsToURIs(addresses)
+	pred := func(info *net.OrchestratorInfo) bool {
+		return core.OrchestratorCanTranscode(info.Capabilities, []ffmpeg.VideoProfile{ffmpeg.P720p30fps16x9})
+	}
+
+	assert := assert.New(t)
+	pool := NewOrchestratorPool(nil, uris)
+	infos, err := pool.GetOrchestrators(len(addresses), pred)
+	assert.Nil(err)
+	assert.Len(infos, 2)
+	for _, info := range infos {
+		assert.NotEqual("https://127.0.0.1:8936", info.Transcoder)
+	}
+
+	// pool predicate and caller predicate are both applied
+	pool = NewOrchestratorPoolWithPred(nil, uris, func(info *net.OrchestratorInfo) bool {
+		return info.Transcoder != "https://127.0.0.1:8937"
+	})
+	infos, err = pool.GetOrchestrators(len(addresses), pred)
+	assert.Nil(err)
+	assert.Len(infos, 1)
+	assert.Equal("https://127.0.0.1:8938", infos[0].Transcoder)
+}
+
+func TestGetOrchestrators_OrchLists(t *testing.T) {
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, orchestratorServer *url.URL) (*net.OrchestratorInfo, error) {
+		return &net.OrchestratorInfo{Transcoder: orchestratorServer.String()}, nil
+	}
+	defer func() { serverGetOrchInfo = server.GetOrchestratorInfo }()
+	defer func(l *server.OrchestratorLists) { server.OrchLists = l }(server.OrchLists)
+
+	require := require.New(t)
+	dbh, dbraw, err := common.TempDB(t)
+	require.Nil(err)
+	defer dbh.Close()
+	defer dbraw.Close()
+
+	server.OrchLists, err = server.NewOrchestratorLists(dbh)
+	require.Nil(err)
+	_, err = server.OrchLists.Add(server.OrchBlocklist, "127.0.0.1:8936")
+	require.Nil(err)
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"}
+	pool := NewOrchestratorPool(nil, stringsToURIs(addresses))
+	infos, err := pool.GetOrchestrators(len(addresses), nil)
+	require.Nil(err)
+	assert.Len(t, infos, 2)
+	for _, info := range infos {
+		assert.NotEqual(t, "https://127.0.0.1:8936", info.Transcoder)
+	}
+
+	// orchestrators excluded by host aren't asked for their info
+	var requested []string
+	var mu sync.Mutex
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, orchestratorServer *url.URL) (*net.OrchestratorInfo, error) {
+		mu.Lock()
+		requested = append(requested, orchestratorServer.String())
+		mu.Unlock()
+		return &net.OrchestratorInfo{Transcoder: orchestratorServer.String()}, nil
+	}
+	_, err = server.OrchLists.Add(server.OrchAllowlist, "127.0.0.1:8937")
+	require.Nil(err)
+	infos, err = pool.GetOrchestrators(len(addresses), nil)
+	require.Nil(err)
+	require.Len(infos, 1)
+	assert.Equal(t, "https://127.0.0.1:8937", infos[0].Transcoder)
+	mu.Lock()
+	assert.Equal(t, []string{"https://127.0.0.1:8937"}, requested)
+	mu.Unlock()
+
+	// nothing is requested if every orchestrator is excluded
+	_, err = server.OrchLists.Add(server.OrchBlocklist, "127.0.0.1")
+	require.Nil(err)
+	infos, err = pool.GetOrchestrators(len(addresses), nil)
+	require.Nil(err)
+	assert.Empty(t, infos)
+	mu.Lock()
+	assert.Len(t, requested, 1)
+	mu.Unlock()
+}
+
 func TestPoolSize(t *testing.T) {
 	addresses := stringsToURIs([]string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"})
 
@@ -226,7 +323,7 @@ func TestNewDBOrchestratorPoolCache_GivenListOfOrchs_CreatesPoolCacheCorrectly(t
 	pool, err := NewDBOrchestratorPoolCache(ctx, node, &stubRoundsManager{})
 	require.NoError(err)
 	assert.Equal(pool.Size(), 3)
-	orchs, err := pool.GetOrchestrators(pool.Size())
+	orchs, err := pool.GetOrchestrators(pool.Size(), nil)
 	for _, o := range orchs {
 		assert.Equal(o.PriceInfo, expPriceInfo)
 		assert.Equal(o.Transcoder, expTranscoder)
@@ -565,7 +662,7 @@ func TestCachedPool_AllOrchestratorsTooExpensive_ReturnsEmptyList(t *testing.T)
 
 	urls := pool.GetURLs()
 	assert.Len(urls, 0)
-	infos, err := pool.GetOrchestrators(len(addresses))
+	infos, err := pool.GetOrchestrators(len(addresses), nil)
 
 	assert.Nil(err, "Should not be error")
 	assert.Len(infos, 0)
@@ -659,7 +756,7 @@ func TestCachedPool_GetOrchestrators_MaxBroadcastPriceNotSet(t *testing.T) {
 	for _, url := range urls {
 		assert.Contains(addresses, url.String())
 	}
-	infos, err := pool.GetOrchestrators(50)
+	infos, err := pool.GetOrchestrators(50, nil)
 	for _, info := range infos {
 		assert.Equal(info.PriceInfo, expPriceInfo)
 		assert.Equal(info.Transcoder, expTranscoder)
@@ -772,7 +869,7 @@ func TestCachedPool_N_OrchestratorsGoodPricing_ReturnsNOrchestrators(t *testing.
 		assert.Contains(addresses[25:], url.String())
 	}
 
-	infos, err := pool.GetOrchestrators(len(orchestrators))
+	infos, err := pool.GetOrchestrators(len(orchestrators), nil)
 
 	assert.Nil(err, "Should not be error")
 	assert.Len(infos, 25)
@@ -835,7 +932,7 @@ func TestCachedPool_GetOrchestrators_TicketParamsValidation(t *testing.T) {
 	sender.On("ValidateTicketParams", mock.Anything).Return(errors.New("ValidateTicketParams error")).Times(25)
 	sender.On("ValidateTicketParams", mock.Anything).Return(nil).Times(25)
 
-	infos, err := pool.GetOrchestrators(len(addresses))
+	infos, err := pool.GetOrchestrators(len(addresses), nil)
 	assert.Nil(err)
 	assert.Len(infos, 25)
 	sender.AssertNumberOfCalls(t, "ValidateTicketParams", 50)
@@ -843,7 +940,7 @@ func TestCachedPool_GetOrchestrators_TicketParamsValidation(t *testing.T) {
 	// Test 0 out of 50 orchs pass ticket params validation
 	sender.On("ValidateTicketParams", mock.Anything).Return(errors.New("ValidateTicketParams error")).Times(50)
 
-	infos, err = pool.GetOrchestrators(len(addresses))
+	infos, err = pool.GetOrchestrators(len(addresses), nil)
 	assert.Nil(err)
 	assert.Len(infos, 0)
 	sender.AssertNumberOfCalls(t, "ValidateTicketParams", 100)
@@ -942,7 +1039,7 @@ func TestCachedPool_GetOrchestrators_OnlyActiveOrchestrators(t *testing.T) {
 	for _, url := range urls {
 		assert.Contains(addresses[:25], url.String())
 	}
-	infos, err := pool.GetOrchestrators(50)
+	infos, err := pool.GetOrchestrators(50, nil)
 	for _, info := range infos {
 		assert.Equal(info.PriceInfo, expPriceInfo)
 		assert.Equal(info.Transcoder, expTranscoder)
@@ -959,12 +1056,13 @@ func TestNewWHOrchestratorPoolCache(t *testing.T) {
 	// mock webhook and orchestrator info request
 	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"}
 
-	getURLsfromWebhook = func(cbUrl *url.URL) ([]byte, error) {
+	getURLsfromWebhook = func(cbUrl *url.URL) ([]byte, http.Header, error) {
 		var wh []webhookResponse
 		for _, addr := range addresses {
 			wh = append(wh, webhookResponse{Address: addr})
 		}
-		return json.Marshal(&wh)
+		body, err := json.Marshal(&wh)
+		return body, nil, err
 	}
 
 	serverGetOrchInfo = func(c context.Context, b common.Broadcaster, s *url.URL) (*net.OrchestratorInfo, error) {
@@ -980,7 +1078,7 @@ func TestNewWHOrchestratorPoolCache(t *testing.T) {
 
 	// assert that list is not refreshed if lastRequest is less than 1 min ago and hash is the same
 	lastReq := whpool.lastRequest
-	orchInfo, err := whpool.GetOrchestrators(2)
+	orchInfo, err := whpool.GetOrchestrators(2, nil)
 	require.Nil(err)
 	assert.Len(orchInfo, 2)
 	assert.Equal(3, whpool.Size())
@@ -996,7 +1094,7 @@ func TestNewWHOrchestratorPoolCache(t *testing.T) {
 	//  assert that list is not refreshed if lastRequest is more than 1 min ago and hash is the same
 	lastReq = time.Now().Add(-2 * time.Minute)
 	whpool.lastRequest = lastReq
-	orchInfo, err = whpool.GetOrchestrators(2)
+	orchInfo, err = whpool.GetOrchestrators(2, nil)
 	require.Nil(err)
 	assert.Len(orchInfo, 2)
 	assert.Equal(3, whpool.Size())
@@ -1016,7 +1114,7 @@ func TestNewWHOrchestratorPoolCache(t *testing.T) {
 	//  assert that list is not refreshed if lastRequest is less than 1 min ago and hash is not the same
 	lastReq = time.Now()
 	whpool.lastRequest = lastReq
-	orchInfo, err = whpool.GetOrchestrators(2)
+	orchInfo, err = whpool.GetOrchestrators(2, nil)
 	require.Nil(err)
 	assert.Len(orchInfo, 2)
 	assert.Equal(3, whpool.Size())
@@ -1033,7 +1131,7 @@ func TestNewWHOrchestratorPoolCache(t *testing.T) {
 	//  assert that list is refreshed if lastRequest is longer than 1 min ago and hash is not the same
 	lastReq = time.Now().Add(-2 * time.Minute)
 	whpool.lastRequest = lastReq
-	orchInfo, err = whpool.GetOrchestrators(2)
+	orchInfo, err = whpool.GetOrchestrators(2, nil)
 	require.Nil(err)
 	assert.Len(orchInfo, 2)
 	assert.Equal(3, whpool.Size())
@@ -1053,37 +1151,195 @@ func TestDeserializeWebhookJSON(t *testing.T) {
 
 	// assert input of webhookResponse address object returns correct address
 	resp, _ := json.Marshal(&[]webhookResponse{webhookResponse{Address: "https://127.0.0.1:8936"}})
-	urls, err := deserializeWebhookJSON(resp)
+	urls, params, err := deserializeWebhookJSON(resp)
 	assert.Nil(err)
 	assert.Equal("https://127.0.0.1:8936", urls[0].String())
+	assert.Equal(map[string]*orchParams{"127.0.0.1:8936": {weight: 1}}, params)
 
 	// assert input of empty byte array returns JSON error
-	urls, err = deserializeWebhookJSON([]byte{})
+	urls, _, err = deserializeWebhookJSON([]byte{})
 	assert.Contains(err.Error(), "unexpected end of JSON input")
 	assert.Nil(urls)
 
 	// assert input of empty byte array returns empty object
 	resp, _ = json.Marshal(&[]webhookResponse{webhookResponse{}})
-	urls, err = deserializeWebhookJSON(resp)
+	urls, _, err = deserializeWebhookJSON(resp)
 	assert.Nil(err)
 	assert.Empty(urls)
 
 	// assert input of invalid addresses returns invalid JSON error
-	urls, err = deserializeWebhookJSON(make([]byte, 64))
+	urls, _, err = deserializeWebhookJSON(make([]byte, 64))
 	assert.Contains(err.Error(), "invalid character")
 	assert.Empty(urls)
 
 	// assert input of invalid JSON returns JSON unmarshal object error
-	urls, err = deserializeWebhookJSON([]byte(`{"name":false}`))
+	urls, _, err = deserializeWebhookJSON([]byte(`{"name":false}`))
 	assert.Contains(err.Error(), "cannot unmarshal object")
 	assert.Empty(urls)
 
 	// assert input of invalid JSON returns JSON unmarshal number error
-	urls, err = deserializeWebhookJSON([]byte(`1112`))
+	urls, _, err = deserializeWebhookJSON([]byte(`1112`))
 	assert.Contains(err.Error(), "cannot unmarshal number")
 	assert.Empty(urls)
 }
 
+func TestDeserializeWebhookJSON_Params(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defer func() { RequiredTags = nil }()
+
+	body := []byte(`[
+		{"address": "https://127.0.0.1:8936"},
+		{"address": "https://127.0.0.1:8937", "region": "eu", "priority": 1, "weight": 2.5, "maxPrice": "1/3", "tags": ["gpu", "eu"]},
+		{"address": "https://127.0.0.1:8938", "maxPrice": "0.5", "tags": ["gpu"]},
+		{"address": "https://127.0.0.1:8939", "weight": -1},
+		{"address": "https://127.0.0.1:8940", "maxPrice": "cheap"},
+		{"address": "https://127.0.0.1:8941", "maxPrice": "-1"}
+	]`)
+	urls, params, err := deserializeWebhookJSON(body)
+	require.Nil(err)
+	// invalid weights and max prices are skipped
+	assert.Equal(stringsToURIs([]string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"}), urls)
+	assert.Equal(map[string]*orchParams{
+		"127.0.0.1:8936": {weight: 1},
+		"127.0.0.1:8937": {region: "eu", priority: 1, weight: 2.5, maxPrice: big.NewRat(1, 3), tags: []string{"gpu", "eu"}},
+		"127.0.0.1:8938": {weight: 1, maxPrice: big.NewRat(1, 2), tags: []string{"gpu"}},
+	}, params)
+
+	// only orchestrators with all of the required tags
+	RequiredTags = []string{"gpu"}
+	urls, _, err = deserializeWebhookJSON(body)
+	require.Nil(err)
+	assert.Equal(stringsToURIs([]string{"https://127.0.0.1:8937", "https://127.0.0.1:8938"}), urls)
+	RequiredTags = []string{"eu", "gpu"}
+	urls, _, err = deserializeWebhookJSON(body)
+	require.Nil(err)
+	assert.Equal(stringsToURIs([]string{"https://127.0.0.1:8937"}), urls)
+}
+
+func TestWebhookPool_Signature(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defer func(get func(*url.URL) ([]byte, http.Header, error)) { getURLsfromWebhook = get }(getURLsfromWebhook)
+	defer func(p func(int) []int) { perm = p }(perm)
+	perm = rand.Perm
+
+	key, err := crypto.GenerateKey()
+	require.Nil(err)
+	signer := crypto.PubkeyToAddress(key.PublicKey)
+	sign := func(sigTime string, body []byte) string {
+		sig, err := crypto.Sign(accounts.TextHash(append([]byte(sigTime), body...)), key)
+		require.Nil(err)
+		sig[64] += 27
+		return hexutil.Encode(sig)
+	}
+
+	body := []byte(`[{"address": "https://127.0.0.1:8936"}]`)
+	sigTime := strconv.FormatInt(time.Now().Unix(), 10)
+	sig := sign(sigTime, body)
+	getURLsfromWebhook = func(cbUrl *url.URL) ([]byte, http.Header, error) {
+		header := http.Header{}
+		header.Set(WebhookSignatureHeader, sig)
+		header.Set(WebhookSignatureTimeHeader, sigTime)
+		return body, header, nil
+	}
+	whURL, _ := url.ParseRequestURI("https://livepeer.live/api/orchestrator")
+	newPool := func(signer *ethcommon.Address) *webhookPool {
+		return &webhookPool{callback: whURL, mu: &sync.RWMutex{}, signer: signer}
+	}
+
+	urls, err := newPool(&signer).getURLs()
+	require.Nil(err)
+	assert.Equal(stringsToURIs([]string{"https://127.0.0.1:8936"}), urls)
+
+	// unsigned, tampered with or signed by someone else
+	sig = ""
+	_, err = newPool(&signer).getURLs()
+	assert.Equal(errWebhookSignature, err)
+	sig = sign(sigTime, body)
+	body = []byte(`[{"address": "https://127.0.0.1:8936"}, {"address": "https://127.0.0.1:8937"}]`)
+	_, err = newPool(&signer).getURLs()
+	assert.Equal(errWebhookSignature, err)
+	other := pm.RandAddress()
+	sig = sign(sigTime, body)
+	_, err = newPool(&other).getURLs()
+	assert.Equal(errWebhookSignature, err)
+	sig = "0x1234"
+	_, err = newPool(&signer).getURLs()
+	assert.Equal(errWebhookSignature, err)
+
+	// the signature time is signed too and must be present
+	sig = sign(sigTime, body)
+	sigTime = strconv.FormatInt(time.Now().Unix()+1, 10)
+	_, err = newPool(&signer).getURLs()
+	assert.Equal(errWebhookSignature, err)
+	sigTime = ""
+	sig = sign(sigTime, body)
+	_, err = newPool(&signer).getURLs()
+	assert.Equal(errWebhookSignature, err)
+	sigTime = "yesterday"
+	sig = sign(sigTime, body)
+	_, err = newPool(&signer).getURLs()
+	assert.Equal(errWebhookSignature, err)
+
+	// replayed or future responses are rejected
+	sigTime = strconv.FormatInt(time.Now().Add(-whSignatureMaxAge-time.Minute).Unix(), 10)
+	sig = sign(sigTime, body)
+	_, err = newPool(&signer).getURLs()
+	assert.Equal(errWebhookSignatureExpired, err)
+	sigTime = strconv.FormatInt(time.Now().Add(whSignatureMaxAge+time.Minute).Unix(), 10)
+	sig = sign(sigTime, body)
+	_, err = newPool(&signer).getURLs()
+	assert.Equal(errWebhookSignatureExpired, err)
+
+	// responses don't need to be signed without a signer
+	sig, sigTime = "", ""
+	urls, err = newPool(nil).getURLs()
+	require.Nil(err)
+	assert.Len(urls, 2)
+}
+
+func TestWebhookPool_MaxPrice(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defer func(get func(*url.URL) ([]byte, http.Header, error)) { getURLsfromWebhook = get }(getURLsfromWebhook)
+	defer func(p func(int) []int) { perm = p }(perm)
+	perm = rand.Perm
+	defer func() { serverGetOrchInfo = server.GetOrchestratorInfo }()
+
+	getURLsfromWebhook = func(cbUrl *url.URL) ([]byte, http.Header, error) {
+		return []byte(`[
+			{"address": "https://127.0.0.1:8936", "maxPrice": "1/2"},
+			{"address": "https://127.0.0.1:8937", "maxPrice": "1/4"},
+			{"address": "https://127.0.0.1:8938"}
+		]`), nil, nil
+	}
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, orchestratorServer *url.URL) (*net.OrchestratorInfo, error) {
+		return &net.OrchestratorInfo{
+			Transcoder: orchestratorServer.String(),
+			PriceInfo:  &net.PriceInfo{PricePerUnit: 1, PixelsPerUnit: 3},
+		}, nil
+	}
+	whURL, _ := url.ParseRequestURI("https://livepeer.live/api/orchestrator")
+	whpool := &webhookPool{callback: whURL, mu: &sync.RWMutex{}}
+
+	// orchestrators above the max price the webhook set for them are skipped
+	infos, err := whpool.GetOrchestrators(3, nil)
+	require.Nil(err)
+	require.Len(infos, 2)
+	var transcoders []string
+	for _, info := range infos {
+		transcoders = append(transcoders, info.Transcoder)
+	}
+	assert.ElementsMatch([]string{"https://127.0.0.1:8936", "https://127.0.0.1:8938"}, transcoders)
+
+	assert.Equal(big.NewRat(1, 2), whpool.MaxPrice(&net.OrchestratorInfo{Transcoder: "https://127.0.0.1:8936"}))
+	assert.Nil(whpool.MaxPrice(&net.OrchestratorInfo{Transcoder: "https://127.0.0.1:8938"}))
+	assert.Nil(whpool.MaxPrice(&net.OrchestratorInfo{Transcoder: "https://127.0.0.1:8939"}))
+	assert.Nil(whpool.MaxPrice(&net.OrchestratorInfo{Transcoder: ":"}))
+	assert.Nil((&webhookPool{mu: &sync.RWMutex{}}).MaxPrice(&net.OrchestratorInfo{Transcoder: "https://127.0.0.1:8936"}))
+}
+
 func TestEthOrchToDBOrch(t *testing.T) {
 	assert := assert.New(t)
 	o := &lpTypes.Transcoder{
@@ -0,0 +1,143 @@
+package discovery
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/server"
+
+	"github.com/golang/glog"
+)
+
+const probeTimeout = 3 * time.Second
+
+// Weight of a new sample in the average round trip time
+const rttSampleWeight = 0.2
+
+var serverPingOrch = server.PingOrchestrator
+
+// Region of the broadcaster. Orchestrators the webhook tags with the same region are preferred
+var Region string
+
+// Prober measures the round trip times orchestrator pools rank orchestrators by; nil disables ranking by round trip time
+var Prober *RTTProber
+
+// RTTStats are the round trip time statistics of an orchestrator
+type RTTStats struct {
+	// Exponentially weighted moving average of the successful probes
+	Average time.Duration
+	Last    time.Duration
+	Samples int
+	// Failed probes since the last successful one
+	Failures  int
+	LastProbe time.Time
+}
+
+// RTTProber periodically pings the orchestrators of a pool
+type RTTProber struct {
+	pool     common.OrchestratorPool
+	interval time.Duration
+
+	mu    sync.RWMutex
+	stats map[string]*RTTStats
+}
+
+// NewRTTProber creates a RTTProber for the orchestrators of pool, which are probed every interval once started
+func NewRTTProber(pool common.OrchestratorPool, interval time.Duration) *RTTProber {
+	return &RTTProber{
+		pool:     pool,
+		interval: interval,
+		stats:    make(map[string]*RTTStats),
+	}
+}
+
+// Start probes the orchestrators right away and then every interval until ctx is done
+func (p *RTTProber) Start(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.probe(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probe pings every orchestrator in the pool concurrently. Statistics of orchestrators
+// that left the pool are dropped
+func (p *RTTProber) probe(ctx context.Context) {
+	uris := p.pool.GetURLs()
+	var wg sync.WaitGroup
+	for _, uri := range uris {
+		wg.Add(1)
+		go func(uri *url.URL) {
+			defer wg.Done()
+			pctx, cancel := context.WithTimeout(ctx, probeTimeout)
+			defer cancel()
+			rtt, err := serverPingOrch(pctx, uri)
+			if err != nil {
+				glog.V(common.DEBUG).Infof("Probe failed orch=%v err=%v", uri, err)
+			}
+			p.update(uri, rtt, err)
+		}(uri)
+	}
+	wg.Wait()
+
+	hosts := make(map[string]bool)
+	for _, uri := range uris {
+		hosts[rttKey(uri)] = true
+	}
+	p.mu.Lock()
+	for host := range p.stats {
+		if !hosts[host] {
+			delete(p.stats, host)
+		}
+	}
+	p.mu.Unlock()
+}
+
+func (p *RTTProber) update(uri *url.URL, rtt time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := rttKey(uri)
+	stats, ok := p.stats[key]
+	if !ok {
+		stats = &RTTStats{}
+		p.stats[key] = stats
+	}
+	stats.LastProbe = time.Now()
+	if err != nil {
+		stats.Failures++
+		return
+	}
+	if stats.Samples == 0 {
+		stats.Average = rtt
+	} else {
+		stats.Average = time.Duration((1-rttSampleWeight)*float64(stats.Average) + rttSampleWeight*float64(rtt))
+	}
+	stats.Last = rtt
+	stats.Samples++
+	stats.Failures = 0
+}
+
+// RTT returns a copy of the statistics of the orchestrator, or nil if it hasn't been probed
+func (p *RTTProber) RTT(uri *url.URL) *RTTStats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	stats, ok := p.stats[rttKey(uri)]
+	if !ok {
+		return nil
+	}
+	s := *stats
+	return &s
+}
+
+func rttKey(uri *url.URL) string {
+	return strings.ToLower(uri.Host)
+}
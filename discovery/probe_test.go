@@ -0,0 +1,261 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/go-livepeer/server"
+)
+
+func stubPing(rtts map[string]time.Duration) func(context.Context, *url.URL) (time.Duration, error) {
+	var mu sync.Mutex
+	return func(ctx context.Context, uri *url.URL) (time.Duration, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		rtt, ok := rtts[uri.Host]
+		if !ok {
+			return 0, errors.New("unreachable")
+		}
+		return rtt, nil
+	}
+}
+
+func TestRTTProber(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defer func() { serverPingOrch = server.PingOrchestrator }()
+
+	rtts := map[string]time.Duration{"127.0.0.1:8936": 100 * time.Millisecond, "127.0.0.1:8937": 50 * time.Millisecond}
+	serverPingOrch = stubPing(rtts)
+	pool := &orchestratorPool{uris: stringsToURIs([]string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"})}
+	p := NewRTTProber(pool, time.Minute)
+
+	assert.Nil(p.RTT(pool.uris[0]))
+	p.probe(context.Background())
+	stats := p.RTT(pool.uris[0])
+	require.NotNil(stats)
+	assert.Equal(100*time.Millisecond, stats.Average)
+	assert.Equal(100*time.Millisecond, stats.Last)
+	assert.Equal(1, stats.Samples)
+	assert.Equal(0, stats.Failures)
+	assert.False(stats.LastProbe.IsZero())
+	stats = p.RTT(pool.uris[2])
+	require.NotNil(stats)
+	assert.Equal(0, stats.Samples)
+	assert.Equal(1, stats.Failures)
+
+	// the average moves towards new samples
+	rtts["127.0.0.1:8936"] = 200 * time.Millisecond
+	delete(rtts, "127.0.0.1:8937")
+	p.probe(context.Background())
+	stats = p.RTT(pool.uris[0])
+	assert.Equal(120*time.Millisecond, stats.Average)
+	assert.Equal(200*time.Millisecond, stats.Last)
+	assert.Equal(2, stats.Samples)
+	// failures don't affect the average
+	stats = p.RTT(pool.uris[1])
+	assert.Equal(50*time.Millisecond, stats.Average)
+	assert.Equal(1, stats.Samples)
+	assert.Equal(1, stats.Failures)
+
+	// a success resets the failures
+	rtts["127.0.0.1:8937"] = 50 * time.Millisecond
+	p.probe(context.Background())
+	assert.Equal(0, p.RTT(pool.uris[1]).Failures)
+
+	// orchestrators that left the pool are forgotten
+	left := pool.uris[0]
+	pool.uris = pool.uris[1:]
+	p.probe(context.Background())
+	assert.Nil(p.RTT(left))
+	assert.NotNil(p.RTT(pool.uris[0]))
+
+	// the returned statistics are a copy
+	p.RTT(pool.uris[0]).Samples = 100
+	assert.Equal(3, p.RTT(pool.uris[0]).Samples)
+}
+
+func TestRTTProber_Start(t *testing.T) {
+	defer func() { serverPingOrch = server.PingOrchestrator }()
+
+	probed := make(chan struct{}, 10)
+	serverPingOrch = func(ctx context.Context, uri *url.URL) (time.Duration, error) {
+		probed <- struct{}{}
+		return time.Millisecond, nil
+	}
+	pool := &orchestratorPool{uris: stringsToURIs([]string{"https://127.0.0.1:8936"})}
+	p := NewRTTProber(pool, 10*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		p.Start(ctx)
+		close(done)
+	}()
+	// probes right away, then periodically
+	for i := 0; i < 2; i++ {
+		select {
+		case <-probed:
+		case <-time.After(time.Second):
+			t.Fatal("orchestrator not probed")
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("prober not stopped")
+	}
+}
+
+func TestRankURIs(t *testing.T) {
+	assert := assert.New(t)
+	defer func() {
+		serverPingOrch = server.PingOrchestrator
+		Prober = nil
+		Region = ""
+		randFloat = rand.Float64
+	}()
+	// keeps orchestrators of the same weight in their original order
+	randFloat = func() float64 { return 0.5 }
+
+	uris := stringsToURIs([]string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938", "https://127.0.0.1:8939"})
+	regions := map[string]*orchParams{"127.0.0.1:8938": {region: "eu", weight: 1}, "127.0.0.1:8939": {region: "us", weight: 1}}
+
+	// nothing to rank by
+	ranked, ok := rankURIs(uris, regions)
+	assert.False(ok)
+	assert.Equal(uris, ranked)
+	Region = "eu"
+	_, ok = rankURIs(uris, nil)
+	assert.False(ok)
+
+	// by region
+	ranked, ok = rankURIs(uris, regions)
+	assert.True(ok)
+	assert.Equal([]*url.URL{uris[2], uris[0], uris[1], uris[3]}, ranked)
+
+	// by region, then round trip time, then unreachable
+	serverPingOrch = stubPing(map[string]time.Duration{
+		"127.0.0.1:8937": 10 * time.Millisecond,
+		"127.0.0.1:8938": 90 * time.Millisecond,
+		"127.0.0.1:8939": 20 * time.Millisecond,
+	})
+	Prober = NewRTTProber(&orchestratorPool{uris: uris}, time.Minute)
+	Prober.probe(context.Background())
+	ranked, ok = rankURIs(uris, regions)
+	assert.True(ok)
+	assert.Equal([]*url.URL{uris[2], uris[1], uris[3], uris[0]}, ranked)
+
+	Region = ""
+	ranked, ok = rankURIs(uris, regions)
+	assert.True(ok)
+	assert.Equal([]*url.URL{uris[1], uris[3], uris[2], uris[0]}, ranked)
+}
+
+func TestRankURIs_PriorityAndWeight(t *testing.T) {
+	assert := assert.New(t)
+	defer func() {
+		serverPingOrch = server.PingOrchestrator
+		Prober = nil
+		Region = ""
+		randFloat = rand.Float64
+	}()
+	randFloat = func() float64 { return 0.5 }
+
+	uris := stringsToURIs([]string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938", "https://127.0.0.1:8939"})
+
+	// default parameters don't rank
+	params := map[string]*orchParams{"127.0.0.1:8936": {weight: 1}, "127.0.0.1:8937": {region: "eu", weight: 1}}
+	_, ok := rankURIs(uris, params)
+	assert.False(ok)
+
+	// lower priorities first, ahead of the region; unprobed orchestrators with higher weights are more likely first
+	Region = "eu"
+	params = map[string]*orchParams{
+		"127.0.0.1:8936": {priority: 1, region: "eu", weight: 1},
+		"127.0.0.1:8937": {weight: 1},
+		"127.0.0.1:8938": {weight: 4},
+	}
+	ranked, ok := rankURIs(uris, params)
+	assert.True(ok)
+	assert.Equal([]*url.URL{uris[2], uris[1], uris[3], uris[0]}, ranked)
+
+	// round trip times are divided by the weight
+	serverPingOrch = stubPing(map[string]time.Duration{
+		"127.0.0.1:8937": 30 * time.Millisecond,
+		"127.0.0.1:8938": 80 * time.Millisecond,
+		"127.0.0.1:8939": 10 * time.Millisecond,
+	})
+	Prober = NewRTTProber(&orchestratorPool{uris: uris}, time.Minute)
+	Prober.probe(context.Background())
+	ranked, ok = rankURIs(uris, params)
+	assert.True(ok)
+	assert.Equal([]*url.URL{uris[3], uris[2], uris[1], uris[0]}, ranked)
+}
+
+func TestBestResponded(t *testing.T) {
+	assert := assert.New(t)
+	info := &net.OrchestratorInfo{}
+
+	assert.True(bestResponded([]bool{false, false}, []*net.OrchestratorInfo{nil, nil}, 0))
+	assert.False(bestResponded([]bool{false, true}, []*net.OrchestratorInfo{nil, info}, 1))
+	assert.True(bestResponded([]bool{true, false}, []*net.OrchestratorInfo{info, nil}, 1))
+	// orchestrators that didn't pass don't count
+	assert.False(bestResponded([]bool{true, false, true}, []*net.OrchestratorInfo{nil, nil, info}, 1))
+	assert.True(bestResponded([]bool{true, true, false}, []*net.OrchestratorInfo{nil, info, nil}, 1))
+	// fewer orchestrators passed than requested
+	assert.True(bestResponded([]bool{true, true}, []*net.OrchestratorInfo{nil, info}, 2))
+}
+
+func TestGetOrchestrators_Ranked(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	defer func() {
+		serverGetOrchInfo = server.GetOrchestratorInfo
+		serverPingOrch = server.PingOrchestrator
+		Prober = nil
+	}()
+
+	// the closest orchestrators are the slowest to respond
+	delays := map[string]time.Duration{"127.0.0.1:8936": 200 * time.Millisecond, "127.0.0.1:8937": 100 * time.Millisecond}
+	serverGetOrchInfo = func(ctx context.Context, bcast common.Broadcaster, orchestratorServer *url.URL) (*net.OrchestratorInfo, error) {
+		time.Sleep(delays[orchestratorServer.Host])
+		return &net.OrchestratorInfo{Transcoder: orchestratorServer.String()}, nil
+	}
+	serverPingOrch = stubPing(map[string]time.Duration{"127.0.0.1:8936": 10 * time.Millisecond, "127.0.0.1:8937": 20 * time.Millisecond})
+
+	addresses := []string{"https://127.0.0.1:8936", "https://127.0.0.1:8937", "https://127.0.0.1:8938"}
+	pool := NewOrchestratorPool(nil, stringsToURIs(addresses))
+	infos, err := pool.GetOrchestrators(2, nil)
+	require.Nil(err)
+	require.Len(infos, 2)
+	assert.Contains([]string{"https://127.0.0.1:8938", "https://127.0.0.1:8937"}, infos[0].Transcoder)
+
+	Prober = NewRTTProber(pool, time.Minute)
+	Prober.probe(context.Background())
+	infos, err = pool.GetOrchestrators(2, nil)
+	require.Nil(err)
+	require.Len(infos, 2)
+	assert.Equal("https://127.0.0.1:8936", infos[0].Transcoder)
+	assert.Equal("https://127.0.0.1:8937", infos[1].Transcoder)
+
+	// orchestrators that don't pass are skipped
+	infos, err = pool.GetOrchestrators(2, func(info *net.OrchestratorInfo) bool {
+		return info.Transcoder != "https://127.0.0.1:8936"
+	})
+	require.Nil(err)
+	require.Len(infos, 2)
+	assert.Equal("https://127.0.0.1:8937", infos[0].Transcoder)
+	assert.Equal("https://127.0.0.1:8938", infos[1].Transcoder)
+}
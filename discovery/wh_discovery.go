@@ -2,9 +2,12 @@ package discovery
 
 import (
 	"encoding/json"
+	"errors"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"net/url"
+	"strconv"
 	"sync"
 	"time"
 
@@ -12,14 +15,70 @@ import (
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/livepeer/go-livepeer/common"
 	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/go-livepeer/pm"
 
 	"github.com/golang/glog"
 )
 
 var whRefreshInterval = 1 * time.Minute
 
+// whSignatureMaxAge is how far the signature time of a webhook response may be from now
+var whSignatureMaxAge = 5 * time.Minute
+
+const (
+	// WebhookSignatureHeader is the header of a webhook response with the signature of
+	// its signature time followed by its body
+	WebhookSignatureHeader = "Livepeer-Signature"
+	// WebhookSignatureTimeHeader is the header of a webhook response with the Unix time
+	// in seconds at which it was signed
+	WebhookSignatureTimeHeader = "Livepeer-Signature-Time"
+)
+
+var (
+	errWebhookSignature        = errors.New("invalid webhook response signature")
+	errWebhookSignatureExpired = errors.New("expired webhook response signature")
+)
+
+// RequiredTags are the tags an orchestrator from the webhook must all have to be used
+var RequiredTags []string
+
+// webhookResponse is an orchestrator returned by the webhook. Only the address is required
 type webhookResponse struct {
 	Address string
+	// Region the orchestrator is in, see Region
+	Region string
+	// Orchestrators with a lower priority are preferred
+	Priority int
+	// Relative preference among orchestrators of the same priority; defaults to 1
+	Weight float64
+	// Wei per pixel, overriding the broadcaster's max price
+	MaxPrice string
+	Tags     []string
+}
+
+// orchParams are what the webhook specifies about an orchestrator besides its address
+type orchParams struct {
+	region   string
+	priority int
+	weight   float64
+	maxPrice *big.Rat
+	tags     []string
+}
+
+func (p *orchParams) hasTags(tags []string) bool {
+	for _, tag := range tags {
+		found := false
+		for _, t := range p.tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
 }
 
 type webhookPool struct {
@@ -29,13 +88,25 @@ type webhookPool struct {
 	lastRequest  time.Time
 	mu           *sync.RWMutex
 	bcast        common.Broadcaster
+	// If set, responses must be signed by this address
+	signer *ethcommon.Address
 }
 
 func NewWebhookPool(bcast common.Broadcaster, callback *url.URL) *webhookPool {
+	return newWebhookPool(bcast, callback, nil)
+}
+
+// NewWebhookPoolWithSigner creates a webhookPool that rejects responses that aren't signed by signer
+func NewWebhookPoolWithSigner(bcast common.Broadcaster, callback *url.URL, signer ethcommon.Address) *webhookPool {
+	return newWebhookPool(bcast, callback, &signer)
+}
+
+func newWebhookPool(bcast common.Broadcaster, callback *url.URL, signer *ethcommon.Address) *webhookPool {
 	p := &webhookPool{
 		callback: callback,
 		mu:       &sync.RWMutex{},
 		bcast:    bcast,
+		signer:   signer,
 	}
 	go p.getURLs()
 	return p
@@ -52,10 +123,14 @@ func (w *webhookPool) getURLs() ([]*url.URL, error) {
 	}
 
 	// retrive addrs from webhook if time since lastRequest is more than the refresh interval
-	body, err := getURLsfromWebhook(w.callback)
+	body, header, err := getURLsfromWebhook(w.callback)
 	if err != nil {
 		return nil, err
 	}
+	if err := w.verify(body, header); err != nil {
+		glog.Errorf("Rejected orchestrator webhook response err=%v", err)
+		return nil, err
+	}
 
 	hash := ethcommon.BytesToHash(crypto.Keccak256(body))
 	if hash == w.responseHash {
@@ -65,12 +140,13 @@ func (w *webhookPool) getURLs() ([]*url.URL, error) {
 		return w.pool.GetURLs(), nil
 	}
 
-	addrs, err := deserializeWebhookJSON(body)
+	addrs, params, err := deserializeWebhookJSON(body)
 	if err != nil {
 		return nil, err
 	}
 
 	pool := NewOrchestratorPool(w.bcast, addrs)
+	pool.params = params
 
 	w.mu.Lock()
 	w.responseHash = hash
@@ -90,7 +166,7 @@ func (w *webhookPool) Size() int {
 	return len(w.GetURLs())
 }
 
-func (w *webhookPool) GetOrchestrators(numOrchestrators int) ([]*net.OrchestratorInfo, error) {
+func (w *webhookPool) GetOrchestrators(numOrchestrators int, pred func(*net.OrchestratorInfo) bool) ([]*net.OrchestratorInfo, error) {
 	_, err := w.getURLs()
 	if err != nil {
 		return nil, err
@@ -99,43 +175,114 @@ func (w *webhookPool) GetOrchestrators(numOrchestrators int) ([]*net.Orchestrato
 	w.mu.RLock()
 	defer w.mu.RUnlock()
 
-	return w.pool.GetOrchestrators(numOrchestrators)
+	return w.pool.GetOrchestrators(numOrchestrators, pred)
+}
+
+// MaxPrice returns the max price the webhook set for the orchestrator, or nil
+func (w *webhookPool) MaxPrice(info *net.OrchestratorInfo) *big.Rat {
+	uri, err := url.Parse(info.GetTranscoder())
+	if err != nil {
+		return nil
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.pool == nil {
+		return nil
+	}
+	if p := w.pool.params[rttKey(uri)]; p != nil {
+		return p.maxPrice
+	}
+	return nil
 }
 
-var getURLsfromWebhook = func(cbUrl *url.URL) ([]byte, error) {
+// verify checks the signature of a response against the signer, if there is one.
+// The signature covers the signature time so that old responses can't be replayed
+func (w *webhookPool) verify(body []byte, header http.Header) error {
+	if w.signer == nil {
+		return nil
+	}
+	sig, sigTime := header.Get(WebhookSignatureHeader), header.Get(WebhookSignatureTimeHeader)
+	if sig == "" || sigTime == "" {
+		return errWebhookSignature
+	}
+	msg := append([]byte(sigTime), body...)
+	if !pm.VerifySig(*w.signer, msg, ethcommon.FromHex(sig)) {
+		return errWebhookSignature
+	}
+	secs, err := strconv.ParseInt(sigTime, 10, 64)
+	if err != nil {
+		return errWebhookSignature
+	}
+	if age := time.Since(time.Unix(secs, 0)); age > whSignatureMaxAge || age < -whSignatureMaxAge {
+		return errWebhookSignatureExpired
+	}
+	return nil
+}
+
+// getURLsfromWebhook returns the body and headers of the webhook response
+var getURLsfromWebhook = func(cbUrl *url.URL) ([]byte, http.Header, error) {
 	var httpc = &http.Client{
 		Timeout: 3 * time.Second,
 	}
 	resp, err := httpc.Get(cbUrl.String())
 	if err != nil {
 		glog.Error("Unable to make webhook request ", err)
-		return nil, err
+		return nil, nil, err
 	}
 	defer resp.Body.Close()
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		glog.Error("Unable to read response body ", err)
-		return nil, err
+		return nil, nil, err
 	}
 
-	return body, nil
+	return body, resp.Header, nil
 }
 
-func deserializeWebhookJSON(body []byte) ([]*url.URL, error) {
+// deserializeWebhookJSON returns the addresses of the orchestrators in the webhook response
+// that have the required tags, and what the response specifies about them by host
+func deserializeWebhookJSON(body []byte) ([]*url.URL, map[string]*orchParams, error) {
 	var addrs []webhookResponse
 	if err := json.Unmarshal(body, &addrs); err != nil {
 		glog.Error("Unable to unmarshal JSON ", err)
-		return nil, err
+		return nil, nil, err
 	}
 	var urls []*url.URL
+	params := make(map[string]*orchParams)
 	for _, addr := range addrs {
 		uri, err := url.ParseRequestURI(addr.Address)
 		if err != nil {
-			glog.Errorf("Unable to parse address  %s : %s", addr, err)
+			glog.Errorf("Unable to parse address  %s : %s", addr.Address, err)
+			continue
+		}
+		p := &orchParams{
+			region:   addr.Region,
+			priority: addr.Priority,
+			weight:   addr.Weight,
+			tags:     addr.Tags,
+		}
+		if p.weight == 0 {
+			p.weight = 1
+		} else if p.weight < 0 {
+			glog.Errorf("Invalid weight for address %s : %v", addr.Address, addr.Weight)
+			continue
+		}
+		if addr.MaxPrice != "" {
+			maxPrice, ok := new(big.Rat).SetString(addr.MaxPrice)
+			if !ok || maxPrice.Sign() < 0 {
+				glog.Errorf("Invalid max price for address %s : %s", addr.Address, addr.MaxPrice)
+				continue
+			}
+			p.maxPrice = maxPrice
+		}
+		if !p.hasTags(RequiredTags) {
 			continue
 		}
 		urls = append(urls, uri)
+		params[rttKey(uri)] = p
 	}
 
-	return urls, nil
+	return urls, params, nil
 }
@@ -42,6 +42,8 @@ const (
 	SegmentTranscodeErrorSaveData           SegmentTranscodeError = "SaveData"
 	SegmentTranscodeErrorSessionEnded       SegmentTranscodeError = "SessionEnded"
 	SegmentTranscodeErrorPlaylist           SegmentTranscodeError = "Playlist"
+	SegmentTranscodeErrorQuotaExceeded      SegmentTranscodeError = "QuotaExceeded"
+	SegmentTranscodeErrorRetriesExhausted   SegmentTranscodeError = "RetriesExhausted"
 
 	numberOfSegmentsToCalcAverage = 30
 	gweiConversionFactor          = 1000000000
@@ -89,6 +91,12 @@ type (
 		mTranscodersNumber            *stats.Int64Measure
 		mTranscodersCapacity          *stats.Int64Measure
 		mTranscodersLoad              *stats.Int64Measure
+		mRemoteTranscodeRetried       *stats.Int64Measure
+		mRemoteTranscoderQuarantined  *stats.Int64Measure
+		mSenderQuotaExceeded          *stats.Int64Measure
+		mTranscodeCacheHits           *stats.Int64Measure
+		mSegmentFallback              *stats.Int64Measure
+		mCrossCheckMismatch           *stats.Int64Measure
 		mSuccessRate                  *stats.Float64Measure
 		mTranscodeTime                *stats.Float64Measure
 		mTranscodeLatency             *stats.Float64Measure
@@ -190,6 +198,12 @@ func InitCensus(nodeType, nodeID, version string) {
 	census.mTranscodersNumber = stats.Int64("transcoders_number", "Number of transcoders currently connected to orchestrator", "tot")
 	census.mTranscodersCapacity = stats.Int64("transcoders_capacity", "Total advertised capacity of transcoders currently connected to orchestrator", "tot")
 	census.mTranscodersLoad = stats.Int64("transcoders_load", "Total load of transcoders currently connected to orchestrator", "tot")
+	census.mRemoteTranscodeRetried = stats.Int64("remote_transcode_retried_total", "Number of times a segment was re-dispatched to another remote transcoder", "tot")
+	census.mSenderQuotaExceeded = stats.Int64("sender_quota_exceeded_total", "Number of segments or sessions rejected because the sender exceeded its quota", "tot")
+	census.mRemoteTranscoderQuarantined = stats.Int64("remote_transcoders_quarantined_total", "Number of times a failing remote transcoder was quarantined", "tot")
+	census.mTranscodeCacheHits = stats.Int64("transcode_cache_hits_total", "Number of segments answered from the transcode result cache", "tot")
+	census.mSegmentFallback = stats.Int64("segment_fallback_total", "Number of segments for which a fallback was inserted into the rendition playlists", "tot")
+	census.mCrossCheckMismatch = stats.Int64("cross_check_mismatch_total", "Number of redundantly transcoded results that disagreed with the other orchestrators", "tot")
 	census.mSuccessRate = stats.Float64("success_rate", "Success rate", "per")
 	census.mTranscodeTime = stats.Float64("transcode_time_seconds", "Transcoding time", "sec")
 	census.mTranscodeLatency = stats.Float64("transcode_latency_seconds",
@@ -427,6 +441,48 @@ func InitCensus(nodeType, nodeID, version string) {
 			TagKeys:     baseTags,
 			Aggregation: view.LastValue(),
 		},
+		&view.View{
+			Name:        "remote_transcode_retried_total",
+			Measure:     census.mRemoteTranscodeRetried,
+			Description: "Number of times a segment was re-dispatched to another remote transcoder",
+			TagKeys:     append([]tag.Key{census.kErrorCode}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Name:        "sender_quota_exceeded_total",
+			Measure:     census.mSenderQuotaExceeded,
+			Description: "Number of segments or sessions rejected because the sender exceeded its quota",
+			TagKeys:     append([]tag.Key{census.kErrorCode}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Name:        "remote_transcoders_quarantined_total",
+			Measure:     census.mRemoteTranscoderQuarantined,
+			Description: "Number of times a failing remote transcoder was quarantined",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Name:        "segment_fallback_total",
+			Measure:     census.mSegmentFallback,
+			Description: "Number of segments for which a fallback was inserted into the rendition playlists",
+			TagKeys:     append([]tag.Key{census.kErrorCode}, baseTags...),
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Name:        "cross_check_mismatch_total",
+			Measure:     census.mCrossCheckMismatch,
+			Description: "Number of redundantly transcoded results that disagreed with the other orchestrators",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
+		&view.View{
+			Name:        "transcode_cache_hits_total",
+			Measure:     census.mTranscodeCacheHits,
+			Description: "Number of segments answered from the transcode result cache",
+			TagKeys:     baseTags,
+			Aggregation: view.Count(),
+		},
 
 		// Metrics for sending payments
 		&view.View{
@@ -763,6 +819,51 @@ func SetTranscodersNumberAndLoad(load, capacity, number int) {
 	stats.Record(census.ctx, census.mTranscodersNumber.M(int64(number)))
 }
 
+// RemoteTranscodeRetried records a segment being re-dispatched to another remote transcoder after an error
+func RemoteTranscodeRetried(code string) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kErrorCode, code))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mRemoteTranscodeRetried.M(1))
+}
+
+// SenderQuotaExceeded records a segment or session being rejected because its sender exceeded a quota
+func SenderQuotaExceeded(code string) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kErrorCode, code))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mSenderQuotaExceeded.M(1))
+}
+
+// RemoteTranscoderQuarantined records a remote transcoder being excluded from selection after repeated failures
+func RemoteTranscoderQuarantined() {
+	stats.Record(census.ctx, census.mRemoteTranscoderQuarantined.M(1))
+}
+
+// TranscodeCacheHit records a segment being answered from the transcode result cache
+func TranscodeCacheHit() {
+	stats.Record(census.ctx, census.mTranscodeCacheHits.M(1))
+}
+
+// SegmentFallback records a fallback being inserted into the rendition playlists in place of transcoded segments
+func SegmentFallback(mode string) {
+	ctx, err := tag.New(census.ctx, tag.Insert(census.kErrorCode, mode))
+	if err != nil {
+		glog.Error("Error creating context", err)
+		return
+	}
+	stats.Record(ctx, census.mSegmentFallback.M(1))
+}
+
+// CrossCheckMismatch records a redundantly transcoded result that disagreed with the majority of orchestrators
+func CrossCheckMismatch() {
+	stats.Record(census.ctx, census.mCrossCheckMismatch.M(1))
+}
+
 func SegmentEmerged(nonce, seqNo uint64, profilesNum int) {
 	glog.Infof("Logging SegmentEmerged... nonce=%d seqNo=%d", nonce, seqNo)
 	census.segmentEmerged(nonce, seqNo, profilesNum)
@@ -5,8 +5,9 @@ import (
 )
 
 type RemoteTranscoderInfo struct {
-	Address  string
-	Capacity int
+	Address      string
+	Capacity     int
+	Capabilities *TranscoderCapabilities `json:",omitempty"`
 }
 
 type NodeStatus struct {
@@ -19,5 +20,5 @@ type NodeStatus struct {
 	RegisteredTranscodersNumber int
 	RegisteredTranscoders       []RemoteTranscoderInfo
 	LocalTranscoding            bool // Indicates orchestrator that is also transcoder
-	// xxx add transcoder's version here
+	Draining                    bool // Indicates node that doesn't accept new work
 }
@@ -24,6 +24,34 @@ var _ = math.Inf
 // proto package needs to be updated.
 const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
 
+// Container format of transcoded segments
+type SegmentFormat int32
+
+const (
+	// MPEG transport stream
+	SegmentFormat_MPEGTS SegmentFormat = 0
+	// Fragmented MP4 (CMAF) with an initialization section
+	SegmentFormat_FMP4 SegmentFormat = 1
+)
+
+var SegmentFormat_name = map[int32]string{
+	0: "MPEGTS",
+	1: "FMP4",
+}
+
+var SegmentFormat_value = map[string]int32{
+	"MPEGTS": 0,
+	"FMP4":   1,
+}
+
+func (x SegmentFormat) String() string {
+	return proto.EnumName(SegmentFormat_name, int32(x))
+}
+
+func (SegmentFormat) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_034e29c79f9ba827, []int{0}
+}
+
 type OSInfo_StorageType int32
 
 const (
@@ -52,6 +80,32 @@ func (OSInfo_StorageType) EnumDescriptor() ([]byte, []int) {
 	return fileDescriptor_034e29c79f9ba827, []int{2, 0}
 }
 
+// Hardware acceleration used by the transcoder
+type TranscoderCapabilities_Acceleration int32
+
+const (
+	TranscoderCapabilities_SOFTWARE TranscoderCapabilities_Acceleration = 0
+	TranscoderCapabilities_NVIDIA   TranscoderCapabilities_Acceleration = 1
+)
+
+var TranscoderCapabilities_Acceleration_name = map[int32]string{
+	0: "SOFTWARE",
+	1: "NVIDIA",
+}
+
+var TranscoderCapabilities_Acceleration_value = map[string]int32{
+	"SOFTWARE": 0,
+	"NVIDIA":   1,
+}
+
+func (x TranscoderCapabilities_Acceleration) String() string {
+	return proto.EnumName(TranscoderCapabilities_Acceleration_name, int32(x))
+}
+
+func (TranscoderCapabilities_Acceleration) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_034e29c79f9ba827, []int{13, 0}
+}
+
 type PingPong struct {
 	// Implementation defined
 	Value                []byte   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
@@ -339,6 +393,8 @@ type OrchestratorInfo struct {
 	TicketParams *TicketParams `protobuf:"bytes,2,opt,name=ticket_params,json=ticketParams,proto3" json:"ticket_params,omitempty"`
 	// Price Info containing the price per pixel to transcode
 	PriceInfo *PriceInfo `protobuf:"bytes,3,opt,name=price_info,json=priceInfo,proto3" json:"price_info,omitempty"`
+	// Capabilities and limits of the orchestrator
+	Capabilities *OrchestratorCapabilities `protobuf:"bytes,4,opt,name=capabilities,proto3" json:"capabilities,omitempty"`
 	// Orchestrator returns info about own input object storage, if it wants it to be used.
 	Storage              []*OSInfo `protobuf:"bytes,32,rep,name=storage,proto3" json:"storage,omitempty"`
 	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
@@ -392,6 +448,13 @@ func (m *OrchestratorInfo) GetPriceInfo() *PriceInfo {
 	return nil
 }
 
+func (m *OrchestratorInfo) GetCapabilities() *OrchestratorCapabilities {
+	if m != nil {
+		return m.Capabilities
+	}
+	return nil
+}
+
 func (m *OrchestratorInfo) GetStorage() []*OSInfo {
 	if m != nil {
 		return m.Storage
@@ -399,6 +462,121 @@ func (m *OrchestratorInfo) GetStorage() []*OSInfo {
 	return nil
 }
 
+// The capabilities and limits an orchestrator advertises to broadcasters.
+// Orchestrators that do not send capabilities are assumed to support any stream.
+type OrchestratorCapabilities struct {
+	// Number of additional streams the orchestrator can currently accept
+	FreeSessions int32 `protobuf:"varint,1,opt,name=free_sessions,json=freeSessions,proto3" json:"free_sessions,omitempty"`
+	// Names of the video profiles that can be transcoded. Empty if any profile is supported.
+	Profiles []string `protobuf:"bytes,2,rep,name=profiles,proto3" json:"profiles,omitempty"`
+	// Output codecs that can be produced
+	Codecs []string `protobuf:"bytes,3,rep,name=codecs,proto3" json:"codecs,omitempty"`
+	// Maximum output width and height. Zero if unlimited.
+	MaxWidth  int32 `protobuf:"varint,4,opt,name=max_width,json=maxWidth,proto3" json:"max_width,omitempty"`
+	MaxHeight int32 `protobuf:"varint,5,opt,name=max_height,json=maxHeight,proto3" json:"max_height,omitempty"`
+	// Whether GPU accelerated transcoding is available
+	Gpu bool `protobuf:"varint,6,opt,name=gpu,proto3" json:"gpu,omitempty"`
+	// Version of the orchestrator node
+	Version string `protobuf:"bytes,7,opt,name=version,proto3" json:"version,omitempty"`
+	// Whether the orchestrator is draining. A draining orchestrator finishes
+	// the streams it has but doesn't accept segments for new streams.
+	Draining bool `protobuf:"varint,8,opt,name=draining,proto3" json:"draining,omitempty"`
+	// Segment formats that can be produced. Orchestrators that do not
+	// advertise any only produce MPEG-TS.
+	Formats              []SegmentFormat `protobuf:"varint,9,rep,packed,name=formats,proto3,enum=net.SegmentFormat" json:"formats,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
+	XXX_unrecognized     []byte          `json:"-"`
+	XXX_sizecache        int32           `json:"-"`
+}
+
+func (m *OrchestratorCapabilities) Reset()         { *m = OrchestratorCapabilities{} }
+func (m *OrchestratorCapabilities) String() string { return proto.CompactTextString(m) }
+func (*OrchestratorCapabilities) ProtoMessage()    {}
+func (*OrchestratorCapabilities) Descriptor() ([]byte, []int) {
+	return fileDescriptor_034e29c79f9ba827, []int{6}
+}
+
+func (m *OrchestratorCapabilities) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_OrchestratorCapabilities.Unmarshal(m, b)
+}
+func (m *OrchestratorCapabilities) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_OrchestratorCapabilities.Marshal(b, m, deterministic)
+}
+func (m *OrchestratorCapabilities) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_OrchestratorCapabilities.Merge(m, src)
+}
+func (m *OrchestratorCapabilities) XXX_Size() int {
+	return xxx_messageInfo_OrchestratorCapabilities.Size(m)
+}
+func (m *OrchestratorCapabilities) XXX_DiscardUnknown() {
+	xxx_messageInfo_OrchestratorCapabilities.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_OrchestratorCapabilities proto.InternalMessageInfo
+
+func (m *OrchestratorCapabilities) GetFreeSessions() int32 {
+	if m != nil {
+		return m.FreeSessions
+	}
+	return 0
+}
+
+func (m *OrchestratorCapabilities) GetProfiles() []string {
+	if m != nil {
+		return m.Profiles
+	}
+	return nil
+}
+
+func (m *OrchestratorCapabilities) GetCodecs() []string {
+	if m != nil {
+		return m.Codecs
+	}
+	return nil
+}
+
+func (m *OrchestratorCapabilities) GetMaxWidth() int32 {
+	if m != nil {
+		return m.MaxWidth
+	}
+	return 0
+}
+
+func (m *OrchestratorCapabilities) GetMaxHeight() int32 {
+	if m != nil {
+		return m.MaxHeight
+	}
+	return 0
+}
+
+func (m *OrchestratorCapabilities) GetGpu() bool {
+	if m != nil {
+		return m.Gpu
+	}
+	return false
+}
+
+func (m *OrchestratorCapabilities) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
+func (m *OrchestratorCapabilities) GetDraining() bool {
+	if m != nil {
+		return m.Draining
+	}
+	return false
+}
+
+func (m *OrchestratorCapabilities) GetFormats() []SegmentFormat {
+	if m != nil {
+		return m.Formats
+	}
+	return nil
+}
+
 // Data included by the broadcaster when submitting a segment for transcoding.
 type SegData struct {
 	// Manifest ID this segment belongs to
@@ -416,17 +594,19 @@ type SegData struct {
 	// XXX should we include this in a sig somewhere until certs are authenticated?
 	Storage []*OSInfo `protobuf:"bytes,32,rep,name=storage,proto3" json:"storage,omitempty"`
 	// Transcoding profiles to use. Supersedes `profiles` field
-	FullProfiles         []*VideoProfile `protobuf:"bytes,33,rep,name=fullProfiles,proto3" json:"fullProfiles,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
-	XXX_unrecognized     []byte          `json:"-"`
-	XXX_sizecache        int32           `json:"-"`
+	FullProfiles []*VideoProfile `protobuf:"bytes,33,rep,name=fullProfiles,proto3" json:"fullProfiles,omitempty"`
+	// Container format of the transcoded segments. Signed over unless MPEGTS
+	Format               SegmentFormat `protobuf:"varint,34,opt,name=format,proto3,enum=net.SegmentFormat" json:"format,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
 }
 
 func (m *SegData) Reset()         { *m = SegData{} }
 func (m *SegData) String() string { return proto.CompactTextString(m) }
 func (*SegData) ProtoMessage()    {}
 func (*SegData) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{6}
+	return fileDescriptor_034e29c79f9ba827, []int{7}
 }
 
 func (m *SegData) XXX_Unmarshal(b []byte) error {
@@ -496,6 +676,13 @@ func (m *SegData) GetFullProfiles() []*VideoProfile {
 	return nil
 }
 
+func (m *SegData) GetFormat() SegmentFormat {
+	if m != nil {
+		return m.Format
+	}
+	return SegmentFormat_MPEGTS
+}
+
 type VideoProfile struct {
 	// Name of VideoProfile
 	Name string `protobuf:"bytes,16,opt,name=name,proto3" json:"name,omitempty"`
@@ -516,7 +703,7 @@ func (m *VideoProfile) Reset()         { *m = VideoProfile{} }
 func (m *VideoProfile) String() string { return proto.CompactTextString(m) }
 func (*VideoProfile) ProtoMessage()    {}
 func (*VideoProfile) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{7}
+	return fileDescriptor_034e29c79f9ba827, []int{8}
 }
 
 func (m *VideoProfile) XXX_Unmarshal(b []byte) error {
@@ -587,7 +774,7 @@ func (m *TranscodedSegmentData) Reset()         { *m = TranscodedSegmentData{} }
 func (m *TranscodedSegmentData) String() string { return proto.CompactTextString(m) }
 func (*TranscodedSegmentData) ProtoMessage()    {}
 func (*TranscodedSegmentData) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{8}
+	return fileDescriptor_034e29c79f9ba827, []int{9}
 }
 
 func (m *TranscodedSegmentData) XXX_Unmarshal(b []byte) error {
@@ -637,7 +824,7 @@ func (m *TranscodeData) Reset()         { *m = TranscodeData{} }
 func (m *TranscodeData) String() string { return proto.CompactTextString(m) }
 func (*TranscodeData) ProtoMessage()    {}
 func (*TranscodeData) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{9}
+	return fileDescriptor_034e29c79f9ba827, []int{10}
 }
 
 func (m *TranscodeData) XXX_Unmarshal(b []byte) error {
@@ -693,7 +880,7 @@ func (m *TranscodeResult) Reset()         { *m = TranscodeResult{} }
 func (m *TranscodeResult) String() string { return proto.CompactTextString(m) }
 func (*TranscodeResult) ProtoMessage()    {}
 func (*TranscodeResult) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{10}
+	return fileDescriptor_034e29c79f9ba827, []int{11}
 }
 
 func (m *TranscodeResult) XXX_Unmarshal(b []byte) error {
@@ -778,17 +965,19 @@ type RegisterRequest struct {
 	// Shared secret for auth
 	Secret string `protobuf:"bytes,1,opt,name=secret,proto3" json:"secret,omitempty"`
 	// Transcoder capacity
-	Capacity             int64    `protobuf:"varint,2,opt,name=capacity,proto3" json:"capacity,omitempty"`
-	XXX_NoUnkeyedLiteral struct{} `json:"-"`
-	XXX_unrecognized     []byte   `json:"-"`
-	XXX_sizecache        int32    `json:"-"`
+	Capacity int64 `protobuf:"varint,2,opt,name=capacity,proto3" json:"capacity,omitempty"`
+	// Transcoder capabilities
+	Capabilities         *TranscoderCapabilities `protobuf:"bytes,3,opt,name=capabilities,proto3" json:"capabilities,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}                `json:"-"`
+	XXX_unrecognized     []byte                  `json:"-"`
+	XXX_sizecache        int32                   `json:"-"`
 }
 
 func (m *RegisterRequest) Reset()         { *m = RegisterRequest{} }
 func (m *RegisterRequest) String() string { return proto.CompactTextString(m) }
 func (*RegisterRequest) ProtoMessage()    {}
 func (*RegisterRequest) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{11}
+	return fileDescriptor_034e29c79f9ba827, []int{12}
 }
 
 func (m *RegisterRequest) XXX_Unmarshal(b []byte) error {
@@ -823,6 +1012,89 @@ func (m *RegisterRequest) GetCapacity() int64 {
 	return 0
 }
 
+func (m *RegisterRequest) GetCapabilities() *TranscoderCapabilities {
+	if m != nil {
+		return m.Capabilities
+	}
+	return nil
+}
+
+// Describes what a transcoder is able to transcode.
+type TranscoderCapabilities struct {
+	Acceleration TranscoderCapabilities_Acceleration `protobuf:"varint,1,opt,name=acceleration,proto3,enum=net.TranscoderCapabilities_Acceleration" json:"acceleration,omitempty"`
+	// Maximum output width. Zero if unlimited
+	MaxWidth int32 `protobuf:"varint,2,opt,name=max_width,json=maxWidth,proto3" json:"max_width,omitempty"`
+	// Maximum output height. Zero if unlimited
+	MaxHeight int32 `protobuf:"varint,3,opt,name=max_height,json=maxHeight,proto3" json:"max_height,omitempty"`
+	// Names of the profiles the transcoder supports. Empty if unrestricted
+	Profiles []string `protobuf:"bytes,4,rep,name=profiles,proto3" json:"profiles,omitempty"`
+	// Transcoder version
+	Version              string   `protobuf:"bytes,5,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *TranscoderCapabilities) Reset()         { *m = TranscoderCapabilities{} }
+func (m *TranscoderCapabilities) String() string { return proto.CompactTextString(m) }
+func (*TranscoderCapabilities) ProtoMessage()    {}
+func (*TranscoderCapabilities) Descriptor() ([]byte, []int) {
+	return fileDescriptor_034e29c79f9ba827, []int{13}
+}
+
+func (m *TranscoderCapabilities) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_TranscoderCapabilities.Unmarshal(m, b)
+}
+func (m *TranscoderCapabilities) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_TranscoderCapabilities.Marshal(b, m, deterministic)
+}
+func (m *TranscoderCapabilities) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_TranscoderCapabilities.Merge(m, src)
+}
+func (m *TranscoderCapabilities) XXX_Size() int {
+	return xxx_messageInfo_TranscoderCapabilities.Size(m)
+}
+func (m *TranscoderCapabilities) XXX_DiscardUnknown() {
+	xxx_messageInfo_TranscoderCapabilities.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_TranscoderCapabilities proto.InternalMessageInfo
+
+func (m *TranscoderCapabilities) GetAcceleration() TranscoderCapabilities_Acceleration {
+	if m != nil {
+		return m.Acceleration
+	}
+	return TranscoderCapabilities_SOFTWARE
+}
+
+func (m *TranscoderCapabilities) GetMaxWidth() int32 {
+	if m != nil {
+		return m.MaxWidth
+	}
+	return 0
+}
+
+func (m *TranscoderCapabilities) GetMaxHeight() int32 {
+	if m != nil {
+		return m.MaxHeight
+	}
+	return 0
+}
+
+func (m *TranscoderCapabilities) GetProfiles() []string {
+	if m != nil {
+		return m.Profiles
+	}
+	return nil
+}
+
+func (m *TranscoderCapabilities) GetVersion() string {
+	if m != nil {
+		return m.Version
+	}
+	return ""
+}
+
 // Sent by the orchestrator to the transcoder
 type NotifySegment struct {
 	// URL of the segment to transcode.
@@ -834,17 +1106,19 @@ type NotifySegment struct {
 	// Set of profiles to transcode this segment into.
 	Profiles []byte `protobuf:"bytes,17,opt,name=profiles,proto3" json:"profiles,omitempty"`
 	// Transcoding profiles to use. Supersedes `profiles` field
-	FullProfiles         []*VideoProfile `protobuf:"bytes,33,rep,name=fullProfiles,proto3" json:"fullProfiles,omitempty"`
-	XXX_NoUnkeyedLiteral struct{}        `json:"-"`
-	XXX_unrecognized     []byte          `json:"-"`
-	XXX_sizecache        int32           `json:"-"`
+	FullProfiles []*VideoProfile `protobuf:"bytes,33,rep,name=fullProfiles,proto3" json:"fullProfiles,omitempty"`
+	// Container format of the transcoded segments.
+	Format               SegmentFormat `protobuf:"varint,34,opt,name=format,proto3,enum=net.SegmentFormat" json:"format,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
 }
 
 func (m *NotifySegment) Reset()         { *m = NotifySegment{} }
 func (m *NotifySegment) String() string { return proto.CompactTextString(m) }
 func (*NotifySegment) ProtoMessage()    {}
 func (*NotifySegment) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{12}
+	return fileDescriptor_034e29c79f9ba827, []int{14}
 }
 
 func (m *NotifySegment) XXX_Unmarshal(b []byte) error {
@@ -900,6 +1174,13 @@ func (m *NotifySegment) GetFullProfiles() []*VideoProfile {
 	return nil
 }
 
+func (m *NotifySegment) GetFormat() SegmentFormat {
+	if m != nil {
+		return m.Format
+	}
+	return SegmentFormat_MPEGTS
+}
+
 // Required parameters for probabilistic micropayment tickets
 type TicketParams struct {
 	// ETH address of the recipient
@@ -923,7 +1204,7 @@ func (m *TicketParams) Reset()         { *m = TicketParams{} }
 func (m *TicketParams) String() string { return proto.CompactTextString(m) }
 func (*TicketParams) ProtoMessage()    {}
 func (*TicketParams) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{13}
+	return fileDescriptor_034e29c79f9ba827, []int{15}
 }
 
 func (m *TicketParams) XXX_Unmarshal(b []byte) error {
@@ -995,7 +1276,7 @@ func (m *TicketSenderParams) Reset()         { *m = TicketSenderParams{} }
 func (m *TicketSenderParams) String() string { return proto.CompactTextString(m) }
 func (*TicketSenderParams) ProtoMessage()    {}
 func (*TicketSenderParams) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{14}
+	return fileDescriptor_034e29c79f9ba827, []int{16}
 }
 
 func (m *TicketSenderParams) XXX_Unmarshal(b []byte) error {
@@ -1045,7 +1326,7 @@ func (m *TicketExpirationParams) Reset()         { *m = TicketExpirationParams{}
 func (m *TicketExpirationParams) String() string { return proto.CompactTextString(m) }
 func (*TicketExpirationParams) ProtoMessage()    {}
 func (*TicketExpirationParams) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{15}
+	return fileDescriptor_034e29c79f9ba827, []int{17}
 }
 
 func (m *TicketExpirationParams) XXX_Unmarshal(b []byte) error {
@@ -1103,7 +1384,7 @@ func (m *Payment) Reset()         { *m = Payment{} }
 func (m *Payment) String() string { return proto.CompactTextString(m) }
 func (*Payment) ProtoMessage()    {}
 func (*Payment) Descriptor() ([]byte, []int) {
-	return fileDescriptor_034e29c79f9ba827, []int{16}
+	return fileDescriptor_034e29c79f9ba827, []int{18}
 }
 
 func (m *Payment) XXX_Unmarshal(b []byte) error {
@@ -1160,19 +1441,23 @@ func (m *Payment) GetExpectedPrice() *PriceInfo {
 }
 
 func init() {
+	proto.RegisterEnum("net.SegmentFormat", SegmentFormat_name, SegmentFormat_value)
 	proto.RegisterEnum("net.OSInfo_StorageType", OSInfo_StorageType_name, OSInfo_StorageType_value)
+	proto.RegisterEnum("net.TranscoderCapabilities_Acceleration", TranscoderCapabilities_Acceleration_name, TranscoderCapabilities_Acceleration_value)
 	proto.RegisterType((*PingPong)(nil), "net.PingPong")
 	proto.RegisterType((*OrchestratorRequest)(nil), "net.OrchestratorRequest")
 	proto.RegisterType((*OSInfo)(nil), "net.OSInfo")
 	proto.RegisterType((*S3OSInfo)(nil), "net.S3OSInfo")
 	proto.RegisterType((*PriceInfo)(nil), "net.PriceInfo")
 	proto.RegisterType((*OrchestratorInfo)(nil), "net.OrchestratorInfo")
+	proto.RegisterType((*OrchestratorCapabilities)(nil), "net.OrchestratorCapabilities")
 	proto.RegisterType((*SegData)(nil), "net.SegData")
 	proto.RegisterType((*VideoProfile)(nil), "net.VideoProfile")
 	proto.RegisterType((*TranscodedSegmentData)(nil), "net.TranscodedSegmentData")
 	proto.RegisterType((*TranscodeData)(nil), "net.TranscodeData")
 	proto.RegisterType((*TranscodeResult)(nil), "net.TranscodeResult")
 	proto.RegisterType((*RegisterRequest)(nil), "net.RegisterRequest")
+	proto.RegisterType((*TranscoderCapabilities)(nil), "net.TranscoderCapabilities")
 	proto.RegisterType((*NotifySegment)(nil), "net.NotifySegment")
 	proto.RegisterType((*TicketParams)(nil), "net.TicketParams")
 	proto.RegisterType((*TicketSenderParams)(nil), "net.TicketSenderParams")
@@ -1183,78 +1468,95 @@ func init() {
 func init() { proto.RegisterFile("net/lp_rpc.proto", fileDescriptor_034e29c79f9ba827) }
 
 var fileDescriptor_034e29c79f9ba827 = []byte{
-	// 1122 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x56, 0xcd, 0x6e, 0xdb, 0x46,
-	0x10, 0x0e, 0x2d, 0x59, 0xb6, 0x46, 0x92, 0x23, 0x6f, 0x1c, 0x87, 0x71, 0xdb, 0x40, 0x21, 0x12,
-	0xc0, 0x3d, 0xc4, 0x2d, 0x6c, 0x24, 0x40, 0x6e, 0x4d, 0x1a, 0x23, 0x36, 0x50, 0xc4, 0xc2, 0xca,
-	0x09, 0xd0, 0x93, 0xb0, 0x22, 0x47, 0xd2, 0xc6, 0xf2, 0x92, 0x59, 0xae, 0x6a, 0x2b, 0xe8, 0x8b,
-	0xb4, 0x87, 0x1e, 0x0a, 0xf4, 0xd2, 0xa7, 0xe8, 0x7b, 0xf4, 0x65, 0x8a, 0x9d, 0x5d, 0xd2, 0x94,
-	0xed, 0x43, 0xd0, 0xdb, 0xce, 0x37, 0xb3, 0xb3, 0xf3, 0xf7, 0x0d, 0x09, 0x5d, 0x85, 0xe6, 0xbb,
-	0x59, 0x36, 0xd4, 0x59, 0xbc, 0x97, 0xe9, 0xd4, 0xa4, 0xac, 0xa6, 0xd0, 0x44, 0x3d, 0x58, 0xef,
-	0x4b, 0x35, 0xe9, 0xa7, 0x6a, 0xc2, 0xb6, 0x60, 0xf5, 0x17, 0x31, 0x9b, 0x63, 0x18, 0xf4, 0x82,
-	0xdd, 0x36, 0x77, 0x42, 0xf4, 0x0a, 0xee, 0x9d, 0xe8, 0x78, 0x8a, 0xb9, 0xd1, 0xc2, 0xa4, 0x9a,
-	0xe3, 0xa7, 0x39, 0xe6, 0x86, 0x85, 0xb0, 0x26, 0x92, 0x44, 0x63, 0x9e, 0x7b, 0xf3, 0x42, 0x64,
-	0x5d, 0xa8, 0xe5, 0x72, 0x12, 0xae, 0x10, 0x6a, 0x8f, 0xd1, 0x6f, 0x01, 0x34, 0x4e, 0x06, 0xc7,
-	0x6a, 0x9c, 0xb2, 0x97, 0xd0, 0xca, 0x4d, 0xaa, 0xc5, 0x04, 0x4f, 0x17, 0x99, 0x7b, 0x69, 0x63,
-	0xff, 0xc1, 0x9e, 0x42, 0xb3, 0xe7, 0x2c, 0xf6, 0x06, 0x57, 0x6a, 0x5e, 0xb5, 0x65, 0x4f, 0xa1,
-	0x91, 0x1f, 0x48, 0x35, 0x4e, 0xc3, 0x6e, 0x2f, 0xd8, 0x6d, 0xed, 0x77, 0xe8, 0xd6, 0xe0, 0xc0,
-	0xdd, 0xe3, 0x5e, 0x19, 0x3d, 0x83, 0x56, 0xc5, 0x05, 0x03, 0x68, 0xbc, 0x39, 0xe6, 0x87, 0x3f,
-	0x9e, 0x76, 0xef, 0xb0, 0x06, 0xac, 0x0c, 0x0e, 0xba, 0x81, 0xc5, 0xde, 0x9e, 0x9c, 0xbc, 0xfd,
-	0xe9, 0xb0, 0xbb, 0x12, 0xfd, 0x19, 0xc0, 0x7a, 0xe1, 0x83, 0x31, 0xa8, 0x4f, 0xd3, 0xdc, 0x50,
-	0x58, 0x4d, 0x4e, 0x67, 0x9b, 0xce, 0x19, 0x2e, 0x28, 0x9d, 0x26, 0xb7, 0x47, 0xb6, 0x0d, 0x8d,
-	0x2c, 0x9d, 0xc9, 0x78, 0x11, 0xd6, 0x08, 0xf4, 0x12, 0xfb, 0x1a, 0x9a, 0xb9, 0x9c, 0x28, 0x61,
-	0xe6, 0x1a, 0xc3, 0x3a, 0xa9, 0xae, 0x00, 0xf6, 0x08, 0x20, 0xd6, 0x98, 0xa0, 0x32, 0x52, 0xcc,
-	0xc2, 0x55, 0x52, 0x57, 0x10, 0xb6, 0x03, 0xeb, 0x97, 0xaf, 0xce, 0x3f, 0xbf, 0x11, 0x06, 0xc3,
-	0x06, 0x69, 0x4b, 0x39, 0x7a, 0x0f, 0xcd, 0xbe, 0x96, 0x31, 0x52, 0x90, 0x11, 0xb4, 0x33, 0x2b,
-	0xf4, 0x51, 0xbf, 0x57, 0xd2, 0x05, 0x5b, 0xe3, 0x4b, 0x18, 0x7b, 0x02, 0x9d, 0x4c, 0x5e, 0xe2,
-	0x2c, 0x2f, 0x8c, 0x56, 0xc8, 0x68, 0x19, 0x8c, 0xfe, 0x09, 0xa0, 0x5b, 0xed, 0x2d, 0xb9, 0x7f,
-	0x04, 0x60, 0xb4, 0x50, 0x79, 0x9c, 0x26, 0xa8, 0x7d, 0x25, 0x2a, 0x08, 0x7b, 0x01, 0x1d, 0x23,
-	0xe3, 0x33, 0x34, 0xc3, 0x4c, 0x68, 0x71, 0x9e, 0x93, 0xeb, 0xd6, 0xfe, 0x26, 0x75, 0xe3, 0x94,
-	0x34, 0x7d, 0x52, 0xf0, 0xb6, 0xa9, 0x48, 0xec, 0x19, 0x00, 0x85, 0x38, 0xa4, 0x16, 0xd6, 0xe8,
-	0xd2, 0x06, 0x5d, 0x2a, 0x53, 0xe3, 0xcd, 0xac, 0xcc, 0xf2, 0x29, 0xac, 0xf9, 0xe6, 0x87, 0xbd,
-	0x5e, 0x6d, 0xb7, 0xb5, 0xdf, 0xaa, 0x0c, 0x09, 0x2f, 0x74, 0xd1, 0xbf, 0x01, 0xac, 0x0d, 0x70,
-	0xf2, 0x46, 0x18, 0x61, 0x23, 0x3f, 0x17, 0x4a, 0x8e, 0x31, 0x37, 0xc7, 0x89, 0x9f, 0xca, 0x0a,
-	0x42, 0x83, 0x89, 0x9f, 0x7c, 0x29, 0xec, 0x91, 0xfa, 0x2d, 0xf2, 0x29, 0x45, 0xd3, 0xe6, 0x74,
-	0xb6, 0x7d, 0xc8, 0x74, 0x3a, 0x96, 0x33, 0xcc, 0xa9, 0x89, 0x6d, 0x5e, 0xca, 0xc5, 0x68, 0xaf,
-	0x96, 0xa3, 0xfd, 0x85, 0x61, 0xb2, 0xe7, 0xd0, 0x1e, 0xcf, 0x67, 0xb3, 0x7e, 0xe1, 0xf8, 0x31,
-	0xd9, 0xba, 0x9a, 0x7d, 0x90, 0x09, 0xa6, 0x5e, 0xc3, 0x97, 0xcc, 0xa2, 0x5f, 0xa1, 0x5d, 0xd5,
-	0xda, 0x78, 0x95, 0x38, 0x47, 0x22, 0x40, 0x93, 0xd3, 0xd9, 0xb2, 0xf6, 0x42, 0x26, 0x66, 0x1a,
-	0x6e, 0xf6, 0x82, 0xdd, 0x55, 0xee, 0x04, 0x3b, 0xa3, 0x53, 0x94, 0x93, 0xa9, 0x09, 0x19, 0xc1,
-	0x5e, 0xb2, 0xb4, 0x1d, 0x49, 0xdb, 0x6d, 0x0c, 0xef, 0x91, 0xa2, 0x10, 0x6d, 0x6e, 0xe3, 0x2c,
-	0x0f, 0xb7, 0x7a, 0xc1, 0x6e, 0x87, 0xdb, 0x63, 0xf4, 0x0a, 0xee, 0x9f, 0x16, 0x7d, 0x4f, 0x06,
-	0x38, 0x39, 0x47, 0x65, 0xa8, 0xd0, 0x5d, 0xa8, 0xcd, 0xf5, 0xcc, 0xcf, 0x86, 0x3d, 0x12, 0x25,
-	0x68, 0xb4, 0x7c, 0x75, 0xbd, 0x14, 0xfd, 0x0c, 0x9d, 0xd2, 0x05, 0x5d, 0x7d, 0x01, 0xeb, 0xb9,
-	0xf3, 0x64, 0xf7, 0x86, 0x2d, 0xc2, 0x8e, 0x1b, 0x9c, 0xdb, 0x1e, 0xe2, 0xa5, 0xed, 0x2d, 0x4b,
-	0xe5, 0xf7, 0x00, 0xee, 0x96, 0xb7, 0x38, 0xe6, 0xf3, 0x99, 0x29, 0x3a, 0x1c, 0x5c, 0x75, 0x78,
-	0x1b, 0x56, 0x51, 0xeb, 0x54, 0x3b, 0xfe, 0x1e, 0xdd, 0xe1, 0x4e, 0x64, 0xbb, 0x50, 0x4f, 0x84,
-	0x11, 0x7e, 0x0e, 0xd9, 0x72, 0x0c, 0xf6, 0xed, 0xa3, 0x3b, 0x9c, 0x2c, 0xd8, 0xb7, 0x50, 0xaf,
-	0x2c, 0x9d, 0xfb, 0xae, 0xbd, 0xd7, 0x48, 0xc3, 0xc9, 0xe4, 0xf5, 0x3a, 0x34, 0x34, 0x05, 0x12,
-	0x1d, 0xc2, 0x5d, 0x8e, 0x13, 0x99, 0x1b, 0x2c, 0x17, 0xe6, 0x36, 0x34, 0x72, 0x8c, 0x35, 0x16,
-	0xdb, 0xc5, 0x4b, 0x76, 0xde, 0x62, 0x91, 0x89, 0x58, 0x9a, 0x85, 0x2f, 0x5e, 0x29, 0x47, 0x7f,
-	0x04, 0xd0, 0x79, 0x97, 0x1a, 0x39, 0x5e, 0xf8, 0xaa, 0xdc, 0x52, 0xfa, 0x2e, 0xd4, 0x3e, 0xa6,
-	0xa3, 0x62, 0x3f, 0x7d, 0x4c, 0x47, 0xf6, 0x25, 0x23, 0xf2, 0xb3, 0xe3, 0x84, 0x62, 0xae, 0x71,
-	0x2f, 0x2d, 0x4d, 0xf6, 0xe6, 0xb5, 0xc9, 0xfe, 0x9f, 0x03, 0xfa, 0x77, 0x00, 0xed, 0x2a, 0xe7,
-	0xed, 0x0e, 0xd4, 0x18, 0xcb, 0x4c, 0xa2, 0x32, 0x9e, 0x82, 0x57, 0x00, 0xfb, 0x06, 0x60, 0x2c,
-	0x62, 0x1c, 0xba, 0xcf, 0x8c, 0x6b, 0x66, 0xd3, 0x22, 0x1f, 0x2c, 0xc0, 0x1e, 0xc2, 0xfa, 0x85,
-	0x54, 0xc3, 0x4c, 0xa7, 0x23, 0x4f, 0xc9, 0xb5, 0x0b, 0xa9, 0xfa, 0x3a, 0x1d, 0xb1, 0x3d, 0xb8,
-	0x57, 0xba, 0x19, 0x6a, 0xa1, 0x92, 0x21, 0x11, 0xd7, 0x11, 0x74, 0xb3, 0x54, 0x71, 0xa1, 0x92,
-	0x23, 0xcb, 0x62, 0x06, 0xf5, 0x1c, 0x31, 0xf1, 0x54, 0xa5, 0x73, 0x74, 0x0c, 0xcc, 0xc5, 0x3a,
-	0x40, 0x95, 0xa0, 0xf6, 0x11, 0x3f, 0x86, 0x76, 0x4e, 0xf2, 0x50, 0xa5, 0x2a, 0x76, 0x9f, 0xa4,
-	0x0e, 0x6f, 0x39, 0xec, 0x9d, 0x85, 0x6e, 0x19, 0xbe, 0xcf, 0xb0, 0xed, 0x5c, 0x1d, 0x5e, 0x66,
-	0x52, 0x0b, 0x23, 0x53, 0xe5, 0xdd, 0x3d, 0x85, 0x8d, 0x58, 0x23, 0x21, 0x43, 0x9d, 0xce, 0x55,
-	0xe2, 0xa7, 0xb1, 0x53, 0xa0, 0xdc, 0x82, 0xec, 0x25, 0x3c, 0x5c, 0x36, 0x1b, 0x8e, 0x66, 0x69,
-	0x7c, 0xe6, 0xb2, 0x72, 0x0f, 0x6d, 0x2f, 0xdd, 0x78, 0x6d, 0xd5, 0x36, 0xb5, 0xe8, 0xaf, 0x15,
-	0x58, 0xeb, 0x8b, 0x05, 0x8d, 0xc3, 0x8d, 0x65, 0x1c, 0x7c, 0xd9, 0x32, 0xa6, 0x61, 0xb4, 0x09,
-	0xfa, 0xb7, 0xbc, 0xc4, 0x8e, 0x60, 0x13, 0xcb, 0x8c, 0x0a, 0x9f, 0x8e, 0x23, 0x5f, 0x55, 0x7c,
-	0x5e, 0xcf, 0x9a, 0x77, 0xf1, 0x7a, 0x1d, 0x8e, 0x61, 0xcb, 0x47, 0xe6, 0xab, 0xeb, 0x9d, 0xd5,
-	0x69, 0xb0, 0x1e, 0x54, 0x9c, 0x55, 0xbb, 0xc1, 0x99, 0xb9, 0xd9, 0xa1, 0xe7, 0xb0, 0x81, 0x97,
-	0x19, 0xc6, 0x06, 0x93, 0x21, 0x7d, 0x20, 0xa8, 0xab, 0x37, 0xbf, 0x1e, 0x9d, 0xc2, 0x8a, 0xa0,
-	0xfd, 0x4b, 0x68, 0x57, 0x79, 0xca, 0x5e, 0xc3, 0xdd, 0xb7, 0x68, 0x96, 0xa0, 0xf0, 0x06, 0x9b,
-	0x3d, 0x5b, 0x77, 0x6e, 0xe7, 0x39, 0x7b, 0x02, 0x75, 0xfb, 0xbb, 0xc4, 0xdc, 0xbf, 0x47, 0xf1,
-	0xe7, 0xb4, 0xb3, 0x2c, 0xee, 0xbf, 0x03, 0x38, 0xbd, 0xfa, 0x60, 0xfe, 0x00, 0xac, 0xd8, 0x05,
-	0x15, 0x74, 0x8b, 0xae, 0x5c, 0x5b, 0x12, 0x3b, 0x6e, 0x11, 0x2d, 0x51, 0xfe, 0xfb, 0x60, 0xd4,
-	0xa0, 0x1f, 0xb6, 0x83, 0xff, 0x02, 0x00, 0x00, 0xff, 0xff, 0x30, 0x9c, 0xf7, 0xdb, 0xc4, 0x09,
-	0x00, 0x00,
+	// 1408 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xbc, 0x57, 0x5d, 0x6f, 0x13, 0x47,
+	0x17, 0xce, 0xfa, 0x2b, 0xf6, 0xb1, 0x1d, 0x9c, 0x21, 0x84, 0x25, 0xbc, 0x20, 0xb3, 0x2f, 0x91,
+	0x5c, 0x54, 0xd2, 0xca, 0x29, 0x48, 0x5c, 0xb5, 0x81, 0x84, 0xc4, 0x12, 0x24, 0xd6, 0x38, 0x80,
+	0x7a, 0x65, 0x8d, 0x77, 0xc7, 0xce, 0x10, 0x7b, 0x77, 0x99, 0x1d, 0x43, 0x82, 0x7a, 0xd5, 0x4a,
+	0xed, 0x6f, 0x68, 0x2f, 0x2b, 0xf5, 0xa6, 0x3f, 0xa5, 0xbf, 0xaa, 0x9a, 0xb3, 0xb3, 0x9b, 0xdd,
+	0xc4, 0x20, 0xae, 0x7a, 0x37, 0xe7, 0x39, 0x67, 0xce, 0x9e, 0x39, 0x1f, 0xcf, 0xcc, 0x42, 0xcb,
+	0xe7, 0xea, 0x9b, 0x69, 0x38, 0x94, 0xa1, 0xbb, 0x15, 0xca, 0x40, 0x05, 0xa4, 0xe8, 0x73, 0xe5,
+	0xb4, 0xa1, 0xda, 0x17, 0xfe, 0xa4, 0x1f, 0xf8, 0x13, 0xb2, 0x06, 0xe5, 0xf7, 0x6c, 0x3a, 0xe7,
+	0xb6, 0xd5, 0xb6, 0x3a, 0x0d, 0x1a, 0x0b, 0xce, 0x0e, 0x5c, 0x3f, 0x92, 0xee, 0x09, 0x8f, 0x94,
+	0x64, 0x2a, 0x90, 0x94, 0xbf, 0x9b, 0xf3, 0x48, 0x11, 0x1b, 0x96, 0x99, 0xe7, 0x49, 0x1e, 0x45,
+	0xc6, 0x3c, 0x11, 0x49, 0x0b, 0x8a, 0x91, 0x98, 0xd8, 0x05, 0x44, 0xf5, 0xd2, 0xf9, 0xdd, 0x82,
+	0xca, 0xd1, 0xa0, 0xe7, 0x8f, 0x03, 0xf2, 0x04, 0xea, 0x91, 0x0a, 0x24, 0x9b, 0xf0, 0xe3, 0xf3,
+	0x30, 0xfe, 0xd2, 0x4a, 0xf7, 0xe6, 0x96, 0xcf, 0xd5, 0x56, 0x6c, 0xb1, 0x35, 0xb8, 0x50, 0xd3,
+	0xac, 0x2d, 0xd9, 0x84, 0x4a, 0xb4, 0x2d, 0xfc, 0x71, 0x60, 0xb7, 0xda, 0x56, 0xa7, 0xde, 0x6d,
+	0xe2, 0xae, 0xc1, 0x76, 0xbc, 0x8f, 0x1a, 0xa5, 0xf3, 0x10, 0xea, 0x19, 0x17, 0x04, 0xa0, 0xb2,
+	0xdb, 0xa3, 0x7b, 0xcf, 0x8e, 0x5b, 0x4b, 0xa4, 0x02, 0x85, 0xc1, 0x76, 0xcb, 0xd2, 0xd8, 0xfe,
+	0xd1, 0xd1, 0xfe, 0x8b, 0xbd, 0x56, 0xc1, 0xf9, 0xd3, 0x82, 0x6a, 0xe2, 0x83, 0x10, 0x28, 0x9d,
+	0x04, 0x91, 0xc2, 0xb0, 0x6a, 0x14, 0xd7, 0xfa, 0x38, 0xa7, 0xfc, 0x1c, 0x8f, 0x53, 0xa3, 0x7a,
+	0x49, 0xd6, 0xa1, 0x12, 0x06, 0x53, 0xe1, 0x9e, 0xdb, 0x45, 0x04, 0x8d, 0x44, 0xfe, 0x07, 0xb5,
+	0x48, 0x4c, 0x7c, 0xa6, 0xe6, 0x92, 0xdb, 0x25, 0x54, 0x5d, 0x00, 0xe4, 0x2e, 0x80, 0x2b, 0xb9,
+	0xc7, 0x7d, 0x25, 0xd8, 0xd4, 0x2e, 0xa3, 0x3a, 0x83, 0x90, 0x0d, 0xa8, 0x9e, 0xed, 0xcc, 0x3e,
+	0xee, 0x32, 0xc5, 0xed, 0x0a, 0x6a, 0x53, 0xd9, 0x79, 0x05, 0xb5, 0xbe, 0x14, 0x2e, 0xc7, 0x20,
+	0x1d, 0x68, 0x84, 0x5a, 0xe8, 0x73, 0xf9, 0xca, 0x17, 0x71, 0xb0, 0x45, 0x9a, 0xc3, 0xc8, 0x7d,
+	0x68, 0x86, 0xe2, 0x8c, 0x4f, 0xa3, 0xc4, 0xa8, 0x80, 0x46, 0x79, 0xd0, 0xf9, 0xa5, 0x00, 0xad,
+	0x6c, 0x6d, 0xd1, 0xfd, 0x5d, 0x00, 0x25, 0x99, 0x1f, 0xb9, 0x81, 0xc7, 0xa5, 0xc9, 0x44, 0x06,
+	0x21, 0x8f, 0xa1, 0xa9, 0x84, 0x7b, 0xca, 0xd5, 0x30, 0x64, 0x92, 0xcd, 0x22, 0x74, 0x5d, 0xef,
+	0xae, 0x62, 0x35, 0x8e, 0x51, 0xd3, 0x47, 0x05, 0x6d, 0xa8, 0x8c, 0x44, 0x1e, 0x02, 0x60, 0x88,
+	0x43, 0x2c, 0x61, 0x11, 0x37, 0xad, 0xe0, 0xa6, 0xf4, 0x68, 0xb4, 0x16, 0xa6, 0xa7, 0xdc, 0x81,
+	0x86, 0xcb, 0x42, 0x36, 0x12, 0x53, 0xa1, 0x04, 0x8f, 0x30, 0x9f, 0xf5, 0xee, 0x9d, 0xb8, 0x53,
+	0x32, 0x31, 0x3f, 0xcb, 0x18, 0xd1, 0xdc, 0x16, 0xb2, 0x09, 0xcb, 0xa6, 0x7f, 0xec, 0x76, 0xbb,
+	0xd8, 0xa9, 0x77, 0xeb, 0x99, 0x3e, 0xa3, 0x89, 0xce, 0xf9, 0xab, 0x00, 0xf6, 0xa7, 0x3c, 0x92,
+	0xff, 0x43, 0x73, 0x2c, 0x39, 0x1f, 0x46, 0x3c, 0x8a, 0x44, 0xe0, 0xc7, 0xcd, 0x5e, 0xa6, 0x0d,
+	0x0d, 0x0e, 0x0c, 0xa6, 0x4b, 0x17, 0xca, 0x60, 0x2c, 0xa6, 0x5c, 0x67, 0xa3, 0xa8, 0x4b, 0x97,
+	0xc8, 0xba, 0x59, 0x74, 0xde, 0xdc, 0xc8, 0x2e, 0xa2, 0xc6, 0x48, 0xe4, 0x36, 0xd4, 0x66, 0xec,
+	0x6c, 0xf8, 0x41, 0x78, 0xea, 0x04, 0x0f, 0x57, 0xa6, 0xd5, 0x19, 0x3b, 0x7b, 0xa3, 0x65, 0x72,
+	0x07, 0x40, 0x2b, 0x4f, 0xb8, 0x98, 0x9c, 0x28, 0xec, 0x95, 0x32, 0xd5, 0xe6, 0x07, 0x08, 0xe8,
+	0x96, 0x9c, 0x84, 0x73, 0xec, 0x92, 0x2a, 0xd5, 0x4b, 0x3d, 0x8d, 0xef, 0xb9, 0xd4, 0xd1, 0xd8,
+	0xcb, 0x58, 0xb1, 0x44, 0xd4, 0xb1, 0x79, 0x92, 0x09, 0x5f, 0xf8, 0x13, 0xbb, 0x8a, 0x1b, 0x52,
+	0x99, 0x7c, 0x0d, 0xcb, 0xe3, 0x40, 0xce, 0x98, 0x8a, 0xec, 0x5a, 0xbb, 0xd8, 0x59, 0xe9, 0x92,
+	0x78, 0xa4, 0xf8, 0x64, 0xc6, 0x7d, 0xf5, 0x1c, 0x55, 0x34, 0x31, 0x71, 0x7e, 0x2b, 0xc0, 0xf2,
+	0x80, 0x4f, 0x76, 0x99, 0x62, 0xba, 0x49, 0x66, 0xcc, 0x17, 0x63, 0x1e, 0xa9, 0x9e, 0x67, 0x08,
+	0x20, 0x83, 0x20, 0x07, 0xf0, 0x77, 0xa6, 0xeb, 0xf4, 0x12, 0x47, 0x8b, 0x45, 0x27, 0x58, 0xf8,
+	0x06, 0xc5, 0x75, 0x2e, 0x6f, 0x25, 0xc4, 0x2f, 0xf2, 0x66, 0x58, 0xa4, 0x9c, 0xb2, 0xc8, 0x17,
+	0x96, 0x93, 0x3c, 0x82, 0xc6, 0x78, 0x3e, 0x9d, 0xf6, 0x13, 0xc7, 0xf7, 0xda, 0xc5, 0xb4, 0x3d,
+	0x5f, 0x0b, 0x8f, 0x07, 0x46, 0x43, 0x73, 0x66, 0xe4, 0x01, 0x54, 0xe2, 0x83, 0xda, 0x4e, 0xdb,
+	0xfa, 0x44, 0x2a, 0x8c, 0x85, 0xf3, 0x13, 0x34, 0xb2, 0x9e, 0xf4, 0xd9, 0x7c, 0x36, 0xe3, 0xc8,
+	0x4b, 0x35, 0x8a, 0x6b, 0x4d, 0xa6, 0x71, 0x6d, 0x57, 0xb1, 0x7a, 0xb1, 0xa0, 0xbb, 0xc1, 0x14,
+	0x95, 0x20, 0x6c, 0x24, 0x5d, 0xbf, 0x91, 0xd0, 0xed, 0xc7, 0xed, 0xeb, 0xa8, 0x48, 0x44, 0x9d,
+	0x87, 0x71, 0x18, 0xd9, 0x6b, 0x6d, 0xab, 0xd3, 0xa4, 0x7a, 0xe9, 0xec, 0xc0, 0x8d, 0xe3, 0x64,
+	0x1c, 0x3d, 0x13, 0x20, 0x16, 0xa5, 0x05, 0xc5, 0xb9, 0x9c, 0x9a, 0x91, 0xd5, 0x4b, 0x64, 0x2a,
+	0x9c, 0x78, 0x53, 0x09, 0x23, 0x39, 0x3f, 0x42, 0x33, 0x75, 0x81, 0x5b, 0x1f, 0x43, 0x35, 0x8a,
+	0x3d, 0xe9, 0x0e, 0xd7, 0x09, 0xdb, 0x88, 0xe7, 0x79, 0xd1, 0x87, 0x68, 0x6a, 0xbb, 0x80, 0xeb,
+	0xff, 0xb0, 0xe0, 0x5a, 0xba, 0x8b, 0xf2, 0x68, 0x3e, 0x55, 0x49, 0x37, 0x58, 0x17, 0xdd, 0xb0,
+	0x0e, 0x65, 0x2e, 0x65, 0x20, 0x63, 0x5a, 0x3d, 0x58, 0xa2, 0xb1, 0x48, 0x3a, 0x50, 0xf2, 0x98,
+	0x62, 0x86, 0x1e, 0x48, 0x3e, 0x06, 0xfd, 0xed, 0x83, 0x25, 0x8a, 0x16, 0xe4, 0x2b, 0x28, 0x65,
+	0xee, 0x82, 0x1b, 0x57, 0x78, 0x01, 0x9b, 0x02, 0x4d, 0x9e, 0x56, 0xa1, 0x22, 0x31, 0x10, 0xe7,
+	0x57, 0x0b, 0xae, 0x51, 0x3e, 0x11, 0x91, 0xe2, 0xe9, 0x45, 0xb6, 0x0e, 0x95, 0x88, 0xbb, 0x92,
+	0x27, 0xac, 0x6f, 0x24, 0xdd, 0x9c, 0x9a, 0x4d, 0x5c, 0xa1, 0xce, 0x4d, 0xf6, 0x52, 0x99, 0x7c,
+	0x7f, 0x89, 0x9c, 0xe2, 0x70, 0x6f, 0xe7, 0xc3, 0xfd, 0x0c, 0x35, 0x39, 0x3f, 0x17, 0x60, 0x7d,
+	0xb1, 0x21, 0x79, 0x01, 0x0d, 0xe6, 0xba, 0x7c, 0xca, 0x25, 0x53, 0x7a, 0x9e, 0xe3, 0x2b, 0xb2,
+	0xf3, 0x19, 0xdf, 0x5b, 0x3b, 0x19, 0x7b, 0x9a, 0xdb, 0x9d, 0xa7, 0x99, 0xc2, 0x67, 0x69, 0xa6,
+	0x78, 0x99, 0x66, 0xf2, 0xe3, 0x99, 0xa7, 0xb5, 0x0c, 0xe1, 0x94, 0x73, 0x84, 0xe3, 0x74, 0xa0,
+	0x91, 0x8d, 0x87, 0x34, 0xa0, 0x3a, 0x38, 0x7a, 0x7e, 0xfc, 0x66, 0x87, 0xee, 0xb5, 0x96, 0xf4,
+	0xd5, 0x7b, 0xf8, 0xba, 0xb7, 0xdb, 0xdb, 0x69, 0x59, 0xce, 0x3f, 0x16, 0x34, 0x0f, 0x03, 0x25,
+	0xc6, 0xe7, 0xa6, 0xb9, 0x16, 0x74, 0x70, 0x0b, 0x8a, 0x6f, 0x83, 0x51, 0x72, 0xfb, 0xbe, 0x0d,
+	0x46, 0xba, 0x5e, 0x8a, 0x45, 0xa7, 0x3d, 0x0f, 0x4b, 0x5f, 0xa4, 0x46, 0xca, 0x45, 0xbb, 0x7a,
+	0x89, 0x4c, 0xfe, 0x03, 0x4e, 0xf8, 0xdb, 0x82, 0x46, 0xf6, 0xf6, 0xd3, 0xaf, 0x01, 0xc9, 0x5d,
+	0x11, 0x0a, 0xee, 0x2b, 0xc3, 0x90, 0x17, 0x80, 0x4e, 0xfd, 0x98, 0xb9, 0x7c, 0x18, 0x3f, 0xb8,
+	0xe2, 0xf9, 0xa9, 0x69, 0xe4, 0xb5, 0x06, 0xc8, 0x2d, 0xa8, 0x7e, 0x10, 0xfe, 0x30, 0x94, 0xc1,
+	0xc8, 0x30, 0xe6, 0xf2, 0x07, 0xe1, 0xf7, 0x65, 0x30, 0x22, 0x5b, 0x70, 0x3d, 0x75, 0x33, 0x94,
+	0xcc, 0xf7, 0x86, 0xc8, 0xab, 0x31, 0x7f, 0xae, 0xa6, 0x2a, 0xca, 0x7c, 0xef, 0x40, 0x93, 0x2c,
+	0x81, 0x52, 0xc4, 0xb9, 0x67, 0x98, 0x14, 0xd7, 0x4e, 0x0f, 0x48, 0x1c, 0xeb, 0x80, 0xfb, 0x1e,
+	0x97, 0x26, 0xe2, 0x7b, 0xd0, 0x88, 0x50, 0x1e, 0xfa, 0x81, 0xef, 0xc6, 0x8f, 0xb3, 0x26, 0xad,
+	0xc7, 0xd8, 0xa1, 0x86, 0x16, 0xcc, 0xfb, 0x47, 0x58, 0x8f, 0x5d, 0xed, 0x9d, 0x85, 0x22, 0x2e,
+	0xb9, 0x71, 0xb7, 0x09, 0x2b, 0xae, 0xe4, 0x88, 0x0c, 0x65, 0x30, 0xf7, 0x3d, 0x43, 0x00, 0xcd,
+	0x04, 0xa5, 0x1a, 0x24, 0x4f, 0xe0, 0x56, 0xde, 0x6c, 0x38, 0x9a, 0x06, 0xee, 0x69, 0x7c, 0xaa,
+	0xf8, 0x43, 0xeb, 0xb9, 0x1d, 0x4f, 0xb5, 0x5a, 0x1f, 0x4d, 0xdf, 0xdc, 0xcb, 0x7d, 0x76, 0x8e,
+	0xad, 0x73, 0xe5, 0x59, 0x62, 0x7d, 0xd9, 0xb3, 0x04, 0xc7, 0x5f, 0x1f, 0xd0, 0x7c, 0xcb, 0x48,
+	0xe4, 0x00, 0x56, 0x79, 0x7a, 0xa2, 0xc4, 0x67, 0x6e, 0xce, 0x17, 0x9e, 0x9a, 0xb6, 0xf8, 0xe5,
+	0x3c, 0xf4, 0x60, 0xcd, 0x44, 0x66, 0xb2, 0x6b, 0x9c, 0x95, 0xb0, 0x09, 0x6f, 0x66, 0x9c, 0x65,
+	0xab, 0x41, 0x89, 0xba, 0x5a, 0xa1, 0x47, 0xb0, 0xc2, 0xcf, 0x42, 0xee, 0x2a, 0xee, 0x0d, 0xf1,
+	0xa9, 0x64, 0x97, 0x17, 0xbe, 0xa3, 0x9a, 0x89, 0x15, 0x42, 0x0f, 0x36, 0xa1, 0x99, 0x6b, 0x5a,
+	0x3d, 0x85, 0x2f, 0xfb, 0x7b, 0xfb, 0xc7, 0x83, 0xd6, 0x12, 0xa9, 0x42, 0xe9, 0xf9, 0xcb, 0xfe,
+	0x77, 0x2d, 0xab, 0x7b, 0x06, 0x8d, 0x2c, 0x83, 0x92, 0xa7, 0x70, 0x6d, 0x9f, 0xab, 0x1c, 0x64,
+	0x5f, 0xe1, 0x59, 0x43, 0xa3, 0x1b, 0x8b, 0x19, 0x98, 0xdc, 0x87, 0x92, 0xfe, 0xbf, 0x20, 0xf1,
+	0x63, 0x3d, 0xf9, 0xd5, 0xd8, 0xc8, 0x8b, 0xdd, 0x43, 0x80, 0x0b, 0x6a, 0x23, 0x3f, 0x00, 0x49,
+	0x48, 0x3a, 0x83, 0xae, 0xe1, 0x96, 0x4b, 0xec, 0xbd, 0x11, 0x8f, 0x64, 0x8e, 0x45, 0xbe, 0xb5,
+	0x46, 0x15, 0xfc, 0xc3, 0xd9, 0xfe, 0x77, 0x00, 0x86, 0x0e, 0xad, 0x20, 0xf5, 0x0c, 0x00, 0x00,
 }
 
 // Reference imports to suppress errors if they are not otherwise used.
@@ -10,6 +10,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/golang/glog"
 
@@ -63,18 +64,29 @@ type BroadcastSessionsManager struct {
 	createSessions func() ([]*BroadcastSession, error)
 }
 
+// checkSessions refreshes the sessions in the background if running low
+// and returns whether any are available.
+// Expects the mutex `bsm.sessLock` to be locked by the caller.
+func (bsm *BroadcastSessionsManager) checkSessions() bool {
+	numSess := bsm.sel.Size()
+	if numSess < int(math.Ceil(float64(bsm.numOrchs)/2.0)) {
+		go bsm.refreshSessions()
+	}
+	return numSess > 0
+}
+
+// hasSessions returns whether any sessions are available, refreshing them if needed
+func (bsm *BroadcastSessionsManager) hasSessions() bool {
+	bsm.sessLock.Lock()
+	defer bsm.sessLock.Unlock()
+	return bsm.checkSessions()
+}
+
 func (bsm *BroadcastSessionsManager) selectSession() *BroadcastSession {
 	bsm.sessLock.Lock()
 	defer bsm.sessLock.Unlock()
 
-	checkSessions := func(m *BroadcastSessionsManager) bool {
-		numSess := m.sel.Size()
-		if numSess < int(math.Ceil(float64(m.numOrchs)/2.0)) {
-			go m.refreshSessions()
-		}
-		return numSess > 0
-	}
-	for checkSessions(bsm) {
+	for bsm.checkSessions() {
 		sess := bsm.sel.Select()
 		// Handle the case where there is an error during selection and Select() returns nil when session list length > 0
 		if sess == nil {
@@ -96,6 +108,19 @@ func (bsm *BroadcastSessionsManager) selectSession() *BroadcastSession {
 	return nil
 }
 
+// selectSessions selects up to count sessions for transcoding a segment redundantly
+func (bsm *BroadcastSessionsManager) selectSessions(count int) []*BroadcastSession {
+	var sessions []*BroadcastSession
+	for len(sessions) < count {
+		sess := bsm.selectSession()
+		if sess == nil {
+			break
+		}
+		sessions = append(sessions, sess)
+	}
+	return sessions
+}
+
 func (bsm *BroadcastSessionsManager) removeSession(session *BroadcastSession) {
 	bsm.sessLock.Lock()
 	defer bsm.sessLock.Unlock()
@@ -103,6 +128,25 @@ func (bsm *BroadcastSessionsManager) removeSession(session *BroadcastSession) {
 	delete(bsm.sessMap, session.OrchestratorInfo.Transcoder)
 }
 
+// failSession removes a session after a failure of its orchestrator
+func (bsm *BroadcastSessionsManager) failSession(session *BroadcastSession, failure OrchFailure) {
+	bsm.removeSession(session)
+	if OrchReputation != nil {
+		OrchReputation.Failed(session.OrchestratorInfo.Transcoder, failure)
+	}
+}
+
+// penalizeSession lowers the selector's score of a session whose already completed
+// result turned out to be wrong
+func (bsm *BroadcastSessionsManager) penalizeSession(sess *BroadcastSession) {
+	bsm.sessLock.Lock()
+	defer bsm.sessLock.Unlock()
+
+	if p, ok := bsm.sel.(sessionPenalizer); ok {
+		p.Penalize(sess)
+	}
+}
+
 func (bsm *BroadcastSessionsManager) completeSession(sess *BroadcastSession) {
 	bsm.sessLock.Lock()
 	defer bsm.sessLock.Unlock()
@@ -193,13 +237,54 @@ func NewSessionManager(node *core.LivepeerNode, params *streamParameters, pl cor
 	return bsm
 }
 
+// canTranscodeStream returns a discovery predicate that skips orchestrators
+// advertising that they cannot transcode the stream's profiles, and orchestrators
+// that don't advertise the stream's segment format
+func canTranscodeStream(params *streamParameters) func(*net.OrchestratorInfo) bool {
+	return func(info *net.OrchestratorInfo) bool {
+		if !core.OrchestratorSupportsFormat(info.GetCapabilities(), params.format) {
+			glog.V(common.DEBUG).Infof("Skipping orchestrator=%v that cannot produce format=%v for manifestID=%v", info.Transcoder, params.format, params.mid)
+			return false
+		}
+		if core.OrchestratorCanTranscode(info.GetCapabilities(), params.profiles) {
+			return true
+		}
+		glog.V(common.DEBUG).Infof("Skipping orchestrator=%v that cannot transcode profiles for manifestID=%v", info.Transcoder, params.mid)
+		return false
+	}
+}
+
+// orchMaxPricer is implemented by orchestrator pools that set the max price of some orchestrators
+type orchMaxPricer interface {
+	MaxPrice(info *net.OrchestratorInfo) *big.Rat
+}
+
+// formatVerifier is implemented by verifiers that only check renditions in some formats
+type formatVerifier interface {
+	SupportsFormat(format core.SegmentFormat) bool
+}
+
+// verifiableFormat returns whether the verification policy, if any, can check renditions in the format
+func verifiableFormat(format core.SegmentFormat) bool {
+	if Policy == nil || Policy.Verifier == nil {
+		return true
+	}
+	if v, ok := Policy.Verifier.(formatVerifier); ok {
+		return v.SupportsFormat(format)
+	}
+	return true
+}
+
 func selectOrchestrator(n *core.LivepeerNode, params *streamParameters, cpl core.PlaylistManager, count int) ([]*BroadcastSession, error) {
 	if n.OrchestratorPool == nil {
 		glog.Info("No orchestrators specified; not transcoding")
 		return nil, errDiscovery
 	}
 
-	tinfos, err := n.OrchestratorPool.GetOrchestrators(count)
+	canTranscode := canTranscodeStream(params)
+	tinfos, err := n.OrchestratorPool.GetOrchestrators(count, func(info *net.OrchestratorInfo) bool {
+		return notCoolingDown(info) && canTranscode(info)
+	})
 	if len(tinfos) <= 0 {
 		glog.Info("No orchestrators found; not transcoding. Error: ", err)
 		return nil, errNoOrchs
@@ -240,6 +325,7 @@ func selectOrchestrator(n *core.LivepeerNode, params *streamParameters, cpl core
 			Broadcaster:      core.NewBroadcaster(n),
 			ManifestID:       params.mid,
 			Profiles:         params.profiles,
+			Format:           params.format,
 			OrchestratorInfo: tinfo,
 			OrchestratorOS:   orchOS,
 			BroadcasterOS:    bcastOS,
@@ -247,6 +333,10 @@ func selectOrchestrator(n *core.LivepeerNode, params *streamParameters, cpl core
 			PMSessionID:      sessionID,
 			Balance:          balance,
 		}
+		if pricer, ok := n.OrchestratorPool.(orchMaxPricer); ok {
+			session.MaxPrice = pricer.MaxPrice(tinfo)
+		}
+		seedLatencyScore(session)
 
 		sessions = append(sessions, session)
 	}
@@ -254,8 +344,16 @@ func selectOrchestrator(n *core.LivepeerNode, params *streamParameters, cpl core
 }
 
 func processSegment(cxn *rtmpConnection, seg *stream.HLSSegment) ([]string, error) {
+	name, uri, err := saveSourceSegment(cxn, seg)
+	if err != nil {
+		return nil, err
+	}
+	return transcodeSourceSegment(cxn, seg, name, uri)
+}
 
-	rtmpStrm := cxn.stream
+// saveSourceSegment saves the source segment to the stream's object storage and
+// inserts it into the source playlist. Returns the name and URI it was saved under
+func saveSourceSegment(cxn *rtmpConnection, seg *stream.HLSSegment) (string, string, error) {
 	nonce := cxn.nonce
 	cpl := cxn.pl
 	mid := cxn.mid
@@ -274,7 +372,7 @@ func processSegment(cxn *rtmpConnection, seg *stream.HLSSegment) ([]string, erro
 		if monitor.Enabled {
 			monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadErrorUnknown, err.Error(), true)
 		}
-		return nil, err
+		return "", "", err
 	}
 	if cpl.GetOSSession().IsExternal() {
 		seg.Name = uri // hijack seg.Name to convey the uploaded URI
@@ -289,15 +387,26 @@ func processSegment(cxn *rtmpConnection, seg *stream.HLSSegment) ([]string, erro
 			monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadErrorUnknown, err.Error(), true)
 		}
 	}
+	return name, uri, nil
+}
+
+// transcodeSourceSegment transcodes a segment saved by saveSourceSegment, retrying
+// according to SegmentRetryPolicy and falling back if configured to
+func transcodeSourceSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name, uri string) ([]string, error) {
+	rtmpStrm := cxn.stream
+	nonce := cxn.nonce
+	mid := cxn.mid
 
 	var sv *verification.SegmentVerifier
-	if Policy != nil {
+	if Policy != nil && Policy.Verifier != nil {
 		sv = verification.NewSegmentVerifier(Policy)
 	}
 
-	for {
-		// if fails, retry; rudimentary
-		if urls, err := transcodeSegment(cxn, seg, name, sv); err == nil {
+	policy := SegmentRetryPolicy
+	start := time.Now()
+	for attempts := 1; ; attempts++ {
+		urls, err := transcodeSegment(cxn, seg, name, sv)
+		if err == nil {
 			return urls, nil
 		}
 
@@ -307,7 +416,32 @@ func processSegment(cxn *rtmpConnection, seg *stream.HLSSegment) ([]string, erro
 			return nil, err
 		}
 
-		// recoverable error, retry
+		if nr, ok := err.(NonRetryableError); ok && nr.error == errNoOrchs && waitForOrchestrators(cxn, start) {
+			// Orchestrators became available before the fallback deadline
+			continue
+		}
+
+		retry, backoff := policy.next(attempts, time.Since(start), seg.Duration, err)
+		if !retry {
+			if isNonRetryableError(err) {
+				glog.Errorf("Not retrying segment nonce=%d manifestID=%s seqNo=%d err=%v", nonce, mid, seg.SeqNo, err)
+			} else {
+				err = ErrRetriesExhausted{Attempts: attempts, Err: err}
+				glog.Errorf("Failed to transcode segment nonce=%d manifestID=%s seqNo=%d err=%v", nonce, mid, seg.SeqNo, err)
+				if monitor.Enabled {
+					monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorRetriesExhausted, nonce, seg.SeqNo, err, true)
+				}
+			}
+			if Fallback != FallbackNone {
+				if urls, ferr := fallbackSegment(cxn, seg, uri); ferr == nil {
+					return urls, nil
+				}
+			}
+			return nil, err
+		}
+
+		glog.V(common.DEBUG).Infof("Retrying segment nonce=%d manifestID=%s seqNo=%d attempts=%d backoff=%v err=%v", nonce, mid, seg.SeqNo, attempts, backoff, err)
+		time.Sleep(backoff)
 	}
 }
 
@@ -316,53 +450,33 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 
 	nonce := cxn.nonce
 	cpl := cxn.pl
-	sess := cxn.sessManager.selectSession()
+	sessions := cxn.sessManager.selectSessions(redundancy())
 	// Return early under a few circumstances:
 	// View-only (non-transcoded) streams or no sessions available
-	if sess == nil {
+	if len(sessions) == 0 {
 		if monitor.Enabled {
 			monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorNoOrchestrators, nonce, seg.SeqNo, errNoOrchs, true)
 		}
 		glog.Infof("No sessions available for segment nonce=%d manifestID=%s seqNo=%d", nonce, cxn.mid, seg.SeqNo)
-		// We may want to introduce a "non-retryable" error type here
-		// would help error propagation for live ingest.
-		// similar to the orchestrator's RemoteTranscoderFatalError
-		return nil, nil
-	}
-	glog.Infof("Trying to transcode segment nonce=%d seqNo=%d", nonce, seg.SeqNo)
-	if monitor.Enabled {
-		monitor.TranscodeTry(nonce, seg.SeqNo)
+		return nil, NewNonRetryableError(errNoOrchs)
 	}
 
-	// storage the orchestrator prefers
-	if ios := sess.OrchestratorOS; ios != nil {
-		// XXX handle case when orch expects direct upload
-		uri, err := ios.SaveData(name, seg.Data)
-		if err != nil {
-			glog.Errorf("Error saving segment to OS nonce=%d seqNo=%d: %v", nonce, seg.SeqNo, err)
-			if monitor.Enabled {
-				monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadErrorOS, err.Error(), false)
-			}
-			cxn.sessManager.removeSession(sess)
+	var sess *BroadcastSession
+	var res *ReceivedTranscodeResult
+	var err error
+	if len(sessions) == 1 {
+		sess = sessions[0]
+		if res, err = submitSegment(cxn, sess, seg, name); err != nil {
 			return nil, err
 		}
-		seg.Name = uri // hijack seg.Name to convey the uploaded URI
-	}
-
-	// send segment to the orchestrator
-	glog.V(common.DEBUG).Infof("Submitting segment nonce=%d manifestID=%s seqNo=%d orch=%s", nonce, cxn.mid, seg.SeqNo, sess.OrchestratorInfo.Transcoder)
-
-	res, err := SubmitSegment(sess, seg, nonce)
-	if err != nil || res == nil {
-		cxn.sessManager.removeSession(sess)
-		if res == nil && err == nil {
-			return nil, errors.New("Empty response")
+	} else {
+		var winner *submitResult
+		if winner, err = submitRedundant(cxn, sessions, seg, name); err != nil {
+			return nil, err
 		}
-		return nil, err
+		sess, seg, res = winner.sess, winner.seg, winner.res
 	}
 
-	cxn.sessManager.completeSession(updateSession(sess, res))
-
 	// download transcoded segments from the transcoder
 	gotErr := false // only send one error msg per segment list
 	var errCode monitor.SegmentTranscodeError
@@ -379,6 +493,11 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 	segHashes := make([][]byte, len(res.Segments))
 	n := len(res.Segments)
 	segURLs := make([]string, len(res.Segments))
+	// Whole fragmented MP4 renditions, kept for verification and their initialization sections
+	var fmp4Data [][]byte
+	if sess.Format == core.FormatFMP4 {
+		fmp4Data = make([][]byte, len(res.Segments))
+	}
 	segHashLock := &sync.Mutex{}
 	cond := sync.NewCond(segHashLock)
 
@@ -392,18 +511,35 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 			cond.L.Unlock()
 		}()
 
-		if bos := sess.BroadcasterOS; bos != nil && !drivers.IsOwnExternal(url) {
+		// Fragmented MP4 renditions are always fetched to be split, even from our own storage
+		if bos := sess.BroadcasterOS; bos != nil && (fmp4Data != nil || !drivers.IsOwnExternal(url)) {
 			data, err := drivers.GetSegmentData(url)
 			if err != nil {
 				errFunc(monitor.SegmentTranscodeErrorDownload, url, err)
 				segHashLock.Lock()
 				dlErr = err
 				segHashLock.Unlock()
-				cxn.sessManager.removeSession(sess)
+				cxn.sessManager.failSession(sess, OrchFailureDownload)
 				return
 			}
-			name := fmt.Sprintf("%s/%d.ts", sess.Profiles[i].Name, seg.SeqNo)
-			newURL, err := bos.SaveData(name, data)
+			hash := crypto.Keccak256(data)
+			name := fmt.Sprintf("%s/%d%s", sess.Profiles[i].Name, seg.SeqNo, sess.Format.Ext())
+			saved := data
+			if fmp4Data != nil {
+				// Playlists reference the media section, with the initialization section in EXT-X-MAP
+				_, media, err := splitFMP4(data)
+				if err != nil {
+					glog.Errorf("Error splitting fMP4 segment nonce=%d manifestID=%s seqNo=%d url=%s err=%v", nonce, cxn.mid, seg.SeqNo, url, err)
+					segHashLock.Lock()
+					dlErr = err
+					segHashLock.Unlock()
+					cxn.sessManager.failSession(sess, OrchFailureVerification)
+					return
+				}
+				name = fmt.Sprintf("%s/%d.m4s", sess.Profiles[i].Name, seg.SeqNo)
+				saved = media
+			}
+			newURL, err := bos.SaveData(name, saved)
 			if err != nil {
 				segHashLock.Lock()
 				saveErr = err
@@ -418,9 +554,11 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 			}
 			url = newURL
 
-			hash := crypto.Keccak256(data)
 			segHashLock.Lock()
 			segHashes[i] = hash
+			if fmp4Data != nil {
+				fmp4Data[i] = data
+			}
 			segHashLock.Unlock()
 		}
 
@@ -430,7 +568,7 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 			go func() {
 				if err := verifyPixels(url, sess.BroadcasterOS, pixels); err != nil {
 					glog.Error(err)
-					cxn.sessManager.removeSession(sess)
+					cxn.sessManager.failSession(sess, OrchFailureVerification)
 				}
 			}()
 		}
@@ -464,13 +602,24 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 
 	if verifier != nil {
 		// verify potentially can change content of segURLs
-		err := verify(verifier, cxn, sess, seg, res.TranscodeData, segURLs)
+		err := verify(verifier, cxn, sess, seg, res.TranscodeData, segURLs, fmp4Data)
 		if err != nil {
 			glog.Errorf("Error verifying nonce=%d manifestID=%s seqNo=%d err=%s", nonce, cxn.mid, seg.SeqNo, err)
 			return nil, err
 		}
 	}
 
+	for i, data := range fmp4Data {
+		init, _, err := splitFMP4(data)
+		if err == nil {
+			err = saveFMP4Init(cpl, &sess.Profiles[i], seg.SeqNo, init)
+		}
+		if err != nil {
+			glog.Errorf("Error saving fMP4 initialization section nonce=%d manifestID=%s seqNo=%d err=%v", nonce, cxn.mid, seg.SeqNo, err)
+			return nil, err
+		}
+	}
+
 	for i, url := range segURLs {
 		err = cpl.InsertHLSSegment(&sess.Profiles[i], seg.SeqNo, url, seg.Duration)
 		if err != nil {
@@ -493,18 +642,76 @@ func transcodeSegment(cxn *rtmpConnection, seg *stream.HLSSegment, name string,
 		len(segHashes) != len(res.Segments) &&
 		!pm.VerifySig(ethcommon.BytesToAddress(ticketParams.Recipient), crypto.Keccak256(segHashes...), res.Sig) {
 		glog.Errorf("Sig check failed for segment nonce=%d seqNo=%d", nonce, seg.SeqNo)
-		cxn.sessManager.removeSession(sess)
+		cxn.sessManager.failSession(sess, OrchFailureVerification)
 		return nil, errPMCheckFailed
 	}
 	if monitor.Enabled {
 		monitor.SegmentFullyTranscoded(nonce, seg.SeqNo, common.ProfilesNames(sess.Profiles), errCode)
 	}
 
+	if OrchReputation != nil {
+		// Only segments whose renditions were downloaded and verified count towards the reputation
+		OrchReputation.Completed(sess.OrchestratorInfo.Transcoder, res.LatencyScore)
+	}
+
 	glog.V(common.DEBUG).Infof("Successfully validated segment nonce=%d seqNo=%d", nonce, seg.SeqNo)
 	return segURLs, nil
 }
 
-var sessionErrStrings = []string{"dial tcp", "unexpected EOF", core.ErrOrchBusy.Error(), core.ErrOrchCap.Error()}
+// submitSegment uploads the segment to the storage the orchestrator prefers, if any,
+// and submits it to the orchestrator. The session is completed or removed depending on the outcome
+func submitSegment(cxn *rtmpConnection, sess *BroadcastSession, seg *stream.HLSSegment, name string) (*ReceivedTranscodeResult, error) {
+	nonce := cxn.nonce
+	glog.Infof("Trying to transcode segment nonce=%d seqNo=%d", nonce, seg.SeqNo)
+	if monitor.Enabled {
+		monitor.TranscodeTry(nonce, seg.SeqNo)
+	}
+
+	// storage the orchestrator prefers
+	if ios := sess.OrchestratorOS; ios != nil {
+		// XXX handle case when orch expects direct upload
+		uri, err := ios.SaveData(name, seg.Data)
+		if err != nil {
+			glog.Errorf("Error saving segment to OS nonce=%d seqNo=%d: %v", nonce, seg.SeqNo, err)
+			if monitor.Enabled {
+				monitor.SegmentUploadFailed(nonce, seg.SeqNo, monitor.SegmentUploadErrorOS, err.Error(), false)
+			}
+			cxn.sessManager.failSession(sess, OrchFailureSubmit)
+			return nil, err
+		}
+		seg.Name = uri // hijack seg.Name to convey the uploaded URI
+	}
+
+	// send segment to the orchestrator
+	glog.V(common.DEBUG).Infof("Submitting segment nonce=%d manifestID=%s seqNo=%d orch=%s", nonce, cxn.mid, seg.SeqNo, sess.OrchestratorInfo.Transcoder)
+
+	res, err := SubmitSegment(sess, seg, nonce)
+	if err != nil && isCapacityError(err) {
+		// The orchestrator is working but won't take the segment; don't count it against its reputation
+		glog.Infof("Orchestrator refused segment; removing session nonce=%d manifestID=%s seqNo=%d orch=%s err=%v", nonce, cxn.mid, seg.SeqNo, sess.OrchestratorInfo.Transcoder, err)
+		cxn.sessManager.removeSession(sess)
+		return nil, err
+	}
+	if err != nil || res == nil {
+		cxn.sessManager.failSession(sess, OrchFailureSubmit)
+		if res == nil && err == nil {
+			return nil, errors.New("Empty response")
+		}
+		return nil, err
+	}
+
+	if res.Info.GetCapabilities().GetDraining() {
+		// The orchestrator finished this segment but wants no new ones; move the stream elsewhere
+		glog.Infof("Orchestrator is draining; removing session manifestID=%s orch=%s", cxn.mid, sess.OrchestratorInfo.Transcoder)
+		cxn.sessManager.removeSession(sess)
+	} else {
+		cxn.sessManager.completeSession(updateSession(sess, res))
+	}
+
+	return res, nil
+}
+
+var sessionErrStrings = []string{"dial tcp", "unexpected EOF", core.ErrOrchBusy.Error(), core.ErrOrchCap.Error(), core.ErrOrchDraining.Error(), core.ErrSenderSessionQuota.Error()}
 
 var sessionErrRegex = common.GenErrRegex(sessionErrStrings)
 
@@ -512,9 +719,23 @@ func shouldStopSession(err error) bool {
 	return sessionErrRegex.MatchString(err.Error())
 }
 
+// capacityErrStrings are the errors of orchestrators that refuse a segment because
+// they are at capacity, draining or enforcing a quota rather than failing
+var capacityErrStrings = []string{core.ErrOrchBusy.Error(), core.ErrOrchCap.Error(), core.ErrOrchDraining.Error(),
+	core.ErrSenderSessionQuota.Error(), core.ErrSenderPixelQuota.Error(), core.ErrSenderSegmentQuota.Error()}
+
+var capacityErrRegex = common.GenErrRegex(capacityErrStrings)
+
+func isCapacityError(err error) bool {
+	return capacityErrRegex.MatchString(err.Error())
+}
+
+// verify checks the renditions at URIs. Fragmented MP4 renditions are only stored as their
+// media sections, so the whole renditions are passed in fmp4Data; they are replaced by the
+// accepted ones if an earlier attempt wins
 func verify(verifier *verification.SegmentVerifier, cxn *rtmpConnection,
 	sess *BroadcastSession, source *stream.HLSSegment,
-	res *net.TranscodeData, URIs []string) error {
+	res *net.TranscodeData, URIs []string, fmp4Data [][]byte) error {
 
 	// Cache segment contents if necessary.
 	// If we need to retry transcoding because verification fails,
@@ -522,6 +743,10 @@ func verify(verifier *verification.SegmentVerifier, cxn *rtmpConnection,
 	// Cache the segments so we can restore them in OS if necessary.
 	renditionData := make([][]byte, len(URIs))
 	for i, fname := range URIs {
+		if fmp4Data != nil {
+			renditionData[i] = fmp4Data[i]
+			continue
+		}
 		if sess.BroadcasterOS.IsExternal() && drivers.IsOwnExternal(fname) {
 			// If broadcaster is using external storage and segments are there
 			// Then have the verifier use that external storage.
@@ -553,6 +778,7 @@ func verify(verifier *verification.SegmentVerifier, cxn *rtmpConnection,
 		Results:      res,
 		URIs:         URIs,
 		Renditions:   renditionData,
+		Format:       sess.Format,
 	}
 
 	// The return value from the verifier, if any, are the *accepted* params.
@@ -563,7 +789,7 @@ func verify(verifier *verification.SegmentVerifier, cxn *rtmpConnection,
 		// If retryable, means tampering was detected from this O
 		// Remove the O from the working set for now
 		// Error falls through towards end if necessary
-		cxn.sessManager.removeSession(sess)
+		cxn.sessManager.failSession(sess, OrchFailureVerification)
 	}
 	if accepted != nil {
 		// The returned set of results has been accepted by the verifier
@@ -572,11 +798,20 @@ func verify(verifier *verification.SegmentVerifier, cxn *rtmpConnection,
 		// If so, reset the local OS if we're using that since it's been
 		// overwritten with this rendition.
 		for i, data := range accepted.Renditions {
+			if fmp4Data != nil {
+				fmp4Data[i] = data
+			}
 			if accepted != params && !sess.BroadcasterOS.IsExternal() {
 				// Sanity check that we actually have the rendition data?
 				if len(data) <= 0 {
 					return errors.New("MissingLocalData")
 				}
+				if fmp4Data != nil {
+					var err error
+					if _, data, err = splitFMP4(data); err != nil {
+						return err
+					}
+				}
 				// SaveData only takes the /<rendition>/<seqNo> part of the URI
 				// However, it returns /stream/<manifestID>/<rendition>/<seqNo>
 				// The incoming URI is likely to be in the longer format.
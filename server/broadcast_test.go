@@ -99,6 +99,8 @@ type stubPlaylistManager struct {
 	seq        uint64
 	profile    ffmpeg.VideoProfile
 	uri        string
+	initURI    string
+	os         drivers.OSSession
 }
 
 func (pm *stubPlaylistManager) ManifestID() core.ManifestID {
@@ -112,6 +114,15 @@ func (pm *stubPlaylistManager) InsertHLSSegment(profile *ffmpeg.VideoProfile, se
 	return nil
 }
 
+func (pm *stubPlaylistManager) InsertHLSInitSegment(profile *ffmpeg.VideoProfile, uri string) error {
+	pm.initURI = uri
+	return nil
+}
+
+func (pm *stubPlaylistManager) GetHLSInitSegment(rendition string) string {
+	return pm.initURI
+}
+
 func (pm *stubPlaylistManager) GetHLSMasterPlaylist() *m3u8.MasterPlaylist {
 	return nil
 }
@@ -120,10 +131,16 @@ func (pm *stubPlaylistManager) GetHLSMediaPlaylist(rendition string) *m3u8.Media
 	return nil
 }
 
+func (pm *stubPlaylistManager) GetDASHManifest() ([]byte, error) {
+	return nil, nil
+}
+
 func (pm *stubPlaylistManager) GetOSSession() drivers.OSSession {
-	return nil
+	return pm.os
 }
 
+func (pm *stubPlaylistManager) FinishHLSPlaylists() {}
+
 func (pm *stubPlaylistManager) Cleanup() {}
 
 type stubSelector struct {
@@ -145,6 +162,8 @@ func TestStopSessionErrors(t *testing.T) {
 		"Unable to submit segment 5 Post https://127.0.0.1:8936/segment: dial tcp 127.0.0.1:8936: getsockopt: connection refused",
 		core.ErrOrchBusy.Error(),
 		core.ErrOrchCap.Error(),
+		core.ErrOrchDraining.Error(),
+		core.ErrSenderSessionQuota.Error(),
 	}
 
 	// Sanity check that we're checking each failure case
@@ -513,6 +532,49 @@ func TestTranscodeSegment_CompleteSession(t *testing.T) {
 	assert.Equal(tr.Info.PriceInfo.PixelsPerUnit, completedSessInfo.PriceInfo.PixelsPerUnit)
 }
 
+func TestTranscodeSegment_DrainingOrchestrator(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	tr := &net.TranscodeResult{
+		Result: &net.TranscodeResult_Data{
+			Data: &net.TranscodeData{
+				Segments: []*net.TranscodedSegmentData{&net.TranscodedSegmentData{Url: "test.flv"}},
+				Sig:      []byte("bar"),
+			},
+		},
+	}
+
+	// Create stub server
+	ts, mux := stubTLSServer()
+	defer ts.Close()
+	mux.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+		buf, err := proto.Marshal(tr)
+		require.Nil(err)
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf)
+	})
+
+	sess := StubBroadcastSession(ts.URL)
+	sess.Profiles = []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}
+	bsm := bsmWithSessList([]*BroadcastSession{sess})
+	cxn := &rtmpConnection{
+		mid:         core.ManifestID("foo"),
+		nonce:       7,
+		pl:          &stubPlaylistManager{manifestID: core.ManifestID("foo")},
+		profile:     &ffmpeg.P144p30fps16x9,
+		sessManager: bsm,
+	}
+
+	tr.Info = &net.OrchestratorInfo{Transcoder: ts.URL, Capabilities: &net.OrchestratorCapabilities{Draining: true}}
+	urls, err := transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil)
+	assert.Nil(err)
+	assert.Len(urls, 1)
+
+	// No further segments are sent to the draining orchestrator
+	assert.NotContains(bsm.sessMap, ts.URL)
+}
+
 func TestTranscodeSegment_VerifyPixels(t *testing.T) {
 	require := require.New(t)
 	assert := assert.New(t)
@@ -854,33 +916,33 @@ func TestVerifier_Verify(t *testing.T) {
 	res := &net.TranscodeData{}
 	verifier := verification.NewSegmentVerifier(&verification.Policy{})
 	URIs := []string{}
-	err := verify(verifier, cxn, sess, source, res, URIs)
+	err := verify(verifier, cxn, sess, source, res, URIs, nil)
 	assert.Nil(err)
 
 	// Test local OS: Should fail with an invalid path
 	sess.ManifestID = core.ManifestID("streamName")
 	sess.BroadcasterOS = drivers.NewMemoryDriver(nil).NewSession("streamName")
 	URIs = append(URIs, "filename")
-	err = verify(verifier, cxn, sess, source, res, URIs)
+	err = verify(verifier, cxn, sess, source, res, URIs, nil)
 	assert.NotNil(err)
 	assert.Contains(err.Error(), "invalid URI for request")
 
 	// Test local OS : Should fail if data does not exist in OS
 	URIs[0] = "/filename"
-	err = verify(verifier, cxn, sess, source, res, URIs)
+	err = verify(verifier, cxn, sess, source, res, URIs, nil)
 	assert.NotNil(err)
 	assert.Contains(err.Error(), "Missing Local Data")
 
 	// Test for segment not in broadcaster's own OS - "livepeer" S3 bucket
 	drivers.S3BUCKET = "livepeer"
 	sess.BroadcasterOS = drivers.NewS3Driver("", drivers.S3BUCKET, "", "").NewSession("")
-	err = verify(verifier, cxn, sess, source, res, URIs)
+	err = verify(verifier, cxn, sess, source, res, URIs, nil)
 	assert.NotNil(err)
 	assert.Contains(err.Error(), "Expected local storage but did not have it")
 
 	// Set broadcaster's OS to "livepeer" S3 bucket and fix the URL
 	URIs[0] = "https://livepeer.s3.amazonaws.com"
-	err = verify(verifier, cxn, sess, source, res, URIs)
+	err = verify(verifier, cxn, sess, source, res, URIs, nil)
 	assert.Nil(err)
 
 	// Check non-retryable errors
@@ -891,7 +953,7 @@ func TestVerifier_Verify(t *testing.T) {
 	verifier = newStubSegmentVerifier(sv)
 	assert.Equal(0, sv.calls)  // sanity check initial call count
 	assert.Len(bsm.sessMap, 1) // sanity check initial bsm map
-	err = verify(verifier, cxn, sess, source, res, URIs)
+	err = verify(verifier, cxn, sess, source, res, URIs, nil)
 	assert.NotNil(err)
 	assert.Equal(1, sv.calls)
 	assert.Equal(sv.err, err)
@@ -903,7 +965,7 @@ func TestVerifier_Verify(t *testing.T) {
 	_, retryable := sv.err.(verification.Retryable)
 	assert.True(retryable)
 	verifier = newStubSegmentVerifier(sv)
-	err = verify(verifier, cxn, sess, source, res, URIs)
+	err = verify(verifier, cxn, sess, source, res, URIs, nil)
 	assert.NotNil(err)
 	assert.Equal(2, sv.calls)
 	assert.Equal(sv.err, err)
@@ -929,7 +991,7 @@ func TestVerifier_Verify(t *testing.T) {
 	sess.BroadcasterOS = mem
 	verifier = newStubSegmentVerifier(sv)
 	URIs[0] = name
-	err = verify(verifier, cxn, sess, source, res, URIs)
+	err = verify(verifier, cxn, sess, source, res, URIs, nil)
 	assert.Equal(sv.err, err)
 
 	// Now "insert" 2nd attempt into OS
@@ -937,9 +999,39 @@ func TestVerifier_Verify(t *testing.T) {
 	_, err = mem.SaveData("/rendition/seg/1", []byte("attempt2"))
 	assert.Nil(err)
 	assert.Equal([]byte("attempt2"), mem.GetData(name))
-	err = verify(verifier, cxn, sess, source, res, URIs)
+	err = verify(verifier, cxn, sess, source, res, URIs, nil)
 	assert.Nil(err)
 	assert.Equal([]byte("attempt1"), mem.GetData(name))
+
+	// Whole fMP4 renditions are verified, and only the media section is restored
+	sv = &stubVerifier{
+		retries: 1,
+		err:     verification.ErrTampered,
+		results: []verification.Results{
+			verification.Results{Score: 9},
+			verification.Results{Score: 1},
+		},
+	}
+	verifier = newStubSegmentVerifier(sv)
+	init := concatBoxes(mp4Box("ftyp", "iso5"), mp4Box("moov", "tracks"))
+	media1 := concatBoxes(mp4Box("moof", "attempt1"), mp4Box("mdat", "samples"))
+	media2 := concatBoxes(mp4Box("moof", "attempt2"), mp4Box("mdat", "samples"))
+	full1 := concatBoxes(init, media1)
+	name, err = mem.SaveData("/rendition/seg/1.m4s", media1)
+	assert.Nil(err)
+	URIs[0] = name
+	fmp4Data := [][]byte{full1}
+	err = verify(verifier, cxn, sess, source, res, URIs, fmp4Data)
+	assert.Equal(sv.err, err)
+	assert.Equal(full1, sv.params.Renditions[0])
+
+	_, err = mem.SaveData("/rendition/seg/1.m4s", media2)
+	assert.Nil(err)
+	fmp4Data = [][]byte{concatBoxes(init, media2)}
+	err = verify(verifier, cxn, sess, source, res, URIs, fmp4Data)
+	assert.Nil(err)
+	assert.Equal(media1, mem.GetData(name))
+	assert.Equal(full1, fmp4Data[0])
 }
 
 func TestVerifier_HLSInsertion(t *testing.T) {
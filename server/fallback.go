@@ -0,0 +1,150 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/monitor"
+	"github.com/livepeer/lpms/ffmpeg"
+	"github.com/livepeer/lpms/stream"
+)
+
+// FallbackMode determines what the broadcaster inserts into the rendition
+// playlists when a segment can't be transcoded by any orchestrator
+type FallbackMode string
+
+const (
+	// FallbackNone leaves the rendition playlists without the segment
+	FallbackNone FallbackMode = ""
+	// FallbackSource inserts the source segment into every rendition playlist
+	FallbackSource FallbackMode = "source"
+	// FallbackTranscode transcodes the lowest resolution rendition with the
+	// FallbackTranscoder and inserts it into every rendition playlist
+	FallbackTranscode FallbackMode = "transcode"
+)
+
+// ParseFallbackMode validates the fallback mode from the command line
+func ParseFallbackMode(mode string) (FallbackMode, error) {
+	switch m := FallbackMode(mode); m {
+	case FallbackNone, FallbackSource, FallbackTranscode:
+		return m, nil
+	}
+	return FallbackNone, fmt.Errorf("Unknown fallback mode %v; expected one of source, transcode", mode)
+}
+
+var Fallback = FallbackNone
+
+// FallbackDeadline is how long the broadcaster waits for orchestrators to become
+// available after a segment emerged before falling back
+var FallbackDeadline = 2 * time.Second
+
+// FallbackTranscoder transcodes segments locally for FallbackTranscode
+var FallbackTranscoder core.Transcoder
+
+var fallbackPollInterval = 200 * time.Millisecond
+
+var errNoFallbackTranscoder = errors.New("no fallback transcoder")
+
+// The source segment can't be mixed into fragmented MP4 playlists
+var errFallbackFormat = errors.New("fallback is only supported for MPEG-TS output")
+
+// waitForOrchestrators waits until orchestrators are available or the fallback deadline passes.
+// Returns whether the segment should be tried again
+func waitForOrchestrators(cxn *rtmpConnection, start time.Time) bool {
+	if Fallback == FallbackNone {
+		return false
+	}
+	for time.Since(start)+fallbackPollInterval < FallbackDeadline {
+		time.Sleep(fallbackPollInterval)
+		if cxn.sessManager.hasSessions() {
+			return true
+		}
+	}
+	return false
+}
+
+// fallbackSegment inserts a substitute for the renditions of a segment that
+// couldn't be transcoded into the playlists. sourceURI is the location of the source segment
+func fallbackSegment(cxn *rtmpConnection, seg *stream.HLSSegment, sourceURI string) ([]string, error) {
+	if Fallback == FallbackNone || cxn.params == nil || len(cxn.params.profiles) == 0 {
+		return nil, errors.New("no fallback")
+	}
+	if cxn.params.format != core.FormatMPEGTS {
+		return nil, errFallbackFormat
+	}
+	profiles := cxn.params.profiles
+
+	uri := sourceURI
+	if Fallback == FallbackTranscode {
+		var err error
+		if uri, err = fallbackTranscode(cxn, seg, profiles); err != nil {
+			glog.Errorf("Error transcoding fallback rendition nonce=%d manifestID=%s seqNo=%d err=%v", cxn.nonce, cxn.mid, seg.SeqNo, err)
+			return nil, err
+		}
+	}
+
+	urls := make([]string, len(profiles))
+	for i := range profiles {
+		urls[i] = uri
+		if err := cxn.pl.InsertHLSSegment(&profiles[i], seg.SeqNo, uri, seg.Duration); err != nil {
+			glog.Errorf("Playlist insertion error nonce=%d manifestID=%s seqNo=%d err=%s", cxn.nonce, cxn.mid, seg.SeqNo, err)
+			return nil, err
+		}
+	}
+
+	glog.Infof("Inserted %s fallback for segment nonce=%d manifestID=%s seqNo=%d", Fallback, cxn.nonce, cxn.mid, seg.SeqNo)
+	if monitor.Enabled {
+		monitor.SegmentFallback(string(Fallback))
+	}
+	return urls, nil
+}
+
+// fallbackTranscode transcodes the lowest resolution profile locally and returns the URI of the rendition
+func fallbackTranscode(cxn *rtmpConnection, seg *stream.HLSSegment, profiles []ffmpeg.VideoProfile) (string, error) {
+	if FallbackTranscoder == nil {
+		return "", errNoFallbackTranscoder
+	}
+	profile := lowestResolution(profiles)
+
+	f, err := ioutil.TempFile("", "fallback*.ts")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(seg.Data)
+	f.Close()
+	if err != nil {
+		return "", err
+	}
+
+	tData, err := FallbackTranscoder.Transcode(string(cxn.mid), f.Name(), []ffmpeg.VideoProfile{profile}, core.FormatMPEGTS)
+	if err != nil {
+		return "", err
+	}
+	if len(tData.Segments) != 1 {
+		return "", errors.New("MismatchedSegments")
+	}
+
+	name := fmt.Sprintf("%s/%d.ts", profile.Name, seg.SeqNo)
+	return cxn.pl.GetOSSession().SaveData(name, tData.Segments[0].Data)
+}
+
+func lowestResolution(profiles []ffmpeg.VideoProfile) ffmpeg.VideoProfile {
+	lowest, lowestPixels := profiles[0], -1
+	for _, p := range profiles {
+		w, h, err := ffmpeg.VideoProfileResolution(p)
+		if err != nil {
+			continue
+		}
+		if lowestPixels < 0 || w*h < lowestPixels {
+			lowest, lowestPixels = p, w*h
+		}
+	}
+	return lowest
+}
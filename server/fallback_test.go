@@ -0,0 +1,173 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/lpms/ffmpeg"
+	"github.com/livepeer/lpms/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubFallbackTranscoder struct {
+	fname    string
+	data     []byte
+	profiles []ffmpeg.VideoProfile
+	err      error
+}
+
+func (t *stubFallbackTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format core.SegmentFormat) (*core.TranscodeData, error) {
+	t.fname, t.profiles = fname, profiles
+	t.data, _ = ioutil.ReadFile(fname)
+	if t.err != nil {
+		return nil, t.err
+	}
+	return &core.TranscodeData{Segments: []*core.TranscodedSegmentData{{Data: []byte("lowres")}}}, nil
+}
+
+func fallbackTestCxn(sessions ...*BroadcastSession) *rtmpConnection {
+	mid := core.ManifestID("fallback")
+	return &rtmpConnection{
+		mid:         mid,
+		pl:          core.NewBasicPlaylistManager(mid, drivers.NewMemoryDriver(nil).NewSession(string(mid))),
+		profile:     &ffmpeg.P144p30fps16x9,
+		params:      &streamParameters{mid: mid, profiles: []ffmpeg.VideoProfile{ffmpeg.P360p30fps16x9, ffmpeg.P240p30fps16x9}},
+		sessManager: bsmWithSessList(sessions),
+	}
+}
+
+func TestParseFallbackMode(t *testing.T) {
+	assert := assert.New(t)
+	for _, m := range []FallbackMode{FallbackNone, FallbackSource, FallbackTranscode} {
+		mode, err := ParseFallbackMode(string(m))
+		assert.Nil(err)
+		assert.Equal(m, mode)
+	}
+	_, err := ParseFallbackMode("foo")
+	assert.NotNil(err)
+}
+
+func TestLowestResolution(t *testing.T) {
+	assert := assert.New(t)
+	profiles := []ffmpeg.VideoProfile{ffmpeg.P720p30fps16x9, ffmpeg.P144p30fps16x9, ffmpeg.P360p30fps16x9}
+	assert.Equal(ffmpeg.P144p30fps16x9, lowestResolution(profiles))
+	assert.Equal(ffmpeg.P720p30fps16x9, lowestResolution(profiles[:1]))
+}
+
+func TestProcessSegment_Fallback(t *testing.T) {
+	defer func(mode FallbackMode, deadline time.Duration, tc core.Transcoder) {
+		Fallback, FallbackDeadline, FallbackTranscoder = mode, deadline, tc
+	}(Fallback, FallbackDeadline, FallbackTranscoder)
+	assert := assert.New(t)
+	FallbackDeadline = 0
+
+	// disabled
+	Fallback = FallbackNone
+	cxn := fallbackTestCxn()
+	urls, err := processSegment(cxn, &stream.HLSSegment{SeqNo: 1, Data: []byte("source"), Duration: 2.0})
+	assert.Nil(urls)
+	assert.Equal(NewNonRetryableError(errNoOrchs), err)
+	assert.Nil(cxn.pl.GetHLSMediaPlaylist(ffmpeg.P240p30fps16x9.Name))
+
+	// source segment is inserted into every rendition playlist
+	Fallback = FallbackSource
+	cxn = fallbackTestCxn()
+	urls, err = processSegment(cxn, &stream.HLSSegment{SeqNo: 1, Data: []byte("source"), Duration: 2.0})
+	assert.Nil(err)
+	assert.Equal([]string{"/stream/fallback/P144p30fps16x9/1.ts", "/stream/fallback/P144p30fps16x9/1.ts"}, urls)
+	memOS := cxn.pl.GetOSSession().(*drivers.MemorySession)
+	assert.Equal([]byte("source"), memOS.GetData(urls[0]))
+	for _, p := range cxn.params.profiles {
+		mpl := cxn.pl.GetHLSMediaPlaylist(p.Name)
+		if assert.NotNil(mpl) {
+			assert.Equal(uint(1), mpl.Count())
+			assert.Equal(urls[0], mpl.Segments[0].URI)
+		}
+	}
+	assert.Len(cxn.pl.GetHLSMasterPlaylist().Variants, 3)
+
+	// lowest resolution is transcoded locally
+	Fallback = FallbackTranscode
+	tc := &stubFallbackTranscoder{}
+	FallbackTranscoder = tc
+	cxn = fallbackTestCxn()
+	urls, err = processSegment(cxn, &stream.HLSSegment{SeqNo: 2, Data: []byte("source"), Duration: 2.0})
+	assert.Nil(err)
+	assert.Equal([]string{"/stream/fallback/P240p30fps16x9/2.ts", "/stream/fallback/P240p30fps16x9/2.ts"}, urls)
+	assert.Equal([]ffmpeg.VideoProfile{ffmpeg.P240p30fps16x9}, tc.profiles)
+	assert.Equal([]byte("source"), tc.data)
+	memOS = cxn.pl.GetOSSession().(*drivers.MemorySession)
+	assert.Equal([]byte("lowres"), memOS.GetData(urls[0]))
+	mpl := cxn.pl.GetHLSMediaPlaylist(ffmpeg.P360p30fps16x9.Name)
+	if assert.NotNil(mpl) {
+		assert.Equal(urls[0], mpl.Segments[0].URI)
+	}
+
+	// transcode errors return the original error
+	tc.err = errors.New("transcode error")
+	cxn = fallbackTestCxn()
+	urls, err = processSegment(cxn, &stream.HLSSegment{SeqNo: 3, Data: []byte("source"), Duration: 2.0})
+	assert.Nil(urls)
+	assert.Equal(NewNonRetryableError(errNoOrchs), err)
+	FallbackTranscoder = nil
+	_, err = processSegment(cxn, &stream.HLSSegment{SeqNo: 4, Data: []byte("source"), Duration: 2.0})
+	assert.Equal(NewNonRetryableError(errNoOrchs), err)
+}
+
+func TestProcessSegment_FallbackWaitsForOrchestrators(t *testing.T) {
+	defer func(mode FallbackMode, deadline, interval time.Duration) {
+		Fallback, FallbackDeadline, fallbackPollInterval = mode, deadline, interval
+	}(Fallback, FallbackDeadline, fallbackPollInterval)
+	assert := assert.New(t)
+	require := require.New(t)
+
+	buf, err := proto.Marshal(&net.TranscodeResult{
+		Result: &net.TranscodeResult_Data{
+			Data: &net.TranscodeData{Segments: []*net.TranscodedSegmentData{{Url: "test.flv"}}},
+		},
+	})
+	require.Nil(err)
+	ts, mux := stubTLSServer()
+	defer ts.Close()
+	mux.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf)
+	})
+
+	Fallback = FallbackSource
+	FallbackDeadline = 5 * time.Second
+	fallbackPollInterval = 10 * time.Millisecond
+	cxn := fallbackTestCxn()
+
+	// orchestrator becomes available before the deadline
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		sess := StubBroadcastSession(ts.URL)
+		sess.Profiles = []ffmpeg.VideoProfile{ffmpeg.P240p30fps16x9}
+		bsm := cxn.sessManager
+		bsm.sessLock.Lock()
+		bsm.sel.Add([]*BroadcastSession{sess})
+		bsm.sessMap[sess.OrchestratorInfo.Transcoder] = sess
+		bsm.sessLock.Unlock()
+	}()
+	start := time.Now()
+	urls, err := processSegment(cxn, &stream.HLSSegment{SeqNo: 1, Data: []byte("source"), Duration: 2.0})
+	assert.Nil(err)
+	assert.Equal([]string{"test.flv"}, urls)
+	assert.True(time.Since(start) < FallbackDeadline)
+
+	// falls back once the deadline passes
+	FallbackDeadline = 50 * time.Millisecond
+	cxn = fallbackTestCxn()
+	urls, err = processSegment(cxn, &stream.HLSSegment{SeqNo: 2, Data: []byte("source"), Duration: 2.0})
+	assert.Nil(err)
+	assert.Equal([]string{"/stream/fallback/P144p30fps16x9/2.ts", "/stream/fallback/P144p30fps16x9/2.ts"}, urls)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/core"
+	ffmpeg "github.com/livepeer/lpms/ffmpeg"
+	"github.com/livepeer/lpms/vidplayer"
+)
+
+var errFMP4NoFragments = errors.New("no movie fragments in segment")
+var errFMP4NoInit = errors.New("no initialization section in segment")
+var errFMP4Box = errors.New("invalid box in segment")
+
+// splitFMP4 separates a fragmented MP4 segment into its initialization section
+// (the boxes before the first movie fragment) and its media section. The random
+// access index at the end of the file is dropped since it refers to the whole file
+func splitFMP4(data []byte) ([]byte, []byte, error) {
+	var init, media []byte
+	hasMoov := false
+	for off := 0; off < len(data); {
+		if len(data)-off < 8 {
+			return nil, nil, errFMP4Box
+		}
+		size := int(binary.BigEndian.Uint32(data[off:]))
+		typ := string(data[off+4 : off+8])
+		switch size {
+		case 0:
+			// box extends to the end of the file
+			size = len(data) - off
+		case 1:
+			if len(data)-off < 16 {
+				return nil, nil, errFMP4Box
+			}
+			size64 := binary.BigEndian.Uint64(data[off+8:])
+			if size64 > uint64(len(data)-off) {
+				return nil, nil, errFMP4Box
+			}
+			size = int(size64)
+		}
+		if size < 8 || size > len(data)-off {
+			return nil, nil, errFMP4Box
+		}
+		box := data[off : off+size]
+		off += size
+
+		switch {
+		case typ == "moof" || media != nil:
+			if typ != "mfra" {
+				media = append(media, box...)
+			}
+		default:
+			hasMoov = hasMoov || typ == "moov"
+			init = append(init, box...)
+		}
+	}
+	if media == nil {
+		return nil, nil, errFMP4NoFragments
+	}
+	if !hasMoov {
+		return nil, nil, errFMP4NoInit
+	}
+	return init, media, nil
+}
+
+// fmp4InitName names the initialization section of a rendition by its contents,
+// so it is only saved again if an orchestrator produces a different one. It is kept
+// next to the stream rather than the segments so memory storage doesn't evict it
+func fmp4InitName(profile string, init []byte) string {
+	return fmt.Sprintf("%s_init_%x.mp4", profile, crypto.Keccak256(init)[:4])
+}
+
+// saveFMP4Init saves the initialization section of a transcoded fragmented MP4
+// segment and sets it as the EXT-X-MAP of the rendition, unless it is unchanged
+func saveFMP4Init(cpl core.PlaylistManager, profile *ffmpeg.VideoProfile, seqNo uint64, init []byte) error {
+	initName := fmp4InitName(profile.Name, init)
+	cur := cpl.GetHLSInitSegment(profile.Name)
+	if path.Base(cur) == initName {
+		return nil
+	}
+	if cur != "" {
+		glog.Warningf("Initialization section changed manifestID=%s profile=%s seqNo=%d", cpl.ManifestID(), profile.Name, seqNo)
+	}
+	initURI, err := cpl.GetOSSession().SaveData(initName, init)
+	if err != nil {
+		return err
+	}
+	return cpl.InsertHLSInitSegment(profile, initURI)
+}
+
+// fmp4Handler serves fragmented MP4 segments from memory storage, which the
+// LPMS player only does for MPEG-TS segments. Other requests go to next
+func fmp4Handler(s *LivepeerServer, next http.Handler) http.Handler {
+	getSegment := getHLSSegmentHandler(s)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ext := path.Ext(r.URL.Path)
+		if !strings.HasPrefix(r.URL.Path, "/stream/") || (ext != ".mp4" && ext != ".m4s") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		data, err := getSegment(r.URL)
+		if err == vidplayer.ErrNotFound {
+			http.Error(w, "ErrNotFound", http.StatusNotFound)
+			return
+		} else if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Expose-Headers", "Content-Length")
+		w.Header().Set("Cache-Control", "max-age=5")
+		w.Header().Set("Content-Type", core.FormatFMP4.ContentType())
+		w.Write(data)
+	})
+}
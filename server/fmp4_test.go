@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/binary"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/drivers"
+	ffmpeg "github.com/livepeer/lpms/ffmpeg"
+)
+
+func mp4Box(typ string, payload string) []byte {
+	b := make([]byte, 8, 8+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(8+len(payload)))
+	copy(b[4:], typ)
+	return append(b, payload...)
+}
+
+func concatBoxes(boxes ...[]byte) []byte {
+	var b []byte
+	for _, box := range boxes {
+		b = append(b, box...)
+	}
+	return b
+}
+
+func TestSplitFMP4(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	ftyp, moov := mp4Box("ftyp", "iso5"), mp4Box("moov", "tracks")
+	moof, mdat := mp4Box("moof", "fragment"), mp4Box("mdat", "samples")
+	data := concatBoxes(ftyp, moov, moof, mdat, moof, mdat, mp4Box("mfra", "index"))
+
+	init, media, err := splitFMP4(data)
+	require.Nil(err)
+	assert.Equal(concatBoxes(ftyp, moov), init)
+	assert.Equal(concatBoxes(moof, mdat, moof, mdat), media)
+
+	// 64 bit box sizes
+	large := make([]byte, 16, 16+len("samples"))
+	binary.BigEndian.PutUint32(large, 1)
+	copy(large[4:], "mdat")
+	binary.BigEndian.PutUint64(large[8:], uint64(16+len("samples")))
+	large = append(large, "samples"...)
+	_, media, err = splitFMP4(concatBoxes(ftyp, moov, moof, large))
+	require.Nil(err)
+	assert.Equal(concatBoxes(moof, large), media)
+
+	// errors
+	_, _, err = splitFMP4(concatBoxes(ftyp, moov))
+	assert.Equal(errFMP4NoFragments, err)
+	_, _, err = splitFMP4(concatBoxes(ftyp, moof, mdat))
+	assert.Equal(errFMP4NoInit, err)
+	_, _, err = splitFMP4(data[:len(data)-1])
+	assert.Equal(errFMP4Box, err)
+	_, _, err = splitFMP4([]byte("not mp4"))
+	assert.Equal(errFMP4Box, err)
+}
+
+func TestSaveFMP4Init(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mid := core.ManifestID("fmp4")
+	storage := drivers.NewMemoryDriver(nil).NewSession(string(mid))
+	cpl := core.NewBasicPlaylistManager(mid, storage)
+	profile := &ffmpeg.P144p30fps16x9
+
+	init := concatBoxes(mp4Box("ftyp", "iso5"), mp4Box("moov", "tracks"))
+	require.Nil(saveFMP4Init(cpl, profile, 1, init))
+	memOS := storage.(*drivers.MemorySession)
+	initURI := cpl.GetHLSInitSegment(profile.Name)
+	assert.Equal(init, memOS.GetData(initURI))
+	assert.Equal(initURI, cpl.GetHLSMediaPlaylist(profile.Name).Map.URI)
+
+	// the same initialization section is only saved once
+	require.Nil(saveFMP4Init(cpl, profile, 2, init))
+	assert.Equal(initURI, cpl.GetHLSInitSegment(profile.Name))
+
+	// a different one replaces it
+	init2 := concatBoxes(mp4Box("ftyp", "iso6"), mp4Box("moov", "tracks"))
+	require.Nil(saveFMP4Init(cpl, profile, 3, init2))
+	assert.NotEqual(initURI, cpl.GetHLSInitSegment(profile.Name))
+	assert.Equal(init2, memOS.GetData(cpl.GetHLSInitSegment(profile.Name)))
+
+	// storage errors
+	storage.EndSession()
+	assert.NotNil(saveFMP4Init(cpl, profile, 4, init))
+}
+
+func TestFMP4Handler(t *testing.T) {
+	assert := assert.New(t)
+	s := setupServer()
+
+	mid := core.ManifestID("fmp4handler")
+	storage := drivers.NodeStorage.NewSession(string(mid))
+	data := []byte("media")
+	_, err := storage.SaveData("P144p30fps16x9/1.m4s", data)
+	require.Nil(t, err)
+
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := fmp4Handler(s, next)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/stream/fmp4handler/P144p30fps16x9/1.m4s", nil))
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("video/mp4", w.Header().Get("Content-Type"))
+	assert.Equal(data, w.Body.Bytes())
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/stream/fmp4handler/P144p30fps16x9/2.m4s", nil))
+	assert.Equal(http.StatusNotFound, w.Code)
+
+	// other requests pass through
+	for _, path := range []string{"/stream/fmp4handler/P144p30fps16x9/1.ts", "/stream/fmp4handler.m3u8", "/live/fmp4handler/1.mp4"} {
+		w = httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		assert.Equal(http.StatusTeapot, w.Code, path)
+	}
+}
@@ -43,6 +43,31 @@ func mustHaveFormParams(h http.Handler, params ...string) http.Handler {
 	})
 }
 
+// Drainer is an interface which describes an object that can be
+// taken out of service without dropping the work it already has
+type Drainer interface {
+	// SetDraining puts the object in or out of drain mode
+	SetDraining(draining bool)
+}
+
+func drainHandler(d Drainer, draining bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if d == nil {
+			respondWith500(w, "missing node")
+			return
+		}
+
+		d.SetDraining(draining)
+
+		w.WriteHeader(http.StatusOK)
+		if draining {
+			w.Write([]byte("draining"))
+		} else {
+			w.Write([]byte("resumed"))
+		}
+	})
+}
+
 // BlockGetter is an interface which describes an object capable
 // of getting blocks
 type BlockGetter interface {
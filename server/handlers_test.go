@@ -14,6 +14,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/accounts"
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/livepeer/go-livepeer/core"
 	"github.com/livepeer/go-livepeer/eth"
 	"github.com/livepeer/go-livepeer/pm"
 	"github.com/stretchr/testify/assert"
@@ -145,6 +146,27 @@ func TestCurrentBlockHandler_Success(t *testing.T) {
 	assert.Equal(http.StatusOK, resp.StatusCode)
 	assert.Equal(big.NewInt(50), new(big.Int).SetBytes(body))
 }
+
+func TestDrainHandler(t *testing.T) {
+	assert := assert.New(t)
+
+	resp := httpPostResp(drainHandler(nil, true), nil, nil)
+	assert.Equal(http.StatusInternalServerError, resp.StatusCode)
+
+	n, _ := core.NewLivepeerNode(nil, "", nil)
+	resp = httpPostResp(drainHandler(n, true), nil, nil)
+	body, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("draining", string(body))
+	assert.True(n.Draining())
+
+	resp = httpPostResp(drainHandler(n, false), nil, nil)
+	body, _ = ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("resumed", string(body))
+	assert.False(n.Draining())
+}
+
 func TestCurrentRoundHandler(t *testing.T) {
 	assert := assert.New(t)
 
@@ -0,0 +1,274 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/lpms/stream"
+	"github.com/livepeer/m3u8"
+)
+
+// HLSPullTimeout is how long a pulled playlist may go without new segments
+// before the broadcaster ends the stream
+var HLSPullTimeout = time.Minute
+
+// Overrides the playlist target duration as the refresh interval if set
+var hlsPullInterval time.Duration
+
+var errNoVariants = errors.New("no variants in master playlist")
+var errNotPulled = errors.New("stream is not pulled from a playlist")
+
+// hlsPuller polls an external HLS playlist and feeds new segments into a stream
+type hlsPuller struct {
+	s   *LivepeerServer
+	cxn *rtmpConnection
+	// location of the media playlist
+	url *url.URL
+	// media sequence number of the next segment to process
+	nextSeq uint64
+	started bool
+	// media sequence number of the first segment in the last playlist
+	firstSeq uint64
+	// added to media sequence numbers so that the stream's sequence numbers
+	// keep increasing when the media sequence of the playlist is reset
+	seqOffset int64
+}
+
+// pullHLSHandler starts transcoding the stream at the given playlist URL.
+// The stream is authenticated with the auth webhook the same way as RTMP
+// and HTTP push ingest. Responds with the ManifestID of the stream
+func (s *LivepeerServer) pullHLSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, err := url.Parse(r.FormValue("url"))
+		if err != nil || !u.IsAbs() {
+			respondWith400(w, fmt.Sprintf("invalid playlist url: %v", r.FormValue("url")))
+			return
+		}
+
+		cxn, err := s.pullHLS(u, core.ManifestID(r.FormValue("manifestID")))
+		if err != nil {
+			respondWith500(w, fmt.Sprintf("could not pull playlist: %v", err))
+			return
+		}
+		w.Write([]byte(cxn.mid))
+	})
+}
+
+// stopHLSPullHandler ends a stream that is pulled from an external playlist.
+// Streams ingested any other way can't be ended through it
+func (s *LivepeerServer) stopHLSPullHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mid := core.ManifestID(r.FormValue("manifestID"))
+		s.connectionLock.RLock()
+		cxn, ok := s.pulledStreams[mid]
+		pulled := ok && s.rtmpConnections[mid] == cxn
+		s.connectionLock.RUnlock()
+		if !pulled {
+			respondWith400(w, errNotPulled.Error())
+			return
+		}
+		if err := removeRTMPStream(s, mid); err != nil {
+			respondWith400(w, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+// pullHLS registers a stream for the playlist and starts polling it in the background
+func (s *LivepeerServer) pullHLS(u *url.URL, mid core.ManifestID) (*rtmpConnection, error) {
+	mediaURL, resolution, err := resolveMediaPlaylist(u)
+	if err != nil {
+		return nil, err
+	}
+
+	if mid == "" {
+		// The path of an external playlist doesn't follow our stream naming
+		mid = core.RandomManifestID()
+	}
+	params := s.newStreamParams(u, mid)
+	if params == nil {
+		return nil, errors.New("could not create stream ID")
+	}
+	params.resolution = resolution
+	cxn, err := s.registerConnection(stream.NewBasicRTMPVideoStream(params))
+	if err != nil {
+		return nil, err
+	}
+
+	s.connectionLock.Lock()
+	s.pulledStreams[cxn.mid] = cxn
+	s.connectionLock.Unlock()
+
+	glog.Infof("Pulling HLS stream manifestID=%s url=%s", cxn.mid, mediaURL)
+	p := &hlsPuller{s: s, cxn: cxn, url: mediaURL}
+	go p.run()
+	return cxn, nil
+}
+
+// resolveMediaPlaylist returns the location of the media playlist to pull and
+// its resolution, if known. For master playlists, the highest bandwidth variant is used
+func resolveMediaPlaylist(u *url.URL) (*url.URL, string, error) {
+	pl, listType, err := fetchPlaylist(u)
+	if err != nil {
+		return nil, "", err
+	}
+	if listType == m3u8.MEDIA {
+		return u, "", nil
+	}
+
+	master := pl.(*m3u8.MasterPlaylist)
+	var best *m3u8.Variant
+	for _, v := range master.Variants {
+		if v != nil && (best == nil || v.Bandwidth > best.Bandwidth) {
+			best = v
+		}
+	}
+	if best == nil {
+		return nil, "", errNoVariants
+	}
+	mediaURL, err := u.Parse(best.URI)
+	if err != nil {
+		return nil, "", err
+	}
+	return mediaURL, best.Resolution, nil
+}
+
+func fetchPlaylist(u *url.URL) (m3u8.Playlist, m3u8.ListType, error) {
+	data, err := drivers.GetSegmentData(u.String())
+	if err != nil {
+		return nil, 0, err
+	}
+	return m3u8.Decode(*bytes.NewBuffer(data), false)
+}
+
+// active returns whether the stream is still registered with the server
+func (p *hlsPuller) active() bool {
+	p.s.connectionLock.RLock()
+	defer p.s.connectionLock.RUnlock()
+	cxn, ok := p.s.rtmpConnections[p.cxn.mid]
+	return ok && cxn == p.cxn
+}
+
+func (p *hlsPuller) run() {
+	mid := p.cxn.mid
+	lastNew := time.Now()
+	for p.active() {
+		interval := SegLen
+		pl, listType, err := fetchPlaylist(p.url)
+		if err == nil && listType != m3u8.MEDIA {
+			err = errors.New("expected a media playlist")
+		}
+		if err != nil {
+			glog.Errorf("Error fetching pulled playlist manifestID=%s url=%s err=%v", mid, p.url, err)
+		} else {
+			mpl := pl.(*m3u8.MediaPlaylist)
+			if mpl.TargetDuration > 0 {
+				interval = time.Duration(mpl.TargetDuration * float64(time.Second))
+			}
+			processed, done := p.processPlaylist(mpl)
+			if processed > 0 {
+				lastNew = time.Now()
+			}
+			if !mpl.Live && done {
+				glog.Infof("Pulled playlist ended manifestID=%s", mid)
+				break
+			}
+		}
+
+		if time.Since(lastNew) > HLSPullTimeout {
+			glog.Infof("No new segments in pulled playlist; ending stream manifestID=%s timeout=%v", mid, HLSPullTimeout)
+			break
+		}
+		if hlsPullInterval > 0 {
+			interval = hlsPullInterval
+		}
+		time.Sleep(interval)
+	}
+
+	if p.active() {
+		removeRTMPStream(p.s, mid)
+	}
+	p.s.connectionLock.Lock()
+	if p.s.pulledStreams[mid] == p.cxn {
+		delete(p.s.pulledStreams, mid)
+	}
+	p.s.connectionLock.Unlock()
+}
+
+// processPlaylist transcodes the segments that haven't been seen yet, in order.
+// A live playlist is joined at its newest segment rather than transcoding its
+// whole window. A segment that fails to download is retried on the next poll
+// along with the segments after it, unless it has left the playlist by then.
+// Returns the number of new segments and whether all of them were processed
+func (p *hlsPuller) processPlaylist(mpl *m3u8.MediaPlaylist) (int, bool) {
+	count := uint64(mpl.Count())
+	if count == 0 {
+		return 0, true
+	}
+	last := mpl.SeqNo + count - 1
+	if !p.started && mpl.Live {
+		p.resync(last)
+	} else if p.started && (mpl.SeqNo < p.firstSeq || last+1 < p.nextSeq) {
+		// The media sequence must never decrease, so the source was most likely restarted
+		glog.Infof("Media sequence of pulled playlist was reset manifestID=%s seqNo=%d nextSeqNo=%d", p.cxn.mid, mpl.SeqNo, p.nextSeq)
+		p.resync(last)
+	}
+	p.firstSeq = mpl.SeqNo
+
+	processed := 0
+	for i, seg := range mpl.Segments {
+		if seg == nil {
+			continue
+		}
+		srcSeqNo := mpl.SeqNo + uint64(i)
+		if p.started && srcSeqNo < p.nextSeq {
+			continue
+		}
+		seqNo := uint64(int64(srcSeqNo) + p.seqOffset)
+
+		segURL, err := p.url.Parse(seg.URI)
+		if err != nil {
+			glog.Errorf("Invalid segment URI in pulled playlist manifestID=%s uri=%s err=%v", p.cxn.mid, seg.URI, err)
+			p.started, p.nextSeq = true, srcSeqNo+1
+			continue
+		}
+		data, err := drivers.GetSegmentData(segURL.String())
+		if err != nil {
+			glog.Errorf("Error downloading pulled segment; retrying on the next poll manifestID=%s seqNo=%d err=%v", p.cxn.mid, seqNo, err)
+			return processed, false
+		}
+		p.started, p.nextSeq = true, srcSeqNo+1
+		processed++
+		glog.V(common.DEBUG).Infof("Pulled segment manifestID=%s seqNo=%d dur=%v", p.cxn.mid, seqNo, seg.Duration)
+
+		hlsSeg := &stream.HLSSegment{
+			Data:     data,
+			Name:     path.Base(segURL.Path),
+			SeqNo:    seqNo,
+			Duration: seg.Duration,
+		}
+		if _, err := processSegment(p.cxn, hlsSeg); err != nil {
+			glog.Errorf("Error processing pulled segment manifestID=%s seqNo=%d err=%v", p.cxn.mid, seqNo, err)
+		}
+	}
+	return processed, true
+}
+
+// resync continues the stream from the segment of the playlist with the given media sequence number
+func (p *hlsPuller) resync(srcSeqNo uint64) {
+	if p.started {
+		p.seqOffset += int64(p.nextSeq) - int64(srcSeqNo)
+	}
+	p.started, p.nextSeq = true, srcSeqNo
+}
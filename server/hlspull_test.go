@@ -0,0 +1,300 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/lpms/stream"
+	"github.com/livepeer/m3u8"
+)
+
+func mediaPlaylist(seqNo int, segments int, closed bool) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "#EXTM3U\n#EXT-X-VERSION:3\n#EXT-X-TARGETDURATION:2\n#EXT-X-MEDIA-SEQUENCE:%d\n", seqNo)
+	for i := 0; i < segments; i++ {
+		fmt.Fprintf(&b, "#EXTINF:2.000,\nseg/%d.ts\n", seqNo+i)
+	}
+	if closed {
+		b.WriteString("#EXT-X-ENDLIST\n")
+	}
+	return b.String()
+}
+
+const testMasterPlaylist = `#EXTM3U
+#EXT-X-STREAM-INF:BANDWIDTH=400000,RESOLUTION=640x360
+low/index.m3u8
+#EXT-X-STREAM-INF:BANDWIDTH=4000000,RESOLUTION=1280x720
+high/index.m3u8
+`
+
+func TestResolveMediaPlaylist(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/master.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testMasterPlaylist))
+	})
+	mux.HandleFunc("/invalid.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("not a playlist"))
+	})
+	mux.HandleFunc("/media.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mediaPlaylist(0, 1, false)))
+	})
+
+	// highest bandwidth variant of a master playlist
+	u, _ := url.Parse(ts.URL + "/master.m3u8")
+	mediaURL, resolution, err := resolveMediaPlaylist(u)
+	require.Nil(err)
+	assert.Equal(ts.URL+"/high/index.m3u8", mediaURL.String())
+	assert.Equal("1280x720", resolution)
+
+	// media playlists are used directly
+	u, _ = url.Parse(ts.URL + "/media.m3u8")
+	mediaURL, resolution, err = resolveMediaPlaylist(u)
+	require.Nil(err)
+	assert.Equal(u, mediaURL)
+	assert.Empty(resolution)
+
+	// errors
+	u, _ = url.Parse(ts.URL + "/invalid.m3u8")
+	_, _, err = resolveMediaPlaylist(u)
+	assert.NotNil(err)
+	u, _ = url.Parse(ts.URL + "/missing.m3u8")
+	_, _, err = resolveMediaPlaylist(u)
+	assert.NotNil(err)
+}
+
+func TestPullHLS(t *testing.T) {
+	defer func(interval time.Duration) { hlsPullInterval = interval }(hlsPullInterval)
+	hlsPullInterval = 10 * time.Millisecond
+	assert := assert.New(t)
+	require := require.New(t)
+	s := setupServer()
+
+	var mu sync.Mutex
+	fetches := 0
+	var segments []string
+	mux := http.NewServeMux()
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	mux.HandleFunc("/index.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		fetches++
+		switch {
+		case fetches <= 2:
+			// the first fetch resolves the playlist type; the stream starts at 6, the live edge
+			w.Write([]byte(mediaPlaylist(5, 2, false)))
+		case fetches == 3:
+			// sliding window moved; only 7 is new
+			w.Write([]byte(mediaPlaylist(6, 2, false)))
+		default:
+			w.Write([]byte(mediaPlaylist(6, 3, true)))
+		}
+	})
+	mux.HandleFunc("/other.m3u8", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mediaPlaylist(0, 1, false)))
+	})
+	mux.HandleFunc("/seg/", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		segments = append(segments, r.URL.Path)
+		mu.Unlock()
+		w.Write([]byte("segment data"))
+	})
+
+	u, _ := url.Parse(ts.URL + "/index.m3u8")
+	cxn, err := s.pullHLS(u, "pullTest")
+	require.Nil(err)
+	assert.Equal(core.ManifestID("pullTest"), cxn.mid)
+
+	// can't pull into a stream that already exists
+	other, _ := url.Parse(ts.URL + "/other.m3u8")
+	_, err = s.pullHLS(other, "pullTest")
+	assert.NotNil(err)
+
+	// the stream ends after the playlist is closed
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		s.connectionLock.RLock()
+		_, exists := s.rtmpConnections["pullTest"]
+		s.connectionLock.RUnlock()
+		if !exists {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	s.connectionLock.RLock()
+	_, exists := s.rtmpConnections["pullTest"]
+	s.connectionLock.RUnlock()
+	assert.False(exists)
+
+	// every segment is processed once, in order
+	mu.Lock()
+	assert.Equal([]string{"/seg/6.ts", "/seg/7.ts", "/seg/8.ts"}, segments)
+	mu.Unlock()
+}
+
+func TestHLSPuller_ProcessPlaylist(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	s := setupServer()
+
+	var mu sync.Mutex
+	var segments []string
+	failing := make(map[string]bool)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if failing[r.URL.Path] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		segments = append(segments, r.URL.Path)
+		w.Write([]byte("segment data"))
+	}))
+	defer ts.Close()
+	u, _ := url.Parse(ts.URL + "/index.m3u8")
+
+	newPuller := func(mid core.ManifestID) *hlsPuller {
+		cxn, err := s.registerConnection(stream.NewBasicRTMPVideoStream(s.newStreamParams(u, mid)))
+		require.Nil(err)
+		segments = nil
+		return &hlsPuller{s: s, cxn: cxn, url: u}
+	}
+	decode := func(pl string) *m3u8.MediaPlaylist {
+		p, _, err := m3u8.Decode(*bytes.NewBufferString(pl), false)
+		require.Nil(err)
+		return p.(*m3u8.MediaPlaylist)
+	}
+	process := func(p *hlsPuller, pl string) int {
+		processed, done := p.processPlaylist(decode(pl))
+		assert.True(done)
+		return processed
+	}
+	seqNos := func(p *hlsPuller) []uint64 {
+		var seqNos []uint64
+		for _, seg := range p.cxn.pl.GetHLSMediaPlaylist(p.cxn.profile.Name).Segments {
+			if seg != nil {
+				seqNos = append(seqNos, seg.SeqId)
+			}
+		}
+		return seqNos
+	}
+
+	// the whole of a closed playlist is transcoded
+	p := newPuller("processVOD")
+	defer removeRTMPStream(s, "processVOD")
+	assert.Equal(3, process(p, mediaPlaylist(5, 3, true)))
+	assert.Equal([]string{"/seg/5.ts", "/seg/6.ts", "/seg/7.ts"}, segments)
+
+	// a live playlist is joined at its live edge
+	p = newPuller("processLive")
+	defer removeRTMPStream(s, "processLive")
+	assert.Equal(1, process(p, mediaPlaylist(5, 3, false)))
+	assert.Equal(0, process(p, mediaPlaylist(5, 3, false)))
+	assert.Equal(2, process(p, mediaPlaylist(6, 4, false)))
+	assert.Equal([]string{"/seg/7.ts", "/seg/8.ts", "/seg/9.ts"}, segments)
+
+	// the stream resyncs at the live edge after the media sequence is reset,
+	// even if the new sequence numbers overlap those already seen
+	assert.Equal(1, process(p, mediaPlaylist(0, 2, false)))
+	assert.Equal(1, process(p, mediaPlaylist(0, 3, false)))
+	assert.Equal(2, process(p, mediaPlaylist(2, 3, false)))
+	assert.Equal(1, process(p, mediaPlaylist(1, 40, false)))
+	assert.Equal([]string{"/seg/7.ts", "/seg/8.ts", "/seg/9.ts", "/seg/1.ts", "/seg/2.ts", "/seg/3.ts", "/seg/4.ts", "/seg/40.ts"}, segments)
+
+	// the stream's sequence numbers keep increasing; the source playlist keeps the latest ones
+	assert.ElementsMatch([]uint64{9, 10, 11, 12, 13, 14}, seqNos(p))
+
+	// empty playlists are ignored
+	assert.Equal(0, process(p, "#EXTM3U\n#EXT-X-TARGETDURATION:2\n"))
+}
+
+func TestPullHLS_Timeout(t *testing.T) {
+	defer func(interval, timeout time.Duration) {
+		hlsPullInterval, HLSPullTimeout = interval, timeout
+	}(hlsPullInterval, HLSPullTimeout)
+	hlsPullInterval = 10 * time.Millisecond
+	HLSPullTimeout = 50 * time.Millisecond
+	s := setupServer()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".m3u8") {
+			w.Write([]byte(mediaPlaylist(0, 1, false)))
+		}
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL + "/index.m3u8")
+	cxn, err := s.pullHLS(u, "")
+	require.Nil(t, err)
+	assert.NotEmpty(t, cxn.mid)
+
+	time.Sleep(300 * time.Millisecond)
+	s.connectionLock.RLock()
+	_, exists := s.rtmpConnections[cxn.mid]
+	s.connectionLock.RUnlock()
+	assert.False(t, exists)
+}
+
+func TestPullHLSHandler(t *testing.T) {
+	assert := assert.New(t)
+	s := setupServer()
+
+	handler := mustHaveFormParams(s.pullHLSHandler(), "url")
+	resp := httpPostFormResp(handler, strings.NewReader(""))
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	resp = httpPostFormResp(handler, strings.NewReader("url=index.m3u8"))
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	resp = httpPostFormResp(handler, strings.NewReader("url=http://127.0.0.1:1/index.m3u8"))
+	assert.Equal(http.StatusInternalServerError, resp.StatusCode)
+
+	stop := mustHaveFormParams(s.stopHLSPullHandler(), "manifestID")
+	resp = httpPostFormResp(stop, strings.NewReader("manifestID=unknown"))
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	// streams that aren't pulled can't be stopped
+	u, _ := url.Parse("rtmp://localhost/pushed")
+	_, err := s.registerConnection(stream.NewBasicRTMPVideoStream(s.newStreamParams(u, "notPulled")))
+	require.Nil(t, err)
+	defer removeRTMPStream(s, "notPulled")
+	resp = httpPostFormResp(stop, strings.NewReader("manifestID=notPulled"))
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+	s.connectionLock.RLock()
+	_, exists := s.rtmpConnections["notPulled"]
+	s.connectionLock.RUnlock()
+	assert.True(exists)
+
+	// pulled streams can
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, ".m3u8") {
+			w.Write([]byte(mediaPlaylist(0, 1, false)))
+		}
+	}))
+	defer ts.Close()
+	u, _ = url.Parse(ts.URL + "/index.m3u8")
+	_, err = s.pullHLS(u, "pulled")
+	require.Nil(t, err)
+	resp = httpPostFormResp(stop, strings.NewReader("manifestID=pulled"))
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	s.connectionLock.RLock()
+	_, exists = s.rtmpConnections["pulled"]
+	s.connectionLock.RUnlock()
+	assert.False(exists)
+}
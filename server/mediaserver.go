@@ -62,6 +62,16 @@ var BroadcastJobVideoProfiles = []ffmpeg.VideoProfile{ffmpeg.P240p30fps4x3, ffmp
 
 var AuthWebhookURL string
 
+// DefaultSegmentFormat is the container of the renditions of streams that
+// don't request one through the auth webhook
+var DefaultSegmentFormat = core.FormatMPEGTS
+
+// RecordStreams records live streams that don't opt in or out through the auth webhook
+var RecordStreams = false
+
+// RecordStorage keeps the recordings of live streams
+var RecordStorage drivers.OSDriver
+
 var refreshIntervalHttpPush = 1 * time.Minute
 
 type streamParameters struct {
@@ -69,6 +79,12 @@ type streamParameters struct {
 	rtmpKey    string
 	profiles   []ffmpeg.VideoProfile
 	resolution string
+	// Container of the renditions
+	format core.SegmentFormat
+	// Whether to keep every segment of the stream in RecordStorage
+	record bool
+	// Number of segments for VOD jobs; zero for live streams
+	vodSegments uint
 }
 
 func (s *streamParameters) StreamID() string {
@@ -98,14 +114,24 @@ type LivepeerServer struct {
 	rtmpConnections map[core.ManifestID]*rtmpConnection
 	lastHLSStreamID core.StreamID
 	lastManifestID  core.ManifestID
-	connectionLock  *sync.RWMutex
+	// Streams pulled from external playlists, by the connection they were registered with
+	pulledStreams  map[core.ManifestID]*rtmpConnection
+	connectionLock *sync.RWMutex
+
+	// VOD jobs by ManifestID, protected by `vodLock`
+	vodJobs map[core.ManifestID]*vodJob
+	vodLock sync.Mutex
 }
 
 type authWebhookResponse struct {
 	ManifestID string   `json:"manifestID"`
 	StreamKey  string   `json:"streamKey"`
 	Presets    []string `json:"presets"`
-	Profiles   []struct {
+	// Container of the renditions; "ts" or "fmp4"
+	Format string `json:"format"`
+	// Overrides RecordStreams if set
+	Record   *bool `json:"record"`
+	Profiles []struct {
 		Name    string `json:"name"`
 		Width   int    `json:"width"`
 		Height  int    `json:"height"`
@@ -128,9 +154,13 @@ func NewLivepeerServer(rtmpAddr string, lpNode *core.LivepeerNode) *LivepeerServ
 	server := lpmscore.New(&opts)
 	ls := &LivepeerServer{RTMPSegmenter: server, LPMS: server, LivepeerNode: lpNode, HTTPMux: opts.HttpMux, connectionLock: &sync.RWMutex{},
 		rtmpConnections: make(map[core.ManifestID]*rtmpConnection),
+		pulledStreams:   make(map[core.ManifestID]*rtmpConnection),
+		vodJobs:         make(map[core.ManifestID]*vodJob),
 	}
 	if lpNode.NodeType == core.BroadcasterNode {
 		opts.HttpMux.HandleFunc("/live/", ls.HandlePush)
+		opts.HttpMux.HandleFunc("/jobs", ls.HandleVOD)
+		opts.HttpMux.HandleFunc("/jobs/", ls.HandleVOD)
 	}
 	return ls
 }
@@ -162,7 +192,7 @@ func (s *LivepeerServer) StartMediaServer(ctx context.Context, transcodingOption
 	if s.LivepeerNode.NodeType == core.BroadcasterNode {
 		go func() {
 			glog.V(4).Infof("HTTP Server listening on http://%v", httpAddr)
-			ec <- http.ListenAndServe(httpAddr, s.HTTPMux)
+			ec <- http.ListenAndServe(httpAddr, fmp4Handler(s, dashManifestHandler(s, s.HTTPMux)))
 		}()
 	}
 
@@ -180,81 +210,108 @@ func (s *LivepeerServer) StartMediaServer(ctx context.Context, transcodingOption
 //RTMP Publish Handlers
 func createRTMPStreamIDHandler(s *LivepeerServer) func(url *url.URL) (strmID stream.AppData) {
 	return func(url *url.URL) (strmID stream.AppData) {
-		//Check webhook for ManifestID
-		//If ManifestID is returned from webhook, use it
-		//Else check URL for ManifestID
-		//If ManifestID is passed in URL, use that one
-		//Else create one
-		var resp *authWebhookResponse
-		var mid core.ManifestID
-		var err error
-		var key string
-		profiles := []ffmpeg.VideoProfile{}
-		if resp, err = authenticateStream(url.String()); err != nil {
-			glog.Error("Authentication denied for ", err)
+		params := s.newStreamParams(url, "")
+		if params == nil {
 			return nil
 		}
-		if resp != nil {
-			mid, key = parseManifestID(resp.ManifestID), resp.StreamKey
-			// Process transcoding options presets
-			if len(resp.Presets) > 0 {
-				profiles = parsePresets(resp.Presets)
-			}
-
-			for _, profile := range resp.Profiles {
-				name := profile.Name
-				if name == "" {
-					name = "webhook_" + common.DefaultProfileName(
-						profile.Width,
-						profile.Height,
-						profile.Bitrate)
-				}
-				prof := ffmpeg.VideoProfile{
-					Name:       name,
-					Bitrate:    fmt.Sprint(profile.Bitrate),
-					Framerate:  profile.FPS,
-					Resolution: fmt.Sprintf("%dx%d", profile.Width, profile.Height),
-				}
-				profiles = append(profiles, prof)
-			}
+		return params
+	}
+}
 
-			// Only set defaults if user did not specify a preset/profile
-			if len(resp.Profiles) <= 0 && len(resp.Presets) <= 0 {
-				profiles = BroadcastJobVideoProfiles
+// newStreamParams authenticates a new stream and builds its parameters.
+// Returns nil if the stream shouldn't be accepted
+func (s *LivepeerServer) newStreamParams(url *url.URL, mid core.ManifestID) *streamParameters {
+	//Check webhook for ManifestID
+	//If ManifestID is returned from webhook, use it
+	//Else use the given ManifestID, if any
+	//Else check URL for ManifestID
+	//If ManifestID is passed in URL, use that one
+	//Else create one
+	var resp *authWebhookResponse
+	var err error
+	var key string
+	profiles := []ffmpeg.VideoProfile{}
+	format := DefaultSegmentFormat
+	record := RecordStreams
+	if resp, err = authenticateStream(url.String()); err != nil {
+		glog.Error("Authentication denied for ", err)
+		return nil
+	}
+	if resp != nil {
+		mid, key = parseManifestID(resp.ManifestID), resp.StreamKey
+		if resp.Format != "" {
+			if format, err = core.ParseSegmentFormat(resp.Format); err != nil {
+				glog.Error("Authentication denied for ", err)
+				return nil
 			}
-		} else {
-			profiles = BroadcastJobVideoProfiles
 		}
-
-		if mid == "" {
-			sid := parseStreamID(url.Path)
-			mid, key = sid.ManifestID, sid.Rendition
+		if resp.Record != nil {
+			record = *resp.Record
 		}
-		if mid == "" {
-			mid = core.RandomManifestID()
+		// Process transcoding options presets
+		if len(resp.Presets) > 0 {
+			profiles = parsePresets(resp.Presets)
 		}
 
-		// Ensure there's no concurrent StreamID with the same name
-		s.connectionLock.RLock()
-		defer s.connectionLock.RUnlock()
-		if core.MaxSessions > 0 && len(s.rtmpConnections) >= core.MaxSessions {
-			glog.Error("Too many connections")
-			return nil
-		}
-		if _, exists := s.rtmpConnections[mid]; exists {
-			glog.Error("Manifest already exists ", mid)
-			return nil
+		for _, profile := range resp.Profiles {
+			name := profile.Name
+			if name == "" {
+				name = "webhook_" + common.DefaultProfileName(
+					profile.Width,
+					profile.Height,
+					profile.Bitrate)
+			}
+			prof := ffmpeg.VideoProfile{
+				Name:       name,
+				Bitrate:    fmt.Sprint(profile.Bitrate),
+				Framerate:  profile.FPS,
+				Resolution: fmt.Sprintf("%dx%d", profile.Width, profile.Height),
+			}
+			profiles = append(profiles, prof)
 		}
 
-		// Generate RTMP part of StreamID
-		if key == "" {
-			key = common.RandomIDGenerator(StreamKeyBytes)
-		}
-		return &streamParameters{
-			mid:      mid,
-			rtmpKey:  key,
-			profiles: profiles,
+		// Only set defaults if user did not specify a preset/profile
+		if len(resp.Profiles) <= 0 && len(resp.Presets) <= 0 {
+			profiles = BroadcastJobVideoProfiles
 		}
+	} else {
+		profiles = BroadcastJobVideoProfiles
+	}
+	if !verifiableFormat(format) {
+		glog.Errorf("Stream rejected; the verifier does not support format=%s", format)
+		return nil
+	}
+
+	if mid == "" {
+		sid := parseStreamID(url.Path)
+		mid, key = sid.ManifestID, sid.Rendition
+	}
+	if mid == "" {
+		mid = core.RandomManifestID()
+	}
+
+	// Ensure there's no concurrent StreamID with the same name
+	s.connectionLock.RLock()
+	defer s.connectionLock.RUnlock()
+	if core.MaxSessions > 0 && len(s.rtmpConnections) >= core.MaxSessions {
+		glog.Error("Too many connections")
+		return nil
+	}
+	if _, exists := s.rtmpConnections[mid]; exists {
+		glog.Error("Manifest already exists ", mid)
+		return nil
+	}
+
+	// Generate RTMP part of StreamID
+	if key == "" {
+		key = common.RandomIDGenerator(StreamKeyBytes)
+	}
+	return &streamParameters{
+		mid:      mid,
+		rtmpKey:  key,
+		profiles: profiles,
+		format:   format,
+		record:   record,
 	}
 }
 
@@ -405,7 +462,19 @@ func (s *LivepeerServer) registerConnection(rtmpStrm stream.RTMPVideoStream) (*r
 		return nil, errAlreadyExists
 	}
 
-	playlist := core.NewBasicPlaylistManager(mid, storage)
+	var playlist core.PlaylistManager
+	if params.vodSegments > 0 {
+		playlist = core.NewVODPlaylistManager(mid, storage, params.vodSegments)
+	} else {
+		playlist = core.NewBasicPlaylistManager(mid, storage)
+		if params.record {
+			if RecordStorage != nil {
+				playlist = core.NewRecordingPlaylistManager(playlist, RecordStorage.NewSession(string(mid)+"/recording"))
+			} else {
+				glog.Errorf("Missing record storage, not recording manifestID=%s", mid)
+			}
+		}
+	}
 	var stakeRdr stakeReader
 	if s.LivepeerNode.Eth != nil {
 		stakeRdr = &storeStakeReader{store: s.LivepeerNode.Database}
@@ -417,7 +486,7 @@ func (s *LivepeerServer) registerConnection(rtmpStrm stream.RTMPVideoStream) (*r
 		pl:          playlist,
 		profile:     &vProfile,
 		params:      params,
-		sessManager: NewSessionManager(s.LivepeerNode, params, playlist, NewMinLSSelector(stakeRdr, 1.0)),
+		sessManager: NewSessionManager(s.LivepeerNode, params, playlist, newSelector(stakeRdr)),
 		lastUsed:    time.Now(),
 	}
 
@@ -453,6 +522,10 @@ func removeRTMPStream(s *LivepeerServer, mid core.ManifestID) error {
 	}
 	cxn.sessManager.cleanup()
 	cxn.pl.Cleanup()
+	if rec, ok := cxn.pl.(*core.RecordingPlaylistManager); ok {
+		// Uploading the playlists shouldn't hold up other streams
+		go rec.FinishRecording()
+	}
 	glog.Infof("Ended stream with id=%s", mid)
 	delete(s.rtmpConnections, mid)
 
@@ -516,6 +589,47 @@ func getHLSMediaPlaylistHandler(s *LivepeerServer) func(url *url.URL) (*m3u8.Med
 	}
 }
 
+// dashManifestHandler serves the DASH manifest of a stream at /stream/<ManifestID>.mpd
+// next to the HLS master playlist. Only fmp4 streams have a DASH manifest, since DASH
+// players don't support MPEG-TS segments. Other requests go to next
+func dashManifestHandler(s *LivepeerServer, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/stream/") || path.Ext(r.URL.Path) != ".mpd" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		var manifestID core.ManifestID
+		if s.ExposeCurrentManifest && "/stream/current.mpd" == strings.ToLower(r.URL.Path) {
+			manifestID = s.LastManifestID()
+		} else {
+			sid := parseStreamID(r.URL.Path)
+			if sid.Rendition != "" {
+				http.Error(w, "ErrNotFound", http.StatusNotFound)
+				return
+			}
+			manifestID = sid.ManifestID
+		}
+
+		s.connectionLock.RLock()
+		cxn, ok := s.rtmpConnections[manifestID]
+		s.connectionLock.RUnlock()
+		if !ok || cxn.pl == nil || cxn.pl.ManifestID() != manifestID || cxn.params.format != core.FormatFMP4 {
+			http.Error(w, "ErrNotFound", http.StatusNotFound)
+			return
+		}
+		data, err := cxn.pl.GetDASHManifest()
+		if err != nil {
+			glog.Errorf("Error generating DASH manifest manifestID=%s err=%v", manifestID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Cache-Control", "max-age=1")
+		w.Header().Set("Content-Type", "application/dash+xml")
+		w.Write(data)
+	})
+}
+
 func getHLSSegmentHandler(s *LivepeerServer) func(url *url.URL) ([]byte, error) {
 	return func(url *url.URL) ([]byte, error) {
 		// Strip the /stream/ prefix
@@ -660,6 +774,10 @@ func (s *LivepeerServer) HandlePush(w http.ResponseWriter, r *http.Request) {
 
 	// Do the transcoding!
 	urls, err := processSegment(cxn, seg)
+	if nr, ok := err.(NonRetryableError); ok && nr.error == errNoOrchs {
+		http.Error(w, "No sessions available", http.StatusServiceUnavailable)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -668,12 +786,18 @@ func (s *LivepeerServer) HandlePush(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "No sessions available", http.StatusServiceUnavailable)
 		return
 	}
+	format := cxn.params.format
 	renditionData := make([][]byte, len(urls))
 	// find data in local storage
 	memOS, ok := cxn.pl.GetOSSession().(*drivers.MemorySession)
 	if ok {
 		for i, fname := range urls {
 			data := memOS.GetData(fname)
+			if data != nil && format == core.FormatFMP4 {
+				// Send self-contained segments rather than the media sections in the playlist
+				init := memOS.GetData(cxn.pl.GetHLSInitSegment(cxn.params.profiles[i].Name))
+				data = append(append([]byte{}, init...), data...)
+			}
 			if data != nil {
 				renditionData[i] = data
 			}
@@ -694,7 +818,7 @@ func (s *LivepeerServer) HandlePush(w http.ResponseWriter, r *http.Request) {
 	mw := multipart.NewWriter(w)
 	for i, url := range urls {
 		mw.SetBoundary(boundary)
-		typ, ext, length := "video/MP2T", "ts", len(renditionData[i])
+		typ, ext, length := format.ContentType(), strings.TrimPrefix(format.Ext(), "."), len(renditionData[i])
 		if length == 0 {
 			typ, ext, length = "application/vnd+livepeer.uri", "txt", len(url)
 		}
@@ -783,6 +907,7 @@ func (s *LivepeerServer) GetNodeStatus() *net.NodeStatus {
 		OrchestratorPool:      []string{},
 		RegisteredTranscoders: []net.RemoteTranscoderInfo{},
 		LocalTranscoding:      s.LivepeerNode.TranscoderManager == nil,
+		Draining:              s.LivepeerNode.Draining(),
 	}
 	if s.LivepeerNode.TranscoderManager != nil {
 		res.RegisteredTranscodersNumber = s.LivepeerNode.TranscoderManager.RegisteredTranscodersCount()
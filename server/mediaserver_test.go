@@ -24,6 +24,7 @@ import (
 	"github.com/livepeer/go-livepeer/core"
 	"github.com/livepeer/go-livepeer/drivers"
 	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/go-livepeer/verification"
 	ffmpeg "github.com/livepeer/lpms/ffmpeg"
 	"github.com/livepeer/lpms/segmenter"
 	"github.com/livepeer/lpms/stream"
@@ -57,7 +58,7 @@ func (d *stubDiscovery) GetURLs() []*url.URL {
 	return nil
 }
 
-func (d *stubDiscovery) GetOrchestrators(num int) ([]*net.OrchestratorInfo, error) {
+func (d *stubDiscovery) GetOrchestrators(num int, pred func(*net.OrchestratorInfo) bool) ([]*net.OrchestratorInfo, error) {
 	if d.waitGetOrch != nil {
 		<-d.waitGetOrch
 	}
@@ -68,7 +69,16 @@ func (d *stubDiscovery) GetOrchestrators(num int) ([]*net.OrchestratorInfo, erro
 		err = d.getOrchError
 		d.lock.Unlock()
 	}
-	return d.infos, err
+	if pred == nil {
+		return d.infos, err
+	}
+	infos := []*net.OrchestratorInfo{}
+	for _, info := range d.infos {
+		if pred(info) {
+			infos = append(infos, info)
+		}
+	}
+	return infos, err
 }
 
 func (d *stubDiscovery) Size() int {
@@ -159,6 +169,39 @@ func TestSelectOrchestrator(t *testing.T) {
 		t.Error("Unexpected PM sessionID")
 	}
 
+	// Orchestrators that cannot transcode the stream's profiles are skipped
+	sd.infos = []*net.OrchestratorInfo{
+		&net.OrchestratorInfo{Transcoder: "a", Capabilities: &net.OrchestratorCapabilities{Profiles: []string{ffmpeg.P720p30fps16x9.Name}}},
+		&net.OrchestratorInfo{Transcoder: "b", Capabilities: &net.OrchestratorCapabilities{MaxWidth: 320, MaxHeight: 240}},
+		&net.OrchestratorInfo{Transcoder: "c", Capabilities: &net.OrchestratorCapabilities{MaxWidth: 640, MaxHeight: 360}},
+		&net.OrchestratorInfo{Transcoder: "d"},
+	}
+	sess, _ = selectOrchestrator(s.LivepeerNode, sp, pl, 4)
+	if len(sess) != 2 || sess[0].OrchestratorInfo.Transcoder != "c" || sess[1].OrchestratorInfo.Transcoder != "d" {
+		t.Error("Expected orchestrators that can transcode the profiles")
+	}
+	sd.infos = sd.infos[:2]
+	if sess, err := selectOrchestrator(s.LivepeerNode, sp, pl, 4); sess != nil || err != errNoOrchs {
+		t.Error("Expected no orchestrators that can transcode the profiles")
+	}
+
+	// Only orchestrators advertising fMP4 are selected for fMP4 streams
+	fmp4Params := *sp
+	fmp4Params.format = core.FormatFMP4
+	sd.infos = []*net.OrchestratorInfo{
+		&net.OrchestratorInfo{Transcoder: "a"},
+		&net.OrchestratorInfo{Transcoder: "b", Capabilities: &net.OrchestratorCapabilities{Formats: []net.SegmentFormat{net.SegmentFormat_MPEGTS}}},
+		&net.OrchestratorInfo{Transcoder: "c", Capabilities: &net.OrchestratorCapabilities{Formats: []net.SegmentFormat{net.SegmentFormat_MPEGTS, net.SegmentFormat_FMP4}}},
+	}
+	sess, _ = selectOrchestrator(s.LivepeerNode, &fmp4Params, pl, 3)
+	if len(sess) != 1 || sess[0].OrchestratorInfo.Transcoder != "c" || sess[0].Format != core.FormatFMP4 {
+		t.Error("Expected orchestrators that can produce fMP4")
+	}
+	sd.infos = []*net.OrchestratorInfo{
+		&net.OrchestratorInfo{},
+		&net.OrchestratorInfo{},
+	}
+
 	// Test start PM session
 	sender := &pm.MockSender{}
 	s.LivepeerNode.Sender = sender
@@ -220,6 +263,41 @@ func TestSelectOrchestrator(t *testing.T) {
 	assert.Equal(sess[1].OrchestratorInfo, &net.OrchestratorInfo{TicketParams: protoParams2})
 }
 
+type stubPricedDiscovery struct {
+	*stubDiscovery
+	maxPrices map[string]*big.Rat
+}
+
+func (d *stubPricedDiscovery) MaxPrice(info *net.OrchestratorInfo) *big.Rat {
+	return d.maxPrices[info.Transcoder]
+}
+
+func TestSelectOrchestrator_MaxPrice(t *testing.T) {
+	assert := assert.New(t)
+	s := setupServer()
+	pool := s.LivepeerNode.OrchestratorPool
+	defer func() { s.LivepeerNode.OrchestratorPool = pool }()
+
+	mid := core.RandomManifestID()
+	sp := &streamParameters{mid: mid, profiles: []ffmpeg.VideoProfile{ffmpeg.P360p30fps16x9}}
+	pl := core.NewBasicPlaylistManager(mid, drivers.NodeStorage.NewSession(string(mid)))
+	sd := &stubDiscovery{infos: []*net.OrchestratorInfo{{Transcoder: "a"}, {Transcoder: "b"}}}
+
+	// pools that don't set max prices
+	s.LivepeerNode.OrchestratorPool = sd
+	sess, err := selectOrchestrator(s.LivepeerNode, sp, pl, 2)
+	assert.Nil(err)
+	assert.Len(sess, 2)
+	assert.Nil(sess[0].MaxPrice)
+
+	s.LivepeerNode.OrchestratorPool = &stubPricedDiscovery{stubDiscovery: sd, maxPrices: map[string]*big.Rat{"b": big.NewRat(1, 2)}}
+	sess, err = selectOrchestrator(s.LivepeerNode, sp, pl, 2)
+	assert.Nil(err)
+	assert.Len(sess, 2)
+	assert.Nil(sess[0].MaxPrice)
+	assert.Equal(big.NewRat(1, 2), sess[1].MaxPrice)
+}
+
 func newStreamParams(mid core.ManifestID, rtmpKey string) *streamParameters {
 	return &streamParameters{mid: mid, rtmpKey: rtmpKey}
 }
@@ -411,6 +489,58 @@ func TestCreateRTMPStreamHandlerWebhook(t *testing.T) {
 	defer ts10.Close()
 	params = createSid(u).(*streamParameters)
 	assert.Len(params.profiles, 0, "Unexpected value in presets")
+
+	// output container
+	assert.Equal(core.FormatMPEGTS, params.format, "Unexpected default format")
+	ts11 := makeServer(`{"manifestID":"a", "format":"fmp4"}`)
+	defer ts11.Close()
+	params = createSid(u).(*streamParameters)
+	assert.Equal(core.FormatFMP4, params.format)
+
+	defer func(f core.SegmentFormat) { DefaultSegmentFormat = f }(DefaultSegmentFormat)
+	DefaultSegmentFormat = core.FormatFMP4
+	ts12 := makeServer(`{"manifestID":"a"}`)
+	defer ts12.Close()
+	params = createSid(u).(*streamParameters)
+	assert.Equal(core.FormatFMP4, params.format, "Should use the default format")
+
+	ts13 := makeServer(`{"manifestID":"a", "format":"webm"}`)
+	defer ts13.Close()
+	assert.Nil(createSid(u), "Should not pass with an unknown format")
+
+	// the local verifier can't check fragmented MP4 renditions
+	defer func(p *verification.Policy) { Policy = p }(Policy)
+	Policy = &verification.Policy{Verifier: &verification.LocalVerifier{}}
+	ts13a := makeServer(`{"manifestID":"a", "format":"fmp4"}`)
+	defer ts13a.Close()
+	assert.Nil(createSid(u), "Should not pass with fmp4 and the local verifier")
+	Policy = &verification.Policy{Verifier: &verification.EpicClassifier{}}
+	params = createSid(u).(*streamParameters)
+	assert.Equal(core.FormatFMP4, params.format)
+	Policy = &verification.Policy{Verifier: &verification.LocalVerifier{}}
+	ts13b := makeServer(`{"manifestID":"a", "format":"ts"}`)
+	defer ts13b.Close()
+	params = createSid(u).(*streamParameters)
+	assert.Equal(core.FormatMPEGTS, params.format)
+	Policy = nil
+
+	// recording
+	ts14 := makeServer(`{"manifestID":"a", "record":true}`)
+	defer ts14.Close()
+	params = createSid(u).(*streamParameters)
+	assert.True(params.record)
+
+	defer func(r bool) { RecordStreams = r }(RecordStreams)
+	RecordStreams = true
+	ts15 := makeServer(`{"manifestID":"a"}`)
+	defer ts15.Close()
+	params = createSid(u).(*streamParameters)
+	assert.True(params.record, "Should use the default")
+
+	ts16 := makeServer(`{"manifestID":"a", "record":false}`)
+	defer ts16.Close()
+	params = createSid(u).(*streamParameters)
+	assert.False(params.record, "Should override the default")
 }
 
 func TestCreateRTMPStreamHandler(t *testing.T) {
@@ -687,6 +817,56 @@ func TestGetHLSMasterPlaylistHandler(t *testing.T) {
 	}
 }
 
+func TestDASHManifestHandler(t *testing.T) {
+	assert := assert.New(t)
+	s := setupServer()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	handler := dashManifestHandler(s, next)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	mid := core.ManifestID("dashManifest")
+	params := newStreamParams(mid, "source")
+	params.format = core.FormatFMP4
+	cxn, err := s.registerConnection(stream.NewBasicRTMPVideoStream(params))
+	require.Nil(t, err)
+	defer removeRTMPStream(s, mid)
+	vProfile := ffmpeg.P720p30fps16x9
+	require.Nil(t, cxn.pl.InsertHLSSegment(&vProfile, 1, "test_seg/1.m4s", 2))
+	require.Nil(t, cxn.pl.InsertHLSInitSegment(&vProfile, "test_seg/init.mp4"))
+
+	w := get("/stream/dashManifest.mpd")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Equal("application/dash+xml", w.Header().Get("Content-Type"))
+	assert.Contains(w.Body.String(), `<SegmentURL media="test_seg/1.m4s"></SegmentURL>`)
+
+	assert.Equal(http.StatusNotFound, get("/stream/unknown.mpd").Code)
+	assert.Equal(http.StatusNotFound, get("/stream/dashManifest/P720p30fps16x9.mpd").Code)
+
+	// the current stream, if exposed
+	assert.Equal(http.StatusNotFound, get("/stream/current.mpd").Code)
+	s.ExposeCurrentManifest = true
+	defer func() { s.ExposeCurrentManifest = false }()
+	assert.Equal(http.StatusOK, get("/stream/current.mpd").Code)
+
+	// MPEG-TS streams have no DASH manifest
+	tsMid := core.ManifestID("dashManifestTS")
+	cxn, err = s.registerConnection(stream.NewBasicRTMPVideoStream(newStreamParams(tsMid, "source")))
+	require.Nil(t, err)
+	defer removeRTMPStream(s, tsMid)
+	require.Nil(t, cxn.pl.InsertHLSSegment(&vProfile, 1, "test_seg/1.ts", 2))
+	assert.Equal(http.StatusNotFound, get("/stream/dashManifestTS.mpd").Code)
+
+	// other requests pass through
+	assert.Equal(http.StatusTeapot, get("/stream/dashManifest.m3u8").Code)
+	assert.Equal(http.StatusTeapot, get("/live/dashManifest.mpd").Code)
+}
+
 func TestRegisterConnection(t *testing.T) {
 	assert := assert.New(t)
 	s := setupServer()
@@ -737,6 +917,54 @@ func TestRegisterConnection(t *testing.T) {
 
 }
 
+func TestRegisterConnection_Record(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	s := setupServer()
+	defer func(d drivers.OSDriver) { RecordStorage = d }(RecordStorage)
+
+	// not recorded without record storage
+	RecordStorage = nil
+	mid := core.ManifestID("recordnostorage")
+	cxn, err := s.registerConnection(stream.NewBasicRTMPVideoStream(&streamParameters{mid: mid, record: true}))
+	require.Nil(err)
+	_, ok := cxn.pl.(*core.BasicPlaylistManager)
+	assert.True(ok)
+	removeRTMPStream(s, mid)
+
+	RecordStorage = drivers.NewMemoryDriver(nil)
+	mid = core.ManifestID("record")
+	cxn, err = s.registerConnection(stream.NewBasicRTMPVideoStream(&streamParameters{mid: mid, record: true}))
+	require.Nil(err)
+	rec, ok := cxn.pl.(*core.RecordingPlaylistManager)
+	require.True(ok)
+
+	seg := []byte("segment")
+	uri, err := cxn.pl.GetOSSession().SaveData("source/0.ts", seg)
+	require.Nil(err)
+	require.Nil(rec.InsertHLSSegment(cxn.profile, 0, uri, 2))
+
+	// the recording is finalized once the stream ends
+	require.Nil(removeRTMPStream(s, mid))
+	recOS := RecordStorage.NewSession(string(mid) + "/recording").(*drivers.MemorySession)
+	var pl []byte
+	for i := 0; i < 100 && pl == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+		pl = recOS.GetData(string(mid) + "/recording/source.m3u8")
+	}
+	require.NotNil(pl)
+	assert.Contains(string(pl), "#EXT-X-ENDLIST")
+	assert.Equal(seg, recOS.GetData(string(mid)+"/recording/source/0.ts"))
+
+	// live streams only
+	mid = core.ManifestID("recordvod")
+	cxn, err = s.registerConnection(stream.NewBasicRTMPVideoStream(&streamParameters{mid: mid, record: true, vodSegments: 1}))
+	require.Nil(err)
+	_, ok = cxn.pl.(*core.BasicPlaylistManager)
+	assert.True(ok)
+	removeRTMPStream(s, mid)
+}
+
 func TestBroadcastSessionManagerWithStreamStartStop(t *testing.T) {
 	assert := assert.New(t)
 
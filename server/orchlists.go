@@ -0,0 +1,272 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/net"
+)
+
+// OrchList is a list the broadcaster uses to include or exclude orchestrators
+type OrchList string
+
+const (
+	// OrchAllowlist restricts the broadcaster to the orchestrators in it, unless it is empty
+	OrchAllowlist OrchList = "allow"
+	// OrchBlocklist excludes the orchestrators in it
+	OrchBlocklist OrchList = "block"
+)
+
+var errOrchList = errors.New("unknown orchestrator list; expected allow or block")
+var errOrchListEntry = errors.New("orchestrator list entry must be an Ethereum address or a service URI")
+
+// OrchLists is applied by the orchestrator pools; nil accepts any orchestrator
+var OrchLists *OrchestratorLists
+
+// OrchListStore persists orchestrator lists so that they survive a node restart
+type OrchListStore interface {
+	InsertOrchListEntry(list, entry string) error
+	DeleteOrchListEntry(list, entry string) error
+	OrchListEntries(list string) ([]string, error)
+}
+
+// OrchestratorLists holds the broadcaster's allowlist and blocklist of orchestrators.
+// Entries are Ethereum addresses, matched against the ticket recipient, or service
+// URIs, matched by host and port, or by host alone if the entry has no port
+type OrchestratorLists struct {
+	lists map[OrchList][]string
+	mu    sync.RWMutex
+	store OrchListStore
+}
+
+// NewOrchestratorLists creates OrchestratorLists that write every change through
+// to store and are initialized with the entries persisted by a previous run
+func NewOrchestratorLists(store OrchListStore) (*OrchestratorLists, error) {
+	l := &OrchestratorLists{
+		lists: make(map[OrchList][]string),
+		store: store,
+	}
+	for _, list := range []OrchList{OrchAllowlist, OrchBlocklist} {
+		entries, err := store.OrchListEntries(string(list))
+		if err != nil {
+			return nil, err
+		}
+		l.lists[list] = entries
+	}
+
+	glog.Infof("Loaded orchestrator lists allowlist=%v blocklist=%v", len(l.lists[OrchAllowlist]), len(l.lists[OrchBlocklist]))
+
+	return l, nil
+}
+
+// ParseOrchList validates the name of an orchestrator list
+func ParseOrchList(list string) (OrchList, error) {
+	switch l := OrchList(list); l {
+	case OrchAllowlist, OrchBlocklist:
+		return l, nil
+	}
+	return "", errOrchList
+}
+
+// normalizeOrchListEntry returns the checksummed Ethereum address or the host and port of a service URI
+func normalizeOrchListEntry(entry string) (string, error) {
+	entry = strings.TrimSpace(entry)
+	if ethcommon.IsHexAddress(entry) {
+		return ethcommon.HexToAddress(entry).Hex(), nil
+	}
+	host := orchHost(entry)
+	if host == "" {
+		return "", errOrchListEntry
+	}
+	return host, nil
+}
+
+// orchHost returns the lowercase host and port of a service URI, which may omit the scheme
+func orchHost(uri string) string {
+	if !strings.Contains(uri, "://") {
+		uri = "https://" + uri
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Host)
+}
+
+// orchHostMatches returns whether a host list entry matches the host and port of a service URI
+func orchHostMatches(entry, host string) bool {
+	if entry == host {
+		return true
+	}
+	e := &url.URL{Host: entry}
+	return e.Port() == "" && e.Hostname() == (&url.URL{Host: host}).Hostname()
+}
+
+// Add adds an entry to a list and returns the entry as it is stored
+func (l *OrchestratorLists) Add(list OrchList, entry string) (string, error) {
+	entry, err := normalizeOrchListEntry(entry)
+	if err != nil {
+		return "", err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.lists[list] {
+		if e == entry {
+			return entry, nil
+		}
+	}
+	if err := l.store.InsertOrchListEntry(string(list), entry); err != nil {
+		return "", err
+	}
+	l.lists[list] = append(l.lists[list], entry)
+	glog.Infof("Added orchestrator list=%v entry=%v", list, entry)
+	return entry, nil
+}
+
+// Remove removes an entry from a list. Removing a missing entry is not an error
+func (l *OrchestratorLists) Remove(list OrchList, entry string) error {
+	entry, err := normalizeOrchListEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.store.DeleteOrchListEntry(string(list), entry); err != nil {
+		return err
+	}
+	entries := l.lists[list]
+	for i, e := range entries {
+		if e == entry {
+			l.lists[list] = append(entries[:i], entries[i+1:]...)
+			glog.Infof("Removed orchestrator list=%v entry=%v", list, entry)
+			break
+		}
+	}
+	return nil
+}
+
+// Entries returns a copy of the entries of a list
+func (l *OrchestratorLists) Entries(list OrchList) []string {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return append([]string{}, l.lists[list]...)
+}
+
+// Allowed returns whether the orchestrator isn't blocked and, if the allowlist isn't empty, is allowed
+func (l *OrchestratorLists) Allowed(info *net.OrchestratorInfo) bool {
+	if l == nil {
+		return true
+	}
+
+	host := orchHost(info.GetTranscoder())
+	var addr string
+	if recipient := info.GetTicketParams().GetRecipient(); len(recipient) > 0 {
+		addr = ethcommon.BytesToAddress(recipient).Hex()
+	}
+	matches := func(entries []string) bool {
+		for _, e := range entries {
+			if e == addr || orchHostMatches(e, host) {
+				return true
+			}
+		}
+		return false
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	if matches(l.lists[OrchBlocklist]) {
+		return false
+	}
+	allow := l.lists[OrchAllowlist]
+	return len(allow) == 0 || matches(allow)
+}
+
+// AllowedURI returns whether the orchestrator at uri may be allowed, judging by its host
+// alone, so that excluded orchestrators aren't asked for their info. Orchestrators it
+// accepts must still pass Allowed, since entries can also be Ethereum addresses
+func (l *OrchestratorLists) AllowedURI(uri *url.URL) bool {
+	if l == nil {
+		return true
+	}
+
+	host := strings.ToLower(uri.Host)
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	for _, e := range l.lists[OrchBlocklist] {
+		if orchHostMatches(e, host) {
+			return false
+		}
+	}
+	allow := l.lists[OrchAllowlist]
+	if len(allow) == 0 {
+		return true
+	}
+	for _, e := range allow {
+		// The address of the orchestrator is only known from its info
+		if ethcommon.IsHexAddress(e) || orchHostMatches(e, host) {
+			return true
+		}
+	}
+	return false
+}
+
+// orchListsHandler returns the allowlist and the blocklist
+func orchListsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if OrchLists == nil {
+			respondWith500(w, "orchestrator lists are only available on broadcasters")
+			return
+		}
+		data, err := json.Marshal(map[OrchList][]string{
+			OrchAllowlist: OrchLists.Entries(OrchAllowlist),
+			OrchBlocklist: OrchLists.Entries(OrchBlocklist),
+		})
+		if err != nil {
+			respondWith500(w, err.Error())
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	})
+}
+
+// updateOrchListHandler adds the "entry" form value to or removes it from the "list" form value
+func updateOrchListHandler(add bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if OrchLists == nil {
+			respondWith500(w, "orchestrator lists are only available on broadcasters")
+			return
+		}
+		list, err := ParseOrchList(r.FormValue("list"))
+		if err != nil {
+			respondWith400(w, err.Error())
+			return
+		}
+		entry := r.FormValue("entry")
+		if add {
+			entry, err = OrchLists.Add(list, entry)
+		} else {
+			err = OrchLists.Remove(list, entry)
+		}
+		if err == errOrchListEntry {
+			respondWith400(w, err.Error())
+			return
+		} else if err != nil {
+			respondWith500(w, fmt.Sprintf("could not update orchestrator list: %v", err))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(entry))
+	})
+}
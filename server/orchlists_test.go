@@ -0,0 +1,267 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	ethcommon "github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livepeer/go-livepeer/net"
+)
+
+type stubOrchListStore struct {
+	lists map[string][]string
+	err   error
+}
+
+func newStubOrchListStore() *stubOrchListStore {
+	return &stubOrchListStore{lists: make(map[string][]string)}
+}
+
+func (s *stubOrchListStore) InsertOrchListEntry(list, entry string) error {
+	if s.err != nil {
+		return s.err
+	}
+	s.lists[list] = append(s.lists[list], entry)
+	return nil
+}
+
+func (s *stubOrchListStore) DeleteOrchListEntry(list, entry string) error {
+	if s.err != nil {
+		return s.err
+	}
+	entries := s.lists[list]
+	for i, e := range entries {
+		if e == entry {
+			s.lists[list] = append(entries[:i:i], entries[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (s *stubOrchListStore) OrchListEntries(list string) ([]string, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return append([]string{}, s.lists[list]...), nil
+}
+
+func TestOrchestratorLists(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := newStubOrchListStore()
+	store.err = errors.New("OrchListEntries error")
+	_, err := NewOrchestratorLists(store)
+	assert.Equal(store.err, err)
+	store.err = nil
+
+	l, err := NewOrchestratorLists(store)
+	require.Nil(err)
+	assert.Empty(l.Entries(OrchAllowlist))
+	assert.Empty(l.Entries(OrchBlocklist))
+
+	// entries are normalized
+	addr := ethcommon.HexToAddress("0x00000000000000000000000000000000000000ab")
+	entry, err := l.Add(OrchBlocklist, strings.ToLower(addr.Hex()))
+	require.Nil(err)
+	assert.Equal(addr.Hex(), entry)
+	entry, err = l.Add(OrchBlocklist, "https://Orch.Example:8935/")
+	require.Nil(err)
+	assert.Equal("orch.example:8935", entry)
+	entry, err = l.Add(OrchBlocklist, " orch.example:8935 ")
+	require.Nil(err)
+	assert.Equal("orch.example:8935", entry)
+	assert.Equal([]string{addr.Hex(), "orch.example:8935"}, l.Entries(OrchBlocklist))
+	assert.Empty(l.Entries(OrchAllowlist))
+
+	_, err = l.Add(OrchBlocklist, "")
+	assert.Equal(errOrchListEntry, err)
+	assert.Equal(errOrchListEntry, l.Remove(OrchBlocklist, "://"))
+
+	// changes are persisted
+	assert.Equal([]string{addr.Hex(), "orch.example:8935"}, store.lists[string(OrchBlocklist)])
+	require.Nil(l.Remove(OrchBlocklist, addr.Hex()))
+	assert.Nil(l.Remove(OrchBlocklist, addr.Hex()))
+	assert.Equal([]string{"orch.example:8935"}, l.Entries(OrchBlocklist))
+	assert.Equal([]string{"orch.example:8935"}, store.lists[string(OrchBlocklist)])
+
+	reloaded, err := NewOrchestratorLists(store)
+	require.Nil(err)
+	assert.Equal([]string{"orch.example:8935"}, reloaded.Entries(OrchBlocklist))
+
+	// store errors leave the lists unchanged
+	store.err = errors.New("store error")
+	_, err = l.Add(OrchAllowlist, "orch2.example:8935")
+	assert.Equal(store.err, err)
+	assert.Equal(store.err, l.Remove(OrchBlocklist, "orch.example:8935"))
+	assert.Empty(l.Entries(OrchAllowlist))
+	assert.Equal([]string{"orch.example:8935"}, l.Entries(OrchBlocklist))
+}
+
+func TestOrchestratorLists_Allowed(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	addr := ethcommon.HexToAddress("0x00000000000000000000000000000000000000ab")
+	info := func(uri string, recipient ethcommon.Address) *net.OrchestratorInfo {
+		return &net.OrchestratorInfo{
+			Transcoder:   uri,
+			TicketParams: &net.TicketParams{Recipient: recipient.Bytes()},
+		}
+	}
+	o1 := info("https://orch1.example:8935", addr)
+	o2 := info("https://orch2.example:8935", ethcommon.Address{})
+	o3 := &net.OrchestratorInfo{Transcoder: "https://orch3.example:8935"}
+
+	// nil lists allow everything
+	var nilLists *OrchestratorLists
+	assert.True(nilLists.Allowed(o1))
+
+	l, err := NewOrchestratorLists(newStubOrchListStore())
+	require.Nil(err)
+	assert.True(l.Allowed(o1))
+	assert.True(l.Allowed(o2))
+	assert.True(l.Allowed(o3))
+
+	// blocked by address
+	_, err = l.Add(OrchBlocklist, addr.Hex())
+	require.Nil(err)
+	assert.False(l.Allowed(o1))
+	assert.True(l.Allowed(o2))
+	assert.True(l.Allowed(o3))
+
+	// only allowed orchestrators are accepted
+	_, err = l.Add(OrchAllowlist, "orch2.example:8935")
+	require.Nil(err)
+	assert.False(l.Allowed(o1))
+	assert.True(l.Allowed(o2))
+	assert.False(l.Allowed(o3))
+
+	// blocking wins over allowing
+	_, err = l.Add(OrchAllowlist, "orch1.example:8935")
+	require.Nil(err)
+	assert.False(l.Allowed(o1))
+	require.Nil(l.Remove(OrchBlocklist, addr.Hex()))
+	assert.True(l.Allowed(o1))
+	_, err = l.Add(OrchBlocklist, "https://ORCH2.example:8935")
+	require.Nil(err)
+	assert.False(l.Allowed(o2))
+
+	// entries without a port match the host on any port
+	_, err = l.Add(OrchAllowlist, "orch3.example")
+	require.Nil(err)
+	assert.True(l.Allowed(o3))
+	assert.True(l.Allowed(info("https://orch3.example:8936", ethcommon.Address{})))
+	_, err = l.Add(OrchBlocklist, "orch1.example")
+	require.Nil(err)
+	assert.False(l.Allowed(o1))
+	assert.False(l.Allowed(info("https://orch1.example", ethcommon.Address{})))
+	assert.False(l.Allowed(info("https://orch1.example.com:8935", ethcommon.Address{})))
+}
+
+func TestOrchestratorLists_AllowedURI(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	uri := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		require.Nil(err)
+		return u
+	}
+	o1 := uri("https://orch1.example:8935")
+	o2 := uri("https://Orch2.example:8935")
+
+	var nilLists *OrchestratorLists
+	assert.True(nilLists.AllowedURI(o1))
+
+	l, err := NewOrchestratorLists(newStubOrchListStore())
+	require.Nil(err)
+	assert.True(l.AllowedURI(o1))
+	assert.True(l.AllowedURI(o2))
+
+	// blocked addresses are only known from the orchestrator info
+	addr := ethcommon.HexToAddress("0x00000000000000000000000000000000000000ab")
+	_, err = l.Add(OrchBlocklist, addr.Hex())
+	require.Nil(err)
+	assert.True(l.AllowedURI(o1))
+
+	// blocked by host
+	_, err = l.Add(OrchBlocklist, "orch1.example")
+	require.Nil(err)
+	assert.False(l.AllowedURI(o1))
+	assert.True(l.AllowedURI(o2))
+
+	// not in the allowlist
+	_, err = l.Add(OrchAllowlist, "orch3.example:8935")
+	require.Nil(err)
+	assert.False(l.AllowedURI(o2))
+	_, err = l.Add(OrchAllowlist, "orch2.example:8935")
+	require.Nil(err)
+	assert.True(l.AllowedURI(o2))
+	assert.False(l.AllowedURI(uri("https://orch2.example:8936")))
+
+	// any orchestrator may have an allowed address
+	_, err = l.Add(OrchAllowlist, addr.Hex())
+	require.Nil(err)
+	assert.True(l.AllowedURI(uri("https://orch4.example:8935")))
+	assert.False(l.AllowedURI(o1))
+}
+
+func TestOrchestratorLists_Handlers(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(l *OrchestratorLists) { OrchLists = l }(OrchLists)
+	OrchLists = nil
+
+	postForm := func(handler http.Handler, list, entry string) (int, string) {
+		form := url.Values{"list": {list}, "entry": {entry}}
+		resp := httpPostFormResp(handler, strings.NewReader(form.Encode()))
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		return resp.StatusCode, strings.TrimSpace(string(body))
+	}
+
+	// not a broadcaster
+	resp := httpGetResp(orchListsHandler())
+	assert.Equal(http.StatusInternalServerError, resp.StatusCode)
+	code, _ := postForm(updateOrchListHandler(true), "block", "orch.example:8935")
+	assert.Equal(http.StatusInternalServerError, code)
+
+	var err error
+	OrchLists, err = NewOrchestratorLists(newStubOrchListStore())
+	require.Nil(err)
+
+	code, body := postForm(updateOrchListHandler(true), "deny", "orch.example:8935")
+	assert.Equal(http.StatusBadRequest, code)
+	assert.Equal(errOrchList.Error(), body)
+	code, body = postForm(updateOrchListHandler(true), "block", "://")
+	assert.Equal(http.StatusBadRequest, code)
+	assert.Equal(errOrchListEntry.Error(), body)
+
+	code, body = postForm(updateOrchListHandler(true), "block", "https://Orch.Example:8935")
+	assert.Equal(http.StatusOK, code)
+	assert.Equal("orch.example:8935", body)
+	code, _ = postForm(updateOrchListHandler(true), "allow", "orch2.example:8935")
+	assert.Equal(http.StatusOK, code)
+
+	resp = httpGetResp(orchListsHandler())
+	require.Equal(http.StatusOK, resp.StatusCode)
+	assert.Equal("application/json", resp.Header.Get("Content-Type"))
+	var lists map[string][]string
+	require.Nil(json.NewDecoder(resp.Body).Decode(&lists))
+	assert.Equal(map[string][]string{"allow": {"orch2.example:8935"}, "block": {"orch.example:8935"}}, lists)
+
+	code, _ = postForm(updateOrchListHandler(false), "block", "orch.example:8935")
+	assert.Equal(http.StatusOK, code)
+	assert.Empty(OrchLists.Entries(OrchBlocklist))
+}
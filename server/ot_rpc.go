@@ -26,6 +26,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	"github.com/livepeer/go-livepeer/common"
@@ -39,17 +40,24 @@ const transcodingErrorMimeType = "livepeer/transcoding-error"
 var errSecret = errors.New("Invalid secret")
 var errZeroCapacity = errors.New("Zero capacity")
 
+// transcoderID identifies this transcoder to the orchestrator across reconnects
+var transcoderID = common.RandName()
+
 // Standalone Transcoder
 
 // RunTranscoder is main routing of standalone transcoder
 // Exiting it will terminate executable
-func RunTranscoder(n *core.LivepeerNode, orchAddr string, capacity int) {
+func RunTranscoder(n *core.LivepeerNode, orchAddr string, capacity int, capabilities *net.TranscoderCapabilities) {
 	expb := backoff.NewExponentialBackOff()
 	expb.MaxInterval = time.Minute
 	expb.MaxElapsedTime = 0
 	backoff.Retry(func() error {
+		if n.Draining() {
+			glog.Info("Terminating drained transcoder")
+			return nil
+		}
 		glog.Info("Registering transcoder to ", orchAddr)
-		err := runTranscoder(n, orchAddr, capacity)
+		err := runTranscoder(n, orchAddr, capacity, capabilities)
 		glog.Info("Unregistering transcoder: ", err)
 		if _, fatal := err.(core.RemoteTranscoderFatalError); fatal {
 			glog.Info("Terminating transcoder because of ", err)
@@ -77,7 +85,7 @@ func checkTranscoderError(err error) error {
 	return err
 }
 
-func runTranscoder(n *core.LivepeerNode, orchAddr string, capacity int) error {
+func runTranscoder(n *core.LivepeerNode, orchAddr string, capacity int, capabilities *net.TranscoderCapabilities) error {
 	tlsConfig := &tls.Config{InsecureSkipVerify: true}
 	conn, err := grpc.Dial(orchAddr,
 		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
@@ -88,11 +96,11 @@ func runTranscoder(n *core.LivepeerNode, orchAddr string, capacity int) error {
 	defer conn.Close()
 
 	c := net.NewTranscoderClient(conn)
-	ctx := context.Background()
+	ctx := metadata.AppendToOutgoingContext(context.Background(), core.TranscoderIDKey, transcoderID)
 	ctx, cancel := context.WithCancel(ctx)
 	// Silence linter
 	defer cancel()
-	r, err := c.RegisterTranscoder(ctx, &net.RegisterRequest{Secret: n.OrchSecret, Capacity: int64(capacity)})
+	r, err := c.RegisterTranscoder(ctx, &net.RegisterRequest{Secret: n.OrchSecret, Capacity: int64(capacity), Capabilities: capabilities})
 	if err := checkTranscoderError(err); err != nil {
 		glog.Error("Could not register transcoder to orchestrator ", err)
 		return err
@@ -109,6 +117,14 @@ func runTranscoder(n *core.LivepeerNode, orchAddr string, capacity int) error {
 			// Cancelling context will close connection to orchestrator
 			cancel()
 			return
+		case <-n.DrainNotify():
+			glog.Info("Draining Livepeer Transcoder; no longer accepting segments")
+			// Orchestrator stops sending segments once the connection is closed.
+			// Results of segments in flight are still submitted over HTTP.
+			cancel()
+			return
+		case <-ctx.Done():
+			return
 		}
 	}()
 
@@ -145,7 +161,8 @@ func runTranscode(n *core.LivepeerNode, orchAddr string, httpc *http.Client, not
 	var contentType string
 	var body bytes.Buffer
 
-	tData, err := n.Transcoder.Transcode(notify.Job, notify.Url, profiles)
+	format := core.SegmentFormat(notify.Format)
+	tData, err := n.Transcoder.Transcode(notify.Job, notify.Url, profiles, format)
 	glog.V(common.VERBOSE).Infof("Transcoding done for taskId=%d url=%s err=%v", notify.TaskId, notify.Url, err)
 	if err != nil {
 		glog.Error("Unable to transcode ", err)
@@ -157,7 +174,7 @@ func runTranscode(n *core.LivepeerNode, orchAddr string, httpc *http.Client, not
 		for _, v := range tData.Segments {
 			w.SetBoundary(boundary)
 			hdrs := textproto.MIMEHeader{
-				"Content-Type":   {"video/MP2T"},
+				"Content-Type":   {format.ContentType()},
 				"Content-Length": {strconv.Itoa(len(v.Data))},
 				"Pixels":         {strconv.FormatInt(v.Pixels, 10)},
 			}
@@ -195,7 +212,7 @@ func runTranscode(n *core.LivepeerNode, orchAddr string, httpc *http.Client, not
 
 func (h *lphttp) RegisterTranscoder(req *net.RegisterRequest, stream net.Transcoder_RegisterTranscoderServer) error {
 	from := common.GetConnectionAddr(stream.Context())
-	glog.Infof("Got a RegisterTranscoder request from transcoder=%s capacity=%d", from, req.Capacity)
+	glog.Infof("Got a RegisterTranscoder request from transcoder=%s capacity=%d capabilities=%v", from, req.Capacity, req.Capabilities)
 
 	if req.Secret != h.orchestrator.TranscoderSecret() {
 		glog.Info(errSecret.Error())
@@ -207,7 +224,7 @@ func (h *lphttp) RegisterTranscoder(req *net.RegisterRequest, stream net.Transco
 	}
 
 	// blocks until stream is finished
-	h.orchestrator.ServeTranscoder(stream, int(req.Capacity))
+	h.orchestrator.ServeTranscoder(stream, int(req.Capacity), req.Capabilities)
 	return nil
 }
 
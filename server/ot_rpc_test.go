@@ -37,7 +37,7 @@ var testRemoteTranscoderResults = &core.TranscodeData{
 	Pixels: 999,
 }
 
-func (st *stubTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile) (*core.TranscodeData, error) {
+func (st *stubTranscoder) Transcode(job string, fname string, profiles []ffmpeg.VideoProfile, format core.SegmentFormat) (*core.TranscodeData, error) {
 	st.called++
 	st.fname = fname
 	st.profiles = profiles
@@ -194,3 +194,42 @@ func TestRemoteTranscoderError(t *testing.T) {
 	assert.Equal(protoVerLPT, headers.Get("Authorization"))
 	assert.Equal(errText, string(body))
 }
+
+type stubRegisterStream struct {
+	common.StubServerStream
+}
+
+func (s *stubRegisterStream) Send(n *net.NotifySegment) error {
+	return nil
+}
+
+func TestRegisterTranscoder(t *testing.T) {
+	assert := assert.New(t)
+	orch := &mockOrchestrator{}
+	h := &lphttp{orchestrator: orch}
+	strm := &stubRegisterStream{}
+	orch.On("TranscoderSecret").Return("")
+
+	// zero capacity
+	err := h.RegisterTranscoder(&net.RegisterRequest{Capacity: 0}, strm)
+	assert.Equal(errZeroCapacity, err)
+
+	// capabilities are passed to the orchestrator
+	caps := &net.TranscoderCapabilities{
+		Acceleration: net.TranscoderCapabilities_NVIDIA,
+		MaxWidth:     1920,
+		MaxHeight:    1080,
+		Profiles:     []string{"P720p30fps16x9"},
+		Version:      "0.5.0",
+	}
+	orch.On("ServeTranscoder", strm, 3, caps).Once()
+	err = h.RegisterTranscoder(&net.RegisterRequest{Capacity: 3, Capabilities: caps}, strm)
+	assert.Nil(err)
+
+	// transcoders that don't advertise capabilities are still accepted
+	orch.On("ServeTranscoder", strm, 2, (*net.TranscoderCapabilities)(nil)).Once()
+	err = h.RegisterTranscoder(&net.RegisterRequest{Capacity: 2}, strm)
+	assert.Nil(err)
+
+	orch.AssertExpectations(t)
+}
@@ -190,6 +190,80 @@ func TestMultipartReturn(t *testing.T) {
 	assert.Equal(503, resp.StatusCode)
 }
 
+func TestMultipartReturn_FMP4(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	s := setupServer()
+
+	ts, mux := stubTLSServer()
+	defer ts.Close()
+
+	init := concatBoxes(mp4Box("ftyp", "iso5"), mp4Box("moov", "tracks"))
+	media := concatBoxes(mp4Box("moof", "fragment"), mp4Box("mdat", "samples"))
+	segPath := "/transcoded/segment.mp4"
+	buf, err := proto.Marshal(&net.TranscodeResult{
+		Result: &net.TranscodeResult_Data{
+			Data: &net.TranscodeData{
+				Segments: []*net.TranscodedSegmentData{{Url: ts.URL + segPath, Pixels: 100}},
+				Sig:      []byte("bar"),
+			},
+		},
+	})
+	require.Nil(err)
+	mux.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(buf)
+	})
+	mux.HandleFunc(segPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Write(append(append([]byte{}, init...), media...))
+	})
+
+	osSession := drivers.NewMemoryDriver(nil).NewSession("mani")
+	sess := StubBroadcastSession(ts.URL)
+	sess.Profiles = []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}
+	sess.ManifestID = "mani"
+	sess.Format = core.FormatFMP4
+	sess.BroadcasterOS = osSession
+	pl := core.NewBasicPlaylistManager("mani", osSession)
+	s.rtmpConnections["mani"] = &rtmpConnection{
+		mid:         core.ManifestID("mani"),
+		pl:          pl,
+		profile:     &ffmpeg.P720p30fps16x9,
+		sessManager: bsmWithSessList([]*BroadcastSession{sess}),
+		params:      &streamParameters{profiles: sess.Profiles, format: core.FormatFMP4},
+	}
+
+	req := httptest.NewRequest("POST", "/live/mani/17.ts", strings.NewReader("InsteadOf.TS"))
+	req.Header.Set("Accept", "multipart/mixed")
+	w := httptest.NewRecorder()
+	s.HandlePush(w, req)
+	resp := w.Result()
+	defer resp.Body.Close()
+	require.Equal(200, resp.StatusCode)
+
+	// parts are self-contained
+	_, params, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	require.Nil(err)
+	mr := multipart.NewReader(resp.Body, params["boundary"])
+	p, err := mr.NextPart()
+	require.Nil(err)
+	assert.Equal("video/mp4", p.Header.Get("Content-Type"))
+	assert.Equal(`attachment; filename="P144p30fps16x9_17.mp4"`, p.Header.Get("Content-Disposition"))
+	bodyPart, err := ioutil.ReadAll(p)
+	assert.Nil(err)
+	assert.Equal(append(append([]byte{}, init...), media...), bodyPart)
+
+	// the playlist references the media section, with the initialization section in EXT-X-MAP
+	mpl := pl.GetHLSMediaPlaylist(ffmpeg.P144p30fps16x9.Name)
+	require.NotNil(mpl)
+	require.NotNil(mpl.Segments[0])
+	assert.True(strings.HasSuffix(mpl.Segments[0].URI, "/17.m4s"))
+	assert.Equal(media, osSession.(*drivers.MemorySession).GetData(mpl.Segments[0].URI))
+	require.NotNil(mpl.Map)
+	assert.Equal(init, osSession.(*drivers.MemorySession).GetData(mpl.Map.URI))
+	// the whole rendition isn't stored
+	assert.Nil(osSession.(*drivers.MemorySession).GetData("P144p30fps16x9/17.mp4"))
+}
+
 func TestMemoryRequestError(t *testing.T) {
 	// assert http request body error returned
 	assert := assert.New(t)
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"fmt"
+
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/monitor"
+	"github.com/livepeer/lpms/stream"
+)
+
+type submitResult struct {
+	sess *BroadcastSession
+	seg  *stream.HLSSegment
+	res  *ReceivedTranscodeResult
+	err  error
+}
+
+// redundancy returns how many orchestrators each segment is submitted to
+func redundancy() int {
+	if Policy == nil || Policy.Redundancy < 1 {
+		return 1
+	}
+	return Policy.Redundancy
+}
+
+// submitRedundant submits the segment to all sessions concurrently and returns the first
+// successful result. If cross-checking is enabled, the remaining results are compared
+// against each other in the background once they arrive
+func submitRedundant(cxn *rtmpConnection, sessions []*BroadcastSession, seg *stream.HLSSegment, name string) (*submitResult, error) {
+	results := make(chan *submitResult, len(sessions))
+	for _, sess := range sessions {
+		// Each submission gets its own copy since seg.Name is hijacked for the uploaded URI
+		go func(sess *BroadcastSession, seg stream.HLSSegment) {
+			res, err := submitSegment(cxn, sess, &seg, name)
+			results <- &submitResult{sess: sess, seg: &seg, res: res, err: err}
+		}(sess, *seg)
+	}
+
+	var err error
+	for i := range sessions {
+		r := <-results
+		if r.err != nil {
+			err = r.err
+			continue
+		}
+		glog.V(common.DEBUG).Infof("Using first redundant result nonce=%d manifestID=%s seqNo=%d orch=%s", cxn.nonce, cxn.mid, seg.SeqNo, r.sess.OrchestratorInfo.Transcoder)
+		if Policy != nil && Policy.CrossCheck {
+			go crossCheck(cxn, seg.SeqNo, r, results, len(sessions)-i-1)
+		}
+		return r, nil
+	}
+	return nil, err
+}
+
+// crossCheck waits for the remaining redundant results and removes the sessions
+// whose results disagree with the majority. Without a majority there is no way to
+// tell which result is right, so all of the sessions are removed
+func crossCheck(cxn *rtmpConnection, seqNo uint64, first *submitResult, results chan *submitResult, remaining int) {
+	received := []*submitResult{first}
+	for i := 0; i < remaining; i++ {
+		if r := <-results; r.err == nil {
+			received = append(received, r)
+		}
+	}
+	if len(received) < 2 {
+		return
+	}
+
+	mismatched, ok := crossCheckResults(received)
+	if !ok {
+		glog.Warningf("Redundant results disagree without a majority nonce=%d manifestID=%s seqNo=%d results=%d", cxn.nonce, cxn.mid, seqNo, len(received))
+	}
+	for _, sess := range mismatched {
+		glog.Errorf("Redundant result disagrees with the majority; removing session nonce=%d manifestID=%s seqNo=%d orch=%s", cxn.nonce, cxn.mid, seqNo, sess.OrchestratorInfo.Transcoder)
+		cxn.sessManager.penalizeSession(sess)
+		cxn.sessManager.failSession(sess, OrchFailureVerification)
+		if monitor.Enabled {
+			monitor.CrossCheckMismatch()
+		}
+	}
+}
+
+// crossCheckResults compares the number of renditions and their pixel counts across results.
+// Returns the sessions whose results differ from the majority. If there is no majority,
+// all of the sessions are returned along with false
+func crossCheckResults(results []*submitResult) ([]*BroadcastSession, bool) {
+	counts := make(map[string]int)
+	keys := make([]string, len(results))
+	for i, r := range results {
+		pixels := make([]int64, len(r.res.Segments))
+		for j, s := range r.res.Segments {
+			pixels[j] = s.Pixels
+		}
+		keys[i] = fmt.Sprint(pixels)
+		counts[keys[i]]++
+	}
+
+	majority := ""
+	for key, count := range counts {
+		if 2*count > len(results) {
+			majority = key
+		}
+	}
+	if majority == "" {
+		sessions := make([]*BroadcastSession, len(results))
+		for i, r := range results {
+			sessions[i] = r.sess
+		}
+		return sessions, false
+	}
+
+	var mismatched []*BroadcastSession
+	for i, r := range results {
+		if keys[i] != majority {
+			mismatched = append(mismatched, r.sess)
+		}
+	}
+	return mismatched, true
+}
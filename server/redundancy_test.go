@@ -0,0 +1,196 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/go-livepeer/verification"
+	"github.com/livepeer/lpms/ffmpeg"
+	"github.com/livepeer/lpms/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func redundantResult(pixels ...int64) *submitResult {
+	td := &net.TranscodeData{}
+	for _, p := range pixels {
+		td.Segments = append(td.Segments, &net.TranscodedSegmentData{Pixels: p})
+	}
+	return &submitResult{sess: StubBroadcastSession(""), res: &ReceivedTranscodeResult{TranscodeData: td}}
+}
+
+func TestCrossCheckResults(t *testing.T) {
+	assert := assert.New(t)
+
+	// all agree
+	a, b, c := redundantResult(1, 2), redundantResult(1, 2), redundantResult(1, 2)
+	mismatched, ok := crossCheckResults([]*submitResult{a, b, c})
+	assert.True(ok)
+	assert.Empty(mismatched)
+
+	// pixel count mismatch
+	c = redundantResult(1, 3)
+	mismatched, ok = crossCheckResults([]*submitResult{a, b, c})
+	assert.True(ok)
+	assert.Equal([]*BroadcastSession{c.sess}, mismatched)
+
+	// missing rendition
+	c = redundantResult(1)
+	mismatched, ok = crossCheckResults([]*submitResult{c, a, b})
+	assert.True(ok)
+	assert.Equal([]*BroadcastSession{c.sess}, mismatched)
+
+	// no majority returns all of the sessions
+	mismatched, ok = crossCheckResults([]*submitResult{a, c})
+	assert.False(ok)
+	assert.Equal([]*BroadcastSession{a.sess, c.sess}, mismatched)
+	d := redundantResult(5, 6)
+	mismatched, ok = crossCheckResults([]*submitResult{a, c, d})
+	assert.False(ok)
+	assert.Equal([]*BroadcastSession{a.sess, c.sess, d.sess}, mismatched)
+}
+
+func TestTranscodeSegment_Redundant(t *testing.T) {
+	defer func(p *verification.Policy) { Policy = p }(Policy)
+	assert := assert.New(t)
+	require := require.New(t)
+
+	result := func(url string, pixels int64) []byte {
+		buf, err := proto.Marshal(&net.TranscodeResult{
+			Result: &net.TranscodeResult_Data{
+				Data: &net.TranscodeData{Segments: []*net.TranscodedSegmentData{{Url: url, Pixels: pixels}}},
+			},
+		})
+		require.Nil(err)
+		return buf
+	}
+	handler := func(buf []byte, delay time.Duration, status int) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(delay)
+			w.WriteHeader(status)
+			w.Write(buf)
+		}
+	}
+
+	fast, fastMux := stubTLSServer()
+	defer fast.Close()
+	fastMux.HandleFunc("/segment", handler(result("fast.ts", 100), 0, http.StatusOK))
+	honest, honestMux := stubTLSServer()
+	defer honest.Close()
+	honestMux.HandleFunc("/segment", handler(result("honest.ts", 100), 50*time.Millisecond, http.StatusOK))
+	cheat, cheatMux := stubTLSServer()
+	defer cheat.Close()
+	cheatMux.HandleFunc("/segment", handler(result("cheat.ts", 999), 50*time.Millisecond, http.StatusOK))
+	failing, failingMux := stubTLSServer()
+	defer failing.Close()
+	failingMux.HandleFunc("/segment", handler(nil, 0, http.StatusInternalServerError))
+
+	newCxn := func(urls ...string) *rtmpConnection {
+		var sessions []*BroadcastSession
+		for _, url := range urls {
+			sess := StubBroadcastSession(url)
+			sess.Profiles = []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}
+			sessions = append(sessions, sess)
+		}
+		return &rtmpConnection{
+			mid:         core.ManifestID("foo"),
+			pl:          &stubPlaylistManager{manifestID: core.ManifestID("foo"), os: drivers.NewMemoryDriver(nil).NewSession("foo")},
+			profile:     &ffmpeg.P144p30fps16x9,
+			sessManager: bsmWithSessList(sessions),
+		}
+	}
+	hasSession := func(cxn *rtmpConnection, url string) bool {
+		cxn.sessManager.sessLock.Lock()
+		defer cxn.sessManager.sessLock.Unlock()
+		_, ok := cxn.sessManager.sessMap[url]
+		return ok
+	}
+	seg := &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}
+
+	// first result wins without cross-checking
+	Policy = &verification.Policy{Redundancy: 3}
+	cxn := newCxn(cheat.URL, honest.URL, fast.URL)
+	urls, err := transcodeSegment(cxn, seg, "dummy", nil)
+	assert.Nil(err)
+	assert.Equal([]string{"fast.ts"}, urls)
+	time.Sleep(100 * time.Millisecond)
+	assert.True(hasSession(cxn, cheat.URL))
+
+	// failures are skipped in favor of a later result
+	cxn = newCxn(honest.URL, failing.URL)
+	urls, err = transcodeSegment(cxn, seg, "dummy", nil)
+	assert.Nil(err)
+	assert.Equal([]string{"honest.ts"}, urls)
+	assert.False(hasSession(cxn, failing.URL))
+
+	// all failing returns an error
+	failing2, failing2Mux := stubTLSServer()
+	defer failing2.Close()
+	failing2Mux.HandleFunc("/segment", handler(nil, 0, http.StatusInternalServerError))
+	cxn = newCxn(failing2.URL, failing.URL)
+	urls, err = transcodeSegment(cxn, seg, "dummy", nil)
+	assert.Nil(urls)
+	assert.NotNil(err)
+
+	// the session disagreeing with the majority is removed
+	Policy.CrossCheck = true
+	cxn = newCxn(cheat.URL, honest.URL, fast.URL)
+	urls, err = transcodeSegment(cxn, seg, "dummy", nil)
+	assert.Nil(err)
+	assert.Equal([]string{"fast.ts"}, urls)
+	deadline := time.Now().Add(time.Second)
+	for hasSession(cxn, cheat.URL) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.False(hasSession(cxn, cheat.URL))
+	assert.True(hasSession(cxn, honest.URL))
+	assert.True(hasSession(cxn, fast.URL))
+
+	// both sessions are removed and penalized when two results disagree
+	sel := NewWeightedSelector(nil, DefaultSelectionWeights)
+	cxn = newCxn(cheat.URL, fast.URL)
+	for _, sess := range cxn.sessManager.sessMap {
+		sel.Add([]*BroadcastSession{sess})
+	}
+	cxn.sessManager.sel = sel
+	urls, err = transcodeSegment(cxn, seg, "dummy", nil)
+	assert.Nil(err)
+	assert.Equal([]string{"fast.ts"}, urls)
+	deadline = time.Now().Add(time.Second)
+	for (hasSession(cxn, cheat.URL) || hasSession(cxn, fast.URL)) && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.False(hasSession(cxn, cheat.URL))
+	assert.False(hasSession(cxn, fast.URL))
+	cxn.sessManager.sessLock.Lock()
+	for _, url := range []string{cheat.URL, fast.URL} {
+		assert.Zero(sel.stats[url].completed)
+		assert.Equal(1, sel.stats[url].selected)
+	}
+	cxn.sessManager.sessLock.Unlock()
+
+	// redundancy is limited by the available sessions
+	cxn = newCxn(fast.URL)
+	urls, err = transcodeSegment(cxn, seg, "dummy", nil)
+	assert.Nil(err)
+	assert.Equal([]string{"fast.ts"}, urls)
+}
+
+func TestSelectSessions(t *testing.T) {
+	assert := assert.New(t)
+	bsm := StubBroadcastSessionsManager()
+	assert.Len(bsm.selectSessions(1), 1)
+	assert.Len(bsm.selectSessions(5), 1)
+	assert.Empty(bsm.selectSessions(1))
+
+	bsm = StubBroadcastSessionsManager()
+	sessions := bsm.selectSessions(2)
+	if assert.Len(sessions, 2) {
+		assert.NotEqual(sessions[0], sessions[1])
+	}
+}
@@ -0,0 +1,201 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/net"
+)
+
+// OrchFailure is the kind of failure an orchestrator is penalized for
+type OrchFailure int
+
+const (
+	// OrchFailureSubmit is a failure to upload or transcode a segment
+	OrchFailureSubmit OrchFailure = iota
+	// OrchFailureDownload is a failure to download a transcoded segment
+	OrchFailureDownload
+	// OrchFailureVerification is a malformed result or one that failed verification,
+	// pixel counting, the signature check or the redundant cross check
+	OrchFailureVerification
+)
+
+// ReputationFailureThreshold is the number of consecutive failures after which
+// an orchestrator is not used until its cooldown passes
+var ReputationFailureThreshold int64 = 3
+
+// ReputationCooldown is how long an orchestrator isn't used after too many
+// consecutive failures or a verification failure
+var ReputationCooldown = 10 * time.Minute
+
+// ReputationFlushInterval is how often changed reputations are persisted
+var ReputationFlushInterval = 30 * time.Second
+
+// OrchReputation tracks orchestrator reputations across streams; nil without a DB
+var OrchReputation *ReputationTracker
+
+// ReputationStore persists orchestrator reputations so that they survive a node restart
+type ReputationStore interface {
+	UpdateOrchReputation(rep *common.DBOrchReputation) error
+	LoadOrchReputations() ([]*common.DBOrchReputation, error)
+}
+
+// ReputationTracker keeps a reputation per orchestrator, by service URI, that is
+// used to seed session selection and to cool down orchestrators that keep failing
+type ReputationTracker struct {
+	reps  map[string]*common.DBOrchReputation
+	dirty map[string]bool
+	mtx   sync.Mutex
+
+	// Serializes flushes so that older copies don't overwrite newer ones
+	flushMtx sync.Mutex
+	store    ReputationStore
+}
+
+// NewReputationTracker creates a ReputationTracker that is initialized with the reputations
+// persisted by a previous run. Changes are written to store when the tracker is flushed
+func NewReputationTracker(store ReputationStore) (*ReputationTracker, error) {
+	stored, err := store.LoadOrchReputations()
+	if err != nil {
+		return nil, err
+	}
+	r := &ReputationTracker{
+		reps:  make(map[string]*common.DBOrchReputation),
+		dirty: make(map[string]bool),
+		store: store,
+	}
+	for _, rep := range stored {
+		r.reps[rep.ServiceURI] = rep
+	}
+
+	glog.Infof("Loaded %v persisted orchestrator reputations", len(stored))
+
+	return r, nil
+}
+
+// Reputation returns a copy of the reputation of an orchestrator, or nil if it hasn't been seen
+func (r *ReputationTracker) Reputation(uri string) *common.DBOrchReputation {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	rep, ok := r.reps[uri]
+	if !ok {
+		return nil
+	}
+	cp := *rep
+	return &cp
+}
+
+// CoolingDown returns whether an orchestrator should not be used
+func (r *ReputationTracker) CoolingDown(uri string) bool {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	rep, ok := r.reps[uri]
+	return ok && time.Now().Before(rep.CooldownUntil)
+}
+
+// Completed records a segment the orchestrator transcoded with a latency score
+func (r *ReputationTracker) Completed(uri string, latencyScore float64) {
+	r.update(uri, func(rep *common.DBOrchReputation) {
+		if rep.Completed == 0 {
+			rep.LatencyScore = latencyScore
+		} else {
+			rep.LatencyScore = latencyScoreDecay*latencyScore + (1-latencyScoreDecay)*rep.LatencyScore
+		}
+		rep.Completed++
+		rep.ConsecutiveFailures = 0
+	})
+}
+
+// Failed records a failure of the orchestrator and starts its cooldown if needed
+func (r *ReputationTracker) Failed(uri string, failure OrchFailure) {
+	r.update(uri, func(rep *common.DBOrchReputation) {
+		switch failure {
+		case OrchFailureSubmit:
+			rep.SubmitFailures++
+		case OrchFailureDownload:
+			rep.DownloadFailures++
+		case OrchFailureVerification:
+			rep.VerificationFailures++
+		}
+		rep.ConsecutiveFailures++
+		if failure == OrchFailureVerification || rep.ConsecutiveFailures >= ReputationFailureThreshold {
+			rep.CooldownUntil = rep.LastSeen.Add(ReputationCooldown)
+			glog.Warningf("Cooling down orchestrator=%s until=%v consecutiveFailures=%d", uri, rep.CooldownUntil, rep.ConsecutiveFailures)
+		}
+	})
+}
+
+func (r *ReputationTracker) update(uri string, fn func(rep *common.DBOrchReputation)) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	rep, ok := r.reps[uri]
+	if !ok {
+		rep = &common.DBOrchReputation{ServiceURI: uri}
+		r.reps[uri] = rep
+	}
+	rep.LastSeen = time.Now()
+	fn(rep)
+	r.dirty[uri] = true
+}
+
+// Start flushes the tracker every ReputationFlushInterval until ctx is done
+func (r *ReputationTracker) Start(ctx context.Context) {
+	ticker := time.NewTicker(ReputationFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			r.Flush()
+			return
+		case <-ticker.C:
+			r.Flush()
+		}
+	}
+}
+
+// Flush persists the reputations that changed since the last flush
+func (r *ReputationTracker) Flush() {
+	r.flushMtx.Lock()
+	defer r.flushMtx.Unlock()
+
+	r.mtx.Lock()
+	reps := make([]common.DBOrchReputation, 0, len(r.dirty))
+	for uri := range r.dirty {
+		reps = append(reps, *r.reps[uri])
+	}
+	r.dirty = make(map[string]bool)
+	r.mtx.Unlock()
+
+	for i := range reps {
+		// Not fatal; the reputation is only lost on restart
+		if err := r.store.UpdateOrchReputation(&reps[i]); err != nil {
+			glog.Errorf("Unable to persist reputation orchestrator=%s err=%v", reps[i].ServiceURI, err)
+		}
+	}
+}
+
+// notCoolingDown returns a discovery predicate that skips orchestrators cooling down
+func notCoolingDown(info *net.OrchestratorInfo) bool {
+	if OrchReputation == nil || !OrchReputation.CoolingDown(info.Transcoder) {
+		return true
+	}
+	glog.V(common.DEBUG).Infof("Skipping orchestrator=%v that is cooling down", info.Transcoder)
+	return false
+}
+
+// seedLatencyScore starts a new session with the latency score of its orchestrator from earlier streams
+func seedLatencyScore(sess *BroadcastSession) {
+	if OrchReputation == nil {
+		return
+	}
+	if rep := OrchReputation.Reputation(sess.OrchestratorInfo.Transcoder); rep != nil && rep.Completed > 0 {
+		sess.LatencyScore = rep.LatencyScore
+	}
+}
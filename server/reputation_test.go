@@ -0,0 +1,276 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/lpms/ffmpeg"
+	"github.com/livepeer/lpms/stream"
+)
+
+type stubReputationStore struct {
+	reps    map[string]common.DBOrchReputation
+	loadErr error
+}
+
+func newStubReputationStore() *stubReputationStore {
+	return &stubReputationStore{reps: make(map[string]common.DBOrchReputation)}
+}
+
+func (s *stubReputationStore) UpdateOrchReputation(rep *common.DBOrchReputation) error {
+	s.reps[rep.ServiceURI] = *rep
+	return nil
+}
+
+func (s *stubReputationStore) LoadOrchReputations() ([]*common.DBOrchReputation, error) {
+	if s.loadErr != nil {
+		return nil, s.loadErr
+	}
+	var reps []*common.DBOrchReputation
+	for _, rep := range s.reps {
+		rep := rep
+		reps = append(reps, &rep)
+	}
+	return reps, nil
+}
+
+func TestReputationTracker(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	store := newStubReputationStore()
+	store.loadErr = errors.New("LoadOrchReputations error")
+	_, err := NewReputationTracker(store)
+	assert.Equal(store.loadErr, err)
+	store.loadErr = nil
+
+	r, err := NewReputationTracker(store)
+	require.Nil(err)
+	assert.Nil(r.Reputation("a"))
+	assert.False(r.CoolingDown("a"))
+
+	r.Completed("a", 2)
+	r.Completed("a", 1)
+	rep := r.Reputation("a")
+	require.NotNil(rep)
+	assert.Equal(1.5, rep.LatencyScore)
+	assert.Equal(int64(2), rep.Completed)
+	assert.WithinDuration(time.Now(), rep.LastSeen, time.Minute)
+	// changes are persisted when flushed
+	assert.Empty(store.reps)
+	r.Flush()
+	assert.Equal(*rep, store.reps["a"])
+	delete(store.reps, "a")
+	r.Flush()
+	assert.Empty(store.reps, "unchanged reputations shouldn't be written again")
+
+	// consecutive failures start a cooldown
+	r.Failed("a", OrchFailureSubmit)
+	r.Failed("a", OrchFailureDownload)
+	r.Completed("a", 1)
+	r.Failed("a", OrchFailureSubmit)
+	r.Failed("a", OrchFailureSubmit)
+	assert.False(r.CoolingDown("a"))
+	r.Failed("a", OrchFailureDownload)
+	assert.True(r.CoolingDown("a"))
+	rep = r.Reputation("a")
+	assert.Equal(int64(3), rep.SubmitFailures)
+	assert.Equal(int64(2), rep.DownloadFailures)
+	assert.Equal(int64(3), rep.ConsecutiveFailures)
+	assert.Equal(rep.LastSeen.Add(ReputationCooldown), rep.CooldownUntil)
+
+	// a single verification failure starts a cooldown
+	r.Failed("b", OrchFailureVerification)
+	assert.True(r.CoolingDown("b"))
+	assert.Equal(int64(1), r.Reputation("b").VerificationFailures)
+
+	// reputations persist across restarts
+	r.Flush()
+	r, err = NewReputationTracker(store)
+	require.Nil(err)
+	assert.True(r.CoolingDown("a"))
+	assert.Equal(int64(3), r.Reputation("a").Completed)
+
+	// cooldowns expire
+	rep = r.Reputation("a")
+	rep.CooldownUntil = time.Now().Add(-time.Second)
+	store.UpdateOrchReputation(rep)
+	r, err = NewReputationTracker(store)
+	require.Nil(err)
+	assert.False(r.CoolingDown("a"))
+}
+
+func TestReputationTracker_Start(t *testing.T) {
+	store := newStubReputationStore()
+	r, err := NewReputationTracker(store)
+	require.Nil(t, err)
+	r.Completed("a", 1)
+
+	// flushes once more when stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Start(ctx)
+		close(done)
+	}()
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("tracker not stopped")
+	}
+	assert.Equal(t, int64(1), store.reps["a"].Completed)
+}
+
+func TestReputation_SessionManager(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(r *ReputationTracker) { OrchReputation = r }(OrchReputation)
+	var err error
+	OrchReputation, err = NewReputationTracker(newStubReputationStore())
+	require.Nil(err)
+	OrchReputation.Completed("slow", 2)
+	OrchReputation.Failed("bad", OrchFailureVerification)
+
+	n, _ := core.NewLivepeerNode(nil, "", nil)
+	n.OrchestratorPool = &stubDiscovery{infos: []*net.OrchestratorInfo{
+		&net.OrchestratorInfo{Transcoder: "slow"},
+		&net.OrchestratorInfo{Transcoder: "bad"},
+		&net.OrchestratorInfo{Transcoder: "new"},
+	}}
+	mid := core.RandomManifestID()
+	pl := core.NewBasicPlaylistManager(mid, drivers.NewMemoryDriver(nil).NewSession(string(mid)))
+
+	// orchestrators cooling down are skipped, others are seeded with their latency score
+	sessions, err := selectOrchestrator(n, &streamParameters{mid: mid}, pl, 3)
+	require.Nil(err)
+	require.Len(sessions, 2)
+	assert.Equal("slow", sessions[0].OrchestratorInfo.Transcoder)
+	assert.Equal(2.0, sessions[0].LatencyScore)
+	assert.Equal("new", sessions[1].OrchestratorInfo.Transcoder)
+	assert.Zero(sessions[1].LatencyScore)
+
+	// a new stream tries the new orchestrator before the one known to be slow
+	bsm := NewSessionManager(n, &streamParameters{mid: mid}, pl, NewMinLSSelector(nil, 1.0))
+	sess := bsm.selectSession()
+	require.NotNil(sess)
+	assert.Equal("new", sess.OrchestratorInfo.Transcoder)
+
+	// completed sessions only count once their segment is verified; failed sessions update the reputation
+	sess.LatencyScore = 0.5
+	bsm.completeSession(sess)
+	assert.Nil(OrchReputation.Reputation("new"))
+	sess = bsm.selectSession()
+	assert.Equal("new", sess.OrchestratorInfo.Transcoder)
+	bsm.failSession(sess, OrchFailureDownload)
+	assert.Equal(int64(1), OrchReputation.Reputation("new").DownloadFailures)
+	assert.NotContains(bsm.sessMap, "new")
+}
+
+func TestReputation_SubmitErrors(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(r *ReputationTracker) { OrchReputation = r }(OrchReputation)
+	var err error
+	OrchReputation, err = NewReputationTracker(newStubReputationStore())
+	require.Nil(err)
+
+	var orchErr string
+	ts, mux := stubTLSServer()
+	defer ts.Close()
+	mux.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, orchErr, http.StatusInternalServerError)
+	})
+
+	submit := func(e string) *BroadcastSessionsManager {
+		orchErr = e
+		sess := StubBroadcastSession(ts.URL)
+		bsm := bsmWithSessList([]*BroadcastSession{sess})
+		cxn := &rtmpConnection{mid: core.ManifestID("foo"), sessManager: bsm}
+		_, err := submitSegment(cxn, sess, &stream.HLSSegment{Data: []byte("dummy")}, "dummy")
+		assert.EqualError(err, e)
+		return bsm
+	}
+
+	// orchestrators refusing segments are removed without a failure
+	for _, e := range capacityErrStrings {
+		bsm := submit(e)
+		assert.Empty(bsm.sessMap, e)
+		assert.Nil(OrchReputation.Reputation(ts.URL), e)
+	}
+
+	// other errors are failures
+	bsm := submit("TranscodeError")
+	assert.Empty(bsm.sessMap)
+	require.NotNil(OrchReputation.Reputation(ts.URL))
+	assert.Equal(int64(1), OrchReputation.Reputation(ts.URL).SubmitFailures)
+}
+
+func TestReputation_DownloadFailures(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	defer func(r *ReputationTracker) { OrchReputation = r }(OrchReputation)
+	var err error
+	OrchReputation, err = NewReputationTracker(newStubReputationStore())
+	require.Nil(err)
+
+	var segURL string
+	ts, mux := stubTLSServer()
+	defer ts.Close()
+	mux.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+		buf, err := proto.Marshal(&net.TranscodeResult{
+			Result: &net.TranscodeResult_Data{
+				Data: &net.TranscodeData{Segments: []*net.TranscodedSegmentData{{Url: segURL}}},
+			},
+		})
+		require.Nil(err)
+		w.Write(buf)
+	})
+	mux.HandleFunc("/stream/ok.ts", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("rendition"))
+	})
+
+	transcode := func() error {
+		sess := StubBroadcastSession(ts.URL)
+		sess.Profiles = []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}
+		sess.BroadcasterOS = drivers.NewMemoryDriver(nil).NewSession("foo")
+		cxn := &rtmpConnection{
+			mid:         core.ManifestID("foo"),
+			pl:          &stubPlaylistManager{manifestID: core.ManifestID("foo")},
+			profile:     &ffmpeg.P144p30fps16x9,
+			sessManager: bsmWithSessList([]*BroadcastSession{sess}),
+		}
+		_, err := transcodeSegment(cxn, &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0}, "dummy", nil)
+		return err
+	}
+
+	// segments are only completed once their renditions are downloaded
+	segURL = ts.URL + "/stream/ok.ts"
+	require.Nil(transcode())
+	require.NotNil(OrchReputation.Reputation(ts.URL))
+	assert.Equal(int64(1), OrchReputation.Reputation(ts.URL).Completed)
+
+	// orchestrators whose renditions can never be downloaded are cooled down
+	segURL = ts.URL + "/stream/missing.ts"
+	for i := int64(0); i < ReputationFailureThreshold; i++ {
+		assert.NotNil(transcode())
+	}
+	rep := OrchReputation.Reputation(ts.URL)
+	assert.Equal(int64(1), rep.Completed)
+	assert.Equal(ReputationFailureThreshold, rep.DownloadFailures)
+	assert.True(OrchReputation.CoolingDown(ts.URL))
+}
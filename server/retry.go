@@ -0,0 +1,94 @@
+package server
+
+import (
+	"fmt"
+	"time"
+)
+
+// NonRetryableError wraps an error to indicate that transcoding the segment
+// again would fail in the same way, so the broadcaster should give up on it
+type NonRetryableError struct {
+	error
+}
+
+// NewNonRetryableError marks the error as not retryable
+func NewNonRetryableError(err error) error {
+	return NonRetryableError{err}
+}
+
+func isNonRetryableError(err error) bool {
+	_, ok := err.(NonRetryableError)
+	return ok
+}
+
+// RetryPolicy determines how many times and for how long the broadcaster
+// tries to transcode a segment before giving up on it
+type RetryPolicy struct {
+	// Maximum number of attempts per segment, including the first one
+	MaxAttempts int
+
+	// No further attempts are started once this multiple of the segment
+	// duration has passed since the first attempt. Zero disables the deadline
+	DeadlineFactor float64
+
+	// Delay before the second attempt, doubled for each following attempt
+	// up to MaxBackoff, if set
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+
+	// Retryable returns whether an error may succeed on another attempt.
+	// If nil, every error that isn't a NonRetryableError is retried
+	Retryable func(error) bool
+}
+
+// SegmentRetryPolicy is used by the broadcaster to retry segments that failed to transcode.
+// Sessions that fail are removed, so retries go to another orchestrator right away
+var SegmentRetryPolicy = &RetryPolicy{
+	MaxAttempts:    3,
+	DeadlineFactor: 2,
+}
+
+// ErrRetriesExhausted is returned with the last error once a segment can't be retried anymore
+type ErrRetriesExhausted struct {
+	Attempts int
+	Err      error
+}
+
+func (e ErrRetriesExhausted) Error() string {
+	return fmt.Sprintf("giving up on segment after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (p *RetryPolicy) retryable(err error) bool {
+	if isNonRetryableError(err) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return true
+}
+
+// next returns whether another attempt should be made after the given number of attempts
+// failed with err, and how long to wait before it
+func (p *RetryPolicy) next(attempts int, elapsed time.Duration, segDur float64, err error) (bool, time.Duration) {
+	if !p.retryable(err) || attempts >= p.MaxAttempts {
+		return false, 0
+	}
+
+	backoff := p.Backoff
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if p.MaxBackoff > 0 && backoff >= p.MaxBackoff {
+			backoff = p.MaxBackoff
+			break
+		}
+	}
+
+	if p.DeadlineFactor > 0 && segDur > 0 {
+		deadline := time.Duration(p.DeadlineFactor * segDur * float64(time.Second))
+		if elapsed+backoff >= deadline {
+			return false, 0
+		}
+	}
+	return true, backoff
+}
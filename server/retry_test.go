@@ -0,0 +1,149 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/lpms/ffmpeg"
+	"github.com/livepeer/lpms/stream"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy_Next(t *testing.T) {
+	assert := assert.New(t)
+	p := &RetryPolicy{
+		MaxAttempts:    5,
+		DeadlineFactor: 2,
+		Backoff:        100 * time.Millisecond,
+		MaxBackoff:     300 * time.Millisecond,
+	}
+	err := errors.New("some error")
+
+	// backoff doubles up to the maximum
+	retry, backoff := p.next(1, 0, 10, err)
+	assert.True(retry)
+	assert.Equal(100*time.Millisecond, backoff)
+	retry, backoff = p.next(2, 0, 10, err)
+	assert.True(retry)
+	assert.Equal(200*time.Millisecond, backoff)
+	retry, backoff = p.next(4, 0, 10, err)
+	assert.True(retry)
+	assert.Equal(300*time.Millisecond, backoff)
+
+	// max attempts
+	retry, _ = p.next(5, 0, 10, err)
+	assert.False(retry)
+
+	// deadline relative to the segment duration, including the backoff
+	retry, _ = p.next(1, 1850*time.Millisecond, 1, err)
+	assert.True(retry)
+	retry, _ = p.next(1, 1950*time.Millisecond, 1, err)
+	assert.False(retry)
+	// no deadline without a duration
+	retry, _ = p.next(1, time.Hour, 0, err)
+	assert.True(retry)
+	p.DeadlineFactor = 0
+	retry, _ = p.next(1, time.Hour, 1, err)
+	assert.True(retry)
+
+	// uncapped backoff
+	p.MaxBackoff = 0
+	_, backoff = p.next(4, 0, 10, err)
+	assert.Equal(800*time.Millisecond, backoff)
+
+	// non-retryable errors
+	retry, _ = p.next(1, 0, 10, NewNonRetryableError(err))
+	assert.False(retry)
+	p.Retryable = func(e error) bool { return e != err }
+	retry, _ = p.next(1, 0, 10, err)
+	assert.False(retry)
+	retry, _ = p.next(1, 0, 10, errors.New("other"))
+	assert.True(retry)
+	retry, _ = p.next(1, 0, 10, NewNonRetryableError(errors.New("other")))
+	assert.False(retry)
+}
+
+func TestProcessSegment_Retries(t *testing.T) {
+	defer func(p *RetryPolicy) { SegmentRetryPolicy = p }(SegmentRetryPolicy)
+	SegmentRetryPolicy = &RetryPolicy{MaxAttempts: 2, DeadlineFactor: 10, Backoff: time.Millisecond, MaxBackoff: time.Millisecond}
+	assert := assert.New(t)
+	require := require.New(t)
+
+	buf, err := proto.Marshal(&net.TranscodeResult{
+		Result: &net.TranscodeResult_Data{
+			Data: &net.TranscodeData{Segments: []*net.TranscodedSegmentData{{Url: "test.flv"}}},
+		},
+	})
+	require.Nil(err)
+
+	submitted := 0
+	failing := func(w http.ResponseWriter, r *http.Request) {
+		submitted++
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+	ts1, mux1 := stubTLSServer()
+	defer ts1.Close()
+	mux1.HandleFunc("/segment", failing)
+	ts2, mux2 := stubTLSServer()
+	defer ts2.Close()
+	mux2.HandleFunc("/segment", failing)
+	ts3, mux3 := stubTLSServer()
+	defer ts3.Close()
+	mux3.HandleFunc("/segment", func(w http.ResponseWriter, r *http.Request) {
+		submitted++
+		w.WriteHeader(http.StatusOK)
+		w.Write(buf)
+	})
+
+	newCxn := func(urls ...string) *rtmpConnection {
+		var sessions []*BroadcastSession
+		for _, url := range urls {
+			sess := StubBroadcastSession(url)
+			sess.Profiles = []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9}
+			sessions = append(sessions, sess)
+		}
+		return &rtmpConnection{
+			mid:         core.ManifestID("foo"),
+			pl:          &stubPlaylistManager{manifestID: core.ManifestID("foo"), os: drivers.NewMemoryDriver(nil).NewSession("foo")},
+			profile:     &ffmpeg.P144p30fps16x9,
+			sessManager: bsmWithSessList(sessions),
+		}
+	}
+	newSeg := func() *stream.HLSSegment { return &stream.HLSSegment{Data: []byte("dummy"), Duration: 2.0} }
+
+	// succeeds on the second attempt
+	urls, err := processSegment(newCxn(ts3.URL, ts1.URL), newSeg())
+	assert.Nil(err)
+	assert.Equal([]string{"test.flv"}, urls)
+	assert.Equal(2, submitted)
+
+	// gives up after the maximum number of attempts
+	submitted = 0
+	urls, err = processSegment(newCxn(ts3.URL, ts2.URL, ts1.URL), newSeg())
+	assert.Nil(urls)
+	exhausted, ok := err.(ErrRetriesExhausted)
+	assert.True(ok)
+	assert.Equal(2, exhausted.Attempts)
+	assert.Equal(2, submitted)
+
+	// no sessions isn't retried
+	submitted = 0
+	urls, err = processSegment(newCxn(), newSeg())
+	assert.Nil(urls)
+	assert.Equal(NewNonRetryableError(errNoOrchs), err)
+	assert.Equal(0, submitted)
+
+	// running out of sessions ends the retries
+	SegmentRetryPolicy.MaxAttempts = 5
+	urls, err = processSegment(newCxn(ts1.URL), newSeg())
+	assert.Nil(urls)
+	assert.Equal(NewNonRetryableError(errNoOrchs), err)
+	assert.Equal(1, submitted)
+}
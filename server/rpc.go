@@ -37,9 +37,10 @@ type Orchestrator interface {
 	Sign([]byte) ([]byte, error)
 	VerifySig(ethcommon.Address, string, []byte) bool
 	CurrentBlock() *big.Int
-	CheckCapacity(core.ManifestID) error
+	CheckCapacity(core.ManifestID, ethcommon.Address) error
+	Capabilities() *net.OrchestratorCapabilities
 	TranscodeSeg(*core.SegTranscodingMetadata, *stream.HLSSegment) (*core.TranscodeResult, error)
-	ServeTranscoder(stream net.Transcoder_RegisterTranscoderServer, capacity int)
+	ServeTranscoder(stream net.Transcoder_RegisterTranscoderServer, capacity int, capabilities *net.TranscoderCapabilities)
 	TranscoderResults(job int64, res *core.RemoteTranscoderResult)
 	ProcessPayment(payment net.Payment, manifestID core.ManifestID) error
 	TicketParams(sender ethcommon.Address) (*net.TicketParams, error)
@@ -92,6 +93,7 @@ type BroadcastSession struct {
 	Broadcaster      common.Broadcaster
 	ManifestID       core.ManifestID
 	Profiles         []ffmpeg.VideoProfile
+	Format           core.SegmentFormat
 	OrchestratorInfo *net.OrchestratorInfo
 	OrchestratorOS   drivers.OSSession
 	BroadcasterOS    drivers.OSSession
@@ -99,6 +101,8 @@ type BroadcastSession struct {
 	PMSessionID      string
 	Balance          Balance
 	LatencyScore     float64
+	// Overrides the max price of the broadcast config if set
+	MaxPrice *big.Rat
 }
 
 // ReceivedTranscodeResult contains received transcode result data and related metadata
@@ -191,8 +195,31 @@ func CheckOrchestratorAvailability(orch Orchestrator) bool {
 	return orch.VerifySig(orch.Address(), string(ping), pong.Value)
 }
 
+// PingOrchestrator - the broadcaster calls PingOrchestrator to measure the round trip time of a Ping to the orchestrator.
+// Connecting to the orchestrator isn't included, but is bound by ctx as well as GRPCConnectTimeout
+func PingOrchestrator(ctx context.Context, orchestratorServer *url.URL) (time.Duration, error) {
+	glog.V(common.DEBUG).Infof("Connecting RPC to probe orch=%v", orchestratorServer)
+	dialCtx, cancel := context.WithTimeout(ctx, GRPCConnectTimeout)
+	defer cancel()
+	conn, err := grpc.DialContext(dialCtx, orchestratorServer.Host,
+		grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)),
+		grpc.WithBlock())
+	if err != nil {
+		return 0, errors.Wrapf(err, "could not connect to orchestrator %v", orchestratorServer)
+	}
+	defer conn.Close()
+	c := net.NewOrchestratorClient(conn)
+
+	ping := crypto.Keccak256([]byte(fmt.Sprintf("%v", time.Now())))
+	start := time.Now()
+	if _, err := c.Ping(ctx, &net.PingPong{Value: ping}); err != nil {
+		return 0, errors.Wrapf(err, "could not ping orchestrator %v", orchestratorServer)
+	}
+	return time.Since(start), nil
+}
+
 func ping(context context.Context, req *net.PingPong, orch Orchestrator) (*net.PingPong, error) {
-	glog.Info("Received Ping request")
+	glog.V(common.DEBUG).Info("Received Ping request")
 	value, err := orch.Sign(req.Value)
 	if err != nil {
 		glog.Error("Unable to sign Ping request")
@@ -267,6 +294,7 @@ func orchestratorInfo(orch Orchestrator, addr ethcommon.Address, serviceURI stri
 		Transcoder:   serviceURI,
 		TicketParams: params,
 		PriceInfo:    priceInfo,
+		Capabilities: orch.Capabilities(),
 	}
 
 	os := drivers.NodeStorage.NewSession(string(core.RandomManifestID()))
@@ -283,7 +311,7 @@ func verifyOrchestratorReq(orch Orchestrator, addr ethcommon.Address, sig []byte
 		glog.Error("orchestrator req sig check failed")
 		return fmt.Errorf("orchestrator req sig check failed")
 	}
-	return orch.CheckCapacity("")
+	return orch.CheckCapacity("", addr)
 }
 
 func pmTicketParams(params *net.TicketParams) *pm.TicketParams {
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	gonet "net"
 	"net/url"
 	"testing"
 	"time"
@@ -63,6 +64,7 @@ type stubOrchestrator struct {
 	block      *big.Int
 	signErr    error
 	sessCapErr error
+	caps       *net.OrchestratorCapabilities
 }
 
 func (r *stubOrchestrator) ServiceURI() *url.URL {
@@ -136,10 +138,13 @@ func newStubOrchestrator() *stubOrchestrator {
 	return &stubOrchestrator{priv: pk, block: big.NewInt(5)}
 }
 
-func (r *stubOrchestrator) CheckCapacity(mid core.ManifestID) error {
+func (r *stubOrchestrator) CheckCapacity(mid core.ManifestID, sender ethcommon.Address) error {
 	return r.sessCapErr
 }
-func (r *stubOrchestrator) ServeTranscoder(stream net.Transcoder_RegisterTranscoderServer, capacity int) {
+func (r *stubOrchestrator) Capabilities() *net.OrchestratorCapabilities {
+	return r.caps
+}
+func (r *stubOrchestrator) ServeTranscoder(stream net.Transcoder_RegisterTranscoderServer, capacity int, capabilities *net.TranscoderCapabilities) {
 }
 func (r *stubOrchestrator) TranscoderResults(job int64, res *core.RemoteTranscoderResult) {
 }
@@ -211,10 +216,14 @@ func TestRPCSeg(t *testing.T) {
 		t.Error("Unable to generate seg creds ", err)
 		return
 	}
-	if _, err := verifySegCreds(o, creds, baddr); err != nil {
+	md, err := verifySegCreds(o, creds, baddr)
+	if err != nil {
 		t.Error("Unable to verify seg creds", err)
 		return
 	}
+	if md.Sender != baddr {
+		t.Error("Unexpected sender ", md.Sender.Hex())
+	}
 
 	// error signing
 	b.signErr = fmt.Errorf("SignErr")
@@ -255,6 +264,10 @@ func TestRPCSeg(t *testing.T) {
 	// corrupt profiles
 	corruptSegData(&net.SegData{Profiles: []byte("abc")}, common.ErrProfile)
 
+	// unknown format
+	corruptSegData(&net.SegData{Format: net.SegmentFormat(-1)}, errSegFormat)
+	corruptSegData(&net.SegData{Format: net.SegmentFormat(len(net.SegmentFormat_name))}, errSegFormat)
+
 	// corrupt sig
 	sd := &net.SegData{ManifestId: []byte(s.ManifestID)}
 	corruptSegData(sd, errSegSig) // missing sig
@@ -566,6 +579,33 @@ func TestPing(t *testing.T) {
 	}
 }
 
+func TestPingOrchestrator_Context(t *testing.T) {
+	assert := assert.New(t)
+
+	// accepts connections but never completes the TLS handshake
+	l, err := gonet.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	uri, _ := url.Parse("https://" + l.Addr().String())
+
+	// connecting gives up once the probe's context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = PingOrchestrator(ctx, uri)
+	assert.NotNil(err)
+	assert.True(time.Since(start) < GRPCConnectTimeout)
+}
+
 func TestValidatePrice(t *testing.T) {
 	assert := assert.New(t)
 	mid := core.RandomManifestID()
@@ -603,6 +643,16 @@ func TestValidatePrice(t *testing.T) {
 	err = validatePrice(s)
 	assert.EqualError(err, fmt.Sprintf("Orchestrator price higher than the set maximum price of %v wei per %v pixels", int64(1), int64(5)))
 
+	// The session's MaxPrice overrides B's MaxPrice
+	s.MaxPrice = big.NewRat(1, 2)
+	err = validatePrice(s)
+	assert.Nil(err)
+	s.MaxPrice = big.NewRat(1, 4)
+	BroadcastCfg.SetMaxPrice(nil)
+	err = validatePrice(s)
+	assert.EqualError(err, fmt.Sprintf("Orchestrator price higher than the set maximum price of %v wei per %v pixels", int64(1), int64(4)))
+	s.MaxPrice = nil
+
 	// O.PriceInfo is nil
 	s.OrchestratorInfo.PriceInfo = nil
 	err = validatePrice(s)
@@ -716,6 +766,30 @@ func TestGetOrchestrator_GivenValidSig_ReturnsTranscoderURI(t *testing.T) {
 	assert.Equal(uri, oInfo.Transcoder)
 }
 
+func TestGetOrchestrator_GivenValidSig_ReturnsCapabilities(t *testing.T) {
+	orch := &mockOrchestrator{}
+	drivers.NodeStorage = drivers.NewMemoryDriver(nil)
+	uri := "http://someuri.com"
+	orch.On("VerifySig", mock.Anything, mock.Anything, mock.Anything).Return(true)
+	orch.On("ServiceURI").Return(url.Parse(uri))
+	orch.On("TicketParams", mock.Anything).Return(nil, nil)
+	orch.On("PriceInfo", mock.Anything).Return(nil, nil)
+	orch.caps = &net.OrchestratorCapabilities{
+		FreeSessions: 3,
+		Profiles:     []string{ffmpeg.P720p30fps16x9.Name},
+		Codecs:       []string{"H264"},
+		MaxWidth:     1280,
+		MaxHeight:    720,
+		Gpu:          true,
+		Version:      "0.5.0",
+	}
+	oInfo, err := getOrchestrator(orch, &net.OrchestratorRequest{})
+
+	assert := assert.New(t)
+	assert.Nil(err)
+	assert.Equal(orch.caps, oInfo.Capabilities)
+}
+
 func TestGetOrchestrator_GivenInvalidSig_ReturnsError(t *testing.T) {
 	orch := &mockOrchestrator{}
 	drivers.NodeStorage = drivers.NewMemoryDriver(nil)
@@ -822,6 +896,7 @@ func (s *mockOSSession) IsExternal() bool {
 
 type mockOrchestrator struct {
 	mock.Mock
+	caps *net.OrchestratorCapabilities
 }
 
 func (o *mockOrchestrator) ServiceURI() *url.URL {
@@ -861,8 +936,8 @@ func (o *mockOrchestrator) TranscodeSeg(md *core.SegTranscodingMetadata, seg *st
 
 	return res, args.Error(1)
 }
-func (o *mockOrchestrator) ServeTranscoder(stream net.Transcoder_RegisterTranscoderServer, capacity int) {
-	o.Called(stream)
+func (o *mockOrchestrator) ServeTranscoder(stream net.Transcoder_RegisterTranscoderServer, capacity int, capabilities *net.TranscoderCapabilities) {
+	o.Called(stream, capacity, capabilities)
 }
 func (o *mockOrchestrator) TranscoderResults(job int64, res *core.RemoteTranscoderResult) {
 	o.Called(job, res)
@@ -888,10 +963,14 @@ func (o *mockOrchestrator) PriceInfo(sender ethcommon.Address) (*net.PriceInfo,
 	return nil, args.Error(1)
 }
 
-func (o *mockOrchestrator) CheckCapacity(mid core.ManifestID) error {
+func (o *mockOrchestrator) CheckCapacity(mid core.ManifestID, sender ethcommon.Address) error {
 	return nil
 }
 
+func (o *mockOrchestrator) Capabilities() *net.OrchestratorCapabilities {
+	return o.caps
+}
+
 func (o *mockOrchestrator) SufficientBalance(addr ethcommon.Address, manifestID core.ManifestID) bool {
 	args := o.Called(addr, manifestID)
 	return args.Bool(0)
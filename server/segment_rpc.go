@@ -37,6 +37,7 @@ const pixelEstimateMultiplier = 1.02
 
 var errSegEncoding = errors.New("ErrorSegEncoding")
 var errSegSig = errors.New("ErrSegSig")
+var errSegFormat = errors.New("ErrSegFormat")
 
 var tlsConfig = &tls.Config{InsecureSkipVerify: true}
 var httpClient = &http.Client{
@@ -87,6 +88,16 @@ func (h *lphttp) ServeSegment(w http.ResponseWriter, r *http.Request) {
 		glog.Errorf("Acceptable error occured when processing payment: %v", paymentError)
 	}
 
+	if oInfo == nil && orch.Capabilities().GetDraining() {
+		// Let the broadcaster know not to send more segments for this stream
+		oInfo, err = orchestratorInfo(orch, sender, orch.ServiceURI().String())
+		if err != nil {
+			glog.Errorf("Error updating orchestrator info: %v", err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+			return
+		}
+	}
+
 	if !orch.SufficientBalance(sender, segData.ManifestID) {
 		glog.Errorf("Insufficient credit balance for stream with manifestID %v\n", segData.ManifestID)
 		http.Error(w, "Insufficient balance", http.StatusBadRequest)
@@ -147,7 +158,7 @@ func (h *lphttp) ServeSegment(w http.ResponseWriter, r *http.Request) {
 	var segments []*net.TranscodedSegmentData
 	var pixels int64
 	for i := 0; err == nil && i < len(res.TranscodeData.Segments); i++ {
-		name := fmt.Sprintf("%s/%d.ts", segData.Profiles[i].Name, segData.Seq) // ANGIE - NEED TO EDIT OUT JOB PROFILES
+		name := fmt.Sprintf("%s/%d%s", segData.Profiles[i].Name, segData.Seq, segData.Format.Ext()) // ANGIE - NEED TO EDIT OUT JOB PROFILES
 		uri, err := res.OS.SaveData(name, res.TranscodeData.Segments[i].Data)
 		if err != nil {
 			glog.Error("Could not upload segment ", segData.Seq)
@@ -253,6 +264,11 @@ func verifySegCreds(orch Orchestrator, segCreds string, broadcaster ethcommon.Ad
 		}
 	}
 
+	if _, ok := net.SegmentFormat_name[int32(segData.Format)]; !ok {
+		glog.Error("Unknown segment format ", segData.Format)
+		return nil, errSegFormat
+	}
+
 	mid := core.ManifestID(segData.ManifestId)
 
 	var os *net.OSInfo
@@ -265,7 +281,9 @@ func verifySegCreds(orch Orchestrator, segCreds string, broadcaster ethcommon.Ad
 		Seq:        segData.Seq,
 		Hash:       ethcommon.BytesToHash(segData.Hash),
 		Profiles:   profiles,
+		Format:     core.SegmentFormat(segData.Format),
 		OS:         os,
+		Sender:     broadcaster,
 	}
 
 	if !orch.VerifySig(broadcaster, string(md.Flatten()), segData.Sig) {
@@ -273,7 +291,7 @@ func verifySegCreds(orch Orchestrator, segCreds string, broadcaster ethcommon.Ad
 		return nil, errSegSig
 	}
 
-	if err := orch.CheckCapacity(mid); err != nil {
+	if err := orch.CheckCapacity(mid, broadcaster); err != nil {
 		glog.Error("Cannot process manifest: ", err)
 		return nil, err
 	}
@@ -423,6 +441,8 @@ func SubmitSegment(sess *BroadcastSession, seg *stream.HLSSegment, nonce uint64)
 				monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorOrchestratorBusy, nonce, seg.SeqNo, err, false)
 			case "OrchestratorCapped":
 				monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorOrchestratorCapped, nonce, seg.SeqNo, err, false)
+			case core.ErrSenderSessionQuota.Error(), core.ErrSenderPixelQuota.Error(), core.ErrSenderSegmentQuota.Error():
+				monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorQuotaExceeded, nonce, seg.SeqNo, err, false)
 			default:
 				monitor.SegmentTranscodeFailed(monitor.SegmentTranscodeErrorTranscode, nonce, seg.SeqNo, err, false)
 			}
@@ -476,6 +496,7 @@ func genSegCreds(sess *BroadcastSession, seg *stream.HLSSegment) (string, error)
 		Seq:        int64(seg.SeqNo),
 		Hash:       ethcommon.BytesToHash(hash),
 		Profiles:   sess.Profiles,
+		Format:     sess.Format,
 	}
 	sig, err := sess.Broadcaster.Sign(md.Flatten())
 	if err != nil {
@@ -499,6 +520,7 @@ func genSegCreds(sess *BroadcastSession, seg *stream.HLSSegment) (string, error)
 		Seq:          md.Seq,
 		Hash:         hash,
 		FullProfiles: fullProfiles,
+		Format:       net.SegmentFormat(sess.Format),
 		Sig:          sig,
 		Storage:      storage,
 	}
@@ -665,6 +687,9 @@ func validatePrice(sess *BroadcastSession) error {
 	}
 
 	maxPrice := BroadcastCfg.MaxPrice()
+	if sess.MaxPrice != nil {
+		maxPrice = sess.MaxPrice
+	}
 	if maxPrice != nil && oPrice.Cmp(maxPrice) == 1 {
 		return fmt.Errorf("Orchestrator price higher than the set maximum price of %v wei per %v pixels", maxPrice.Num().Int64(), maxPrice.Denom().Int64())
 	}
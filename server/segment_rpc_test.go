@@ -15,6 +15,7 @@ import (
 	"testing"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
 	"github.com/golang/protobuf/proto"
 	"github.com/livepeer/go-livepeer/common"
 	"github.com/livepeer/go-livepeer/core"
@@ -236,6 +237,35 @@ func TestGenSegCreds_Profiles(t *testing.T) {
 	assert.Equal(expectedProfiles, segData.FullProfiles)
 }
 
+func TestSegCreds_Format(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	s := &BroadcastSession{
+		Broadcaster: stubBroadcaster2(),
+		ManifestID:  core.RandomManifestID(),
+		Profiles:    []ffmpeg.VideoProfile{ffmpeg.P360p30fps16x9},
+		Format:      core.FormatFMP4,
+	}
+	seg := &stream.HLSSegment{Data: []byte("foo")}
+	creds, err := genSegCreds(s, seg)
+	require.Nil(err)
+
+	// the format is signed over
+	expected := &core.SegTranscodingMetadata{
+		ManifestID: s.ManifestID,
+		Hash:       ethcommon.BytesToHash(ethcrypto.Keccak256(seg.Data)),
+		Profiles:   s.Profiles,
+		Format:     core.FormatFMP4,
+	}
+	orch := &mockOrchestrator{}
+	orch.On("VerifySig", mock.Anything, string(expected.Flatten()), mock.Anything).Return(true)
+
+	md, err := verifySegCreds(orch, creds, ethcommon.Address{})
+	require.Nil(err)
+	assert.Equal(core.FormatFMP4, md.Format)
+	orch.AssertExpectations(t)
+}
+
 func TestVerifySegCreds_FullProfiles(t *testing.T) {
 	assert := assert.New(t)
 	orch := &mockOrchestrator{}
@@ -441,6 +471,67 @@ func TestServeSegment_ReturnSingleTranscodedSegmentData(t *testing.T) {
 	assert.Equal(1, len(res.Data.Segments))
 }
 
+func TestServeSegment_Draining(t *testing.T) {
+	orch := &mockOrchestrator{caps: &net.OrchestratorCapabilities{Draining: true}}
+	handler := serveSegmentHandler(orch)
+	drivers.NodeStorage = drivers.NewMemoryDriver(nil)
+
+	require := require.New(t)
+
+	orch.On("VerifySig", mock.Anything, mock.Anything, mock.Anything).Return(true)
+	orch.On("ServiceURI").Return(url.Parse("http://someuri.com"))
+	orch.On("TicketParams", mock.Anything).Return(nil, nil)
+	orch.On("PriceInfo", mock.Anything).Return(nil, nil)
+
+	s := &BroadcastSession{
+		Broadcaster: stubBroadcaster2(),
+		ManifestID:  core.RandomManifestID(),
+		Profiles: []ffmpeg.VideoProfile{
+			ffmpeg.P720p60fps16x9,
+		},
+	}
+	seg := &stream.HLSSegment{Data: []byte("foo")}
+	creds, err := genSegCreds(s, seg)
+	require.Nil(err)
+
+	md, err := verifySegCreds(orch, creds, ethcommon.Address{})
+	require.Nil(err)
+
+	orch.On("ProcessPayment", net.Payment{}, s.ManifestID).Return(nil)
+	orch.On("SufficientBalance", mock.Anything, s.ManifestID).Return(true)
+
+	tData := &core.TranscodeData{Segments: []*core.TranscodedSegmentData{&core.TranscodedSegmentData{Data: []byte("foo")}}}
+	tRes := &core.TranscodeResult{
+		TranscodeData: tData,
+		Sig:           []byte("foo"),
+		OS:            drivers.NewMemoryDriver(nil).NewSession(""),
+	}
+	orch.On("TranscodeSeg", md, seg).Return(tRes, nil)
+	orch.On("DebitFees", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	headers := map[string]string{
+		paymentHeader: "",
+		segmentHeader: creds,
+	}
+	resp := httpPostResp(handler, bytes.NewReader(seg.Data), headers)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.Nil(err)
+
+	var tr net.TranscodeResult
+	err = proto.Unmarshal(body, &tr)
+	require.Nil(err)
+
+	// The segment is transcoded and the broadcaster is told the orchestrator is draining
+	assert := assert.New(t)
+	assert.Equal(http.StatusOK, resp.StatusCode)
+	_, ok := tr.Result.(*net.TranscodeResult_Data)
+	assert.True(ok)
+	require.NotNil(tr.Info)
+	assert.True(tr.Info.Capabilities.Draining)
+}
+
 func TestServeSegment_ReturnMultipleTranscodedSegmentData(t *testing.T) {
 	orch := &mockOrchestrator{}
 	handler := serveSegmentHandler(orch)
@@ -2,7 +2,12 @@ package server
 
 import (
 	"container/heap"
+	"errors"
+	"fmt"
+	"math"
 	"math/rand"
+	"strconv"
+	"strings"
 
 	ethcommon "github.com/ethereum/go-ethereum/common"
 	"github.com/golang/glog"
@@ -18,6 +23,12 @@ type BroadcastSessionsSelector interface {
 	Clear()
 }
 
+// sessionPenalizer is implemented by selectors that keep per orchestrator scores
+// which should account for results found to be wrong after completion
+type sessionPenalizer interface {
+	Penalize(sess *BroadcastSession)
+}
+
 type sessHeap []*BroadcastSession
 
 func (h sessHeap) Len() int {
@@ -108,9 +119,16 @@ func NewMinLSSelector(stakeRdr stakeReader, minLS float64) *MinLSSelector {
 	}
 }
 
-// Add adds the sessions to the selector's list of sessions without a latency score
+// Add adds the sessions to the selector's list of sessions without a latency score.
+// Sessions seeded with a latency score from earlier streams are added to the list of sessions with one
 func (s *MinLSSelector) Add(sessions []*BroadcastSession) {
-	s.unknownSessions = append(s.unknownSessions, sessions...)
+	for _, sess := range sessions {
+		if sess.LatencyScore > 0 {
+			heap.Push(s.knownSessions, sess)
+		} else {
+			s.unknownSessions = append(s.unknownSessions, sess)
+		}
+	}
 }
 
 // Complete adds the session to the selector's list sessions with a latency score
@@ -239,3 +257,264 @@ func (s *LIFOSelector) Size() int {
 func (s *LIFOSelector) Clear() {
 	*s = nil
 }
+
+// SelectorMode determines how the broadcaster picks sessions for segments
+type SelectorMode string
+
+const (
+	// SelectorMinLS prefers the session with the lowest latency score, see MinLSSelector
+	SelectorMinLS SelectorMode = "minls"
+	// SelectorWeighted scores sessions on price, latency, success rate and stake, see WeightedSelector
+	SelectorWeighted SelectorMode = "weighted"
+)
+
+// ParseSelectorMode validates the selector mode from the command line
+func ParseSelectorMode(mode string) (SelectorMode, error) {
+	switch m := SelectorMode(mode); m {
+	case SelectorMinLS, SelectorWeighted:
+		return m, nil
+	}
+	return SelectorMinLS, fmt.Errorf("Unknown selector %v; expected one of minls, weighted", mode)
+}
+
+var Selector = SelectorMinLS
+
+// SelectorWeights configures the WeightedSelector of streams
+var SelectorWeights = DefaultSelectionWeights
+
+func newSelector(stakeRdr stakeReader) BroadcastSessionsSelector {
+	if Selector == SelectorWeighted {
+		return NewWeightedSelector(stakeRdr, SelectorWeights)
+	}
+	return NewMinLSSelector(stakeRdr, 1.0)
+}
+
+// SelectionWeights blends the criteria that WeightedSelector scores sessions on.
+// Only the ratios between the weights matter
+type SelectionWeights struct {
+	Price       float64
+	Latency     float64
+	SuccessRate float64
+	Stake       float64
+}
+
+var DefaultSelectionWeights = SelectionWeights{Price: 1, Latency: 1, SuccessRate: 1, Stake: 1}
+
+// ParseSelectionWeights parses comma separated name=weight pairs, eg "price=2,latency=1".
+// The names are price, latency, success and stake; omitted criteria are weighted zero
+func ParseSelectionWeights(s string) (SelectionWeights, error) {
+	var w SelectionWeights
+	for _, pair := range strings.Split(s, ",") {
+		kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+		if len(kv) != 2 {
+			return w, fmt.Errorf("Invalid selection weight %v; expected name=weight", pair)
+		}
+		weight, err := strconv.ParseFloat(kv[1], 64)
+		if err != nil || weight < 0 || math.IsInf(weight, 0) {
+			return w, fmt.Errorf("Invalid selection weight %v; expected a non-negative number", pair)
+		}
+		switch kv[0] {
+		case "price":
+			w.Price = weight
+		case "latency":
+			w.Latency = weight
+		case "success":
+			w.SuccessRate = weight
+		case "stake":
+			w.Stake = weight
+		default:
+			return w, fmt.Errorf("Unknown selection criterion %v; expected one of price, latency, success, stake", kv[0])
+		}
+	}
+	if w == (SelectionWeights{}) {
+		return w, errors.New("At least one selection weight must be positive")
+	}
+	return w, nil
+}
+
+// Sessions without a latency score yet are assumed to transcode in real time
+const unknownLatencyScore = 1.0
+
+// Weight of the latest latency score in the average for an orchestrator
+const latencyScoreDecay = 0.5
+
+type orchSelectionStats struct {
+	selected  int
+	completed int
+	// moving average of the latency scores of completed segments
+	latencyScore float64
+}
+
+// WeightedSelector selects the BroadcastSession with the highest score, a weighted sum of
+//   - price: the lowest positive price per pixel among the sessions divided by the session's; free sessions
+//     score like the cheapest and sessions without a valid price score 0
+//   - latency: 1 / (1 + the moving average of the orchestrator's latency scores)
+//   - success rate: the share of selections the orchestrator completed, smoothed for new orchestrators
+//   - stake: the orchestrator's stake relative to the highest stake among the sessions
+//
+// The history of an orchestrator is kept until Clear, so it carries over session refreshes.
+// WeightedSelector is not concurrency safe so the caller is responsible for ensuring safety for concurrent method calls
+type WeightedSelector struct {
+	sessions []*BroadcastSession
+	// By transcoder URI
+	stats  map[string]*orchSelectionStats
+	stakes map[ethcommon.Address]int64
+
+	stakeRdr stakeReader
+	weights  SelectionWeights
+}
+
+// NewWeightedSelector returns an instance of WeightedSelector. Stake is ignored without a stakeRdr
+func NewWeightedSelector(stakeRdr stakeReader, weights SelectionWeights) *WeightedSelector {
+	return &WeightedSelector{
+		stats:    make(map[string]*orchSelectionStats),
+		stakes:   make(map[ethcommon.Address]int64),
+		stakeRdr: stakeRdr,
+		weights:  weights,
+	}
+}
+
+// Add adds the sessions to the selector's list of sessions and reads the stake of their orchestrators.
+// A latency score seeded from earlier streams counts as one completed segment of a new orchestrator
+func (s *WeightedSelector) Add(sessions []*BroadcastSession) {
+	s.sessions = append(s.sessions, sessions...)
+	for _, sess := range sessions {
+		if _, ok := s.stats[sess.OrchestratorInfo.Transcoder]; !ok && sess.LatencyScore > 0 {
+			s.stats[sess.OrchestratorInfo.Transcoder] = &orchSelectionStats{selected: 1, completed: 1, latencyScore: sess.LatencyScore}
+		}
+	}
+	if s.stakeRdr == nil || s.weights.Stake == 0 {
+		return
+	}
+
+	var addrs []ethcommon.Address
+	for _, sess := range sessions {
+		addr := sessionRecipient(sess)
+		if _, ok := s.stakes[addr]; !ok {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return
+	}
+	stakes, err := s.stakeRdr.Stakes(addrs)
+	if err != nil {
+		// Not fatal; these orchestrators are scored as having no stake
+		glog.Errorf("failed to read stake weights for selection: %v", err)
+		return
+	}
+	for _, addr := range addrs {
+		s.stakes[addr] = stakes[addr]
+	}
+}
+
+// Complete records the latency score of the session and adds it back to the selector's list of sessions
+func (s *WeightedSelector) Complete(sess *BroadcastSession) {
+	stats := s.getStats(sess)
+	if stats.completed == 0 {
+		stats.latencyScore = sess.LatencyScore
+	} else {
+		stats.latencyScore = latencyScoreDecay*sess.LatencyScore + (1-latencyScoreDecay)*stats.latencyScore
+	}
+	stats.completed++
+	s.sessions = append(s.sessions, sess)
+}
+
+// Penalize takes back the completion credited to the session so that it counts as a failure
+func (s *WeightedSelector) Penalize(sess *BroadcastSession) {
+	if stats := s.getStats(sess); stats.completed > 0 {
+		stats.completed--
+	}
+}
+
+// Select returns the session with the highest score. Ties go to the session added first
+func (s *WeightedSelector) Select() *BroadcastSession {
+	if len(s.sessions) == 0 {
+		return nil
+	}
+
+	prices := make([]float64, len(s.sessions))
+	priced := make([]bool, len(s.sessions))
+	minPrice, maxStake := math.Inf(1), int64(0)
+	for i, sess := range s.sessions {
+		prices[i], priced[i] = sessionPrice(sess)
+		if priced[i] && prices[i] > 0 {
+			minPrice = math.Min(minPrice, prices[i])
+		}
+		if stake := s.stakes[sessionRecipient(sess)]; stake > maxStake {
+			maxStake = stake
+		}
+	}
+
+	best, bestScore := 0, math.Inf(-1)
+	for i, sess := range s.sessions {
+		stats := s.getStats(sess)
+
+		priceScore := 0.0
+		if priced[i] {
+			priceScore = 1.0
+			if prices[i] > 0 {
+				priceScore = minPrice / prices[i]
+			}
+		}
+		ls := unknownLatencyScore
+		if stats.completed > 0 {
+			ls = stats.latencyScore
+		}
+		successRate := float64(stats.completed+1) / float64(stats.selected+2)
+		stakeScore := 0.0
+		if maxStake > 0 {
+			stakeScore = float64(s.stakes[sessionRecipient(sess)]) / float64(maxStake)
+		}
+
+		score := s.weights.Price*priceScore +
+			s.weights.Latency/(1+ls) +
+			s.weights.SuccessRate*successRate +
+			s.weights.Stake*stakeScore
+		if score > bestScore {
+			best, bestScore = i, score
+		}
+	}
+
+	sess := s.sessions[best]
+	s.sessions = append(s.sessions[:best], s.sessions[best+1:]...)
+	// Selections that never complete count as failures
+	s.getStats(sess).selected++
+	return sess
+}
+
+// Size returns the number of sessions stored by the selector
+func (s *WeightedSelector) Size() int {
+	return len(s.sessions)
+}
+
+// Clear resets the selector's state
+func (s *WeightedSelector) Clear() {
+	s.sessions = nil
+	s.stats = make(map[string]*orchSelectionStats)
+	s.stakes = make(map[ethcommon.Address]int64)
+	s.stakeRdr = nil
+}
+
+func (s *WeightedSelector) getStats(sess *BroadcastSession) *orchSelectionStats {
+	stats, ok := s.stats[sess.OrchestratorInfo.Transcoder]
+	if !ok {
+		stats = &orchSelectionStats{}
+		s.stats[sess.OrchestratorInfo.Transcoder] = stats
+	}
+	return stats
+}
+
+func sessionRecipient(sess *BroadcastSession) ethcommon.Address {
+	return ethcommon.BytesToAddress(sess.OrchestratorInfo.GetTicketParams().GetRecipient())
+}
+
+// sessionPrice returns the price per pixel of the session, and false if it has no valid price
+func sessionPrice(sess *BroadcastSession) (float64, bool) {
+	price, err := ratPriceInfo(sess.OrchestratorInfo.GetPriceInfo())
+	if err != nil || price == nil || price.Sign() < 0 {
+		return 0, false
+	}
+	f, _ := price.Float64()
+	return f, true
+}
@@ -429,3 +429,209 @@ func TestMinLSSelector_RemoveUnknownSession(t *testing.T) {
 	sel.removeUnknownSession(0)
 	assert.Empty(sel.unknownSessions)
 }
+
+func weightedSession(transcoder string, pricePerUnit int64, recipient byte) *BroadcastSession {
+	return &BroadcastSession{
+		OrchestratorInfo: &net.OrchestratorInfo{
+			Transcoder:   transcoder,
+			PriceInfo:    &net.PriceInfo{PricePerUnit: pricePerUnit, PixelsPerUnit: 1},
+			TicketParams: &net.TicketParams{Recipient: []byte{recipient}},
+		},
+	}
+}
+
+func TestWeightedSelector_Price(t *testing.T) {
+	assert := assert.New(t)
+
+	sel := NewWeightedSelector(nil, SelectionWeights{Price: 1})
+	assert.Nil(sel.Select())
+
+	sessions := []*BroadcastSession{
+		weightedSession("a", 3, 1),
+		weightedSession("b", 1, 2),
+		weightedSession("c", 2, 3),
+		// a zero price scores the same as the lowest positive price
+		weightedSession("d", 0, 4),
+		weightedSession("e", 1, 5),
+	}
+	sel.Add(sessions)
+	assert.Equal(5, sel.Size())
+	// ties go to the first session
+	assert.Equal(sessions[1], sel.Select())
+	assert.Equal(sessions[3], sel.Select())
+	assert.Equal(sessions[4], sel.Select())
+	assert.Equal(sessions[2], sel.Select())
+	assert.Equal(sessions[0], sel.Select())
+	assert.Zero(sel.Size())
+	assert.Nil(sel.Select())
+}
+
+func TestWeightedSelector_Unpriced(t *testing.T) {
+	assert := assert.New(t)
+
+	noPrice := &BroadcastSession{OrchestratorInfo: &net.OrchestratorInfo{Transcoder: "none"}}
+	invalid := weightedSession("invalid", 1, 1)
+	invalid.OrchestratorInfo.PriceInfo.PixelsPerUnit = 0
+	negative := weightedSession("negative", -1, 2)
+	cheap := weightedSession("cheap", 1, 3)
+	expensive := weightedSession("expensive", 2, 4)
+
+	// unpriced sessions score the worst and don't affect the scores of priced sessions
+	sel := NewWeightedSelector(nil, SelectionWeights{Price: 1, Latency: 1})
+	sel.Add([]*BroadcastSession{noPrice, invalid, negative, expensive, cheap})
+	assert.Equal(cheap, sel.Select())
+	assert.Equal(expensive, sel.Select())
+	assert.Equal(noPrice, sel.Select())
+	assert.Equal(invalid, sel.Select())
+	assert.Equal(negative, sel.Select())
+
+	// the price criterion doesn't matter if no session has a price
+	sel = NewWeightedSelector(nil, SelectionWeights{Price: 1})
+	sel.Add([]*BroadcastSession{noPrice, invalid})
+	assert.Equal(noPrice, sel.Select())
+	assert.Equal(invalid, sel.Select())
+}
+
+func TestWeightedSelector_LatencyAndSuccessRate(t *testing.T) {
+	assert := assert.New(t)
+
+	sel := NewWeightedSelector(nil, SelectionWeights{Latency: 1, SuccessRate: 1})
+	a, b := weightedSession("a", 1, 1), weightedSession("b", 1, 2)
+	sel.Add([]*BroadcastSession{a, b})
+
+	// a transcodes slower than real time, b faster
+	assert.Equal(a, sel.Select())
+	a.LatencyScore = 2
+	sel.Complete(a)
+	assert.Equal(b, sel.Select())
+	b.LatencyScore = 0.5
+	sel.Complete(b)
+	assert.Equal(b, sel.Select())
+	sel.Complete(b)
+
+	// latency scores are averaged
+	stats := sel.stats["b"]
+	assert.Equal(2, stats.completed)
+	assert.Equal(2, stats.selected)
+	b.LatencyScore = 1.5
+	assert.Equal(b, sel.Select())
+	sel.Complete(b)
+	assert.Equal(1.0, stats.latencyScore)
+
+	// b keeps failing and is added back in session refreshes until a is preferred
+	for i := 0; i < 3; i++ {
+		assert.Equal(b, sel.Select())
+		sel.Add([]*BroadcastSession{b})
+	}
+	assert.Equal(6, stats.selected)
+	assert.Equal(3, stats.completed)
+	assert.Equal(a, sel.Select())
+
+	sel.Clear()
+	assert.Zero(sel.Size())
+	assert.Empty(sel.stats)
+}
+
+func TestWeightedSelector_Stake(t *testing.T) {
+	assert := assert.New(t)
+
+	stakeRdr := newStubStakeReader()
+	a, b, c := weightedSession("a", 1, 1), weightedSession("b", 1, 2), weightedSession("c", 1, 3)
+	stakeRdr.SetStakes(map[ethcommon.Address]int64{
+		sessionRecipient(a): 100,
+		sessionRecipient(b): 300,
+	})
+	sel := NewWeightedSelector(stakeRdr, SelectionWeights{Stake: 1})
+	sel.Add([]*BroadcastSession{a, b, c})
+	assert.Len(sel.stakes, 3)
+	assert.Equal(b, sel.Select())
+	assert.Equal(a, sel.Select())
+	assert.Equal(c, sel.Select())
+
+	// stakes are only read once per orchestrator
+	stakeRdr.err = errors.New("Stakes error")
+	sel.Add([]*BroadcastSession{a, b})
+	assert.Equal(b, sel.Select())
+
+	// failing to read stakes isn't fatal
+	d := weightedSession("d", 1, 4)
+	sel.Add([]*BroadcastSession{d})
+	assert.Equal(2, sel.Size())
+	assert.Equal(a, sel.Select())
+	assert.Equal(d, sel.Select())
+
+	sel.Clear()
+	assert.Nil(sel.stakeRdr)
+}
+
+func TestWeightedSelector_Blend(t *testing.T) {
+	assert := assert.New(t)
+
+	// a is twice as expensive as b but completes segments in half the time
+	newSel := func(weights SelectionWeights) *WeightedSelector {
+		sel := NewWeightedSelector(nil, weights)
+		a, b := weightedSession("a", 2, 1), weightedSession("b", 1, 2)
+		sel.Add([]*BroadcastSession{a, b})
+		sel.stats["a"] = &orchSelectionStats{selected: 1, completed: 1, latencyScore: 0.25}
+		sel.stats["b"] = &orchSelectionStats{selected: 1, completed: 1, latencyScore: 0.5}
+		return sel
+	}
+	// price 0.5 + latency 0.8 vs price 1 + latency 0.67
+	assert.Equal("b", newSel(SelectionWeights{Price: 1, Latency: 1}).Select().OrchestratorInfo.Transcoder)
+	assert.Equal("a", newSel(SelectionWeights{Price: 1, Latency: 5}).Select().OrchestratorInfo.Transcoder)
+}
+
+func TestParseSelectionWeights(t *testing.T) {
+	assert := assert.New(t)
+
+	w, err := ParseSelectionWeights("price=2, latency=1.5,success=0,stake=1")
+	assert.Nil(err)
+	assert.Equal(SelectionWeights{Price: 2, Latency: 1.5, Stake: 1}, w)
+
+	w, err = ParseSelectionWeights("latency=1")
+	assert.Nil(err)
+	assert.Equal(SelectionWeights{Latency: 1}, w)
+
+	for _, s := range []string{"", "price", "price=-1", "price=x", "price=1,cost=1", "price=0"} {
+		_, err = ParseSelectionWeights(s)
+		assert.NotNil(err, s)
+	}
+}
+
+func TestParseSelectorMode(t *testing.T) {
+	assert := assert.New(t)
+
+	mode, err := ParseSelectorMode("weighted")
+	assert.Nil(err)
+	assert.Equal(SelectorWeighted, mode)
+	mode, err = ParseSelectorMode("minls")
+	assert.Nil(err)
+	assert.Equal(SelectorMinLS, mode)
+	_, err = ParseSelectorMode("random")
+	assert.NotNil(err)
+
+	defer func(m SelectorMode) { Selector = m }(Selector)
+	Selector = SelectorWeighted
+	assert.IsType(&WeightedSelector{}, newSelector(nil))
+	Selector = SelectorMinLS
+	assert.IsType(&MinLSSelector{}, newSelector(nil))
+}
+
+func TestSelectors_SeededLatencyScore(t *testing.T) {
+	assert := assert.New(t)
+
+	// sessions with a latency score from earlier streams are known
+	minLS := NewMinLSSelector(nil, 1.0)
+	seeded := &BroadcastSession{LatencyScore: 0.5}
+	minLS.Add([]*BroadcastSession{&BroadcastSession{}, seeded})
+	assert.Len(minLS.unknownSessions, 1)
+	assert.Equal(1, minLS.knownSessions.Len())
+	assert.Equal(seeded, minLS.Select())
+
+	weighted := NewWeightedSelector(nil, SelectionWeights{Latency: 1})
+	a, b := weightedSession("a", 1, 1), weightedSession("b", 1, 2)
+	b.LatencyScore = 0.5
+	weighted.Add([]*BroadcastSession{a, b})
+	assert.Equal(&orchSelectionStats{selected: 1, completed: 1, latencyScore: 0.5}, weighted.stats["b"])
+	assert.Equal(b, weighted.Select())
+}
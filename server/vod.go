@@ -0,0 +1,388 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/lpms/ffmpeg"
+	"github.com/livepeer/lpms/stream"
+	"github.com/livepeer/m3u8"
+)
+
+// VODParallelism is the number of segments of a VOD job transcoded at once.
+// Unlike live streams, all segments of a file are available up front
+var VODParallelism = 4
+
+// Attempts per VOD segment, in addition to the retries within processSegment,
+// for when all sessions are busy with the other segments of the job
+var vodSegmentAttempts = 3
+var vodRetryInterval = time.Second
+
+// Splits the input file into segments in the output directory
+var segmentVODFile = segmentFile
+
+var vodHTTPClient = &http.Client{Timeout: 10 * time.Minute}
+
+// How long the status of a finished VOD job can be queried
+var vodJobRetention = time.Hour
+
+var errVODSegmentsFailed = errors.New("segments failed to transcode")
+var errVODNoSegments = errors.New("no segments in input")
+var errVODStorage = errors.New("VOD jobs require external object storage; set -s3bucket or -gsbucket")
+
+// VODJobState is the stage a VOD job is in
+type VODJobState string
+
+const (
+	VODJobPending     VODJobState = "pending"
+	VODJobSegmenting  VODJobState = "segmenting"
+	VODJobTranscoding VODJobState = "transcoding"
+	VODJobComplete    VODJobState = "complete"
+	VODJobFailed      VODJobState = "failed"
+)
+
+// VODJobStatus is the progress of a VOD job as reported by the API
+type VODJobStatus struct {
+	ID         core.ManifestID `json:"id"`
+	State      VODJobState     `json:"state"`
+	Segments   int             `json:"segments"`
+	Transcoded int             `json:"transcoded"`
+	Failed     int             `json:"failed"`
+	Playlist   string          `json:"playlist,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+type vodSegment struct {
+	fname    string
+	duration float64
+}
+
+type vodJob struct {
+	mu     sync.Mutex
+	status VODJobStatus
+
+	params *streamParameters
+	// local copy of the input file, removed when the job ends
+	input string
+	// location of the input if it needs to be downloaded
+	url string
+}
+
+func (j *vodJob) getStatus() VODJobStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.status
+}
+
+func (j *vodJob) update(f func(*VODJobStatus)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	f(&j.status)
+}
+
+// HandleVOD creates VOD jobs with a POST to /jobs and reports their status with a GET to /jobs/<id>.
+// The file to transcode is either the request body or downloaded from the `url` parameter.
+// Jobs require external object storage since the memory driver only keeps the most recent segments
+func (s *LivepeerServer) HandleVOD(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "POST":
+		s.createVODJob(w, r)
+	case "GET":
+		id := core.ManifestID(strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/"))
+		s.vodLock.Lock()
+		job, ok := s.vodJobs[id]
+		s.vodLock.Unlock()
+		if !ok {
+			http.Error(w, "Unknown job", http.StatusNotFound)
+			return
+		}
+		respondVODStatus(w, job.getStatus())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func respondVODStatus(w http.ResponseWriter, status VODJobStatus) {
+	data, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+func (s *LivepeerServer) createVODJob(w http.ResponseWriter, r *http.Request) {
+	if _, memory := drivers.NodeStorage.(*drivers.MemoryOS); memory || drivers.NodeStorage == nil {
+		http.Error(w, errVODStorage.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	job := &vodJob{url: r.URL.Query().Get("url")}
+	if job.url != "" {
+		if u, err := url.Parse(job.url); err != nil || !u.IsAbs() {
+			http.Error(w, "Invalid url", http.StatusBadRequest)
+			return
+		}
+	} else {
+		f, err := ioutil.TempFile("", "vod")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		n, err := io.Copy(f, r.Body)
+		f.Close()
+		if err != nil || n == 0 {
+			os.Remove(f.Name())
+			http.Error(w, "Missing file", http.StatusBadRequest)
+			return
+		}
+		job.input = f.Name()
+	}
+
+	r.URL = &url.URL{Scheme: "http", Host: r.Host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+	job.params = s.newStreamParams(r.URL, core.RandomManifestID())
+	if job.params == nil {
+		os.Remove(job.input)
+		http.Error(w, "Could not create stream ID", http.StatusInternalServerError)
+		return
+	}
+	job.status = VODJobStatus{ID: job.params.mid, State: VODJobPending}
+
+	s.vodLock.Lock()
+	s.vodJobs[job.params.mid] = job
+	s.vodLock.Unlock()
+
+	glog.Infof("Created VOD job manifestID=%s url=%s", job.params.mid, job.url)
+	go s.runVODJob(job)
+	respondVODStatus(w, job.getStatus())
+}
+
+func (s *LivepeerServer) runVODJob(job *vodJob) {
+	mid := job.params.mid
+	playlist, err := s.transcodeVOD(job)
+	job.update(func(st *VODJobStatus) {
+		if err != nil {
+			st.State, st.Error = VODJobFailed, err.Error()
+		} else {
+			st.State, st.Playlist = VODJobComplete, playlist
+		}
+	})
+	if err != nil {
+		glog.Errorf("VOD job failed manifestID=%s err=%v", mid, err)
+	} else {
+		glog.Infof("VOD job complete manifestID=%s playlist=%s", mid, playlist)
+	}
+
+	time.AfterFunc(vodJobRetention, func() {
+		s.vodLock.Lock()
+		delete(s.vodJobs, mid)
+		s.vodLock.Unlock()
+	})
+}
+
+// transcodeVOD segments the input, transcodes all segments and returns the location of
+// the master playlist in object storage
+func (s *LivepeerServer) transcodeVOD(job *vodJob) (string, error) {
+	if job.input != "" {
+		defer os.Remove(job.input)
+	}
+	dir, err := ioutil.TempDir("", "vod")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+
+	job.update(func(st *VODJobStatus) { st.State = VODJobSegmenting })
+	if job.url != "" {
+		if job.input, err = downloadVODInput(job.url, dir); err != nil {
+			return "", err
+		}
+	}
+	segments, err := segmentVODFile(job.input, dir)
+	if err != nil {
+		return "", err
+	}
+	if len(segments) == 0 {
+		return "", errVODNoSegments
+	}
+
+	job.params.vodSegments = uint(len(segments))
+	cxn, err := s.registerConnection(stream.NewBasicRTMPVideoStream(job.params))
+	if err != nil {
+		return "", err
+	}
+	job.update(func(st *VODJobStatus) { st.State, st.Segments = VODJobTranscoding, len(segments) })
+
+	// The output is in object storage, so the stream doesn't need to stay registered
+	defer removeRTMPStream(s, cxn.mid)
+	failed := s.transcodeVODSegments(job, cxn, segments)
+	if failed > 0 {
+		return "", fmt.Errorf("%d %v", failed, errVODSegmentsFailed)
+	}
+
+	cxn.pl.FinishHLSPlaylists()
+	return saveVODPlaylists(cxn.pl, cxn.params.format)
+}
+
+// transcodeVODSegments transcodes the segments in parallel and returns the number that failed
+func (s *LivepeerServer) transcodeVODSegments(job *vodJob, cxn *rtmpConnection, segments []vodSegment) int {
+	queue := make(chan int, len(segments))
+	for i := range segments {
+		queue <- i
+	}
+	close(queue)
+
+	var wg sync.WaitGroup
+	for w := 0; w < VODParallelism; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				err := transcodeVODSegment(cxn, uint64(i), segments[i])
+				job.update(func(st *VODJobStatus) {
+					if err != nil {
+						st.Failed++
+					} else {
+						st.Transcoded++
+					}
+				})
+			}
+		}()
+	}
+	wg.Wait()
+	return job.getStatus().Failed
+}
+
+func transcodeVODSegment(cxn *rtmpConnection, seqNo uint64, vseg vodSegment) error {
+	data, err := ioutil.ReadFile(vseg.fname)
+	if err != nil {
+		return err
+	}
+	seg := &stream.HLSSegment{
+		Data:     data,
+		SeqNo:    seqNo,
+		Duration: vseg.duration,
+	}
+	// The source is only saved once; retries only transcode it again
+	saved := false
+	var name, uri string
+	for attempt := 1; ; attempt++ {
+		if !saved {
+			name, uri, err = saveSourceSegment(cxn, seg)
+			saved = err == nil
+		}
+		if saved {
+			_, err = transcodeSourceSegment(cxn, seg, name, uri)
+		}
+		if err == nil || attempt >= vodSegmentAttempts {
+			break
+		}
+		glog.V(common.DEBUG).Infof("Retrying VOD segment manifestID=%s seqNo=%d attempt=%d err=%v", cxn.mid, seqNo, attempt, err)
+		time.Sleep(vodRetryInterval)
+	}
+	if err != nil {
+		glog.Errorf("Failed to transcode VOD segment manifestID=%s seqNo=%d err=%v", cxn.mid, seqNo, err)
+	}
+	return err
+}
+
+func downloadVODInput(uri string, dir string) (string, error) {
+	resp, err := vodHTTPClient.Get(uri)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error downloading input: %v", resp.Status)
+	}
+	fname := filepath.Join(dir, "input"+path.Ext(resp.Request.URL.Path))
+	f, err := os.Create(fname)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		return "", err
+	}
+	return fname, nil
+}
+
+// segmentFile splits the input with lpms into segments of SegLen in dir
+func segmentFile(input string, dir string) ([]vodSegment, error) {
+	outPlaylist := filepath.Join(dir, "segments.m3u8")
+	tmpl := filepath.Join(dir, "segment_%d.ts")
+	seglen := fmt.Sprintf("%d", int(SegLen.Seconds()))
+	if err := ffmpeg.RTMPToHLS(input, outPlaylist, tmpl, seglen, 0); err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(outPlaylist)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pl, listType, err := m3u8.DecodeFrom(f, false)
+	if err != nil {
+		return nil, err
+	}
+	if listType != m3u8.MEDIA {
+		return nil, errors.New("unexpected segment playlist type")
+	}
+
+	var segments []vodSegment
+	for _, seg := range pl.(*m3u8.MediaPlaylist).Segments {
+		if seg == nil {
+			continue
+		}
+		fname := seg.URI
+		if !filepath.IsAbs(fname) {
+			fname = filepath.Join(dir, fname)
+		}
+		segments = append(segments, vodSegment{fname: fname, duration: seg.Duration})
+	}
+	return segments, nil
+}
+
+// saveVODPlaylists uploads the media playlists, a master playlist referencing them and, for
+// fmp4 jobs, a DASH manifest next to the segments in object storage. Returns the location of
+// the master playlist
+func saveVODPlaylists(pl core.PlaylistManager, format core.SegmentFormat) (string, error) {
+	storage := pl.GetOSSession()
+	master := m3u8.NewMasterPlaylist()
+	for _, v := range pl.GetHLSMasterPlaylist().Variants {
+		if v == nil || v.Chunklist == nil {
+			continue
+		}
+		name := path.Base(v.URI)
+		if _, err := storage.SaveData(name, v.Chunklist.Encode().Bytes()); err != nil {
+			return "", err
+		}
+		master.Append(name, v.Chunklist, v.VariantParams)
+	}
+	if format == core.FormatFMP4 {
+		mpd, err := pl.GetDASHManifest()
+		if err != nil {
+			return "", err
+		}
+		if _, err := storage.SaveData("index.mpd", mpd); err != nil {
+			return "", err
+		}
+	}
+	return storage.SaveData("index.m3u8", master.Encode().Bytes())
+}
@@ -0,0 +1,299 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/drivers"
+	"github.com/livepeer/lpms/ffmpeg"
+)
+
+func stubSegmentVOD(count int) func(string, string) ([]vodSegment, error) {
+	return func(input string, dir string) ([]vodSegment, error) {
+		var segments []vodSegment
+		for i := 0; i < count; i++ {
+			fname := filepath.Join(dir, fmt.Sprintf("%d.ts", i))
+			if err := ioutil.WriteFile(fname, []byte(fmt.Sprintf("segment %d", i)), 0644); err != nil {
+				return nil, err
+			}
+			segments = append(segments, vodSegment{fname: fname, duration: 2})
+		}
+		return segments, nil
+	}
+}
+
+// externalMemoryDriver keeps data in memory but behaves as external object storage,
+// whose data outlives the sessions
+type externalMemoryDriver struct {
+	*drivers.MemoryOS
+}
+
+type externalMemorySession struct {
+	*drivers.MemorySession
+}
+
+func (d *externalMemoryDriver) NewSession(path string) drivers.OSSession {
+	return &externalMemorySession{d.MemoryOS.NewSession(path).(*drivers.MemorySession)}
+}
+
+func (s *externalMemorySession) IsExternal() bool { return true }
+
+func (s *externalMemorySession) EndSession() {}
+
+// setupVODServer returns the test server without stream authentication and with external storage
+func setupVODServer() (*LivepeerServer, *drivers.MemoryOS) {
+	AuthWebhookURL = ""
+	s := setupServer()
+	storage := drivers.NewMemoryDriver(nil)
+	drivers.NodeStorage = &externalMemoryDriver{storage}
+	return s, storage
+}
+
+func postVOD(s *LivepeerServer, target string, body string) (*http.Response, VODJobStatus) {
+	w := httptest.NewRecorder()
+	s.HandleVOD(w, httptest.NewRequest("POST", target, strings.NewReader(body)))
+	resp := w.Result()
+	var status VODJobStatus
+	json.NewDecoder(resp.Body).Decode(&status)
+	return resp, status
+}
+
+func waitForVODJob(s *LivepeerServer, id core.ManifestID) VODJobStatus {
+	var status VODJobStatus
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); {
+		w := httptest.NewRecorder()
+		s.HandleVOD(w, httptest.NewRequest("GET", "/jobs/"+string(id), nil))
+		json.NewDecoder(w.Result().Body).Decode(&status)
+		if status.State == VODJobComplete || status.State == VODJobFailed {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return status
+}
+
+func TestHandleVOD_Errors(t *testing.T) {
+	assert := assert.New(t)
+	s, _ := setupVODServer()
+
+	// missing file
+	resp, _ := postVOD(s, "/jobs", "")
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	// invalid url
+	resp, _ = postVOD(s, "/jobs?url=input.mp4", "")
+	assert.Equal(http.StatusBadRequest, resp.StatusCode)
+
+	// unknown job
+	w := httptest.NewRecorder()
+	s.HandleVOD(w, httptest.NewRequest("GET", "/jobs/unknown", nil))
+	assert.Equal(http.StatusNotFound, w.Result().StatusCode)
+
+	w = httptest.NewRecorder()
+	s.HandleVOD(w, httptest.NewRequest("PUT", "/jobs", nil))
+	assert.Equal(http.StatusMethodNotAllowed, w.Result().StatusCode)
+
+	// memory storage
+	drivers.NodeStorage = drivers.NewMemoryDriver(nil)
+	resp, _ = postVOD(s, "/jobs", "file data")
+	assert.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+	drivers.NodeStorage = nil
+	resp, _ = postVOD(s, "/jobs", "file data")
+	assert.Equal(http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestHandleVOD_Upload(t *testing.T) {
+	defer func(seg func(string, string) ([]vodSegment, error), fallback FallbackMode, deadline time.Duration) {
+		segmentVODFile, Fallback, FallbackDeadline = seg, fallback, deadline
+	}(segmentVODFile, Fallback, FallbackDeadline)
+	assert := assert.New(t)
+	require := require.New(t)
+	s, storage := setupVODServer()
+	// Without orchestrators, the source is used for the renditions
+	Fallback, FallbackDeadline = FallbackSource, 0
+	BroadcastJobVideoProfiles = []ffmpeg.VideoProfile{ffmpeg.P360p30fps16x9}
+	segmentVODFile = stubSegmentVOD(10)
+
+	resp, status := postVOD(s, "/jobs", "file data")
+	require.Equal(http.StatusOK, resp.StatusCode)
+	require.NotEmpty(status.ID)
+
+	status = waitForVODJob(s, status.ID)
+	require.Equal(VODJobComplete, status.State, status.Error)
+	assert.Equal(10, status.Segments)
+	assert.Equal(10, status.Transcoded)
+	assert.Equal(0, status.Failed)
+
+	// complete playlists are in object storage
+	sess := storage.GetSession(string(status.ID))
+	require.NotNil(sess)
+	master := sess.GetData(status.Playlist)
+	require.NotNil(master)
+	assert.Contains(string(master), "P360p30fps16x9.m3u8")
+	media := string(sess.GetData(string(status.ID) + "/P360p30fps16x9.m3u8"))
+	assert.Contains(media, "#EXT-X-ENDLIST")
+	assert.Equal(10, strings.Count(media, "#EXTINF"))
+	// MPEG-TS jobs don't get a DASH manifest
+	assert.Nil(sess.GetData(string(status.ID) + "/index.mpd"))
+
+	// the stream is removed once the job ends
+	s.connectionLock.RLock()
+	_, exists := s.rtmpConnections[status.ID]
+	s.connectionLock.RUnlock()
+	assert.False(exists)
+}
+
+func TestSaveVODPlaylists(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	save := func(format core.SegmentFormat) (*drivers.MemorySession, string) {
+		mid := core.RandomManifestID()
+		sess := drivers.NewMemoryDriver(nil).NewSession(string(mid)).(*drivers.MemorySession)
+		pl := core.NewVODPlaylistManager(mid, sess, 2)
+		profile := &ffmpeg.P360p30fps16x9
+		for i := uint64(0); i < 2; i++ {
+			require.Nil(pl.InsertHLSSegment(profile, i, fmt.Sprintf("%s/%d.m4s", profile.Name, i), 2))
+		}
+		require.Nil(pl.InsertHLSInitSegment(profile, profile.Name+"/init.mp4"))
+		pl.FinishHLSPlaylists()
+		master, err := saveVODPlaylists(pl, format)
+		require.Nil(err)
+		require.NotNil(sess.GetData(master))
+		return sess, string(mid)
+	}
+
+	// fmp4 jobs also get a DASH manifest
+	sess, mid := save(core.FormatFMP4)
+	assert.NotNil(sess.GetData(mid + "/P360p30fps16x9.m3u8"))
+	mpd := string(sess.GetData(mid + "/index.mpd"))
+	assert.Contains(mpd, `type="static"`)
+	assert.Contains(mpd, `<Representation id="P360p30fps16x9"`)
+
+	sess, mid = save(core.FormatMPEGTS)
+	assert.NotNil(sess.GetData(mid + "/P360p30fps16x9.m3u8"))
+	assert.Nil(sess.GetData(mid + "/index.mpd"))
+}
+
+func TestHandleVOD_Retention(t *testing.T) {
+	defer func(seg func(string, string) ([]vodSegment, error), retention time.Duration) {
+		segmentVODFile, vodJobRetention = seg, retention
+	}(segmentVODFile, vodJobRetention)
+	assert := assert.New(t)
+	s, _ := setupVODServer()
+	segmentVODFile = stubSegmentVOD(0)
+	vodJobRetention = 50 * time.Millisecond
+
+	_, status := postVOD(s, "/jobs", "file data")
+	status = waitForVODJob(s, status.ID)
+	assert.Equal(VODJobFailed, status.State)
+
+	// finished jobs expire
+	time.Sleep(100 * time.Millisecond)
+	w := httptest.NewRecorder()
+	s.HandleVOD(w, httptest.NewRequest("GET", "/jobs/"+string(status.ID), nil))
+	assert.Equal(http.StatusNotFound, w.Result().StatusCode)
+}
+
+func TestHandleVOD_Failures(t *testing.T) {
+	defer func(seg func(string, string) ([]vodSegment, error), interval time.Duration) {
+		segmentVODFile, vodRetryInterval = seg, interval
+	}(segmentVODFile, vodRetryInterval)
+	vodRetryInterval = time.Millisecond
+	assert := assert.New(t)
+	s, _ := setupVODServer()
+
+	// segmenting fails
+	segmentVODFile = func(string, string) ([]vodSegment, error) { return nil, errors.New("segmenting error") }
+	_, status := postVOD(s, "/jobs", "file data")
+	status = waitForVODJob(s, status.ID)
+	assert.Equal(VODJobFailed, status.State)
+	assert.Equal("segmenting error", status.Error)
+
+	// empty input
+	segmentVODFile = stubSegmentVOD(0)
+	_, status = postVOD(s, "/jobs", "file data")
+	status = waitForVODJob(s, status.ID)
+	assert.Equal(VODJobFailed, status.State)
+	assert.Equal(errVODNoSegments.Error(), status.Error)
+
+	// no orchestrators and no fallback
+	segmentVODFile = stubSegmentVOD(3)
+	_, status = postVOD(s, "/jobs", "file data")
+	status = waitForVODJob(s, status.ID)
+	assert.Equal(VODJobFailed, status.State)
+	assert.Equal(3, status.Segments)
+	assert.Equal(3, status.Failed)
+	s.connectionLock.RLock()
+	_, exists := s.rtmpConnections[status.ID]
+	s.connectionLock.RUnlock()
+	assert.False(exists)
+
+	// input can't be downloaded
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+	_, status = postVOD(s, "/jobs?url="+ts.URL+"/input.mp4", "")
+	status = waitForVODJob(s, status.ID)
+	assert.Equal(VODJobFailed, status.State)
+	assert.Contains(status.Error, "404")
+}
+
+type countingOSSession struct {
+	drivers.OSSession
+	saves int
+}
+
+func (s *countingOSSession) SaveData(name string, data []byte) (string, error) {
+	s.saves++
+	return s.OSSession.SaveData(name, data)
+}
+
+type countingPlaylistManager struct {
+	*stubPlaylistManager
+	inserts int
+}
+
+func (pm *countingPlaylistManager) InsertHLSSegment(profile *ffmpeg.VideoProfile, seqNo uint64, uri string, duration float64) error {
+	pm.inserts++
+	return pm.stubPlaylistManager.InsertHLSSegment(profile, seqNo, uri, duration)
+}
+
+func TestTranscodeVODSegment_Retries(t *testing.T) {
+	defer func(interval time.Duration) { vodRetryInterval = interval }(vodRetryInterval)
+	vodRetryInterval = time.Millisecond
+	assert := assert.New(t)
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "vodRetries")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+	fname := filepath.Join(dir, "0.ts")
+	require.Nil(ioutil.WriteFile(fname, []byte("segment"), 0644))
+	ossess := &countingOSSession{OSSession: drivers.NewMemoryDriver(nil).NewSession("vodRetries")}
+	pl := &countingPlaylistManager{stubPlaylistManager: &stubPlaylistManager{manifestID: "vodRetries", os: ossess}}
+	cxn := &rtmpConnection{
+		mid:         "vodRetries",
+		pl:          pl,
+		profile:     &ffmpeg.P144p30fps16x9,
+		sessManager: bsmWithSessList(nil),
+	}
+
+	// without sessions every attempt fails, but the source is only saved once
+	err = transcodeVODSegment(cxn, 0, vodSegment{fname: fname, duration: 2})
+	assert.NotNil(err)
+	assert.Equal(1, ossess.saves)
+	assert.Equal(1, pl.inserts)
+}
@@ -69,6 +69,41 @@ func (s *LivepeerServer) StartCliWebserver(bindAddr string) {
 	srv.ListenAndServe()
 }
 
+// StartTranscoderCliWebserver starts the web server for the CLI of a standalone
+// transcoder, which only serves the node status and drain mode. A drained transcoder
+// disconnects from its orchestrator and exits once its work in flight is done, so
+// drain mode can't be left. Blocks until exit and returns the error of the server
+func (s *LivepeerServer) StartTranscoderCliWebserver(bindAddr string) error {
+	srv := &http.Server{
+		Addr:    bindAddr,
+		Handler: s.transcoderCliHandlers(),
+	}
+
+	glog.Info("Transcoder CLI server listening on ", bindAddr)
+	return srv.ListenAndServe()
+}
+
+func (s *LivepeerServer) transcoderCliHandlers() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/status", s.statusHandler())
+	mux.Handle("/drain", drainHandler(s.LivepeerNode, true))
+	return mux
+}
+
+func (s *LivepeerServer) statusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := s.GetNodeStatus()
+		if status != nil {
+			if data, err := json.Marshal(status); err == nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(data)
+				return
+			}
+		}
+		http.Error(w, "Error getting status", http.StatusInternalServerError)
+	})
+}
+
 func (s *LivepeerServer) cliWebServerHandlers(bindAddr string) *http.ServeMux {
 	// Override default mux because pprof only uses the default mux
 	// We really don't want to accidentally pull pprof into other listeners.
@@ -748,17 +783,7 @@ func (s *LivepeerServer) cliWebServerHandlers(bindAddr string) *http.ServeMux {
 		w.Write([]byte(fmt.Sprintf("\n\nLatestPlaylist: %v", s.LatestPlaylist())))
 	})
 
-	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
-		status := s.GetNodeStatus()
-		if status != nil {
-			if data, err := json.Marshal(status); err == nil {
-				w.Header().Set("Content-Type", "application/json")
-				w.Write(data)
-				return
-			}
-		}
-		http.Error(w, "Error getting status", http.StatusInternalServerError)
-	})
+	mux.Handle("/status", s.statusHandler())
 
 	mux.HandleFunc("/contractAddresses", func(w http.ResponseWriter, r *http.Request) {
 		if s.LivepeerNode.Eth != nil {
@@ -1095,6 +1120,19 @@ func (s *LivepeerServer) cliWebServerHandlers(bindAddr string) *http.ServeMux {
 
 	mux.Handle("/currentBlock", currentBlockHandler(s.LivepeerNode.Database))
 
+	// Drain mode
+	mux.Handle("/drain", drainHandler(s.LivepeerNode, true))
+	mux.Handle("/resume", drainHandler(s.LivepeerNode, false))
+
+	// Orchestrator allowlist and blocklist
+	mux.Handle("/orchestratorLists", orchListsHandler())
+	mux.Handle("/addToOrchestratorList", mustHaveFormParams(updateOrchListHandler(true), "list", "entry"))
+	mux.Handle("/removeFromOrchestratorList", mustHaveFormParams(updateOrchListHandler(false), "list", "entry"))
+
+	// HLS pull ingest
+	mux.Handle("/pullHLS", mustHaveFormParams(s.pullHLSHandler(), "url"))
+	mux.Handle("/stopHLSPull", mustHaveFormParams(s.stopHLSPullHandler(), "manifestID"))
+
 	// TicketBroker
 
 	mux.Handle("/fundDepositAndReserve", mustHaveFormParams(fundDepositAndReserveHandler(s.LivepeerNode.Eth), "depositAmount", "reserveAmount"))
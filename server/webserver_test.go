@@ -2,6 +2,8 @@ package server
 
 import (
 	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"strings"
 	"testing"
 
@@ -41,3 +43,31 @@ func TestSetOrchestratorPriceInfo(t *testing.T) {
 	err = s.setOrchestratorPriceInfo("1", "-5")
 	assert.EqualErrorf(t, err, err.Error(), "pixels per unit must be greater than 0, provided %d\n", -5)
 }
+
+func TestTranscoderCliHandlers(t *testing.T) {
+	assert := assert.New(t)
+	n, _ := core.NewLivepeerNode(nil, "", nil)
+	n.NodeType = core.TranscoderNode
+	s := NewLivepeerServer("127.0.0.1:1938", n)
+	mux := s.transcoderCliHandlers()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return w
+	}
+
+	assert.Equal(http.StatusOK, get("/drain").Code)
+	assert.True(n.Draining())
+	w := get("/status")
+	assert.Equal(http.StatusOK, w.Code)
+	assert.Contains(w.Body.String(), `"Draining":true`)
+
+	// nothing else is served; drained transcoders exit so they can't be resumed
+	for _, path := range []string{"/resume", "/ethAddr", "/setBroadcastConfig", "/transferTokens", "/debug/pprof/"} {
+		assert.Equal(http.StatusNotFound, get(path).Code, path)
+	}
+
+	// bind failures are returned
+	assert.NotNil(s.StartTranscoderCliWebserver("127.0.0.1:-1"))
+}
@@ -0,0 +1,258 @@
+package verification
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math"
+	"os"
+	"time"
+
+	"github.com/golang/glog"
+
+	"github.com/livepeer/go-livepeer/common"
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/go-livepeer/drivers"
+
+	"github.com/livepeer/joy4/av"
+	"github.com/livepeer/joy4/format/ts"
+	"github.com/livepeer/lpms/ffmpeg"
+)
+
+var ErrResolutionMismatch = Retryable{errors.New("ResolutionMismatch")}
+var ErrFrameCountMismatch = Retryable{errors.New("FrameCountMismatch")}
+var ErrDurationMismatch = Retryable{errors.New("DurationMismatch")}
+var ErrMissingRendition = errors.New("MissingRendition")
+var ErrUnsupportedFormat = errors.New("UnsupportedFormat")
+
+// DefaultLocalVerifierTolerance is the relative difference allowed between
+// expected and actual durations and frame counts
+const DefaultLocalVerifierTolerance = 0.1
+
+// LocalVerifier checks renditions on the broadcaster without an external
+// classifier. Renditions are decoded with lpms for frame and pixel counts,
+// and their resolution, duration and audio are compared against the
+// requested profiles and the source segment
+type LocalVerifier struct {
+	// Relative difference allowed between expected and actual durations
+	// and frame counts. DefaultLocalVerifierTolerance is used if zero
+	Tolerance float64
+}
+
+// mediaProbe is what the verifier learns about a segment from its container
+type mediaProbe struct {
+	Width, Height int
+	VideoFrames   int
+	VideoDuration time.Duration
+	HasAudio      bool
+	AudioDuration time.Duration
+}
+
+func (v *LocalVerifier) tolerance() float64 {
+	if v.Tolerance <= 0 {
+		return DefaultLocalVerifierTolerance
+	}
+	return v.Tolerance
+}
+
+// SupportsFormat returns whether renditions in the format can be verified.
+// Only MPEG-TS segments can be demuxed for now
+func (v *LocalVerifier) SupportsFormat(format core.SegmentFormat) bool {
+	return format == core.FormatMPEGTS
+}
+
+func (v *LocalVerifier) Verify(params *Params) (*Results, error) {
+	if params.Source == nil {
+		return nil, ErrMissingSource
+	}
+	if !v.SupportsFormat(params.Format) {
+		return nil, ErrUnsupportedFormat
+	}
+	glog.V(common.DEBUG).Infof("Verifying segment locally manifestID=%s seqNo=%d", params.ManifestID, params.Source.SeqNo)
+
+	src, err := probeSegment(params.Source.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		score  float64
+		pixels []int64
+		verr   error
+	)
+	// Keep gathering pixel counts after the first error so the
+	// caller can compare them against the reported counts.
+	// Renditions that can't be fetched, probed or decoded are the
+	// orchestrator's fault, so transcoding them again might help
+	for i, p := range params.Profiles {
+		data, err := renditionData(params, i)
+		if err != nil {
+			return nil, err
+		}
+		rend, err := probeSegment(data)
+		if err != nil {
+			return nil, Retryable{err}
+		}
+		decoded, err := decodeSegment(data)
+		if err != nil {
+			return nil, err
+		}
+		pixels = append(pixels, decoded.Pixels)
+
+		rerr := checkRendition(src, rend, decoded, p, params.Source.Duration, v.tolerance())
+		if rerr == nil {
+			score += 1 / float64(len(params.Profiles))
+		} else if verr == nil {
+			glog.Infof("Rendition failed verification manifestID=%s seqNo=%d profile=%s err=%v", params.ManifestID, params.Source.SeqNo, p.Name, rerr)
+			verr = rerr
+		}
+	}
+	return &Results{Score: score, Pixels: pixels}, verr
+}
+
+// checkRendition compares a rendition against its profile and the source segment.
+// srcDur is the duration of the source segment in seconds, if known
+func checkRendition(src, rend *mediaProbe, decoded *ffmpeg.MediaInfo, profile ffmpeg.VideoProfile, srcDur float64, tolerance float64) error {
+	w, h, err := ffmpeg.VideoProfileResolution(profile)
+	if err != nil {
+		return err
+	}
+	if rend.Width != w || rend.Height != h {
+		return ErrResolutionMismatch
+	}
+	if decoded.Frames <= 0 || decoded.Pixels != int64(decoded.Frames)*int64(w)*int64(h) {
+		return ErrResolutionMismatch
+	}
+
+	duration := src.VideoDuration.Seconds()
+	if srcDur > 0 {
+		duration = srcDur
+	}
+	if !withinTolerance(rend.VideoDuration.Seconds(), duration, tolerance) {
+		return ErrDurationMismatch
+	}
+
+	expectedFrames := float64(src.VideoFrames)
+	if profile.Framerate > 0 {
+		expectedFrames = duration * float64(profile.Framerate)
+	}
+	if !withinTolerance(float64(decoded.Frames), expectedFrames, tolerance) {
+		return ErrFrameCountMismatch
+	}
+
+	if src.HasAudio != rend.HasAudio {
+		return ErrAudioMismatch
+	}
+	if src.HasAudio && !withinTolerance(rend.AudioDuration.Seconds(), src.AudioDuration.Seconds(), tolerance) {
+		return ErrAudioMismatch
+	}
+	return nil
+}
+
+func withinTolerance(actual, expected, tolerance float64) bool {
+	if expected == 0 {
+		return actual == 0
+	}
+	return math.Abs(actual-expected)/expected <= tolerance
+}
+
+// renditionData returns the cached rendition data if available or fetches it otherwise
+func renditionData(params *Params, i int) ([]byte, error) {
+	if i < len(params.Renditions) && len(params.Renditions[i]) > 0 {
+		return params.Renditions[i], nil
+	}
+	if i < len(params.URIs) && params.URIs[i] != "" {
+		data, err := drivers.GetSegmentData(params.URIs[i])
+		if err != nil {
+			return nil, Retryable{err}
+		}
+		return data, nil
+	}
+	return nil, ErrMissingRendition
+}
+
+// probeSegment reads the stream parameters and timing of an MPEG-TS segment
+func probeSegment(data []byte) (*mediaProbe, error) {
+	dmx := ts.NewDemuxer(bytes.NewReader(data))
+	streams, err := dmx.Streams()
+	if err != nil {
+		return nil, err
+	}
+
+	probe := &mediaProbe{}
+	video, audio := -1, -1
+	for i, s := range streams {
+		if s.Type().IsVideo() && video < 0 {
+			if vc, ok := s.(av.VideoCodecData); ok {
+				probe.Width, probe.Height = vc.Width(), vc.Height()
+				video = i
+			}
+		} else if s.Type().IsAudio() && audio < 0 {
+			probe.HasAudio = true
+			audio = i
+		}
+	}
+	if video < 0 {
+		return nil, ErrVideoUnavailable
+	}
+
+	var videoStart, videoEnd, audioStart, audioEnd time.Duration
+	audioSeen := false
+	for {
+		pkt, err := dmx.ReadPacket()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		switch int(pkt.Idx) {
+		case video:
+			if probe.VideoFrames == 0 {
+				videoStart = pkt.Time
+			}
+			probe.VideoFrames++
+			videoEnd = pkt.Time
+		case audio:
+			end := pkt.Time
+			if ac, ok := streams[audio].(av.AudioCodecData); ok {
+				if dur, err := ac.PacketDuration(pkt.Data); err == nil {
+					end += dur
+				}
+			}
+			if !audioSeen {
+				audioStart, audioSeen = pkt.Time, true
+			}
+			audioEnd = end
+		}
+	}
+
+	// The last frame lasts as long as the average frame
+	if probe.VideoFrames > 1 {
+		span := videoEnd - videoStart
+		probe.VideoDuration = span + span/time.Duration(probe.VideoFrames-1)
+	}
+	probe.AudioDuration = audioEnd - audioStart
+	return probe, nil
+}
+
+// decodeSegment decodes the rendition with lpms to count frames and pixels.
+// Decoding errors are retryable
+func decodeSegment(data []byte) (*ffmpeg.MediaInfo, error) {
+	f, err := ioutil.TempFile("", "verify*.ts")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(data)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := ffmpeg.Transcode3(&ffmpeg.TranscodeOptionsIn{Fname: f.Name()}, nil)
+	if err != nil {
+		return nil, Retryable{err}
+	}
+	return &res.Decoded, nil
+}
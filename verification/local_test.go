@@ -0,0 +1,183 @@
+package verification
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/livepeer/go-livepeer/core"
+	"github.com/livepeer/lpms/ffmpeg"
+	"github.com/livepeer/lpms/stream"
+)
+
+func TestProbeSegment(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data, err := ioutil.ReadFile("../core/test.ts")
+	require.Nil(err)
+	probe, err := probeSegment(data)
+	require.Nil(err)
+	assert.Equal(1280, probe.Width)
+	assert.Equal(720, probe.Height)
+	assert.True(probe.VideoFrames > 0)
+	assert.True(probe.HasAudio)
+	assert.InDelta(8.6, probe.VideoDuration.Seconds(), 0.1)
+	assert.InDelta(8.6, probe.AudioDuration.Seconds(), 0.1)
+
+	// not a transport stream
+	_, err = probeSegment([]byte("not a segment"))
+	assert.NotNil(err)
+}
+
+func TestCheckRendition(t *testing.T) {
+	assert := assert.New(t)
+
+	profile := ffmpeg.P144p30fps16x9
+	w, h, err := ffmpeg.VideoProfileResolution(profile)
+	require.Nil(t, err)
+	src := &mediaProbe{Width: 1280, Height: 720, VideoFrames: 120, VideoDuration: 2 * time.Second, HasAudio: true, AudioDuration: 2 * time.Second}
+	rend := func() *mediaProbe {
+		return &mediaProbe{Width: w, Height: h, VideoFrames: 60, VideoDuration: 2 * time.Second, HasAudio: true, AudioDuration: 2 * time.Second}
+	}
+	decoded := func(frames int) *ffmpeg.MediaInfo {
+		return &ffmpeg.MediaInfo{Frames: frames, Pixels: int64(frames * w * h)}
+	}
+	tol := DefaultLocalVerifierTolerance
+
+	assert.Nil(checkRendition(src, rend(), decoded(60), profile, 2.0, tol))
+	// falls back to the probed source duration
+	assert.Nil(checkRendition(src, rend(), decoded(60), profile, 0, tol))
+
+	// resolution
+	r := rend()
+	r.Height = h + 2
+	assert.Equal(ErrResolutionMismatch, checkRendition(src, r, decoded(60), profile, 2.0, tol))
+	assert.Equal(ErrResolutionMismatch, checkRendition(src, rend(), &ffmpeg.MediaInfo{Frames: 60, Pixels: 1}, profile, 2.0, tol))
+	assert.Equal(ErrResolutionMismatch, checkRendition(src, rend(), decoded(0), profile, 2.0, tol))
+
+	// duration
+	r = rend()
+	r.VideoDuration = time.Second
+	assert.Equal(ErrDurationMismatch, checkRendition(src, r, decoded(60), profile, 2.0, tol))
+
+	// frame count against the profile framerate
+	assert.Equal(ErrFrameCountMismatch, checkRendition(src, rend(), decoded(40), profile, 2.0, tol))
+	assert.Nil(checkRendition(src, rend(), decoded(57), profile, 2.0, tol))
+	// and against the source without a framerate
+	passthrough := profile
+	passthrough.Framerate = 0
+	assert.Equal(ErrFrameCountMismatch, checkRendition(src, rend(), decoded(60), passthrough, 2.0, tol))
+	assert.Nil(checkRendition(src, rend(), decoded(120), passthrough, 2.0, tol))
+
+	// audio
+	r = rend()
+	r.HasAudio = false
+	assert.Equal(ErrAudioMismatch, checkRendition(src, r, decoded(60), profile, 2.0, tol))
+	r = rend()
+	r.AudioDuration = time.Second
+	assert.Equal(ErrAudioMismatch, checkRendition(src, r, decoded(60), profile, 2.0, tol))
+	noAudio := *src
+	noAudio.HasAudio = false
+	assert.Equal(ErrAudioMismatch, checkRendition(&noAudio, rend(), decoded(60), profile, 2.0, tol))
+
+	// mismatches are retryable
+	for _, err := range []error{ErrResolutionMismatch, ErrDurationMismatch, ErrFrameCountMismatch, ErrAudioMismatch} {
+		assert.True(IsRetryable(err))
+	}
+}
+
+func TestLocalVerifier_Errors(t *testing.T) {
+	assert := assert.New(t)
+	v := &LocalVerifier{}
+	assert.Equal(DefaultLocalVerifierTolerance, v.tolerance())
+
+	_, err := v.Verify(&Params{})
+	assert.Equal(ErrMissingSource, err)
+
+	data, err := ioutil.ReadFile("../core/test.ts")
+	require.Nil(t, err)
+	_, err = v.Verify(&Params{
+		Source:   &stream.HLSSegment{Data: data},
+		Profiles: []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9},
+	})
+	assert.Equal(ErrMissingRendition, err)
+
+	// fragmented MP4 renditions can't be demuxed
+	assert.True(v.SupportsFormat(core.FormatMPEGTS))
+	assert.False(v.SupportsFormat(core.FormatFMP4))
+	_, err = v.Verify(&Params{
+		Source:     &stream.HLSSegment{Data: data},
+		Profiles:   []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9},
+		Renditions: [][]byte{data},
+		Format:     core.FormatFMP4,
+	})
+	assert.Equal(ErrUnsupportedFormat, err)
+	assert.False(IsRetryable(err))
+}
+
+func TestLocalVerifier_Verify(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+	v := &LocalVerifier{}
+
+	data, err := ioutil.ReadFile("../core/test.ts")
+	require.Nil(err)
+	source := &stream.HLSSegment{SeqNo: 1, Data: data}
+	// the source passes as a rendition at its own resolution and frame rate
+	profile := ffmpeg.P720p30fps16x9
+	profile.Framerate = 0
+	res, err := v.Verify(&Params{
+		Source:     source,
+		Profiles:   []ffmpeg.VideoProfile{profile},
+		Renditions: [][]byte{data},
+	})
+	require.Nil(err)
+	assert.Equal(1.0, res.Score)
+	require.Len(res.Pixels, 1)
+	assert.True(res.Pixels[0] > 0)
+	assert.Zero(res.Pixels[0] % (1280 * 720))
+
+	// but not at another resolution; pixels are still counted
+	res, err = v.Verify(&Params{
+		Source:     source,
+		Profiles:   []ffmpeg.VideoProfile{ffmpeg.P144p30fps16x9},
+		Renditions: [][]byte{data},
+	})
+	assert.Equal(ErrResolutionMismatch, err)
+	assert.Zero(res.Score)
+	assert.Len(res.Pixels, 1)
+
+	// bad renditions are retryable
+	_, err = v.Verify(&Params{
+		Source:     source,
+		Profiles:   []ffmpeg.VideoProfile{profile},
+		Renditions: [][]byte{[]byte("not a segment")},
+	})
+	assert.NotNil(err)
+	assert.True(IsRetryable(err))
+
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+	_, err = v.Verify(&Params{
+		Source:   source,
+		Profiles: []ffmpeg.VideoProfile{profile},
+		URIs:     []string{ts.URL + "/missing.ts"},
+	})
+	assert.NotNil(err)
+	assert.True(IsRetryable(err))
+
+	// a bad source isn't
+	_, err = v.Verify(&Params{
+		Source:     &stream.HLSSegment{Data: []byte("not a segment")},
+		Profiles:   []ffmpeg.VideoProfile{profile},
+		Renditions: [][]byte{data},
+	})
+	assert.NotNil(err)
+	assert.False(IsRetryable(err))
+}
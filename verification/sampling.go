@@ -0,0 +1,99 @@
+package verification
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// Number of consecutive passing verifications after which the sample rate
+// for an orchestrator is lowered
+const sampleCleanStreak = 10
+
+// The sample rate for an orchestrator with a clean streak never drops
+// below this fraction of the policy's sample rate
+const sampleMinFactor = 0.25
+
+type orchSample struct {
+	rate   float64
+	streak int
+}
+
+// sampler tracks the sample rate for each orchestrator. Orchestrators that
+// failed verification are verified on every segment until they build up
+// clean streaks again, and those with long clean streaks are verified less
+type sampler struct {
+	mu    sync.Mutex
+	orchs map[string]*orchSample
+	// Secret that segments are sampled with, so that orchestrators can't tell
+	// which segments will be verified. Generated on first use if not set
+	key []byte
+}
+
+// samplePoint maps the source data onto [0, 1) with a keyed hash so the same
+// segment is always sampled the same way by the holder of the key
+func samplePoint(key, data []byte) float64 {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	h := mac.Sum(nil)
+	return float64(binary.BigEndian.Uint64(h[:8])) / math.Exp2(64)
+}
+
+func (s *sampler) rate(orch string, base float64) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if o, ok := s.orchs[orch]; ok {
+		return o.rate
+	}
+	return base
+}
+
+func (s *sampler) sampleKey() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.key == nil {
+		s.key = make([]byte, sha256.Size)
+		if _, err := rand.Read(s.key); err != nil {
+			panic(err)
+		}
+	}
+	return s.key
+}
+
+// sample returns whether a segment transcoded by the orchestrator should be verified.
+// A base rate outside of (0, 1) verifies every segment
+func (s *sampler) sample(orch string, base float64, data []byte) bool {
+	if base <= 0 || base >= 1 {
+		return true
+	}
+	return samplePoint(s.sampleKey(), data) < s.rate(orch, base)
+}
+
+// record updates the sample rate for the orchestrator with the outcome of a verification
+func (s *sampler) record(orch string, base float64, passed bool) {
+	if base <= 0 || base >= 1 {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.orchs == nil {
+		s.orchs = make(map[string]*orchSample)
+	}
+	o, ok := s.orchs[orch]
+	if !ok {
+		o = &orchSample{rate: base}
+		s.orchs[orch] = o
+	}
+
+	if !passed {
+		o.rate, o.streak = 1, 0
+		return
+	}
+	o.streak++
+	if o.streak >= sampleCleanStreak {
+		o.rate, o.streak = math.Max(o.rate/2, base*sampleMinFactor), 0
+	}
+}
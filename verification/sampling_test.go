@@ -0,0 +1,142 @@
+package verification
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/livepeer/go-livepeer/net"
+	"github.com/livepeer/lpms/stream"
+)
+
+func sampledCount(s *sampler, orch string, base float64, n int) int {
+	count := 0
+	for i := 0; i < n; i++ {
+		if s.sample(orch, base, []byte(fmt.Sprintf("segment %d", i))) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSampler_Sample(t *testing.T) {
+	assert := assert.New(t)
+	s := &sampler{}
+
+	// every segment is verified without a rate
+	assert.Equal(100, sampledCount(s, "o", 0, 100))
+	assert.Equal(100, sampledCount(s, "o", 1, 100))
+
+	// roughly the rate is sampled
+	count := sampledCount(s, "o", 0.25, 1000)
+	assert.True(count > 200 && count < 300, "sampled %d", count)
+
+	// sampling is deterministic on the source data
+	data := []byte("source")
+	assert.Equal(samplePoint(s.key, data), samplePoint(s.key, data))
+	for i := 0; i < 10; i++ {
+		assert.Equal(s.sample("o", 0.5, data), s.sample("o", 0.5, data))
+	}
+}
+
+func TestSampler_Key(t *testing.T) {
+	assert := assert.New(t)
+
+	// a key is generated on first use and kept
+	s1, s2 := &sampler{}, &sampler{}
+	s1.sample("o", 0.5, nil)
+	s2.sample("o", 0.5, nil)
+	assert.Len(s1.key, 32)
+	assert.NotEqual(s1.key, s2.key)
+	key := s1.key
+	s1.sample("o", 0.5, nil)
+	assert.Equal(key, s1.key)
+
+	// samplers with different keys sample different segments, so the
+	// segments that will be verified can't be predicted without the key
+	differ := 0
+	for i := 0; i < 100; i++ {
+		data := []byte(fmt.Sprintf("segment %d", i))
+		if s1.sample("o", 0.5, data) != s2.sample("o", 0.5, data) {
+			differ++
+		}
+		assert.Equal(s1.sample("o", 0.5, data), (&sampler{key: key}).sample("o", 0.5, data))
+	}
+	assert.True(differ > 20 && differ < 80, "differ %d", differ)
+}
+
+func TestSampler_Record(t *testing.T) {
+	assert := assert.New(t)
+	s := &sampler{}
+	base := 0.4
+
+	// no adaptation without a rate
+	s.record("o", 0, false)
+	assert.Empty(s.orchs)
+
+	// failure verifies every segment
+	s.record("o", base, false)
+	assert.Equal(1.0, s.rate("o", base))
+	assert.Equal(base, s.rate("other", base))
+
+	// clean streaks halve the rate down to the minimum
+	for i := 0; i < sampleCleanStreak-1; i++ {
+		s.record("o", base, true)
+	}
+	assert.Equal(1.0, s.rate("o", base))
+	s.record("o", base, true)
+	assert.Equal(0.5, s.rate("o", base))
+	for i := 0; i < 5*sampleCleanStreak; i++ {
+		s.record("o", base, true)
+	}
+	assert.Equal(base*sampleMinFactor, s.rate("o", base))
+
+	// another failure resets the streak
+	s.record("o", base, false)
+	assert.Equal(1.0, s.rate("o", base))
+	assert.Equal(0, s.orchs["o"].streak)
+}
+
+func TestVerify_SampleRate(t *testing.T) {
+	assert := assert.New(t)
+	verifier := &countingVerifier{}
+	policy := &Policy{Verifier: verifier, SampleRate: 0.5}
+	orch := &net.OrchestratorInfo{Transcoder: "o"}
+
+	// skipped segments aren't verified
+	verified := 0
+	for i := 0; i < 100; i++ {
+		sv := NewSegmentVerifier(policy)
+		source := &stream.HLSSegment{Data: []byte(fmt.Sprintf("segment %d", i))}
+		res, err := sv.Verify(&Params{Source: source, Orchestrator: orch})
+		assert.Nil(err)
+		if res != nil {
+			verified++
+		}
+	}
+	assert.Equal(verified, verifier.calls)
+	assert.True(verified > 0 && verified < 100, "verified %d", verified)
+
+	// a failure verifies every following segment from the orchestrator
+	verifier.err = ErrPixelMismatch
+	for i := 0; verifier.calls == verified; i++ {
+		NewSegmentVerifier(policy).Verify(&Params{Source: &stream.HLSSegment{Data: []byte(fmt.Sprintf("failing %d", i))}, Orchestrator: orch})
+	}
+	verifier.err = nil
+	verifier.calls = 0
+	for i := 0; i < 5; i++ {
+		NewSegmentVerifier(policy).Verify(&Params{Source: &stream.HLSSegment{Data: []byte(fmt.Sprintf("after %d", i))}, Orchestrator: orch})
+	}
+	assert.Equal(5, verifier.calls)
+}
+
+type countingVerifier struct {
+	calls int
+	err   error
+}
+
+func (v *countingVerifier) Verify(params *Params) (*Results, error) {
+	v.calls++
+	return &Results{}, v.err
+}
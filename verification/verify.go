@@ -42,6 +42,9 @@ type Params struct {
 
 	// Cached data when local object storage is used
 	Renditions [][]byte
+
+	// Container of the renditions
+	Format core.SegmentFormat
 }
 
 type Results struct {
@@ -64,11 +67,21 @@ type Policy struct {
 	// Maximum number of retries until the policy chooses a winner
 	Retries int
 
-	// How often to invoke the verifier, on a per-segment basis
-	SampleRate float64 // XXX for later
+	// Fraction of segments to invoke the verifier on. Segments are sampled
+	// based on a keyed hash of their source data with a secret generated by
+	// the node, and the rate adapts to each orchestrator's verification
+	// history. Zero verifies every segment
+	SampleRate float64
+
+	// How many orchestrators to submit each segment to in parallel.
+	// The first successful result is used
+	Redundancy int
 
-	// How many parallel transcodes to support
-	Redundancy int // XXX for later
+	// Whether to compare the results of redundant transcodes and penalize
+	// orchestrators that disagree with the majority, or all of them if there is none
+	CrossCheck bool
+
+	samples sampler
 }
 
 type SegmentVerifierResults struct {
@@ -103,8 +116,14 @@ func (sv *SegmentVerifier) Verify(params *Params) (*Params, error) {
 	if sv.policy.Verifier == nil {
 		return nil, nil
 	}
-	// TODO Use policy sampling rate to determine whether to invoke verifier.
-	//      If not, exit early. Seed sample using source data for repeatability!
+	orch := params.Orchestrator.GetTranscoder()
+	var data []byte
+	if params.Source != nil {
+		data = params.Source.Data
+	}
+	if !sv.policy.samples.sample(orch, sv.policy.SampleRate, data) {
+		return nil, nil
+	}
 	res, err := sv.policy.Verifier.Verify(params)
 
 	// Check pixel counts
@@ -123,6 +142,9 @@ func (sv *SegmentVerifier) Verify(params *Params) (*Params, error) {
 		}
 	}
 
+	if err == nil || IsRetryable(err) {
+		sv.policy.samples.record(orch, sv.policy.SampleRate, err == nil)
+	}
 	if err == nil {
 		// Verification passed successfully, so use this set of params
 		return params, nil